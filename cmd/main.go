@@ -6,6 +6,7 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"aiAgent/internal/agent"
 	"aiAgent/internal/browser"
@@ -15,6 +16,9 @@ import (
 	"aiAgent/internal/llm"
 	"aiAgent/internal/logger"
 	"aiAgent/internal/migrations"
+	"aiAgent/internal/scheduler"
+
+	"go.uber.org/zap"
 )
 
 func main() {
@@ -48,33 +52,72 @@ func run() error {
 
 	repo := database.NewTaskRepository(db.DB)
 
+	// Задачи, оставшиеся в статусе running после падения или Ctrl+C, помечаем как прерванные
+	if n, err := repo.MarkRunningTasksInterrupted(); err != nil {
+		log.Warn("Ошибка пометки прерванных задач", zap.Error(err))
+	} else if n > 0 {
+		log.Info("Найдены прерванные задачи, используйте resume <id>", zap.Int64("count", n))
+	}
+
 	var llmClient llm.LLMClient
 	if cfg.OpenAI.KeyAI != "" {
 		llmClient = llm.NewClient(cfg.OpenAI.KeyAI, cfg.OpenAI.Model, repo)
 	}
 
-	br := browser.New(browser.Config{
+	// Эмбеддер памяти: nil - локальный, векторы OpenAI требуют ключа API
+	var embedder agent.Embedder
+	if client, ok := llmClient.(*llm.Client); ok && cfg.Agent.MemoryEmbedder == "openai" {
+		embedder = client
+	}
+
+	// Один процесс Playwright на всё приложение, каждая задача получает свою сессию браузера
+	engine := browser.NewEngine(browser.Config{
 		Headless:     cfg.Browser.Headless,
 		UserDataDir:  cfg.Browser.UserDataDir,
 		BrowsersPath: cfg.Browser.BrowsersPath,
 		Display:      cfg.Browser.Display,
 	})
+	br := engine.NewSession()
 
 	// Создаём user input provider для агента
 	userInput := cli.NewUserInputProvider()
 
+	// Политики повторов: встроенные, поверх них - правила из AGENT_RETRY_*
+	retryPolicies, err := agent.ParseRetryPolicies(agent.DefaultRetryPolicies(3, 2*time.Second), cfg.Agent.RetryPolicies)
+	if err != nil {
+		return fmt.Errorf("ошибка настройки повторов: %w", err)
+	}
+
 	// Создаём агента с дефолтными настройками
 	ag := agent.New(br, llmClient, repo, log, agent.Config{
 		MaxSteps:          50,
 		MaxTokens:         cfg.OpenAI.MaxTokens,
 		Retries:           3,
+		RetryPolicies:     retryPolicies,
 		UserInputProvider: userInput,
 		UseSubAgents:      true,
+		AgentsDir:         cfg.Agent.SpecsDir,
+		LLMRouting:        cfg.Agent.LLMRouting,
 		UseMultiStep:      false, // ОТКЛЮЧЕНО: теперь используется новый Reasoning Layer (ReAct pattern)
 		MultiStepSize:     5,
+		UseSubgoals:       cfg.Agent.Subgoals,
 		UseMemory:         true, // Включаем Memory для reasoning patterns
+		MemoryTTL:         time.Duration(cfg.Agent.MemoryTTLDays) * 24 * time.Hour,
+		Embedder:          embedder,
+		Budget: agent.BudgetLimits{
+			DailyTokens:   cfg.Agent.DailyTokenBudget,
+			DailyCost:     cfg.Agent.DailyCostBudget,
+			MonthlyTokens: cfg.Agent.MonthlyTokenBudget,
+			MonthlyCost:   cfg.Agent.MonthlyCostBudget,
+		},
+		NewBrowser: func() browser.Browser {
+			return engine.NewSession()
+		},
 	})
 
+	// Пул воркеров для фоновых задач
+	jobs := agent.NewJobManager(cfg.Agent.Workers)
+
 	// Создаём context с поддержкой cancellation
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -89,7 +132,10 @@ func run() error {
 		cancel()
 	}()
 
-	console := cli.New(repo, log, llmClient, br, ag)
+	// Планировщик запускает задачи по расписаниям через тот же пул воркеров
+	go scheduler.New(repo, ag, jobs, log).Run(ctx)
+
+	console := cli.New(repo, log, llmClient, br, ag, userInput, jobs)
 	console.Run(ctx)
 
 	log.Info("Приложение корректно завершено")
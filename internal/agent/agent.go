@@ -2,6 +2,7 @@ package agent
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -31,7 +32,10 @@ func getSanitizer(llmClient llm.LLMClient) *sanitizer.DataSanitizer {
 //   - MaxTokens: 2000
 //   - Retries: 3
 //   - RetryDelay: 2 секунды
+//   - RetryPolicies: DefaultRetryPolicies
 //   - ConfidenceMin: 0.7
+//   - TranscriptWindow: 8
+//   - MemoryTTL: 30 дней
 //
 // При использовании подагентов (UseSubAgents=true) инициализируются специализированные агенты
 // для навигации, работы с формами, извлечения данных и взаимодействия с элементами.
@@ -51,6 +55,9 @@ func New(br browser.Browser, llmClient llm.LLMClient, repo *database.TaskReposit
 	if cfg.ConfidenceMin == 0 {
 		cfg.ConfidenceMin = 0.7
 	}
+	if cfg.TranscriptWindow == 0 {
+		cfg.TranscriptWindow = llm.DefaultTranscriptWindow
+	}
 
 	agent := &Agent{
 		browser:           br,
@@ -59,42 +66,80 @@ func New(br browser.Browser, llmClient llm.LLMClient, repo *database.TaskReposit
 		log:               log,
 		maxSteps:          cfg.MaxSteps,
 		maxTokens:         cfg.MaxTokens,
-		retries:           cfg.Retries,
-		retryDelay:        cfg.RetryDelay,
+		retryPolicies:     DefaultRetryPolicies(cfg.Retries, cfg.RetryDelay),
 		userInputProvider: cfg.UserInputProvider,
 		securityChecker:   NewSecurityChecker(llmClient),
 		sanitizer:         getSanitizer(llmClient),
 		cfg:               cfg,
 	}
 
-	if cfg.UseSubAgents {
-		router := NewAgentRouter(llmClient, cfg.ConfidenceMin)
-
-		// Регистрируем специализированных агентов для трех задач
-		emailAgent := NewEmailSpamAgent(agent)
-		foodAgent := NewFoodDeliveryAgent(agent)
-		jobAgent := NewJobSearchAgent(agent)
-
-		router.RegisterAgent(emailAgent)
-		router.RegisterAgent(foodAgent)
-		router.RegisterAgent(jobAgent)
+	for scope, policy := range cfg.RetryPolicies {
+		agent.retryPolicies[scope] = policy
+	}
 
-		// Устанавливаем EmailSpamAgent как дефолтный (можно изменить на любой другой)
-		router.SetDefaultAgent(emailAgent)
+	if cfg.AgentsDir != "" {
+		agent.specs = agent.loadAgentSpecs(cfg.AgentsDir)
+	}
 
-		agent.router = router
+	if cfg.UseSubAgents || len(agent.specs) > 0 {
+		agent.router = agent.newRouter()
 	}
 
 	if cfg.UseMemory {
-		memory := NewAgentMemory(repo)
+		memory := NewAgentMemory(repo, cfg.MemoryTTL, cfg.Embedder)
+		if err := memory.LoadFromDatabase(context.Background()); err != nil {
+			log.Warn("Ошибка загрузки памяти агента", zap.Error(err))
+		} else {
+			paths, failures, sites := memory.Counts()
+			log.Info("Память агента загружена", zap.Int("paths", paths), zap.Int("failures", failures), zap.Int("sites", sites))
+		}
 		agent.memory = memory
 	}
 
 	agent.circuitBreakers = NewCircuitBreakerPool()
+	agent.targetBreakers = NewCircuitBreakerPool()
 
 	return agent
 }
 
+// newRouter создает роутер со специализированными агентами, выполняющими задачи через a.
+func (a *Agent) newRouter() *AgentRouter {
+	router := NewAgentRouter(a.llmClient, a.cfg.ConfidenceMin)
+
+	// Регистрируем специализированных агентов для трех задач
+	emailAgent := NewEmailSpamAgent(a)
+	foodAgent := NewFoodDeliveryAgent(a)
+	jobAgent := NewJobSearchAgent(a)
+
+	router.RegisterAgent(emailAgent)
+	router.RegisterAgent(foodAgent)
+	router.RegisterAgent(jobAgent)
+
+	router.SetLLMRouting(a.cfg.LLMRouting)
+
+	// Декларативные агенты из AgentsDir; агент с именем встроенного заменяет его
+	for _, spec := range a.specs {
+		router.RegisterAgent(NewDeclarativeAgent(a, spec))
+	}
+
+	// Устанавливаем EmailSpamAgent как дефолтный (можно изменить на любой другой)
+	router.SetDefaultAgent(emailAgent)
+
+	return router
+}
+
+// loadAgentSpecs загружает описания декларативных агентов. Файлы с ошибками пропускаются с предупреждением.
+func (a *Agent) loadAgentSpecs(dir string) []*AgentSpec {
+	specs, err := LoadAgentSpecs(dir)
+	if err != nil {
+		a.log.Warn("Ошибка загрузки описаний агентов", zap.String("dir", dir), zap.Error(err))
+	}
+	for _, spec := range specs {
+		a.log.Info("Загружен декларативный агент", zap.String("agent", spec.Name), zap.String("file", spec.File))
+	}
+	return specs
+}
+
 // contextFields создаёт набор контекстных полей для логирования
 func (a *Agent) contextFields(taskID *uint, stepNo int, fields ...zap.Field) []zap.Field {
 	result := make([]zap.Field, 0, len(fields)+2)
@@ -108,59 +153,110 @@ func (a *Agent) contextFields(taskID *uint, stepNo int, fields ...zap.Field) []z
 	return result
 }
 
+// getPageContext возвращает контекст текущей страницы. К нему добавляются знания о сайте
+// из прошлых визитов: селекторы по назначению, страница входа, формы, закрытие попапов.
 func (a *Agent) getPageContext(ctx context.Context) (string, error) {
 	snapshot, err := a.browser.GetPageSnapshot(ctx)
 	if err == nil && snapshot != nil {
-		return a.limitContextFromSnapshot(snapshot), nil
+		a.snapshot = snapshot
+		return a.limitContextFromSnapshot(snapshot) + a.siteNotes(ctx, snapshot.URL), nil
 	}
+	a.snapshot = nil
 
 	htmlContext, err := a.browser.GetPageContext(ctx)
 	if err != nil {
 		return "", err
 	}
-	return a.limitContext(htmlContext), nil
+	url, _, _ := a.browser.GetPageInfo(ctx)
+	return a.limitContext(htmlContext) + a.siteNotes(ctx, url), nil
 }
 
 func (a *Agent) performReasoning(ctx context.Context, userInput, pageContext string, taskID *uint, stepNo int) (*llm.ReasoningStep, error) {
-	// Проверяем есть ли memory и релевантные patterns
-	if a.memory != nil && a.cfg.UseMemory {
-		// Пытаемся найти релевантный опыт из памяти
-		// TODO: В будущем добавить поиск relevant reasoning patterns из memory
-		// Пока используем обычный Reason
-	}
+	// Релевантный опыт из памяти: похожие успешные пути, повторяющиеся ошибки и знания о текущем сайте
+	memoryContext := a.recallMemory(ctx, userInput, taskID, stepNo)
 
 	// Выполняем reasoning с retry logic
 	var reasoning *llm.ReasoningStep
-	err := retryAction(ctx, a.retries, a.retryDelay, func() error {
-		r, e := a.llmClient.Reason(ctx, userInput, pageContext, a.reasoningHistory, taskID, nil)
-		if e != nil {
-			return e
-		}
-		reasoning = r
-		return nil
+	err := a.retry(ctx, RetryScopeLLM, func() error {
+		return a.callLLM(ctx, func() error {
+			var r *llm.ReasoningStep
+			var e error
+			if memoryContext != "" {
+				r, e = a.llmClient.ReasonWithContext(ctx, userInput, pageContext, a.reasoningHistory, a.transcript, memoryContext, taskID, nil)
+			} else {
+				r, e = a.llmClient.Reason(ctx, userInput, pageContext, a.reasoningHistory, a.transcript, taskID, nil)
+			}
+			if e != nil {
+				return e
+			}
+			reasoning = r
+			return nil
+		})
 	})
 
 	return reasoning, err
 }
 
-// performBasicReflection выполняет базовую рефлексию после действия.
-// Это упрощенная версия - полная рефлексия с LLM анализом будет добавлена в Фазе 4.
-func (a *Agent) performBasicReflection(stepNo int, plan *llm.StepPlan, result string, execErr error) {
-	// Базовая рефлексия
-	if execErr != nil {
-		// TODO (Фаза 4): Добавить LLM-based анализ ошибки
-		// TODO (Фаза 4): Обновить reasoning history с результатом
-		// TODO (Фаза 4): Сохранить в Memory как failed pattern
+// recallMemory возвращает memoryContext для рассуждения: успешные пути и ошибки, близкие к задаче
+// и текущему домену. Пустая строка - памяти нет или релевантных записей не найдено.
+func (a *Agent) recallMemory(ctx context.Context, userInput string, taskID *uint, stepNo int) string {
+	if a.memory == nil || !a.cfg.UseMemory {
+		return ""
+	}
+
+	url, _, _ := a.browser.GetPageInfo(ctx)
+	recall, err := a.memory.Recall(ctx, userInput, extractDomain(url), DefaultRecallLimit)
+	if err != nil {
+		a.log.Warn("Ошибка поиска по памяти, рассуждаем без опыта", a.contextFields(taskID, stepNo, zap.Error(err))...)
+		return ""
+	}
+	// Знания о текущем сайте уже добавлены в контекст страницы (см. siteNotes)
+	recall.Site = nil
+	if recall.Empty() {
+		return ""
+	}
+
+	a.log.Debug("Найден релевантный опыт в памяти", a.contextFields(taskID, stepNo,
+		zap.Int("paths", len(recall.Paths)),
+		zap.Int("failures", len(recall.Failures)))...)
+	return recall.Format()
+}
+
+// nextPlan выбирает действие очередного шага. Если пользователь запросил управление браузером,
+// шагом становится takeover без обращения к LLM.
+func (a *Agent) nextPlan(params executeStepsParams, pageContext string, stepNo int) (*llm.StepPlan, error) {
+	if takeoverRequested(params.ctx) {
+		return userTakeoverPlan(), nil
+	}
+
+	// ========================================
+	// ФАЗА 1: REASONING (новое!)
+	// Явное рассуждение перед планированием действия
+	// ========================================
+	reasoning, err := a.performReasoning(params.ctx, params.userInput, pageContext, params.taskID, stepNo)
+	if err != nil {
+		a.log.Warn("Ошибка reasoning, продолжаем с планированием", a.contextFields(params.taskID, stepNo, zap.Error(err))...)
+		// Не критично - можем продолжить без explicit reasoning
 	} else {
-		// TODO (Фаза 4): Добавить LLM-based оценку успешности
-		// TODO (Фаза 4): Проверить достигнута ли цель шага
-		// TODO (Фаза 4): Сохранить в Memory как successful pattern
+		// Добавляем reasoning в историю
+		a.reasoningHistory.AddStep(*reasoning)
+
+		// Если reasoning говорит что нужен user input - обработаем это
+		if reasoning.RequiresUserInput && a.userInputProvider != nil {
+			// В будущем можно добавить автоматический ask_user action здесь
+		}
 	}
+
+	// ========================================
+	// ФАЗА 2: PLANNING
+	// Планирование действия (теперь с учетом reasoning)
+	// ========================================
+	return a.getPlanForStep(params.ctx, params.userInput, pageContext, params.taskID)
 }
 
 func (a *Agent) getPlanForStep(ctx context.Context, userInput, pageContext string, taskID *uint) (*llm.StepPlan, error) {
 	var plan *llm.StepPlan
-	err := retryAction(ctx, a.retries, a.retryDelay, func() error {
+	err := a.retry(ctx, RetryScopeLLM, func() error {
 		// Получаем последний reasoning step для передачи в планирование
 		var latestReasoning *llm.ReasoningStep
 		if a.reasoningHistory != nil {
@@ -168,17 +264,24 @@ func (a *Agent) getPlanForStep(ctx context.Context, userInput, pageContext strin
 		}
 
 		// Используем новый метод PlanActionWithReasoning для ReAct pattern
-		p, e := a.llmClient.PlanActionWithReasoning(ctx, userInput, pageContext, latestReasoning, taskID, nil)
-		if e != nil {
-			return e
-		}
-		plan = p
-		return nil
+		return a.callLLM(ctx, func() error {
+			p, e := a.llmClient.PlanActionWithReasoning(ctx, userInput, pageContext, latestReasoning, a.transcript, taskID, nil)
+			if e != nil {
+				return e
+			}
+			plan = p
+			return nil
+		})
 	})
 	return plan, err
 }
 
 func (a *Agent) checkSecurityAndConfirm(ctx context.Context, plan *llm.StepPlan, stepNo int) (bool, error) {
+	// При передаче управления действия выполняет сам пользователь
+	if plan.Action == "takeover" {
+		return true, nil
+	}
+
 	isDangerous, llmMessage, err := a.securityChecker.IsDangerousAction(ctx, plan.Action, plan.Selector, plan.Value, plan.Reasoning)
 	if err != nil {
 		a.log.Warn("Ошибка проверки безопасности, продолжаем выполнение", a.contextFields(nil, stepNo, zap.Error(err))...)
@@ -189,6 +292,11 @@ func (a *Agent) checkSecurityAndConfirm(ctx context.Context, plan *llm.StepPlan,
 		return true, nil
 	}
 
+	if a.dryRun {
+		a.dryRunSecurityNote(ctx, plan, stepNo, llmMessage)
+		return true, nil
+	}
+
 	if a.userInputProvider == nil {
 		a.log.Warn("Опасное действие обнаружено, но провайдер пользовательского ввода не настроен", a.contextFields(nil, stepNo, zap.String("action", plan.Action))...)
 		return true, nil
@@ -203,7 +311,7 @@ func (a *Agent) checkSecurityAndConfirm(ctx context.Context, plan *llm.StepPlan,
 	answerLower := strings.ToLower(strings.TrimSpace(answer))
 	if answerLower != "yes" && answerLower != "y" && answerLower != "да" && answerLower != "д" {
 		a.log.Info("Пользователь отменил опасное действие", a.contextFields(nil, stepNo, zap.String("action", plan.Action))...)
-		fmt.Printf("[Шаг %d] Действие отменено пользователем\n", stepNo)
+		fmt.Fprintf(outputFrom(ctx), "[Шаг %d] Действие отменено пользователем\n", stepNo)
 		return false, nil
 	}
 
@@ -219,9 +327,15 @@ func (a *Agent) createStepRecord(task *database.Task, stepNo int, plan *llm.Step
 		TargetSelector: a.sanitizer.SanitizeSelector(plan.Selector),
 		Reasoning:      a.sanitizer.Sanitize(plan.Reasoning),
 		Result:         a.sanitizer.Sanitize(result),
+		DryRun:         a.dryRun,
+		Attempts:       actionAttempts(a.attempts, plan.Action),
+		AttemptLog:     attemptLog(a.attempts),
 	}
 }
 
+// ErrStepLimit возвращается, когда задача (или подзадача) не завершена за отведенное число шагов.
+var ErrStepLimit = errors.New("достигнут лимит шагов")
+
 type executeStepsParams struct {
 	ctx        context.Context
 	userInput  string
@@ -229,6 +343,9 @@ type executeStepsParams struct {
 	taskID     *uint
 	saveSteps  bool
 	updateTask bool
+	checkpoint *database.TaskCheckpoint // Checkpoint для возобновления прерванной задачи (может быть nil)
+	firstStep  int                      // Номер первого шага (0 - с 1 или со следующего после checkpoint)
+	subgoal    *database.TaskSubgoal    // Выполняемая подзадача (nil - задача целиком)
 }
 
 func (a *Agent) executeSteps(params executeStepsParams) error {
@@ -241,10 +358,52 @@ func (a *Agent) executeSteps(params executeStepsParams) error {
 		task = t
 	}
 
-	// Инициализация reasoning history для этой задачи (ReAct pattern)
+	// Инициализация reasoning history и транскрипта действий для этой задачи (ReAct pattern)
 	a.reasoningHistory = &llm.ReasoningHistory{}
+	a.transcript = llm.NewActionTranscript(a.cfg.TranscriptWindow)
+	a.loopDetector = NewLoopDetector()
+
+	firstStep := 1
+	if params.checkpoint != nil {
+		if err := a.restoreFromCheckpoint(params.checkpoint); err != nil {
+			return err
+		}
+		firstStep = params.checkpoint.StepNo + 1
+	}
+	if params.firstStep > 0 {
+		firstStep = params.firstStep
+	}
+
+	// Checkpoint сохраняется только для задач из БД и не при пробном запуске
+	checkpointing := params.taskID != nil && params.saveSteps && !a.dryRun
+
+	startTime := time.Now()
+	var successfulSteps []llm.StepPlan
+	var nextPageContext string
+	var lastFailed *failedAction // Ошибка предыдущего шага: обход, найденный моделью, запоминается
+
+	// Подзадача проверяется по своему критерию; результат и критерии задачи ведет runSubgoals
+	var criteria []string
+	if params.subgoal != nil {
+		criteria = subgoalCriteria(params.subgoal)
+	} else {
+		a.initResults(params.taskID, params.userInput, task.OutputSchema, task.Result, params.checkpoint != nil)
+		// Критерии успеха: заданы пользователем или формулируются LLM по начальной странице
+		criteria = ParseCriteria(task.SuccessCriteria)
+	}
+	if len(criteria) == 0 {
+		// Начальный контекст страницы переиспользуется первым шагом
+		nextPageContext, _ = a.getPageContext(params.ctx)
+		criteria = a.deriveCriteria(params.ctx, params.userInput, nextPageContext, params.taskID)
+	}
+
+	for stepNo := firstStep; stepNo <= params.maxSteps; stepNo++ {
+		if checkpointing && stepNo > firstStep {
+			a.saveCheckpoint(params.ctx, *params.taskID, stepNo-1)
+			a.saveSubgoalProgress(params.subgoal)
+		}
+		a.attempts = nil
 
-	for stepNo := 1; stepNo <= params.maxSteps; stepNo++ {
 		// Проверка отмены контекста
 		select {
 		case <-params.ctx.Done():
@@ -253,58 +412,107 @@ func (a *Agent) executeSteps(params executeStepsParams) error {
 		default:
 		}
 
-		pageContext, err := a.getPageContext(params.ctx)
-		if err != nil {
-			a.log.Warn("Ошибка получения контекста страницы", a.contextFields(params.taskID, stepNo, zap.Error(err))...)
-			pageContext = ""
+		// Проверка бюджета токенов/стоимости перед очередным шагом
+		if err := a.stopIfBudgetExceeded(params.taskID, stepNo, params.updateTask); err != nil {
+			return err
+		}
+		if params.subgoal != nil {
+			params.subgoal.StepsUsed++
 		}
 
-		// ========================================
-		// ФАЗА 1: REASONING (новое!)
-		// Явное рассуждение перед планированием действия
-		// ========================================
-		reasoning, err := a.performReasoning(params.ctx, params.userInput, pageContext, params.taskID, stepNo)
-		if err != nil {
-			a.log.Warn("Ошибка reasoning, продолжаем с планированием", a.contextFields(params.taskID, stepNo, zap.Error(err))...)
-			// Не критично - можем продолжить без explicit reasoning
-		} else {
-			// Добавляем reasoning в историю
-			a.reasoningHistory.AddStep(*reasoning)
-
-			// Если reasoning говорит что нужен user input - обработаем это
-			if reasoning.RequiresUserInput && a.userInputProvider != nil {
-				// В будущем можно добавить автоматический ask_user action здесь
+		// Контекст страницы после предыдущего шага уже получен на фазе рефлексии
+		pageContext := nextPageContext
+		nextPageContext = ""
+		if pageContext == "" {
+			var err error
+			pageContext, err = a.getPageContext(params.ctx)
+			if err != nil {
+				a.log.Warn("Ошибка получения контекста страницы", a.contextFields(params.taskID, stepNo, zap.Error(err))...)
+				pageContext = ""
 			}
 		}
 
-		// ========================================
-		// ФАЗА 2: PLANNING
-		// Планирование действия (теперь с учетом reasoning)
-		// ========================================
-		plan, err := a.getPlanForStep(params.ctx, params.userInput, pageContext, params.taskID)
+		plan, err := a.nextPlan(params, pageContext, stepNo)
 		if err != nil {
 			a.log.Error("Ошибка планирования действия", a.contextFields(params.taskID, stepNo, zap.Error(err))...)
 			if isCriticalError(err) {
 				return fmt.Errorf("критичная ошибка планирования: %w", err)
 			}
+			// LLM недоступен - шаги не тратятся впустую, пока предохранитель не разрешит пробный запрос
+			var openErr *CircuitOpenError
+			if errors.As(err, &openErr) {
+				select {
+				case <-params.ctx.Done():
+					return params.ctx.Err()
+				case <-time.After(openErr.RetryAfter):
+				}
+			}
 			continue
 		}
 
 		if plan.Action == "complete" {
+			// Перед тем как принять завершение, проверяем критерии успеха по живой странице и собранным данным
+			// При пробном запуске страница не менялась - проверять критерии бессмысленно
+			var verdict *llm.CompletionVerdict
+			var verifyErr error
+			if !a.dryRun {
+				verdict, verifyErr = a.verifyCompletion(params.ctx, params.userInput, criteria, pageContext, params.taskID, stepNo)
+			}
+			if verifyErr != nil {
+				return fmt.Errorf("ошибка проверки критериев успеха: %w", verifyErr)
+			}
+			if verdict != nil && !verdict.Passed {
+				if err := a.rejectCompletion(params, task, stepNo, plan, pageContext, verdict); err != nil {
+					return err
+				}
+				continue
+			}
+
+			summary := a.completionSummary(plan.Reasoning, verdict)
 			if params.saveSteps {
-				step := a.createStepRecord(task, stepNo, plan, plan.Reasoning)
+				step := a.createStepRecord(task, stepNo, plan, summary)
 				if err := a.repo.CreateStep(step); err != nil {
 					a.log.Error("Ошибка сохранения шага", a.contextFields(params.taskID, stepNo, zap.Error(err))...)
 				}
 			}
+			if params.subgoal != nil {
+				params.subgoal.Result = summary
+				return nil
+			}
 			if params.updateTask && params.taskID != nil {
-				if err := a.repo.UpdateTaskStatus(*params.taskID, "completed", a.sanitizer.Sanitize(plan.Reasoning)); err != nil {
+				if err := a.repo.UpdateTaskStatus(*params.taskID, "completed", summary); err != nil {
 					a.log.Error("Ошибка обновления статуса", a.contextFields(params.taskID, stepNo, zap.Error(err))...)
 				}
 			}
+			if checkpointing {
+				if err := a.repo.DeleteCheckpoint(*params.taskID); err != nil {
+					a.log.Warn("Ошибка удаления checkpoint", a.contextFields(params.taskID, stepNo, zap.Error(err))...)
+				}
+			}
+			a.recordSuccessfulPath(params.ctx, params.userInput, successfulSteps, time.Since(startTime))
 			return nil
 		}
 
+		// Ограничения декларативного агента: запрещенное действие не выполняется, модель видит отказ в транскрипте
+		if a.spec != nil {
+			if err := a.spec.checkAction(plan); err != nil {
+				a.log.Warn("Действие запрещено профилем агента", a.contextFields(params.taskID, stepNo, zap.String("action", plan.Action), zap.Error(err))...)
+				if params.saveSteps {
+					step := a.createStepRecord(task, stepNo, plan, err.Error())
+					if err := a.repo.CreateStep(step); err != nil {
+						a.log.Error("Ошибка сохранения шага", a.contextFields(params.taskID, stepNo, zap.Error(err))...)
+					}
+				}
+				a.transcript.Add(llm.TranscriptEntry{
+					StepNo:    stepNo,
+					Plan:      *plan,
+					Result:    err.Error(),
+					ErrorType: "blocked_by_agent",
+				})
+				continue
+			}
+		}
+
 		approved, err := a.checkSecurityAndConfirm(params.ctx, plan, stepNo)
 		if err != nil {
 			if params.saveSteps {
@@ -322,20 +530,76 @@ func (a *Agent) executeSteps(params executeStepsParams) error {
 					a.log.Error("Ошибка сохранения шага", a.contextFields(params.taskID, stepNo, zap.Error(err))...)
 				}
 			}
+			a.transcript.Add(llm.TranscriptEntry{
+				StepNo:    stepNo,
+				Plan:      *plan,
+				Result:    "Действие отменено пользователем",
+				ErrorType: "rejected_by_user",
+			})
 			continue
 		}
 
-		result, err := a.executeActionWithRetry(params.ctx, plan)
+		urlBefore, titleBefore, _ := a.browser.GetPageInfo(params.ctx)
+		element := a.snapshotElement(plan.Selector)
+
+		result, err := a.executeActionWithRecovery(params.ctx, plan, element)
+
+		urlAfter, titleAfter, _ := a.browser.GetPageInfo(params.ctx)
+		entry := llm.TranscriptEntry{
+			StepNo:      stepNo,
+			Plan:        *plan,
+			Result:      result,
+			URLBefore:   urlBefore,
+			URLAfter:    urlAfter,
+			TitleBefore: titleBefore,
+			TitleAfter:  titleAfter,
+		}
+
+		// ========================================
+		// ФАЗА 3: REFLECTION
+		// Сравниваем страницу до и после действия и проверяем, достигнута ли цель шага
+		// ========================================
+		var pageAfter string
+		if err == nil {
+			pageAfter, _ = a.getPageContext(params.ctx)
+			nextPageContext = pageAfter
+		}
+		reflection := a.performReflection(params.ctx, params.userInput, stepNo, plan, pageContext, pageAfter, result, err, params.taskID)
+		a.reasoningHistory.AttachReflection(reflection)
+		a.recordReflection(params.ctx, params.userInput, plan, reflection)
+
+		// Проверка зацикливания: повтор действия, осцилляция между страницами, страница не меняется.
+		// При пробном запуске страница не меняется никогда, поэтому застой не проверяется
+		var fingerprintBefore string
+		if !a.dryRun {
+			fingerprintBefore = PageFingerprint(urlBefore, titleBefore, pageContext)
+		}
+		loop := a.loopDetector.Observe(plan, urlAfter, fingerprintBefore, PageFingerprint(urlAfter, titleAfter, pageAfter))
+		var loopErr error
+		if loop != nil {
+			entry.Observation, loopErr = a.handleLoop(params.ctx, params.taskID, stepNo, loop)
+		}
+
 		if err != nil {
+			lastFailed = &failedAction{plan: *plan, err: err}
 			actionErr := classifyError(plan.Action, err)
+			entry.Result = err.Error()
+			entry.ErrorType = string(actionErr.Kind)
+			if observation := circuitObservation(err); observation != "" {
+				entry.Observation = strings.TrimSpace(observation + " " + entry.Observation)
+			}
+			a.transcript.Add(entry)
 			errorMsg := fmt.Sprintf("Ошибка: %v", err)
 			a.log.Error("Ошибка выполнения действия", a.contextFields(params.taskID, stepNo,
 				zap.String("action", plan.Action),
 				zap.Error(err),
-				zap.String("error_type", actionErr.Type.String()))...)
+				zap.String("error_kind", string(actionErr.Kind)),
+				zap.String("error_class", actionErr.Type.String()))...)
 
 			if params.saveSteps {
 				step := a.createStepRecord(task, stepNo, plan, errorMsg)
+				a.applyReflection(step, reflection)
+				a.applyLoopDetection(step, loop)
 				if err := a.repo.CreateStep(step); err != nil {
 					a.log.Error("Ошибка сохранения шага", a.contextFields(params.taskID, stepNo, zap.Error(err))...)
 				}
@@ -349,27 +613,65 @@ func (a *Agent) executeSteps(params executeStepsParams) error {
 				zap.String("action", plan.Action),
 				zap.Error(err))...)
 		} else {
+			if reflection.Succeeded() {
+				successfulSteps = append(successfulSteps, *plan)
+				a.learnSite(params.ctx, plan, element, urlBefore, urlAfter, params.taskID, stepNo)
+				a.rememberWorkaround(params.ctx, lastFailed, plan)
+			} else {
+				// Действие формально выполнено, но цель шага не достигнута -
+				// модель должна увидеть это как неудачу, а не как успех
+				entry.ErrorType = string(reflection.Verdict)
+				entry.Result = fmt.Sprintf("%s (%s)", result, reflection.Explanation)
+				a.log.Warn("Рефлексия: цель шага не достигнута", a.contextFields(params.taskID, stepNo,
+					zap.String("action", plan.Action),
+					zap.String("verdict", string(reflection.Verdict)),
+					zap.String("cause", reflection.ErrorCause))...)
+			}
+			lastFailed = nil
+			a.transcript.Add(entry)
+
 			if params.saveSteps {
 				step := a.createStepRecord(task, stepNo, plan, result)
+				a.applyReflection(step, reflection)
+				a.applyLoopDetection(step, loop)
 				if err := a.repo.CreateStep(step); err != nil {
 					a.log.Error("Ошибка сохранения шага", a.contextFields(params.taskID, stepNo, zap.Error(err))...)
 				}
 			}
+		}
 
-			// ========================================
-			// ФАЗА 3: REFLECTION (базовая версия)
-			// Рефлексия после успешного выполнения действия
-			// ========================================
-			a.performBasicReflection(stepNo, plan, result, err)
+		a.logStep(params.ctx, stepNo, plan, err)
+		if a.dryRun && err == nil {
+			fmt.Fprintf(outputFrom(params.ctx), "[Шаг %d] %s\n", stepNo, result)
 		}
 
-		a.logStep(stepNo, plan, err)
+		if loopErr != nil {
+			a.log.Warn("Задача остановлена из-за зацикливания", a.contextFields(params.taskID, stepNo, zap.Error(loopErr))...)
+			return loopErr
+		}
+	}
+
+	if checkpointing && params.maxSteps >= firstStep {
+		a.saveCheckpoint(params.ctx, *params.taskID, params.maxSteps)
+		a.saveSubgoalProgress(params.subgoal)
 	}
 
-	return fmt.Errorf("достигнут лимит шагов (%d)", params.maxSteps)
+	return fmt.Errorf("%w (%d)", ErrStepLimit, params.maxSteps)
 }
 
+// ExecuteTask выполняет задачу из БД в отдельной сессии агента (см. forTask),
+// поэтому несколько задач могут выполняться параллельно.
 func (a *Agent) ExecuteTask(ctx context.Context, task *database.Task) error {
+	return a.forTask().executeTask(ctx, task)
+}
+
+func (a *Agent) executeTask(ctx context.Context, task *database.Task) error {
+	ctx = llm.WithTaskID(ctx, task.ID)
+
+	if err := a.stopIfBudgetExceeded(&task.ID, 0, true); err != nil {
+		return err
+	}
+
 	if err := a.repo.UpdateTaskStatus(task.ID, "running", ""); err != nil {
 		return fmt.Errorf("ошибка обновления статуса задачи: %w", err)
 	}
@@ -390,24 +692,39 @@ func (a *Agent) ExecuteTask(ctx context.Context, task *database.Task) error {
 	}
 
 	if a.router != nil {
-		pageContext, _ := a.getPageContext(ctx)
-		selectedAgent, err := a.router.RouteTask(ctx, task.UserInput, pageContext)
+		decision, err := a.routeTask(ctx, task)
 		if err != nil {
 			a.log.Warn("Ошибка маршрутизации задачи, используем основной агент", a.contextFields(&task.ID, 0, zap.Error(err))...)
-		} else {
-			a.log.Info("Задача маршрутизирована", a.contextFields(&task.ID, 0, zap.String("agent_type", string(selectedAgent.GetType())))...)
-			// Делегируем выполнение специализированному агенту
-			return selectedAgent.Execute(ctx, task.UserInput, a.maxSteps)
 		}
+		// Делегируем выполнение специализированному агенту, при неудаче - следующим кандидатам
+		return a.executeWithFallback(ctx, task, decision)
 	}
 
+	return a.executeTaskRecord(ctx, task, a.maxSteps)
+}
+
+// executeTaskRecord выполняет задачу из БД: шаги сохраняются, статус задачи обновляется,
+// после каждого шага сохраняется checkpoint. При UseSubgoals задача сначала разбивается на подзадачи.
+func (a *Agent) executeTaskRecord(ctx context.Context, task *database.Task, maxSteps int) error {
+	// Попытка из цепочки fallback нумерует шаги после шагов предыдущих попыток
+	firstStep := a.stepOffset + 1
+	lastStep := a.stepOffset + maxSteps
+
+	if a.cfg.UseSubgoals && a.llmClient != nil {
+		err := a.decomposeTask(ctx, task, maxSteps)
+		if err == nil {
+			return a.runSubgoals(ctx, task, firstStep, lastStep, nil)
+		}
+		a.log.Warn("Ошибка декомпозиции задачи, выполняем без подзадач", a.contextFields(&task.ID, 0, zap.Error(err))...)
+	}
 	return a.executeSteps(executeStepsParams{
 		ctx:        ctx,
 		userInput:  task.UserInput,
-		maxSteps:   a.maxSteps,
+		maxSteps:   lastStep,
 		taskID:     &task.ID,
 		saveSteps:  true,
 		updateTask: true,
+		firstStep:  firstStep,
 	})
 }
 
@@ -422,28 +739,37 @@ func (a *Agent) executeTaskString(ctx context.Context, taskText string, maxSteps
 	})
 }
 
-func (a *Agent) logStep(stepNo int, plan *llm.StepPlan, err error) {
+func (a *Agent) logStep(ctx context.Context, stepNo int, plan *llm.StepPlan, err error) {
 	if err != nil {
-		fmt.Printf("[Шаг %d] %s - ОШИБКА\n", stepNo, plan.Action)
+		fmt.Fprintf(outputFrom(ctx), "[Шаг %d] %s - ОШИБКА\n", stepNo, plan.Action)
 	} else {
 		reasoning := plan.Reasoning
 		if len(reasoning) > 60 {
 			reasoning = reasoning[:57] + "..."
 		}
-		fmt.Printf("[Шаг %d] %s: %s\n", stepNo, plan.Action, reasoning)
+		fmt.Fprintf(outputFrom(ctx), "[Шаг %d] %s: %s\n", stepNo, plan.Action, reasoning)
 	}
 }
 
+// executeActionWithRetry выполняет действие с повторами по политике действия (см. RetryPolicy).
+// Каждая попытка проходит через предохранители сайта и цели (см. actionBreakers): открытый
+// предохранитель прекращает повторы.
 func (a *Agent) executeActionWithRetry(ctx context.Context, plan *llm.StepPlan) (string, error) {
+	return a.executeActionWithPolicy(ctx, plan, a.retryPolicy(plan.Action))
+}
+
+// executeActionWithPolicy выполняет действие по заданной политике повторов через предохранители.
+func (a *Agent) executeActionWithPolicy(ctx context.Context, plan *llm.StepPlan, policy RetryPolicy) (string, error) {
 	var result string
+	breakers := a.actionBreakers(ctx, plan)
 
-	err := retryAction(ctx, a.retries, a.retryDelay, func() error {
+	err := a.retryWith(ctx, policy, plan.Action, func() error {
+		if e := breakers.allow(); e != nil {
+			return e
+		}
 		res, e := a.executeAction(ctx, plan)
+		breakers.record(plan.Action, e)
 		if e != nil {
-			actionErr := classifyError(plan.Action, e)
-			if actionErr.Type == ErrorTypeCritical {
-				return e
-			}
 			return e
 		}
 		result = res
@@ -458,6 +784,12 @@ func (a *Agent) executeActionWithRetry(ctx context.Context, plan *llm.StepPlan)
 }
 
 func (a *Agent) executeAction(ctx context.Context, plan *llm.StepPlan) (string, error) {
+	if a.dryRun {
+		if result, ok := simulateAction(plan); ok {
+			return result, nil
+		}
+	}
+
 	switch plan.Action {
 	case "navigate":
 		if err := a.browser.Navigate(ctx, plan.Value); err != nil {
@@ -482,6 +814,9 @@ func (a *Agent) executeAction(ctx context.Context, plan *llm.StepPlan) (string,
 		if err != nil {
 			return "", fmt.Errorf("извлечение: %w", err)
 		}
+		if a.results != nil {
+			return a.extractRecords(ctx, a.limitContext(context))
+		}
 		return fmt.Sprintf("Извлечено: %s", a.limitContext(context)), nil
 
 	case "ask_user":
@@ -494,6 +829,9 @@ func (a *Agent) executeAction(ctx context.Context, plan *llm.StepPlan) (string,
 		}
 		return fmt.Sprintf("Ответ пользователя: %s", answer), nil
 
+	case "takeover":
+		return a.takeover(ctx, plan.Value)
+
 	default:
 		return "", fmt.Errorf("неизвестное действие: %s", plan.Action)
 	}
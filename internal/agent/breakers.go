@@ -0,0 +1,170 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"aiAgent/internal/llm"
+)
+
+// Предохранители (circuit breakers) не дают агенту раз за разом бить в мертвую цель.
+// На каждое действие над страницей проверяются два: сайта ("site:<домен>") и действия над целью
+// на сайте ("action:<домен> <действие> <селектор>"). Предохранитель сайта считает только ошибки,
+// говорящие о недоступности сайта (таймауты, сеть, неудачная навигация); предохранитель цели -
+// любые ошибки действия. Все обращения агента к LLM (планы, перепланирование, проверки) идут через предохранитель "llm".
+// Открытый предохранитель возвращает *CircuitOpenError, и модель видит его в транскрипте как
+// наблюдение: цель временно заблокирована, нужен другой подход.
+// Предохранители сайтов и LLM общие для всех задач агента: мертвый сайт одной задачи мертв и для
+// остальных. Предохранители целей у каждой задачи свои: ошибки селектора зависят от состояния
+// страницы задачи и не должны блокировать ту же цель в параллельной задаче.
+
+// llmBreakerKey - ключ предохранителя обращений к LLM.
+const llmBreakerKey = "llm"
+
+// actionBreakers - предохранители одного действия (nil - не проверяется).
+type actionBreakers struct {
+	site   *CircuitBreaker
+	target *CircuitBreaker
+}
+
+// actionBreakers возвращает предохранители действия. Для навигации сайт - адрес перехода,
+// для остальных действий - текущая страница. Действия, не обращающиеся к странице, не защищаются.
+func (a *Agent) actionBreakers(ctx context.Context, plan *llm.StepPlan) actionBreakers {
+	if a.circuitBreakers == nil || a.dryRun {
+		return actionBreakers{}
+	}
+
+	var domain string
+	switch plan.Action {
+	case "navigate":
+		domain = extractDomain(plan.Value)
+	case "click", "type", "extract_info":
+		url, _, _ := a.browser.GetPageInfo(ctx)
+		domain = extractDomain(url)
+	default:
+		return actionBreakers{}
+	}
+	if domain == "" {
+		return actionBreakers{}
+	}
+
+	breakers := actionBreakers{site: a.circuitBreakers.GetBreaker("site:" + domain)}
+	if a.targetBreakers != nil {
+		target := "action:" + domain + " " + plan.Action
+		if plan.Selector != "" {
+			target += " " + plan.Selector
+		}
+		breakers.target = a.targetBreakers.GetBreaker(target)
+	}
+	return breakers
+}
+
+// allow возвращает *CircuitOpenError, если заблокирован сайт или цель. Пробный вызов сайта,
+// разрешенный перед заблокированной целью, освобождается: действие не выполнится.
+func (b actionBreakers) allow() error {
+	if b.site != nil {
+		if err := b.site.Allow(); err != nil {
+			return err
+		}
+	}
+	if b.target != nil {
+		if err := b.target.Allow(); err != nil {
+			if b.site != nil {
+				b.site.cancelProbe()
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// record учитывает результат действия. Отмена задачи не считается ни ошибкой, ни успехом:
+// пробный вызов освобождается для следующего действия.
+func (b actionBreakers) record(action string, err error) {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		for _, breaker := range []*CircuitBreaker{b.site, b.target} {
+			if breaker != nil {
+				breaker.cancelProbe()
+			}
+		}
+		return
+	}
+	if b.site != nil {
+		b.site.Record(err != nil && siteFailure(action, err))
+	}
+	if b.target != nil {
+		b.target.Record(err != nil)
+	}
+}
+
+// siteFailure сообщает, что ошибка говорит о недоступности сайта, а не о проблеме с элементом.
+func siteFailure(action string, err error) bool {
+	if action == "navigate" {
+		return true
+	}
+	switch ErrorKindOf(err) {
+	case ErrorKindNavigationTimeout, ErrorKindTimeout, ErrorKindNetwork, ErrorKindBlockedByPolicy:
+		return true
+	}
+	return false
+}
+
+// circuitObservation - наблюдение для модели о заблокированной цели ("" - ошибка не от предохранителя).
+func circuitObservation(err error) string {
+	var openErr *CircuitOpenError
+	if !errors.As(err, &openErr) {
+		return ""
+	}
+	if domain, ok := strings.CutPrefix(openErr.Key, "site:"); ok {
+		return fmt.Sprintf("Сайт %s временно недоступен (%d ошибок подряд). Не обращайся к нему ближайшие %s: используй другой сайт или другой путь к цели.",
+			domain, openErr.Failures, openErr.RetryAfter.Round(time.Second))
+	}
+	return fmt.Sprintf("Это действие с этим селектором заблокировано после %d ошибок подряд. Не повторяй его: выбери другой элемент или другой способ достичь цели.",
+		openErr.Failures)
+}
+
+// llmFailure сообщает, что ошибка говорит о недоступности LLM: сеть, таймаут, лимит запросов
+// или ошибка сервера (5xx). Некорректный ответ и переполненный контекст - ошибки запроса:
+// сервис ответил, и предохранитель их не считает.
+func llmFailure(err error) bool {
+	switch ErrorKindOf(err) {
+	case ErrorKindNetwork, ErrorKindTimeout, ErrorKindRateLimited:
+		return true
+	}
+	return llm.IsServerError(err)
+}
+
+// callLLM выполняет обращение к LLM через предохранитель "llm". Ошибкой считаются только
+// ошибки доступности (см. llmFailure), остальные ответы - успешные.
+func (a *Agent) callLLM(ctx context.Context, fn func() error) error {
+	if a.circuitBreakers == nil {
+		return fn()
+	}
+	breaker := a.circuitBreakers.GetBreaker(llmBreakerKey)
+	if err := breaker.Allow(); err != nil {
+		return err
+	}
+	err := fn()
+	if ctx.Err() != nil {
+		breaker.cancelProbe()
+		return err
+	}
+	breaker.Record(err != nil && llmFailure(err))
+	return err
+}
+
+// BreakerStats возвращает состояние общих предохранителей агента и предохранителей целей
+// его собственной сессии (цели фоновых задач видны только самим задачам).
+func (a *Agent) BreakerStats() []BreakerStats {
+	if a.circuitBreakers == nil {
+		return nil
+	}
+	stats := a.circuitBreakers.Stats()
+	if a.targetBreakers != nil {
+		stats = append(stats, a.targetBreakers.Stats()...)
+	}
+	return stats
+}
@@ -0,0 +1,137 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"aiAgent/internal/database"
+	"aiAgent/internal/llm"
+
+	"go.uber.org/zap"
+)
+
+// ErrBudgetExceeded возвращается когда исчерпан бюджет задачи или глобальный бюджет.
+var ErrBudgetExceeded = errors.New("бюджет исчерпан")
+
+// StatusBudgetExceeded - статус задачи, остановленной из-за исчерпания бюджета.
+const StatusBudgetExceeded = "budget_exceeded"
+
+// BudgetLimits - глобальные лимиты расхода LLM (0 - без лимита).
+// Расход считается по входным и выходным токенам llm_logs всех задач за текущий день/месяц.
+type BudgetLimits struct {
+	DailyTokens   int64   // Токенов в сутки
+	DailyCost     float64 // USD в сутки
+	MonthlyTokens int64   // Токенов в месяц
+	MonthlyCost   float64 // USD в месяц
+}
+
+// Usage - расход токенов и его оценочная стоимость в USD.
+type Usage struct {
+	Tokens int64
+	Cost   float64
+}
+
+func usageFrom(rows []database.ModelUsage) Usage {
+	var u Usage
+	for _, row := range rows {
+		u.Tokens += row.Tokens
+		u.Cost += llm.EstimateCost(row.Model, row.PromptTokens, row.CompletionTokens, row.Tokens)
+	}
+	return u
+}
+
+// TaskUsage возвращает расход LLM задачи.
+func (a *Agent) TaskUsage(taskID uint) (Usage, error) {
+	rows, err := a.repo.TaskTokenUsage(taskID)
+	if err != nil {
+		return Usage{}, fmt.Errorf("ошибка подсчета расхода задачи: %w", err)
+	}
+	return usageFrom(rows), nil
+}
+
+// GlobalUsage возвращает расход LLM всех задач за текущие сутки и текущий месяц.
+func (a *Agent) GlobalUsage(now time.Time) (daily, monthly Usage, err error) {
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+
+	dailyRows, err := a.repo.TokenUsageSince(dayStart)
+	if err != nil {
+		return Usage{}, Usage{}, fmt.Errorf("ошибка подсчета дневного расхода: %w", err)
+	}
+	monthlyRows, err := a.repo.TokenUsageSince(monthStart)
+	if err != nil {
+		return Usage{}, Usage{}, fmt.Errorf("ошибка подсчета месячного расхода: %w", err)
+	}
+	return usageFrom(dailyRows), usageFrom(monthlyRows), nil
+}
+
+// BudgetLimits возвращает глобальные лимиты расхода.
+func (a *Agent) BudgetLimits() BudgetLimits {
+	return a.cfg.Budget
+}
+
+// checkBudget проверяет бюджет задачи (если taskID задан) и глобальные лимиты.
+// Возвращает ошибку, оборачивающую ErrBudgetExceeded, если какой-либо лимит исчерпан.
+func (a *Agent) checkBudget(taskID *uint) error {
+	if taskID != nil {
+		task, err := a.repo.GetTaskByID(*taskID)
+		if err != nil {
+			return fmt.Errorf("ошибка чтения задачи: %w", err)
+		}
+		if task.TokenBudget > 0 || task.CostBudget > 0 {
+			usage, err := a.TaskUsage(task.ID)
+			if err != nil {
+				return err
+			}
+			if err := exceeded("задачи", usage, int64(task.TokenBudget), task.CostBudget); err != nil {
+				return err
+			}
+		}
+	}
+
+	limits := a.cfg.Budget
+	if limits == (BudgetLimits{}) {
+		return nil
+	}
+
+	daily, monthly, err := a.GlobalUsage(time.Now())
+	if err != nil {
+		return err
+	}
+	if err := exceeded("дневной", daily, limits.DailyTokens, limits.DailyCost); err != nil {
+		return err
+	}
+	return exceeded("месячный", monthly, limits.MonthlyTokens, limits.MonthlyCost)
+}
+
+func exceeded(scope string, usage Usage, tokenLimit int64, costLimit float64) error {
+	if tokenLimit > 0 && usage.Tokens >= tokenLimit {
+		return fmt.Errorf("%w: %s лимит токенов (%d из %d)", ErrBudgetExceeded, scope, usage.Tokens, tokenLimit)
+	}
+	if costLimit > 0 && usage.Cost >= costLimit {
+		return fmt.Errorf("%w: %s лимит стоимости ($%.4f из $%.4f)", ErrBudgetExceeded, scope, usage.Cost, costLimit)
+	}
+	return nil
+}
+
+// stopIfBudgetExceeded проверяет бюджет и при исчерпании переводит задачу в статус budget_exceeded.
+// Ошибки подсчета расхода не останавливают задачу.
+func (a *Agent) stopIfBudgetExceeded(taskID *uint, stepNo int, updateTask bool) error {
+	err := a.checkBudget(taskID)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, ErrBudgetExceeded) {
+		a.log.Warn("Ошибка проверки бюджета", a.contextFields(taskID, stepNo, zap.Error(err))...)
+		return nil
+	}
+
+	a.log.Warn("Бюджет исчерпан, задача остановлена", a.contextFields(taskID, stepNo, zap.Error(err))...)
+	if updateTask && taskID != nil {
+		if err := a.repo.UpdateTaskStatus(*taskID, StatusBudgetExceeded, err.Error()); err != nil {
+			a.log.Error("Ошибка обновления статуса задачи", a.contextFields(taskID, stepNo, zap.Error(err))...)
+		}
+	}
+	return err
+}
@@ -0,0 +1,137 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"aiAgent/internal/database"
+	"aiAgent/internal/llm"
+
+	"go.uber.org/zap"
+)
+
+// saveCheckpoint сохраняет состояние задачи после завершенного шага:
+// историю рассуждений, транскрипт действий, текущий URL и storage state браузера.
+func (a *Agent) saveCheckpoint(ctx context.Context, taskID uint, stepNo int) {
+	historyJSON, err := json.Marshal(a.reasoningHistory)
+	if err != nil {
+		a.log.Warn("Ошибка сериализации reasoning history для checkpoint", a.contextFields(&taskID, stepNo, zap.Error(err))...)
+		return
+	}
+
+	transcriptJSON, err := json.Marshal(a.transcript)
+	if err != nil {
+		a.log.Warn("Ошибка сериализации транскрипта для checkpoint", a.contextFields(&taskID, stepNo, zap.Error(err))...)
+		return
+	}
+
+	lastURL, _, _ := a.browser.GetPageInfo(ctx)
+
+	storageState, err := a.browser.StorageState(ctx)
+	if err != nil || storageState == "" {
+		storageState = "{}"
+	}
+
+	cp := &database.TaskCheckpoint{
+		TaskID:           taskID,
+		StepNo:           stepNo,
+		ReasoningHistory: string(historyJSON),
+		Transcript:       string(transcriptJSON),
+		LastURL:          lastURL,
+		StorageState:     storageState,
+	}
+
+	if err := a.repo.SaveCheckpoint(cp); err != nil {
+		a.log.Warn("Ошибка сохранения checkpoint", a.contextFields(&taskID, stepNo, zap.Error(err))...)
+	}
+}
+
+// restoreFromCheckpoint восстанавливает историю рассуждений и транскрипт из checkpoint.
+func (a *Agent) restoreFromCheckpoint(cp *database.TaskCheckpoint) error {
+	history := &llm.ReasoningHistory{}
+	if cp.ReasoningHistory != "" {
+		if err := json.Unmarshal([]byte(cp.ReasoningHistory), history); err != nil {
+			return fmt.Errorf("ошибка восстановления reasoning history: %w", err)
+		}
+	}
+
+	transcript := llm.NewActionTranscript(a.cfg.TranscriptWindow)
+	if cp.Transcript != "" {
+		if err := json.Unmarshal([]byte(cp.Transcript), transcript); err != nil {
+			return fmt.Errorf("ошибка восстановления транскрипта: %w", err)
+		}
+	}
+
+	a.reasoningHistory = history
+	a.transcript = transcript
+	return nil
+}
+
+// ResumeTask продолжает выполнение прерванной задачи с последнего сохраненного шага.
+// Браузер восстанавливается из checkpoint (cookies, localStorage, последний URL),
+// после чего executeSteps продолжает работу со следующего номера шага.
+func (a *Agent) ResumeTask(ctx context.Context, task *database.Task) error {
+	return a.forTask().resumeTask(ctx, task)
+}
+
+func (a *Agent) resumeTask(ctx context.Context, task *database.Task) error {
+	ctx = llm.WithTaskID(ctx, task.ID)
+
+	cp, err := a.repo.GetCheckpoint(task.ID)
+	if err != nil {
+		return fmt.Errorf("checkpoint для задачи #%d не найден: %w", task.ID, err)
+	}
+
+	if err := a.stopIfBudgetExceeded(&task.ID, cp.StepNo, true); err != nil {
+		return err
+	}
+
+	if err := a.repo.UpdateTaskStatus(task.ID, "running", ""); err != nil {
+		return fmt.Errorf("ошибка обновления статуса задачи: %w", err)
+	}
+
+	if err := a.browser.Launch(ctx); err != nil {
+		return fmt.Errorf("ошибка запуска браузера: %w", err)
+	}
+	defer a.browser.Close()
+
+	if err := a.browser.RestoreStorageState(ctx, cp.StorageState); err != nil {
+		a.log.Warn("Не удалось восстановить storage state", a.contextFields(&task.ID, cp.StepNo, zap.Error(err))...)
+	}
+
+	if cp.LastURL != "" && cp.LastURL != "about:blank" {
+		if err := a.browser.Navigate(ctx, cp.LastURL); err != nil {
+			a.log.Warn("Не удалось открыть последний URL из checkpoint", a.contextFields(&task.ID, cp.StepNo, zap.String("url", cp.LastURL), zap.Error(err))...)
+		}
+	}
+
+	a.log.Info("Возобновление задачи из checkpoint", a.contextFields(&task.ID, cp.StepNo, zap.String("url", cp.LastURL))...)
+
+	// Прерванная попытка цепочки fallback продолжается тем же агентом с той же нумерацией шагов
+	ctx, attempt, maxSteps := a.resumeAttempt(ctx, task.ID)
+
+	// Задача, разбитая на подзадачи, продолжается с первой невыполненной подзадачи;
+	// прерванная подзадача продолжается с историей и транскриптом из checkpoint
+	if subgoals, err := a.repo.ListSubgoals(task.ID); err == nil && len(subgoals) > 0 {
+		err := a.runSubgoals(ctx, task, cp.StepNo+1, maxSteps, cp)
+		if attempt != nil {
+			a.finishAttempt(attempt, err)
+		}
+		return err
+	}
+
+	err = a.executeSteps(executeStepsParams{
+		ctx:        ctx,
+		userInput:  task.UserInput,
+		maxSteps:   maxSteps,
+		taskID:     &task.ID,
+		saveSteps:  true,
+		updateTask: true,
+		checkpoint: cp,
+	})
+	if attempt != nil {
+		a.finishAttempt(attempt, err)
+	}
+	return err
+}
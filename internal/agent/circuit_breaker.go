@@ -2,8 +2,10 @@ package agent
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"math"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 )
@@ -16,12 +18,60 @@ const (
 	StateHalfOpen
 )
 
+func (s CircuitState) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// ErrCircuitOpen - цель временно заблокирована: предохранитель открыт после серии ошибок.
+// Конкретная ошибка - *CircuitOpenError, errors.Is(err, ErrCircuitOpen) для нее истинно.
+var ErrCircuitOpen = errors.New("предохранитель открыт")
+
+// CircuitOpenError возвращается вместо вызова, пока предохранитель открыт.
+type CircuitOpenError struct {
+	Key        string        // Ключ предохранителя в пуле
+	Failures   int           // Ошибок подряд перед открытием
+	RetryAfter time.Duration // Через сколько будет разрешен пробный вызов
+	Probing    bool          // Пробный вызов уже идет: до его результата остальные вызовы не пропускаются
+}
+
+func (e *CircuitOpenError) Error() string {
+	if e.Probing {
+		return fmt.Sprintf("%s (%s): %d ошибок подряд, идет пробный вызов", ErrCircuitOpen, e.Key, e.Failures)
+	}
+	return fmt.Sprintf("%s (%s): %d ошибок подряд, пробный вызов через %s",
+		ErrCircuitOpen, e.Key, e.Failures, e.RetryAfter.Round(time.Second))
+}
+
+func (e *CircuitOpenError) Is(target error) bool {
+	return target == ErrCircuitOpen
+}
+
+// BreakerStats - состояние предохранителя для вывода пользователю.
+type BreakerStats struct {
+	Key         string
+	State       CircuitState
+	Failures    int
+	LastFailure time.Time
+	RetryAfter  time.Duration // Для открытого предохранителя - время до пробного вызова
+}
+
 type CircuitBreaker struct {
+	key          string
 	maxFailures  int
 	resetTimeout time.Duration
 	state        CircuitState
 	failures     int
 	lastFailure  time.Time
+	probeStart   time.Time // Начало пробного вызова в half-open (нулевое - пробы нет)
 	mu           sync.RWMutex
 }
 
@@ -42,42 +92,82 @@ func NewCircuitBreaker(maxFailures int, resetTimeout time.Duration) *CircuitBrea
 }
 
 func (cb *CircuitBreaker) Call(ctx context.Context, fn func() error) error {
-	cb.mu.Lock()
-
-	if cb.state == StateOpen {
-		if time.Since(cb.lastFailure) > cb.resetTimeout {
-			cb.state = StateHalfOpen
-			cb.failures = 0
-		} else {
-			cb.mu.Unlock()
-			return fmt.Errorf("circuit breaker is open")
-		}
+	if err := cb.Allow(); err != nil {
+		return err
 	}
 
-	cb.mu.Unlock()
-
 	err := fn()
+	cb.Record(err != nil)
+	return err
+}
 
+// Allow проверяет, можно ли выполнить вызов. Открытый предохранитель после resetTimeout
+// переходит в half-open и пропускает один пробный вызов; остальные вызовы до его результата
+// получают *CircuitOpenError, как и вызовы при открытом предохранителе. Проба, результат которой
+// так и не записан (вызов отменен), через resetTimeout уступает место следующей.
+func (cb *CircuitBreaker) Allow() error {
 	cb.mu.Lock()
 	defer cb.mu.Unlock()
 
-	if err != nil {
-		cb.failures++
-		cb.lastFailure = time.Now()
-
-		if cb.failures >= cb.maxFailures {
-			cb.state = StateOpen
+	switch cb.state {
+	case StateOpen:
+		wait := cb.resetTimeout - time.Since(cb.lastFailure)
+		if wait > 0 {
+			return &CircuitOpenError{Key: cb.key, Failures: cb.failures, RetryAfter: wait}
 		}
-
-		return err
+		cb.state = StateHalfOpen
+	case StateHalfOpen:
+		if !cb.probeStart.IsZero() && time.Since(cb.probeStart) < cb.resetTimeout {
+			return &CircuitOpenError{Key: cb.key, Failures: cb.failures, Probing: true}
+		}
+	default:
+		return nil
 	}
+	cb.probeStart = time.Now()
+	return nil
+}
+
+// cancelProbe освобождает пробный вызов, разрешенный Allow, но не выполненный
+// (например, заблокирован другой предохранитель того же действия).
+func (cb *CircuitBreaker) cancelProbe() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.probeStart = time.Time{}
+}
 
-	if cb.state == StateHalfOpen {
+// Record учитывает результат вызова. Ошибка пробного вызова в half-open снова открывает предохранитель.
+func (cb *CircuitBreaker) Record(failed bool) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.probeStart = time.Time{}
+	if !failed {
 		cb.state = StateClosed
+		cb.failures = 0
+		return
 	}
-	cb.failures = 0
 
-	return nil
+	cb.failures++
+	cb.lastFailure = time.Now()
+	if cb.state == StateHalfOpen || cb.failures >= cb.maxFailures {
+		cb.state = StateOpen
+	}
+}
+
+func (cb *CircuitBreaker) Stats() BreakerStats {
+	cb.mu.RLock()
+	defer cb.mu.RUnlock()
+
+	stats := BreakerStats{
+		Key:         cb.key,
+		State:       cb.state,
+		Failures:    cb.failures,
+		LastFailure: cb.lastFailure,
+	}
+	if cb.state == StateOpen {
+		stats.RetryAfter = max(cb.resetTimeout-time.Since(cb.lastFailure), 0)
+	}
+	return stats
 }
 
 func (cb *CircuitBreaker) GetState() CircuitState {
@@ -91,8 +181,11 @@ func (cb *CircuitBreaker) Reset() {
 	defer cb.mu.Unlock()
 	cb.state = StateClosed
 	cb.failures = 0
+	cb.probeStart = time.Time{}
 }
 
+// RetryWithExponentialBackoff повторяет fn с удвоением задержки (не больше 30 секунд), пока ошибка
+// относится к классу retryable. Агент использует политики повторов по областям (см. RetryPolicy).
 func RetryWithExponentialBackoff(ctx context.Context, maxRetries int, baseDelay time.Duration, fn func() error) error {
 	if maxRetries == 0 {
 		maxRetries = 3
@@ -101,39 +194,18 @@ func RetryWithExponentialBackoff(ctx context.Context, maxRetries int, baseDelay
 		baseDelay = 1 * time.Second
 	}
 
-	var lastErr error
-
-	for attempt := 0; attempt < maxRetries; attempt++ {
-		if attempt > 0 {
-			delay := time.Duration(float64(baseDelay) * math.Pow(2, float64(attempt-1)))
-			maxDelay := 30 * time.Second
-			if delay > maxDelay {
-				delay = maxDelay
-			}
-
-			select {
-			case <-ctx.Done():
-				return ctx.Err()
-			case <-time.After(delay):
-			}
-		}
-
-		err := fn()
-		if err == nil {
-			return nil
-		}
-
-		lastErr = err
-
-		actionErr := classifyError("", err)
-		if actionErr.Type == ErrorTypeCritical {
-			return err
-		}
-	}
-
-	return fmt.Errorf("max retries exceeded: %w", lastErr)
+	policy := RetryPolicy{MaxAttempts: maxRetries, BaseDelay: baseDelay, MaxDelay: 30 * time.Second, Backoff: 2}
+	_, err := policy.Run(ctx, RetryScopeDefault, fn)
+	return err
 }
 
+const (
+	maxPoolBreakers    = 1000             // Сколько предохранителей хранит пул: ключи целей не ограничены
+	breakerIdleTimeout = 10 * time.Minute // Закрытый предохранитель без ошибок дольше этого вытесняется
+)
+
+// CircuitBreakerPool - предохранители по ключам. Закрытые предохранители без недавних ошибок
+// вытесняются, когда пул заполнен: они ничем не отличаются от новых.
 type CircuitBreakerPool struct {
 	breakers map[string]*CircuitBreaker
 	mu       sync.RWMutex
@@ -159,12 +231,45 @@ func (pool *CircuitBreakerPool) GetBreaker(key string) *CircuitBreaker {
 	if breaker, ok := pool.breakers[key]; ok {
 		return breaker
 	}
+	if len(pool.breakers) >= maxPoolBreakers {
+		pool.evictIdle()
+	}
 
 	breaker := NewCircuitBreaker(5, 30*time.Second)
+	breaker.key = key
 	pool.breakers[key] = breaker
 	return breaker
 }
 
+// evictIdle удаляет закрытые предохранители без ошибок за breakerIdleTimeout. Если пул все равно
+// заполнен, удаляются закрытые с самой давней ошибкой. Открытые и half-open сохраняются всегда:
+// они блокируют цель. Вызывается под pool.mu.
+func (pool *CircuitBreakerPool) evictIdle() {
+	var closed []BreakerStats
+	for key, breaker := range pool.breakers {
+		stats := breaker.Stats()
+		if stats.State != StateClosed {
+			continue
+		}
+		if stats.Failures == 0 || time.Since(stats.LastFailure) > breakerIdleTimeout {
+			delete(pool.breakers, key)
+			continue
+		}
+		closed = append(closed, stats)
+	}
+
+	excess := len(pool.breakers) - maxPoolBreakers + 1
+	if excess <= 0 {
+		return
+	}
+	slices.SortFunc(closed, func(a, b BreakerStats) int {
+		return a.LastFailure.Compare(b.LastFailure)
+	})
+	for _, stats := range closed[:min(excess, len(closed))] {
+		delete(pool.breakers, stats.Key)
+	}
+}
+
 func (pool *CircuitBreakerPool) ResetAll() {
 	pool.mu.Lock()
 	defer pool.mu.Unlock()
@@ -173,3 +278,18 @@ func (pool *CircuitBreakerPool) ResetAll() {
 		breaker.Reset()
 	}
 }
+
+// Stats возвращает состояние предохранителей пула, отсортированное по ключу.
+func (pool *CircuitBreakerPool) Stats() []BreakerStats {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	stats := make([]BreakerStats, 0, len(pool.breakers))
+	for _, breaker := range pool.breakers {
+		stats = append(stats, breaker.Stats())
+	}
+	slices.SortFunc(stats, func(a, b BreakerStats) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+	return stats
+}
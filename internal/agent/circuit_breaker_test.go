@@ -0,0 +1,223 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"aiAgent/internal/llm"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// expire переводит открытый предохранитель в состояние, когда resetTimeout уже истек.
+func expire(cb *CircuitBreaker) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.lastFailure = time.Now().Add(-2 * cb.resetTimeout)
+	if !cb.probeStart.IsZero() {
+		cb.probeStart = time.Now().Add(-2 * cb.resetTimeout)
+	}
+}
+
+// openBreaker возвращает предохранитель, открытый после maxFailures ошибок.
+func openBreaker(t *testing.T) *CircuitBreaker {
+	t.Helper()
+	cb := NewCircuitBreaker(3, time.Minute)
+	for range 3 {
+		if err := cb.Allow(); err != nil {
+			t.Fatalf("Allow() в закрытом состоянии: %v", err)
+		}
+		cb.Record(true)
+	}
+	if cb.GetState() != StateOpen {
+		t.Fatalf("состояние = %s, ожидалось %s", cb.GetState(), StateOpen)
+	}
+	return cb
+}
+
+func TestCircuitBreakerOpens(t *testing.T) {
+	cb := NewCircuitBreaker(3, time.Minute)
+	for i := range 2 {
+		cb.Record(true)
+		if cb.GetState() != StateClosed {
+			t.Fatalf("после %d ошибок состояние = %s, ожидалось %s", i+1, cb.GetState(), StateClosed)
+		}
+	}
+	cb.Record(false)
+	if stats := cb.Stats(); stats.Failures != 0 {
+		t.Fatalf("успех должен сбрасывать счетчик, ошибок = %d", stats.Failures)
+	}
+
+	cb = openBreaker(t)
+	err := cb.Allow()
+	var openErr *CircuitOpenError
+	if !errors.As(err, &openErr) || !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Allow() при открытом предохранителе = %v, ожидалась *CircuitOpenError", err)
+	}
+	if openErr.Probing || openErr.Failures != 3 || openErr.RetryAfter <= 0 {
+		t.Errorf("CircuitOpenError = %+v, ожидались 3 ошибки и положительное RetryAfter", openErr)
+	}
+	if ErrorKindOf(err) != ErrorKindCircuitOpen {
+		t.Errorf("ErrorKindOf() = %s, ожидалось %s", ErrorKindOf(err), ErrorKindCircuitOpen)
+	}
+}
+
+func TestCircuitBreakerHalfOpen(t *testing.T) {
+	tests := []struct {
+		name   string
+		failed bool
+		want   CircuitState
+	}{
+		{"успешная проба закрывает", false, StateClosed},
+		{"ошибка пробы снова открывает", true, StateOpen},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cb := openBreaker(t)
+			expire(cb)
+
+			if err := cb.Allow(); err != nil {
+				t.Fatalf("пробный вызов не пропущен: %v", err)
+			}
+			if cb.GetState() != StateHalfOpen {
+				t.Fatalf("состояние = %s, ожидалось %s", cb.GetState(), StateHalfOpen)
+			}
+			var openErr *CircuitOpenError
+			if err := cb.Allow(); !errors.As(err, &openErr) || !openErr.Probing {
+				t.Fatalf("второй вызов во время пробы = %v, ожидалась ошибка с Probing", err)
+			}
+
+			cb.Record(tt.failed)
+			if cb.GetState() != tt.want {
+				t.Errorf("после пробы состояние = %s, ожидалось %s", cb.GetState(), tt.want)
+			}
+			if err := cb.Allow(); (err == nil) != (tt.want == StateClosed) {
+				t.Errorf("Allow() после пробы = %v", err)
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerProbeRelease(t *testing.T) {
+	tests := []struct {
+		name    string
+		release func(cb *CircuitBreaker)
+	}{
+		{"отмена пробы", func(cb *CircuitBreaker) { cb.cancelProbe() }},
+		{"зависшая проба истекает", expire},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cb := openBreaker(t)
+			expire(cb)
+			if err := cb.Allow(); err != nil {
+				t.Fatalf("пробный вызов не пропущен: %v", err)
+			}
+
+			tt.release(cb)
+			if err := cb.Allow(); err != nil {
+				t.Errorf("новая проба не пропущена: %v", err)
+			}
+			if cb.GetState() != StateHalfOpen {
+				t.Errorf("состояние = %s, ожидалось %s", cb.GetState(), StateHalfOpen)
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerReset(t *testing.T) {
+	cb := openBreaker(t)
+	cb.Reset()
+	if err := cb.Allow(); err != nil || cb.GetState() != StateClosed {
+		t.Errorf("после Reset: Allow() = %v, состояние = %s", err, cb.GetState())
+	}
+}
+
+func TestCircuitBreakerPoolEviction(t *testing.T) {
+	pool := NewCircuitBreakerPool()
+	open := pool.GetBreaker("open")
+	for range 5 {
+		open.Record(true)
+	}
+	recent := pool.GetBreaker("recent")
+	recent.Record(true)
+	for i := len(pool.breakers); i < maxPoolBreakers; i++ {
+		pool.GetBreaker(fmt.Sprintf("idle-%d", i))
+	}
+
+	pool.GetBreaker("new")
+	if len(pool.breakers) != 3 {
+		t.Errorf("после вытеснения %d предохранителей, ожидалось 3", len(pool.breakers))
+	}
+	for _, key := range []string{"open", "recent", "new"} {
+		if _, ok := pool.breakers[key]; !ok {
+			t.Errorf("предохранитель %s вытеснен", key)
+		}
+	}
+}
+
+func TestCircuitBreakerPoolEvictsOldestFailure(t *testing.T) {
+	pool := NewCircuitBreakerPool()
+	now := time.Now()
+	for i := range maxPoolBreakers {
+		cb := pool.GetBreaker(fmt.Sprintf("cb-%d", i))
+		cb.Record(true)
+		cb.lastFailure = now.Add(-time.Duration(maxPoolBreakers-i) * time.Millisecond)
+	}
+
+	pool.GetBreaker("new")
+	if len(pool.breakers) != maxPoolBreakers {
+		t.Errorf("в пуле %d предохранителей, ожидалось %d", len(pool.breakers), maxPoolBreakers)
+	}
+	if _, ok := pool.breakers["cb-0"]; ok {
+		t.Error("предохранитель с самой давней ошибкой не вытеснен")
+	}
+}
+
+func TestLLMFailure(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"таймаут", fmt.Errorf("запрос: %w", context.DeadlineExceeded), true},
+		{"лимит запросов", fmt.Errorf("%w: 429", llm.ErrRateLimited), true},
+		{"ошибка сервера", &openai.APIError{HTTPStatusCode: http.StatusBadGateway}, true},
+		{"ошибка сервера без тела", &openai.RequestError{HTTPStatusCode: http.StatusServiceUnavailable}, true},
+		{"ошибка запроса", &openai.APIError{HTTPStatusCode: http.StatusBadRequest}, false},
+		{"длинный контекст", llm.ErrContextTooLong, false},
+		{"некорректный ответ", llm.ErrMalformedResponse, false},
+		{"отмена", context.Canceled, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := llmFailure(tt.err); got != tt.want {
+				t.Errorf("llmFailure(%v) = %v, ожидалось %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestForTaskBreakers(t *testing.T) {
+	base := &Agent{circuitBreakers: NewCircuitBreakerPool(), targetBreakers: NewCircuitBreakerPool()}
+	first, second := base.forTask(), base.forTask()
+
+	if first.circuitBreakers != base.circuitBreakers || second.circuitBreakers != base.circuitBreakers {
+		t.Error("предохранители сайтов и LLM должны быть общими для задач")
+	}
+	if first.targetBreakers == base.targetBreakers || first.targetBreakers == second.targetBreakers {
+		t.Error("у каждой задачи должны быть свои предохранители целей")
+	}
+
+	key := "action:example.com click #submit"
+	for range 5 {
+		first.targetBreakers.GetBreaker(key).Record(true)
+	}
+	if err := second.targetBreakers.GetBreaker(key).Allow(); err != nil {
+		t.Errorf("цель заблокирована в другой задаче: %v", err)
+	}
+}
@@ -0,0 +1,190 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"aiAgent/internal/database"
+	"aiAgent/internal/llm"
+
+	"go.uber.org/zap"
+)
+
+// StepVerdictCriteriaNotMet - вердикт шага complete, отклоненного проверкой критериев успеха.
+const StepVerdictCriteriaNotMet = "criteria_not_met"
+
+// maxResultLen - сколько символов одного результата extract_info передается на проверку завершения.
+const maxResultLen = 2000
+
+// ParseCriteria разбирает критерии успеха (по одному на строку или через ';').
+func ParseCriteria(s string) []string {
+	var criteria []string
+	for _, line := range strings.FieldsFunc(s, func(r rune) bool { return r == '\n' || r == ';' }) {
+		if line = strings.TrimSpace(line); line != "" {
+			criteria = append(criteria, line)
+		}
+	}
+	return criteria
+}
+
+// deriveCriteria формулирует критерии успеха через LLM, если пользователь их не задал,
+// и (для задач из БД, кроме пробного запуска) сохраняет их в задаче, чтобы resume проверял те же критерии.
+// Ошибка LLM не критична: без критериев завершение принимается как раньше.
+func (a *Agent) deriveCriteria(ctx context.Context, userInput, pageContext string, taskID *uint) []string {
+	if a.llmClient == nil {
+		return nil
+	}
+
+	var criteria []string
+	err := a.retry(ctx, RetryScopeLLM, func() error {
+		return a.callLLM(ctx, func() error {
+			c, e := a.llmClient.DeriveCriteria(ctx, userInput, pageContext, taskID, nil)
+			if e != nil {
+				return e
+			}
+			criteria = c
+			return nil
+		})
+	})
+	if err != nil {
+		a.log.Warn("Не удалось сформулировать критерии успеха", a.contextFields(taskID, 0, zap.Error(err))...)
+		return nil
+	}
+	if len(criteria) == 0 {
+		return nil
+	}
+
+	a.log.Info("Критерии успеха сформулированы", a.contextFields(taskID, 0, zap.Strings("criteria", criteria))...)
+	if taskID != nil && !a.dryRun {
+		if err := a.repo.UpdateTaskCriteria(*taskID, strings.Join(criteria, "\n")); err != nil {
+			a.log.Warn("Ошибка сохранения критериев успеха", a.contextFields(taskID, 0, zap.Error(err))...)
+		}
+	}
+	return criteria
+}
+
+// collectedResults собирает данные, извлеченные агентом (extract_info) за время выполнения задачи.
+func collectedResults(transcript *llm.ActionTranscript) string {
+	if transcript.Len() == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	for _, e := range transcript.Entries {
+		if e.Plan.Action != "extract_info" || e.Failed() {
+			continue
+		}
+		fmt.Fprintf(&sb, "[Шаг %d] %s\n", e.StepNo, truncateRunes(e.Result, maxResultLen))
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// verifyCompletion проверяет критерии успеха перед тем как принять завершение задачи.
+// Возвращает nil вердикт, если критериев нет. Если проверить критерии не удалось,
+// завершение не принимается: возвращается вердикт, где все критерии не выполнены.
+// Ошибка возвращается только критичная (отмена, бюджет).
+func (a *Agent) verifyCompletion(ctx context.Context, userInput string, criteria []string, pageContext string, taskID *uint, stepNo int) (*llm.CompletionVerdict, error) {
+	if len(criteria) == 0 || a.llmClient == nil {
+		return nil, nil
+	}
+
+	var verdict *llm.CompletionVerdict
+	err := a.retry(ctx, RetryScopeLLM, func() error {
+		return a.callLLM(ctx, func() error {
+			v, e := a.llmClient.VerifyCompletion(ctx, userInput, criteria, pageContext, collectedResults(a.transcript), a.transcript, taskID, nil)
+			if e != nil {
+				return e
+			}
+			verdict = v
+			return nil
+		})
+	})
+	if err != nil {
+		if isCriticalError(err) {
+			return nil, err
+		}
+		a.log.Warn("Ошибка проверки критериев успеха, завершение отклонено", a.contextFields(taskID, stepNo, zap.Error(err))...)
+		return unverifiedVerdict(criteria, err), nil
+	}
+
+	a.log.Info("Проверка критериев успеха", a.contextFields(taskID, stepNo,
+		zap.Bool("passed", verdict.Passed),
+		zap.Int("unmet", len(verdict.Unmet())))...)
+	return verdict, nil
+}
+
+// unverifiedVerdict - вердикт для критериев, которые не удалось проверить: все считаются невыполненными.
+func unverifiedVerdict(criteria []string, err error) *llm.CompletionVerdict {
+	verdict := &llm.CompletionVerdict{Summary: fmt.Sprintf("проверка не выполнена: %v", err)}
+	for i, criterion := range criteria {
+		verdict.Checks = append(verdict.Checks, llm.CriterionCheck{Number: i + 1, Criterion: criterion, Evidence: "проверка не выполнена"})
+	}
+	return verdict
+}
+
+// unmetObservation формирует наблюдение для модели о невыполненных критериях.
+func unmetObservation(verdict *llm.CompletionVerdict) string {
+	parts := make([]string, 0, len(verdict.Checks))
+	for _, c := range verdict.Unmet() {
+		if c.Evidence != "" {
+			parts = append(parts, fmt.Sprintf("%s (%s)", c.Criterion, c.Evidence))
+		} else {
+			parts = append(parts, c.Criterion)
+		}
+	}
+	return "Задача еще не выполнена, не выполнены критерии: " + strings.Join(parts, "; ")
+}
+
+// completionSummary формирует ResultSummary: итог от модели и вердикт проверки критериев с доказательствами.
+func (a *Agent) completionSummary(reasoning string, verdict *llm.CompletionVerdict) string {
+	summary := reasoning
+	if verdict != nil {
+		summary += "\n\n" + verdict.Format()
+	}
+	return a.sanitizer.Sanitize(summary)
+}
+
+// rejectCompletion отклоняет преждевременное завершение: шаг сохраняется с вердиктом criteria_not_met,
+// а модель видит в транскрипте, какие критерии еще не выполнены, и продолжает работу.
+// Повторные попытки завершиться без выполнения критериев учитываются детектором зацикливания.
+func (a *Agent) rejectCompletion(params executeStepsParams, task *database.Task, stepNo int, plan *llm.StepPlan, pageContext string, verdict *llm.CompletionVerdict) error {
+	a.log.Warn("Завершение отклонено: критерии успеха не выполнены", a.contextFields(params.taskID, stepNo,
+		zap.Int("unmet", len(verdict.Unmet())))...)
+	fmt.Fprintf(outputFrom(params.ctx), "[Шаг %d] Завершение отклонено: критерии успеха не выполнены (%d)\n", stepNo, len(verdict.Unmet()))
+
+	url, title, _ := a.browser.GetPageInfo(params.ctx)
+	fingerprint := PageFingerprint(url, title, pageContext)
+	loop := a.loopDetector.Observe(plan, url, fingerprint, fingerprint)
+
+	entry := llm.TranscriptEntry{
+		StepNo:      stepNo,
+		Plan:        *plan,
+		Result:      verdict.Format(),
+		ErrorType:   StepVerdictCriteriaNotMet,
+		URLBefore:   url,
+		URLAfter:    url,
+		TitleBefore: title,
+		TitleAfter:  title,
+		Observation: unmetObservation(verdict),
+	}
+	var loopErr error
+	if loop != nil {
+		var observation string
+		observation, loopErr = a.handleLoop(params.ctx, params.taskID, stepNo, loop)
+		if observation != "" {
+			entry.Observation += " " + observation
+		}
+	}
+	a.transcript.Add(entry)
+
+	if params.saveSteps {
+		step := a.createStepRecord(task, stepNo, plan, verdict.Format())
+		step.Verdict = StepVerdictCriteriaNotMet
+		step.VerdictReason = a.sanitizer.Sanitize(unmetObservation(verdict))
+		a.applyLoopDetection(step, loop)
+		if err := a.repo.CreateStep(step); err != nil {
+			a.log.Error("Ошибка сохранения шага", a.contextFields(params.taskID, stepNo, zap.Error(err))...)
+		}
+	}
+	return loopErr
+}
@@ -0,0 +1,321 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+
+	"aiAgent/internal/database"
+	"aiAgent/internal/llm"
+
+	"gopkg.in/yaml.v3"
+)
+
+// AgentSpec - описание специализированного агента в YAML/JSON файле.
+// Такие агенты загружаются из Config.AgentsDir при старте и регистрируются в AgentRouter
+// наравне со встроенными, поэтому новый агент добавляется без перекомпиляции.
+type AgentSpec struct {
+	Name           string   `yaml:"name" json:"name"`                       // Имя агента (тип задачи в роутере)
+	Description    string   `yaml:"description" json:"description"`         // Описание для списка агентов
+	Keywords       []string `yaml:"keywords" json:"keywords"`               // Ключевые слова задачи для маршрутизации
+	Examples       []string `yaml:"examples" json:"examples"`               // Примеры задач для маршрутизации
+	SystemPrompt   string   `yaml:"system_prompt" json:"system_prompt"`     // Инструкции, добавляемые к системному промпту
+	AllowedTools   []string `yaml:"allowed_tools" json:"allowed_tools"`     // Разрешенные действия (пусто - все)
+	AllowedDomains []string `yaml:"allowed_domains" json:"allowed_domains"` // Разрешенные для навигации домены (пусто - любые)
+	MaxSteps       int      `yaml:"max_steps" json:"max_steps"`             // Бюджет шагов (0 - общий лимит агента)
+	File           string   `yaml:"-" json:"-"`                             // Файл, из которого загружено описание
+}
+
+// minExampleOverlap - доля слов примера, которые должны встретиться в задаче, чтобы пример считался похожим.
+const minExampleOverlap = 0.6
+
+// LoadAgentSpecs загружает описания агентов из файлов *.yaml, *.yml и *.json в каталоге dir.
+// Отсутствующий каталог - не ошибка. Файлы с ошибками пропускаются: их ошибки возвращаются
+// вместе с успешно загруженными описаниями.
+func LoadAgentSpecs(dir string) ([]*AgentSpec, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("ошибка чтения каталога агентов: %w", err)
+	}
+
+	var specs []*AgentSpec
+	var errs []error
+	names := make(map[string]string)
+	for _, entry := range entries {
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml" && ext != ".json") {
+			continue
+		}
+
+		path := filepath.Join(dir, entry.Name())
+		spec, err := loadAgentSpec(path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+			continue
+		}
+		if other, ok := names[spec.Name]; ok {
+			errs = append(errs, fmt.Errorf("%s: агент %s уже описан в %s", path, spec.Name, other))
+			continue
+		}
+		names[spec.Name] = path
+		specs = append(specs, spec)
+	}
+
+	return specs, errors.Join(errs...)
+}
+
+func loadAgentSpec(path string) (*AgentSpec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения файла: %w", err)
+	}
+
+	spec := &AgentSpec{File: path}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(spec)
+	} else {
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		err = decoder.Decode(spec)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка разбора описания агента: %w", err)
+	}
+
+	if err := spec.normalize(); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// normalize приводит поля описания к единому виду и проверяет их.
+func (s *AgentSpec) normalize() error {
+	s.Name = strings.TrimSpace(s.Name)
+	if s.Name == "" {
+		return fmt.Errorf("не указано имя агента (name)")
+	}
+	if strings.ContainsFunc(s.Name, unicode.IsSpace) {
+		return fmt.Errorf("имя агента не должно содержать пробелов: %s", s.Name)
+	}
+
+	s.Keywords = normalizeList(s.Keywords, strings.ToLower)
+	s.Examples = normalizeList(s.Examples, nil)
+	if len(s.Keywords) == 0 && len(s.Examples) == 0 {
+		return fmt.Errorf("агент %s: нужны keywords или examples для маршрутизации", s.Name)
+	}
+
+	s.AllowedTools = normalizeList(s.AllowedTools, strings.ToLower)
+	known := llm.ToolNames()
+	for _, tool := range s.AllowedTools {
+		if !slices.Contains(known, tool) {
+			return fmt.Errorf("агент %s: неизвестное действие %s (доступны: %s)", s.Name, tool, strings.Join(known, ", "))
+		}
+	}
+
+	s.AllowedDomains = normalizeList(s.AllowedDomains, func(domain string) string {
+		return strings.TrimPrefix(strings.ToLower(domain), "www.")
+	})
+	if s.MaxSteps < 0 {
+		return fmt.Errorf("агент %s: max_steps не может быть отрицательным", s.Name)
+	}
+	s.SystemPrompt = strings.TrimSpace(s.SystemPrompt)
+	return nil
+}
+
+// normalizeList обрезает пробелы, применяет transform и отбрасывает пустые значения.
+func normalizeList(values []string, transform func(string) string) []string {
+	result := make([]string, 0, len(values))
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if transform != nil {
+			v = transform(v)
+		}
+		if v != "" {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// allowsAction проверяет, разрешено ли действие профилем агента.
+// Завершение задачи разрешено всегда, как и передача управления: модели takeover без разрешения
+// не предлагается, а по команде пользователя управление передается при любом профиле.
+func (s *AgentSpec) allowsAction(action string) bool {
+	return len(s.AllowedTools) == 0 || action == "complete" || action == "takeover" || slices.Contains(s.AllowedTools, action)
+}
+
+// allowsURL проверяет, входит ли домен URL (или его родительский домен) в allowlist агента.
+func (s *AgentSpec) allowsURL(rawURL string) bool {
+	if len(s.AllowedDomains) == 0 {
+		return true
+	}
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "https://" + rawURL
+	}
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	host := strings.TrimPrefix(strings.ToLower(parsed.Hostname()), "www.")
+	for _, domain := range s.AllowedDomains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkAction возвращает ошибку, если действие нарушает ограничения профиля агента.
+// Действие не выполняется, ошибка попадает в транскрипт, и модель выбирает другое действие.
+func (s *AgentSpec) checkAction(plan *llm.StepPlan) error {
+	if !s.allowsAction(plan.Action) {
+		return fmt.Errorf("действие %s запрещено для агента %s (разрешены: %s)", plan.Action, s.Name, strings.Join(s.AllowedTools, ", "))
+	}
+	if plan.Action == "navigate" && !s.allowsURL(plan.Value) {
+		return fmt.Errorf("переход на %s запрещен для агента %s (разрешены домены: %s)", plan.Value, s.Name, strings.Join(s.AllowedDomains, ", "))
+	}
+	return nil
+}
+
+func (s *AgentSpec) profile() *llm.AgentProfile {
+	return &llm.AgentProfile{
+		Name:         s.Name,
+		SystemPrompt: s.SystemPrompt,
+		AllowedTools: s.AllowedTools,
+	}
+}
+
+// DeclarativeAgent - специализированный агент, описанный в YAML/JSON файле (см. AgentSpec)
+type DeclarativeAgent struct {
+	baseAgent *Agent
+	spec      *AgentSpec
+}
+
+// NewDeclarativeAgent создает агента по описанию spec
+func NewDeclarativeAgent(baseAgent *Agent, spec *AgentSpec) *DeclarativeAgent {
+	return &DeclarativeAgent{
+		baseAgent: baseAgent,
+		spec:      spec,
+	}
+}
+
+// CanHandle оценивает задачу по ключевым словам и похожести на примеры,
+// а контекст страницы - по разрешенным доменам агента
+func (a *DeclarativeAgent) CanHandle(ctx context.Context, task string, pageContext string) (float64, error) {
+	taskLower := strings.ToLower(task)
+	contextLower := strings.ToLower(pageContext)
+
+	taskScore := 0.0
+	for _, keyword := range a.spec.Keywords {
+		if strings.Contains(taskLower, keyword) {
+			taskScore = 0.9
+			break
+		}
+	}
+	if taskScore == 0 {
+		if overlap := bestExampleOverlap(taskLower, a.spec.Examples); overlap >= minExampleOverlap {
+			taskScore = 0.9 * overlap
+		}
+	}
+
+	contextScore := 0.0
+	for _, domain := range a.spec.AllowedDomains {
+		if strings.Contains(contextLower, domain) {
+			contextScore = 0.3
+			break
+		}
+	}
+
+	return taskScore + contextScore, nil
+}
+
+// bestExampleOverlap возвращает наибольшую долю слов примера, встречающихся в задаче.
+func bestExampleOverlap(taskLower string, examples []string) float64 {
+	taskWords := make(map[string]bool)
+	for _, w := range routingWords(taskLower) {
+		taskWords[w] = true
+	}
+
+	best := 0.0
+	for _, example := range examples {
+		words := routingWords(strings.ToLower(example))
+		if len(words) == 0 {
+			continue
+		}
+		matched := 0
+		for _, w := range words {
+			if taskWords[w] {
+				matched++
+			}
+		}
+		best = max(best, float64(matched)/float64(len(words)))
+	}
+	return best
+}
+
+// routingWords разбивает текст на слова длиннее двух символов (предлоги и союзы не учитываются).
+func routingWords(text string) []string {
+	fields := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	words := fields[:0]
+	for _, f := range fields {
+		if len([]rune(f)) > 2 {
+			words = append(words, f)
+		}
+	}
+	return words
+}
+
+// Execute выполняет задачу через базового агента с промптом, действиями, доменами и бюджетом шагов из описания
+func (a *DeclarativeAgent) Execute(ctx context.Context, task *database.Task, maxSteps int) error {
+	return a.baseAgent.executeTaskRecord(a.apply(ctx), task, a.stepBudget(maxSteps))
+}
+
+// apply включает ограничения агента в базовом агенте и возвращает контекст с профилем для запросов к модели
+func (a *DeclarativeAgent) apply(ctx context.Context) context.Context {
+	a.baseAgent.spec = a.spec
+	return llm.WithAgentProfile(ctx, a.spec.profile())
+}
+
+// stepBudget ограничивает бюджет шагов значением max_steps из описания
+func (a *DeclarativeAgent) stepBudget(maxSteps int) int {
+	if a.spec.MaxSteps > 0 && a.spec.MaxSteps < maxSteps {
+		return a.spec.MaxSteps
+	}
+	return maxSteps
+}
+
+// GetExpertise возвращает список экспертиз агента (ключевые слова из описания)
+func (a *DeclarativeAgent) GetExpertise() []string {
+	expertise := slices.Clone(a.spec.Keywords)
+	sort.Strings(expertise)
+	return expertise
+}
+
+// GetType возвращает тип задачи (имя агента из описания)
+func (a *DeclarativeAgent) GetType() TaskType {
+	return TaskType(a.spec.Name)
+}
+
+// GetDescription возвращает описание агента
+func (a *DeclarativeAgent) GetDescription() string {
+	if a.spec.Description != "" {
+		return a.spec.Description
+	}
+	return fmt.Sprintf("Агент %s из %s", a.spec.Name, a.spec.File)
+}
@@ -0,0 +1,115 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"aiAgent/internal/database"
+	"aiAgent/internal/llm"
+
+	"go.uber.org/zap"
+)
+
+// Пробный запуск (dry-run): агент проходит полный цикл Reason -> PlanActionWithReasoning ->
+// SecurityChecker на реальной странице, но не вызывает Click, Type и Navigate.
+// Вместо результата действия в шаг записывается его симуляция ("would click X").
+// Подтверждения и вопросы пользователю не задаются, память, checkpoint и статус задачи не меняются.
+
+// DryRunTask выполняет пробный запуск задачи из БД в отдельной сессии агента.
+// Шаги сохраняются с отметкой dry_run, статус задачи не меняется.
+// Используется базовый ReAct цикл (без multi-step и подагентов), чтобы каждый шаг был виден отдельно.
+// startURL - стартовая страница (--url): на нее выполняется настоящий переход в отдельной сессии,
+// чтобы шаги планировались по реальному снимку страницы, а не по about:blank. Без startURL
+// используется текущая страница общего браузера (команда open); адрес из текста задачи не угадывается.
+func (a *Agent) DryRunTask(ctx context.Context, task *database.Task, startURL string) error {
+	t := a.forTask()
+	t.dryRun = true
+
+	if err := t.stopIfBudgetExceeded(&task.ID, 0, false); err != nil {
+		return err
+	}
+
+	if startURL == "" {
+		if _, _, err := a.browser.GetPageInfo(ctx); err != nil {
+			return errors.New("стартовая страница не задана: укажите --url или откройте страницу командой open")
+		}
+		t.browser = a.browser
+		fmt.Fprintln(outputFrom(ctx), "Пробный запуск на текущей странице браузера")
+		return t.executeDryRun(ctx, task, "")
+	}
+
+	if IsDomainBlocked(startURL) {
+		return fmt.Errorf("стартовая страница %s заблокирована", startURL)
+	}
+	if err := t.browser.Launch(ctx); err != nil {
+		return fmt.Errorf("ошибка запуска браузера: %w", err)
+	}
+	defer t.browser.Close()
+
+	// Переход только открывает страницу - ничего на ней не меняет
+	if err := t.browser.Navigate(ctx, startURL); err != nil {
+		return fmt.Errorf("ошибка перехода на стартовую страницу: %w", err)
+	}
+	fmt.Fprintf(outputFrom(ctx), "Пробный запуск на странице %s\n", startURL)
+	return t.executeDryRun(ctx, task, startURL)
+}
+
+func (a *Agent) executeDryRun(ctx context.Context, task *database.Task, startURL string) error {
+	ctx = llm.WithTaskID(ctx, task.ID)
+	a.log.Info("Пробный запуск задачи", a.contextFields(&task.ID, 0, zap.String("start_url", startURL))...)
+	return a.executeSteps(executeStepsParams{
+		ctx:        ctx,
+		userInput:  task.UserInput,
+		maxSteps:   a.maxSteps,
+		taskID:     &task.ID,
+		saveSteps:  true,
+		updateTask: false,
+	})
+}
+
+// DryRun выполняет пробный запуск задачи, не сохраненной в БД.
+// Если общий браузер уже открыт (команда open), используется его текущая страница,
+// иначе запускается отдельная сессия.
+func (a *Agent) DryRun(ctx context.Context, taskText string, maxSteps int) error {
+	t := a.forTask()
+	t.dryRun = true
+
+	if _, _, err := a.browser.GetPageInfo(ctx); err == nil {
+		t.browser = a.browser
+	} else {
+		if err := t.browser.Launch(ctx); err != nil {
+			return fmt.Errorf("ошибка запуска браузера: %w", err)
+		}
+		defer t.browser.Close()
+	}
+
+	return t.executeTaskString(ctx, taskText, maxSteps)
+}
+
+// simulateAction возвращает симулированный результат действия, меняющего страницу.
+// ok=false - действие не меняет страницу и выполняется как обычно (extract_info).
+func simulateAction(plan *llm.StepPlan) (result string, ok bool) {
+	switch plan.Action {
+	case "navigate":
+		return fmt.Sprintf("would navigate to %s", plan.Value), true
+	case "click":
+		return fmt.Sprintf("would click %s", plan.Selector), true
+	case "type":
+		return fmt.Sprintf("would type '%s' into %s", plan.Value, plan.Selector), true
+	case "ask_user":
+		return fmt.Sprintf("would ask user: %s", plan.Value), true
+	case "takeover":
+		return fmt.Sprintf("would hand over browser to user: %s", plan.Value), true
+	default:
+		return "", false
+	}
+}
+
+// dryRunSecurityNote выводит предупреждение об опасном действии вместо запроса подтверждения.
+func (a *Agent) dryRunSecurityNote(ctx context.Context, plan *llm.StepPlan, stepNo int, llmMessage string) {
+	a.log.Info("Пробный запуск: действие потребует подтверждения", a.contextFields(nil, stepNo,
+		zap.String("action", plan.Action),
+		zap.String("reason", llmMessage))...)
+	fmt.Fprintf(outputFrom(ctx), "[Шаг %d] Потребуется подтверждение пользователя: %s\n", stepNo, llmMessage)
+}
@@ -3,6 +3,8 @@ package agent
 import (
 	"context"
 	"strings"
+
+	"aiAgent/internal/database"
 )
 
 // EmailSpamAgent - специализированный агент для работы с почтой и удаления спама
@@ -57,8 +59,8 @@ func (a *EmailSpamAgent) CanHandle(ctx context.Context, task string, pageContext
 }
 
 // Execute выполняет задачу через базового агента
-func (a *EmailSpamAgent) Execute(ctx context.Context, task string, maxSteps int) error {
-	return a.baseAgent.executeTaskString(ctx, task, maxSteps)
+func (a *EmailSpamAgent) Execute(ctx context.Context, task *database.Task, maxSteps int) error {
+	return a.baseAgent.executeTaskRecord(ctx, task, maxSteps)
 }
 
 // GetExpertise возвращает список экспертиз агента
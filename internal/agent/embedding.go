@@ -0,0 +1,101 @@
+package agent
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// Embedder переводит текст в вектор для семантического поиска по памяти агента.
+// Векторы разных реализаций несовместимы: размерность Dimensions позволяет заметить смену
+// реализации и пересчитать векторы, сохраненные в БД.
+type Embedder interface {
+	Embed(ctx context.Context, text string) ([]float32, error)
+	Dimensions() int
+}
+
+// hashEmbedderDimensions - размерность векторов HashEmbedder.
+const hashEmbedderDimensions = 512
+
+// HashEmbedder - локальный эмбеддер без обращения к внешним сервисам.
+// Текст раскладывается на слова и символьные триграммы слов, признаки хешируются в вектор
+// фиксированной размерности. Триграммы сглаживают словоформы: "удали спам в почте" и
+// "удалить спам из почты" получают близкие векторы, хотя совпадает только слово "спам".
+type HashEmbedder struct{}
+
+// NewHashEmbedder создает локальный эмбеддер
+func NewHashEmbedder() *HashEmbedder {
+	return &HashEmbedder{}
+}
+
+// Embed возвращает нормированный вектор признаков текста (нулевой вектор для текста без слов)
+func (e *HashEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	vector := make([]float32, hashEmbedderDimensions)
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, word := range words {
+		runes := []rune(word)
+		// Короткие служебные слова (предлоги, союзы) почти не несут смысла задачи
+		if len(runes) <= 2 {
+			continue
+		}
+		addFeature(vector, "w:"+word, 1)
+
+		padded := append(append([]rune{'^'}, runes...), '$')
+		for i := 0; i+3 <= len(padded); i++ {
+			addFeature(vector, "t:"+string(padded[i:i+3]), 0.5)
+		}
+	}
+	normalize(vector)
+	return vector, nil
+}
+
+// Dimensions возвращает размерность векторов
+func (e *HashEmbedder) Dimensions() int {
+	return hashEmbedderDimensions
+}
+
+// addFeature добавляет признак в вектор; знак из старшего бита хеша уменьшает искажения от коллизий.
+func addFeature(vector []float32, feature string, weight float32) {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(feature))
+	sum := h.Sum32()
+	if sum&(1<<31) != 0 {
+		weight = -weight
+	}
+	vector[sum%uint32(len(vector))] += weight
+}
+
+func normalize(vector []float32) {
+	var norm float64
+	for _, v := range vector {
+		norm += float64(v) * float64(v)
+	}
+	if norm == 0 {
+		return
+	}
+	scale := float32(1 / math.Sqrt(norm))
+	for i := range vector {
+		vector[i] *= scale
+	}
+}
+
+// cosineSimilarity возвращает косинусную близость векторов; 0 для векторов разной размерности или нулевых.
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(normA*normB)
+}
@@ -0,0 +1,100 @@
+package agent
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+func TestHashEmbedderSimilarity(t *testing.T) {
+	tests := []struct {
+		name     string
+		a, b     string
+		min, max float64
+	}{
+		{"тот же текст", "удали спам в почте", "удали спам в почте", 0.999, 1.001},
+		{"регистр и знаки не важны", "Удали СПАМ в почте!", "удали спам в почте", 0.999, 1.001},
+		{"другие словоформы", "удали спам в почте", "удалить спам из почты", minPathSimilarity, 1},
+		{"близкие формулировки", "найди вакансии go разработчика", "найди вакансию golang разработчик", minPathSimilarity, 1},
+		{"разные задачи", "удали спам в почте", "закажи пиццу с доставкой", -1, 0.2},
+		{"пустой текст", "", "удали спам", 0, 0},
+		{"только короткие слова", "в и на", "удали спам", 0, 0},
+	}
+
+	e := NewHashEmbedder()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := e.Embed(context.Background(), tt.a)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := e.Embed(context.Background(), tt.b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := cosineSimilarity(a, b); got < tt.min || got > tt.max {
+				t.Errorf("близость(%q, %q) = %.3f, ожидалось от %.2f до %.2f", tt.a, tt.b, got, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func TestHashEmbedderVector(t *testing.T) {
+	e := NewHashEmbedder()
+	vector, err := e.Embed(context.Background(), "удали спам в почте")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vector) != e.Dimensions() {
+		t.Fatalf("размерность = %d, ожидалось %d", len(vector), e.Dimensions())
+	}
+	var norm float64
+	for _, v := range vector {
+		norm += float64(v) * float64(v)
+	}
+	if math.Abs(norm-1) > 1e-5 {
+		t.Errorf("норма вектора = %.6f, ожидалась 1", norm)
+	}
+}
+
+func TestCosineSimilarity(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []float32
+		want float64
+	}{
+		{"совпадают", []float32{1, 2, 3}, []float32{1, 2, 3}, 1},
+		{"противоположны", []float32{1, 0}, []float32{-1, 0}, -1},
+		{"ортогональны", []float32{1, 0}, []float32{0, 1}, 0},
+		{"масштаб не важен", []float32{1, 1}, []float32{3, 3}, 1},
+		{"разная размерность", []float32{1, 0}, []float32{1, 0, 0}, 0},
+		{"нулевой вектор", []float32{0, 0}, []float32{1, 0}, 0},
+		{"пустые", nil, nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cosineSimilarity(tt.a, tt.b); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("cosineSimilarity() = %v, ожидалось %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecallFailuresByTask(t *testing.T) {
+	ctx := context.Background()
+	m := NewAgentMemory(nil, 0, nil)
+	if err := m.RecordFailure(ctx, "удали спам в почте", "click", "#delete", ErrorKindElementNotFound, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.RecordFailure(ctx, "закажи пиццу с доставкой", "click", "#order", ErrorKindTimeout, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	recall, err := m.Recall(ctx, "удалить спам из почты", "", DefaultRecallLimit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recall.Failures) != 1 || recall.Failures[0].Selector != "#delete" {
+		t.Errorf("Recall().Failures = %+v, ожидалась ошибка #delete задачи про спам", recall.Failures)
+	}
+}
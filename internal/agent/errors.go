@@ -2,9 +2,12 @@ package agent
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"strings"
-	"time"
+	"net"
+
+	"aiAgent/internal/browser"
+	"aiAgent/internal/llm"
 )
 
 type ErrorType int
@@ -28,8 +31,113 @@ func (e ErrorType) String() string {
 	}
 }
 
+// ErrorKind - вид ошибки. Единая таксономия для повторов, памяти агента (ключи паттернов ошибок)
+// и записей шагов. Вид определяется по типизированным ошибкам браузера и LLM через errors.Is/As,
+// а не по тексту сообщения.
+type ErrorKind string
+
+const (
+	ErrorKindElementNotFound   ErrorKind = "element_not_found"
+	ErrorKindElementNotVisible ErrorKind = "element_not_visible"
+	ErrorKindElementDetached   ErrorKind = "element_detached"
+	ErrorKindInvalidSelector   ErrorKind = "invalid_selector"
+	ErrorKindNavigationTimeout ErrorKind = "navigation_timeout"
+	ErrorKindTimeout           ErrorKind = "timeout"
+	ErrorKindNetwork           ErrorKind = "network"
+	ErrorKindBlockedByPolicy   ErrorKind = "blocked_by_policy"
+	ErrorKindBrowserCrashed    ErrorKind = "browser_crashed"
+	ErrorKindRateLimited       ErrorKind = "rate_limited"
+	ErrorKindContextTooLong    ErrorKind = "context_too_long"
+	ErrorKindMalformedResponse ErrorKind = "malformed_response"
+	ErrorKindCircuitOpen       ErrorKind = "circuit_open"
+	ErrorKindBudgetExceeded    ErrorKind = "budget_exceeded"
+	ErrorKindCanceled          ErrorKind = "canceled"
+	ErrorKindUnknown           ErrorKind = "unknown"
+
+	// Неудачи без ошибки: действие выполнено, но рефлексия не подтвердила цель шага
+	ErrorKindNoEffect    ErrorKind = ErrorKind(llm.VerdictNoEffect)
+	ErrorKindWrongTarget ErrorKind = ErrorKind(llm.VerdictWrongTarget)
+)
+
+// errorKinds - соответствие ошибок браузера и LLM видам. Порядок важен: ошибка может оборачивать
+// несколько (таймаут навигации, упавший браузер при ожидании элемента).
+var errorKinds = []struct {
+	target error
+	kind   ErrorKind
+}{
+	{browser.ErrBrowserCrashed, ErrorKindBrowserCrashed},
+	{browser.ErrNotStarted, ErrorKindBrowserCrashed},
+	{browser.ErrNavigationTimeout, ErrorKindNavigationTimeout},
+	{browser.ErrElementNotFound, ErrorKindElementNotFound},
+	{browser.ErrElementNotVisible, ErrorKindElementNotVisible},
+	{browser.ErrElementDetached, ErrorKindElementDetached},
+	{browser.ErrInvalidSelector, ErrorKindInvalidSelector},
+	{browser.ErrBlockedByPolicy, ErrorKindBlockedByPolicy},
+	{browser.ErrTimeout, ErrorKindTimeout},
+	{llm.ErrRateLimited, ErrorKindRateLimited},
+	{llm.ErrContextTooLong, ErrorKindContextTooLong},
+	{llm.ErrMalformedResponse, ErrorKindMalformedResponse},
+	{ErrCircuitOpen, ErrorKindCircuitOpen},
+	{ErrBudgetExceeded, ErrorKindBudgetExceeded},
+	{context.Canceled, ErrorKindCanceled},
+	{context.DeadlineExceeded, ErrorKindTimeout},
+}
+
+// ErrorKindOf определяет вид ошибки. Ошибки, не относящиеся ни к одному виду, - ErrorKindUnknown.
+func ErrorKindOf(err error) ErrorKind {
+	if err == nil {
+		return ""
+	}
+	for _, k := range errorKinds {
+		if errors.Is(err, k.target) {
+			return k.kind
+		}
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return ErrorKindTimeout
+		}
+		return ErrorKindNetwork
+	}
+	return ErrorKindUnknown
+}
+
+// ParseErrorKind возвращает вид по его имени (например, из записи шага или причины вердикта).
+// Незнакомое имя - ErrorKindUnknown.
+func ParseErrorKind(s string) ErrorKind {
+	kind := ErrorKind(s)
+	switch kind {
+	case ErrorKindNetwork, ErrorKindUnknown, ErrorKindNoEffect, ErrorKindWrongTarget:
+		return kind
+	}
+	for _, k := range errorKinds {
+		if k.kind == kind {
+			return kind
+		}
+	}
+	return ErrorKindUnknown
+}
+
+// Class возвращает класс ошибки вида. Повторяются только ошибки, которые может исправить ожидание:
+// таймауты, сеть, лимиты, элементы, которые еще подгружаются или перерисовываются.
+// Критичные останавливают задачу. Остальные, включая неизвестные, - временные: повтор того же
+// действия не поможет, но модель может выбрать другое.
+func (k ErrorKind) Class() ErrorType {
+	switch k {
+	case ErrorKindTimeout, ErrorKindNavigationTimeout, ErrorKindNetwork, ErrorKindRateLimited,
+		ErrorKindElementNotFound, ErrorKindElementNotVisible, ErrorKindElementDetached, ErrorKindMalformedResponse:
+		return ErrorTypeRetryable
+	case ErrorKindBrowserCrashed, ErrorKindBudgetExceeded, ErrorKindCanceled:
+		return ErrorTypeCritical
+	default:
+		return ErrorTypeTemporary
+	}
+}
+
 type ActionError struct {
 	Type    ErrorType
+	Kind    ErrorKind
 	Action  string
 	Message string
 	Err     error
@@ -48,46 +156,16 @@ func classifyError(action string, err error) *ActionError {
 		return nil
 	}
 
-	errStr := err.Error()
-	msg := err.Error()
-
-	if strings.Contains(errStr, "timeout") ||
-		strings.Contains(errStr, "network") ||
-		strings.Contains(errStr, "connection") ||
-		strings.Contains(errStr, "ECONNREFUSED") ||
-		strings.Contains(errStr, "ETIMEDOUT") {
-		return &ActionError{
-			Type:    ErrorTypeRetryable,
-			Action:  action,
-			Message: msg,
-			Err:     err,
-		}
-	}
-
-	if strings.Contains(errStr, "not found") ||
-		strings.Contains(errStr, "selector") ||
-		strings.Contains(errStr, "element") {
-		return &ActionError{
-			Type:    ErrorTypeTemporary,
-			Action:  action,
-			Message: msg,
-			Err:     err,
-		}
-	}
-
+	kind := ErrorKindOf(err)
 	return &ActionError{
-		Type:    ErrorTypeCritical,
+		Type:    kind.Class(),
+		Kind:    kind,
 		Action:  action,
-		Message: msg,
+		Message: err.Error(),
 		Err:     err,
 	}
 }
 
-func retryAction(ctx context.Context, maxRetries int, baseDelay time.Duration, fn func() error) error {
-	return RetryWithExponentialBackoff(ctx, maxRetries, baseDelay, fn)
-}
-
 func isCriticalError(err error) bool {
-	actionErr := classifyError("", err)
-	return actionErr.Type == ErrorTypeCritical
+	return ErrorKindOf(err).Class() == ErrorTypeCritical
 }
@@ -0,0 +1,97 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"aiAgent/internal/browser"
+	"aiAgent/internal/llm"
+)
+
+// netError - сетевая ошибка с признаком таймаута.
+type netError struct{ timeout bool }
+
+func (e netError) Error() string   { return "dial tcp: connection refused" }
+func (e netError) Timeout() bool   { return e.timeout }
+func (e netError) Temporary() bool { return false }
+
+var _ net.Error = netError{}
+
+func TestErrorKindOf(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want ErrorKind
+	}{
+		{"нет ошибки", nil, ""},
+		{"элемент не найден", fmt.Errorf("клик: %w", browser.ErrElementNotFound), ErrorKindElementNotFound},
+		{"таймаут навигации важнее таймаута", fmt.Errorf("%w: %w", browser.ErrNavigationTimeout, browser.ErrTimeout), ErrorKindNavigationTimeout},
+		{"упавший браузер при ожидании элемента", fmt.Errorf("%w: %w", browser.ErrElementNotFound, browser.ErrBrowserCrashed), ErrorKindBrowserCrashed},
+		{"браузер не запущен", browser.ErrNotStarted, ErrorKindBrowserCrashed},
+		{"лимит LLM", fmt.Errorf("%w: 429", llm.ErrRateLimited), ErrorKindRateLimited},
+		{"предохранитель", &CircuitOpenError{Key: "llm"}, ErrorKindCircuitOpen},
+		{"отмена", fmt.Errorf("шаг: %w", context.Canceled), ErrorKindCanceled},
+		{"дедлайн", context.DeadlineExceeded, ErrorKindTimeout},
+		{"сеть", &net.OpError{Op: "dial", Err: netError{}}, ErrorKindNetwork},
+		{"сетевой таймаут", netError{timeout: true}, ErrorKindTimeout},
+		{"текст не разбирается", errors.New("element not found"), ErrorKindUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ErrorKindOf(tt.err); got != tt.want {
+				t.Errorf("ErrorKindOf(%v) = %q, ожидалось %q", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestErrorKindClass(t *testing.T) {
+	tests := []struct {
+		kind ErrorKind
+		want ErrorType
+	}{
+		{ErrorKindTimeout, ErrorTypeRetryable},
+		{ErrorKindNetwork, ErrorTypeRetryable},
+		{ErrorKindElementNotFound, ErrorTypeRetryable},
+		{ErrorKindMalformedResponse, ErrorTypeRetryable},
+		{ErrorKindBrowserCrashed, ErrorTypeCritical},
+		{ErrorKindBudgetExceeded, ErrorTypeCritical},
+		{ErrorKindCanceled, ErrorTypeCritical},
+		{ErrorKindInvalidSelector, ErrorTypeTemporary},
+		{ErrorKindCircuitOpen, ErrorTypeTemporary},
+		{ErrorKindNoEffect, ErrorTypeTemporary},
+		{ErrorKindUnknown, ErrorTypeTemporary},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.kind), func(t *testing.T) {
+			if got := tt.kind.Class(); got != tt.want {
+				t.Errorf("%s.Class() = %s, ожидалось %s", tt.kind, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrorKind(t *testing.T) {
+	tests := []struct {
+		name string
+		want ErrorKind
+	}{
+		{"timeout", ErrorKindTimeout},
+		{"network", ErrorKindNetwork},
+		{"no_effect", ErrorKindNoEffect},
+		{"wrong_target", ErrorKindWrongTarget},
+		{"canceled", ErrorKindCanceled},
+		{"unknown", ErrorKindUnknown},
+		{"что-то другое", ErrorKindUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseErrorKind(tt.name); got != tt.want {
+				t.Errorf("ParseErrorKind(%q) = %q, ожидалось %q", tt.name, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"aiAgent/internal/database"
+
+	"go.uber.org/zap"
+)
+
+// Цепочка fallback: если специализированный агент не справился (критичная ошибка, лимит шагов,
+// зацикливание), задача передается следующему кандидату из ранжирования роутера, а в конце -
+// общему циклу шагов без профиля агента. Браузер между попытками не перезапускается:
+// следующая попытка продолжает с той страницы, на которой остановилась предыдущая.
+// Каждая попытка сохраняется в task_attempts.
+
+// Статусы попыток
+const (
+	AttemptRunning     = "running"
+	AttemptCompleted   = "completed"
+	AttemptFailed      = "failed"
+	AttemptInterrupted = "interrupted"
+)
+
+// Chain возвращает агентов в порядке попыток: выбранный агент, затем остальные кандидаты
+// с уверенностью не ниже minConfidence.
+func (d *RoutingDecision) Chain(minConfidence float64) []SpecializedAgent {
+	chain := []SpecializedAgent{d.Agent}
+	for _, candidate := range d.Ranking {
+		if candidate.Confidence < minConfidence || candidate.Agent.GetType() == d.Agent.GetType() {
+			continue
+		}
+		chain = append(chain, candidate.Agent)
+	}
+	return chain
+}
+
+// canFallback проверяет, имеет ли смысл передавать задачу следующему агенту.
+// Отмена задачи и исчерпание бюджета LLM останавливают цепочку: следующая попытка тоже не выполнится.
+func canFallback(err error) bool {
+	return !errors.Is(err, context.Canceled) &&
+		!errors.Is(err, context.DeadlineExceeded) &&
+		!errors.Is(err, ErrBudgetExceeded)
+}
+
+// executeWithFallback выполняет задачу агентами из решения маршрутизации по очереди,
+// пока одна из попыток не завершится успешно. decision == nil - сразу общий цикл шагов.
+func (a *Agent) executeWithFallback(ctx context.Context, task *database.Task, decision *RoutingDecision) error {
+	if err := a.repo.DeleteAttempts(task.ID); err != nil {
+		a.log.Warn("Ошибка удаления попыток предыдущего запуска", a.contextFields(&task.ID, 0, zap.Error(err))...)
+	}
+
+	var chain []SpecializedAgent
+	if decision != nil {
+		chain = decision.Chain(a.router.confidenceMin)
+	}
+
+	var lastErr error
+	for i := 0; i <= len(chain); i++ {
+		// После специализированных агентов задачу получает общий цикл шагов
+		name := string(TaskTypeGeneral)
+		run := func(ctx context.Context) error {
+			return a.executeTaskRecord(ctx, task, a.maxSteps)
+		}
+		if i < len(chain) {
+			agent := chain[i]
+			name = string(agent.GetType())
+			run = func(ctx context.Context) error {
+				return agent.Execute(ctx, task, a.maxSteps)
+			}
+		}
+
+		if i > 0 {
+			a.log.Warn("Агент не справился с задачей, передаем следующему", a.contextFields(&task.ID, a.stepOffset,
+				zap.String("next_agent", name),
+				zap.Error(lastErr))...)
+			fmt.Fprintf(outputFrom(ctx), "[Fallback] Задача передана агенту %s: %v\n", name, lastErr)
+		}
+
+		err := a.runAttempt(ctx, task, i+1, name, run)
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+		if !canFallback(err) {
+			return err
+		}
+	}
+	return lastErr
+}
+
+// runAttempt выполняет одну попытку и сохраняет ее в task_attempts.
+// Шаги попытки нумеруются после последнего шага предыдущей, профиль предыдущего агента сбрасывается.
+func (a *Agent) runAttempt(ctx context.Context, task *database.Task, attemptNo int, agentName string, run func(ctx context.Context) error) error {
+	a.spec = nil
+	attempt := &database.TaskAttempt{
+		TaskID:    task.ID,
+		AttemptNo: attemptNo,
+		Agent:     agentName,
+		FirstStep: a.stepOffset + 1,
+		Status:    AttemptRunning,
+		StartedAt: time.Now(),
+	}
+	if err := a.repo.CreateAttempt(attempt); err != nil {
+		a.log.Warn("Ошибка сохранения попытки", a.contextFields(&task.ID, 0, zap.Error(err))...)
+	}
+
+	err := run(ctx)
+	a.finishAttempt(attempt, err)
+	return err
+}
+
+// finishAttempt сохраняет итог попытки и сдвигает нумерацию шагов для следующей.
+func (a *Agent) finishAttempt(attempt *database.TaskAttempt, err error) {
+	lastStep, stepErr := a.repo.LastStepNoSince(attempt.TaskID, attempt.StartedAt)
+	if stepErr != nil {
+		a.log.Warn("Ошибка получения последнего шага попытки", a.contextFields(&attempt.TaskID, 0, zap.Error(stepErr))...)
+	}
+	attempt.LastStep = lastStep
+	a.stepOffset = max(a.stepOffset, lastStep)
+
+	switch {
+	case err == nil:
+		attempt.Status = AttemptCompleted
+	case errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded):
+		attempt.Status = AttemptInterrupted
+		attempt.Error = err.Error()
+	default:
+		attempt.Status = AttemptFailed
+		attempt.Error = err.Error()
+	}
+	now := time.Now()
+	attempt.FinishedAt = &now
+
+	if attempt.ID == 0 {
+		return
+	}
+	if err := a.repo.UpdateAttempt(attempt); err != nil {
+		a.log.Warn("Ошибка сохранения итога попытки", a.contextFields(&attempt.TaskID, 0, zap.Error(err))...)
+	}
+}
+
+// resumeAttempt восстанавливает прерванную попытку перед возобновлением задачи:
+// нумерацию шагов и ограничения декларативного агента. Возвращает контекст и номер последнего шага.
+// Если прерванной попытки нет, возвращает nil и общий лимит шагов.
+func (a *Agent) resumeAttempt(ctx context.Context, taskID uint) (context.Context, *database.TaskAttempt, int) {
+	attempts, err := a.repo.ListAttempts(taskID)
+	if err != nil || len(attempts) == 0 {
+		return ctx, nil, a.maxSteps
+	}
+	attempt := &attempts[len(attempts)-1]
+	if attempt.Status != AttemptInterrupted && attempt.Status != AttemptRunning {
+		return ctx, nil, a.maxSteps
+	}
+
+	a.stepOffset = attempt.FirstStep - 1
+	budget := a.maxSteps
+	if a.router != nil {
+		if agent, ok := a.router.agents[TaskType(attempt.Agent)].(*DeclarativeAgent); ok {
+			ctx = agent.apply(ctx)
+			budget = agent.stepBudget(budget)
+		}
+	}
+
+	attempt.Status = AttemptRunning
+	attempt.Error = ""
+	attempt.FinishedAt = nil
+	if err := a.repo.UpdateAttempt(attempt); err != nil {
+		a.log.Warn("Ошибка обновления попытки", a.contextFields(&taskID, 0, zap.Error(err))...)
+	}
+	return ctx, attempt, a.stepOffset + budget
+}
@@ -3,6 +3,8 @@ package agent
 import (
 	"context"
 	"strings"
+
+	"aiAgent/internal/database"
 )
 
 // FoodDeliveryAgent - специализированный агент для оформления заказов на доставку еды
@@ -59,8 +61,8 @@ func (a *FoodDeliveryAgent) CanHandle(ctx context.Context, task string, pageCont
 }
 
 // Execute выполняет задачу через базового агента
-func (a *FoodDeliveryAgent) Execute(ctx context.Context, task string, maxSteps int) error {
-	return a.baseAgent.executeTaskString(ctx, task, maxSteps)
+func (a *FoodDeliveryAgent) Execute(ctx context.Context, task *database.Task, maxSteps int) error {
+	return a.baseAgent.executeTaskRecord(ctx, task, maxSteps)
 }
 
 // GetExpertise возвращает список экспертиз агента
@@ -3,6 +3,8 @@ package agent
 import (
 	"context"
 	"strings"
+
+	"aiAgent/internal/database"
 )
 
 // JobSearchAgent - специализированный агент для поиска вакансий и отправки откликов
@@ -59,8 +61,8 @@ func (a *JobSearchAgent) CanHandle(ctx context.Context, task string, pageContext
 }
 
 // Execute выполняет задачу через базового агента
-func (a *JobSearchAgent) Execute(ctx context.Context, task string, maxSteps int) error {
-	return a.baseAgent.executeTaskString(ctx, task, maxSteps)
+func (a *JobSearchAgent) Execute(ctx context.Context, task *database.Task, maxSteps int) error {
+	return a.baseAgent.executeTaskRecord(ctx, task, maxSteps)
 }
 
 // GetExpertise возвращает список экспертиз агента
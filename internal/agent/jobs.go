@@ -0,0 +1,389 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// JobStatus - состояние фоновой задачи.
+type JobStatus string
+
+const (
+	JobQueued    JobStatus = "queued"    // Задача ждет свободного воркера
+	JobRunning   JobStatus = "running"   // Задача выполняется
+	JobCompleted JobStatus = "completed" // Задача завершена успешно
+	JobFailed    JobStatus = "failed"    // Задача завершилась ошибкой
+	JobCanceled  JobStatus = "canceled"  // Задача отменена пользователем
+)
+
+// defaultJobOutputLines - сколько последних строк вывода хранится для attach.
+const defaultJobOutputLines = 500
+
+// ErrJobAlreadyRunning возвращается при попытке запустить задачу, которая уже выполняется.
+var ErrJobAlreadyRunning = errors.New("задача уже выполняется")
+
+// Job представляет задачу, выполняющуюся в фоновой горутине.
+type Job struct {
+	TaskID     uint
+	QueuedAt   time.Time
+	Output     *JobOutput
+	mu         sync.RWMutex
+	status     JobStatus
+	startedAt  time.Time
+	finishedAt time.Time
+	err        error
+	cancel     context.CancelFunc
+	done       chan struct{}
+	takeover   bool // Пользователь запросил управление браузером
+}
+
+// Status возвращает текущее состояние задачи.
+func (j *Job) Status() JobStatus {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	return j.status
+}
+
+// active возвращает true если задача ждет в очереди или выполняется.
+func (j *Job) active() bool {
+	status := j.Status()
+	return status == JobQueued || status == JobRunning
+}
+
+func (j *Job) setRunning() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.status = JobRunning
+	j.startedAt = time.Now()
+}
+
+// Err возвращает ошибку завершения задачи (nil пока задача выполняется или при успехе).
+func (j *Job) Err() error {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	return j.err
+}
+
+// StartedAt возвращает время начала выполнения (нулевое пока задача в очереди).
+func (j *Job) StartedAt() time.Time {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	return j.startedAt
+}
+
+// FinishedAt возвращает время завершения задачи (нулевое пока задача выполняется).
+func (j *Job) FinishedAt() time.Time {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	return j.finishedAt
+}
+
+// Done возвращает канал, который закрывается после завершения задачи.
+func (j *Job) Done() <-chan struct{} {
+	return j.done
+}
+
+// Cancel отменяет контекст задачи.
+func (j *Job) Cancel() {
+	j.cancel()
+}
+
+// RequestTakeover просит агента передать управление браузером пользователю перед следующим шагом.
+func (j *Job) RequestTakeover() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.takeover = true
+}
+
+// takeTakeover возвращает true и сбрасывает запрос, если пользователь запросил управление.
+func (j *Job) takeTakeover() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	requested := j.takeover
+	j.takeover = false
+	return requested
+}
+
+// setResult фиксирует итог задачи. canceled - контекст задачи был отменен
+// (через Cancel или при завершении приложения).
+func (j *Job) setResult(err error, canceled bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	switch {
+	case err == nil:
+		j.status = JobCompleted
+	case canceled || errors.Is(err, context.Canceled):
+		j.status = JobCanceled
+	default:
+		j.status = JobFailed
+	}
+	j.err = err
+	j.finishedAt = time.Now()
+}
+
+// close закрывает подписки на вывод и сигнализирует о завершении задачи.
+func (j *Job) close() {
+	j.Output.closeSubscribers()
+	close(j.done)
+}
+
+// JobOutput - потокобезопасный буфер вывода фоновой задачи.
+// Хранит последние строки для просмотра через attach и рассылает новые строки подписчикам.
+type JobOutput struct {
+	mu          sync.Mutex
+	lines       []string
+	maxLines    int
+	partial     bytes.Buffer
+	subscribers map[int]chan string
+	nextID      int
+	closed      bool
+}
+
+// NewJobOutput создает буфер вывода, хранящий не более maxLines последних строк.
+func NewJobOutput(maxLines int) *JobOutput {
+	if maxLines <= 0 {
+		maxLines = defaultJobOutputLines
+	}
+	return &JobOutput{
+		maxLines:    maxLines,
+		subscribers: make(map[int]chan string),
+	}
+}
+
+// Write реализует io.Writer: разбивает вывод на строки и рассылает их подписчикам.
+func (o *JobOutput) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.partial.Write(p)
+	for {
+		line, err := o.partial.ReadString('\n')
+		if err != nil {
+			// Неполная строка остается в буфере до следующей записи
+			o.partial.Reset()
+			o.partial.WriteString(line)
+			break
+		}
+		o.appendLine(line)
+	}
+	return len(p), nil
+}
+
+func (o *JobOutput) appendLine(line string) {
+	o.lines = append(o.lines, line)
+	if len(o.lines) > o.maxLines {
+		o.lines = o.lines[len(o.lines)-o.maxLines:]
+	}
+	for _, ch := range o.subscribers {
+		select {
+		case ch <- line:
+		default:
+			// Медленный подписчик пропускает строку, чтобы не блокировать агента
+		}
+	}
+}
+
+// Subscribe возвращает накопленные строки и канал новых строк.
+// Канал закрывается по завершении задачи или после вызова unsubscribe.
+func (o *JobOutput) Subscribe() (history []string, lines <-chan string, unsubscribe func()) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	history = append([]string(nil), o.lines...)
+	ch := make(chan string, 100)
+	if o.closed {
+		close(ch)
+		return history, ch, func() {}
+	}
+
+	id := o.nextID
+	o.nextID++
+	o.subscribers[id] = ch
+
+	return history, ch, func() {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		if sub, ok := o.subscribers[id]; ok {
+			delete(o.subscribers, id)
+			close(sub)
+		}
+	}
+}
+
+func (o *JobOutput) closeSubscribers() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.partial.Len() > 0 {
+		o.appendLine(o.partial.String() + "\n")
+		o.partial.Reset()
+	}
+	for id, ch := range o.subscribers {
+		delete(o.subscribers, id)
+		close(ch)
+	}
+	o.closed = true
+}
+
+// JobManager - пул воркеров для задач, выполняющихся в фоне.
+// Одновременно выполняется не более workers задач, остальные ждут в очереди.
+// Каждая задача получает собственный отменяемый контекст и буфер вывода.
+type JobManager struct {
+	mu      sync.RWMutex
+	jobs    map[uint]*Job
+	slots   chan struct{}
+	workers int
+}
+
+// NewJobManager создает пул из workers воркеров (минимум 1).
+func NewJobManager(workers int) *JobManager {
+	if workers <= 0 {
+		workers = 1
+	}
+	return &JobManager{
+		jobs:    make(map[uint]*Job),
+		slots:   make(chan struct{}, workers),
+		workers: workers,
+	}
+}
+
+// Workers возвращает размер пула.
+func (m *JobManager) Workers() int {
+	return m.workers
+}
+
+// Start ставит run в очередь пула; задача начинает выполняться, когда освобождается воркер.
+// run получает контекст, отменяемый через Cancel, с выводом, направленным в буфер задачи,
+// и запросами передачи управления (Takeover).
+// onDone вызывается в той же горутине после завершения run, но до закрытия Done (может быть nil).
+func (m *JobManager) Start(parent context.Context, taskID uint, run func(ctx context.Context) error, onDone func(job *Job)) (*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if existing, ok := m.jobs[taskID]; ok && existing.active() {
+		return nil, fmt.Errorf("задача #%d: %w", taskID, ErrJobAlreadyRunning)
+	}
+
+	ctx, cancel := context.WithCancel(parent)
+	job := &Job{
+		TaskID:   taskID,
+		QueuedAt: time.Now(),
+		Output:   NewJobOutput(defaultJobOutputLines),
+		status:   JobQueued,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+	m.jobs[taskID] = job
+
+	// Свободный воркер занимается сразу, чтобы статус был известен вызывающему
+	acquired := false
+	select {
+	case m.slots <- struct{}{}:
+		acquired = true
+		job.setRunning()
+	default:
+	}
+
+	go func() {
+		defer cancel()
+
+		if !acquired {
+			select {
+			case m.slots <- struct{}{}:
+				acquired = true
+				job.setRunning()
+			case <-ctx.Done():
+				// Отменена пока ждала в очереди
+			}
+		}
+
+		var err error
+		if acquired {
+			err = run(WithOutput(withJob(ctx, job), job.Output))
+			<-m.slots
+		} else {
+			err = ctx.Err()
+		}
+
+		job.setResult(err, ctx.Err() != nil)
+		if onDone != nil {
+			onDone(job)
+		}
+		job.close()
+	}()
+
+	return job, nil
+}
+
+// Get возвращает задачу по ID.
+func (m *JobManager) Get(taskID uint) (*Job, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	job, ok := m.jobs[taskID]
+	return job, ok
+}
+
+// List возвращает все известные задачи, отсортированные по времени постановки в очередь.
+func (m *JobManager) List() []*Job {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	jobs := make([]*Job, 0, len(m.jobs))
+	for _, job := range m.jobs {
+		jobs = append(jobs, job)
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].QueuedAt.Before(jobs[j].QueuedAt)
+	})
+	return jobs
+}
+
+// Cancel отменяет выполняющуюся или ожидающую в очереди задачу.
+func (m *JobManager) Cancel(taskID uint) error {
+	job, ok := m.Get(taskID)
+	if !ok {
+		return fmt.Errorf("фоновая задача #%d не найдена", taskID)
+	}
+	if !job.active() {
+		return fmt.Errorf("задача #%d не выполняется (%s)", taskID, job.Status())
+	}
+	job.Cancel()
+	return nil
+}
+
+// Takeover запрашивает передачу управления браузером пользователю.
+// Агент остановится перед следующим шагом и будет ждать Enter в консоли.
+func (m *JobManager) Takeover(taskID uint) error {
+	job, ok := m.Get(taskID)
+	if !ok {
+		return fmt.Errorf("фоновая задача #%d не найдена", taskID)
+	}
+	if !job.active() {
+		return fmt.Errorf("задача #%d не выполняется (%s)", taskID, job.Status())
+	}
+	job.RequestTakeover()
+	return nil
+}
+
+// Shutdown отменяет все выполняющиеся и ожидающие задачи и ждет их завершения не дольше timeout.
+func (m *JobManager) Shutdown(timeout time.Duration) {
+	jobs := m.List()
+	for _, job := range jobs {
+		if job.active() {
+			job.Cancel()
+		}
+	}
+
+	deadline := time.After(timeout)
+	for _, job := range jobs {
+		select {
+		case <-job.Done():
+		case <-deadline:
+			return
+		}
+	}
+}
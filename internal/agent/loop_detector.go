@@ -0,0 +1,241 @@
+package agent
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"aiAgent/internal/database"
+	"aiAgent/internal/llm"
+
+	"go.uber.org/zap"
+)
+
+// ErrLoopDetected возвращается когда агент зациклился и пользователь не помог выйти из цикла.
+var ErrLoopDetected = errors.New("агент зациклился")
+
+// LoopKind - тип обнаруженного зацикливания.
+type LoopKind string
+
+const (
+	LoopRepeatedAction LoopKind = "repeated_action" // Одно и то же действие/селектор/значение несколько раз подряд
+	LoopOscillation    LoopKind = "oscillation"     // Переходы туда-обратно между двумя страницами
+	LoopNoProgress     LoopKind = "no_progress"     // Страница не меняется несколько шагов подряд
+)
+
+// LoopEscalation - реакция агента на зацикливание. Уровень растет с каждым обнаружением.
+type LoopEscalation string
+
+const (
+	EscalateObserve LoopEscalation = "observe"  // Сообщить модели, что она зациклилась
+	EscalateReplan  LoopEscalation = "replan"   // Сбросить текущую стратегию и спланировать заново
+	EscalateAskUser LoopEscalation = "ask_user" // Спросить пользователя, как продолжить
+)
+
+var loopEscalations = []LoopEscalation{EscalateObserve, EscalateReplan, EscalateAskUser}
+
+const (
+	defaultRepeatThreshold     = 3 // Сколько одинаковых действий подряд считается зацикливанием
+	defaultStagnationThreshold = 3 // Сколько шагов без изменения страницы подряд считается застоем
+	loopHistorySize            = 8
+)
+
+// LoopDetection - результат обнаружения зацикливания.
+type LoopDetection struct {
+	Kind       LoopKind
+	Escalation LoopEscalation
+	Detail     string
+}
+
+// Observation возвращает текст наблюдения для модели.
+func (d *LoopDetection) Observation() string {
+	switch d.Escalation {
+	case EscalateReplan:
+		return fmt.Sprintf("ВНИМАНИЕ: агент повторно зациклился (%s). Текущая стратегия не работает - выработай НОВУЮ стратегию и не повторяй предыдущие действия.", d.Detail)
+	default:
+		return fmt.Sprintf("ВНИМАНИЕ: похоже, агент зациклился (%s). Попробуй другой подход: другой элемент, другую страницу или другое действие.", d.Detail)
+	}
+}
+
+// loopObservation - одно наблюдение детектора.
+type loopObservation struct {
+	key       string // action|selector|value
+	url       string
+	unchanged bool // Отпечаток страницы не изменился после действия
+}
+
+// LoopDetector отслеживает повторяющиеся действия, осцилляцию между страницами
+// и шаги без изменения страницы. Каждое обнаружение повышает уровень эскалации:
+// observe -> replan -> ask_user. После ответа пользователя уровень сбрасывается.
+type LoopDetector struct {
+	history             []loopObservation
+	level               int
+	repeatThreshold     int
+	stagnationThreshold int
+}
+
+// NewLoopDetector создает детектор с порогами по умолчанию.
+func NewLoopDetector() *LoopDetector {
+	return &LoopDetector{
+		repeatThreshold:     defaultRepeatThreshold,
+		stagnationThreshold: defaultStagnationThreshold,
+	}
+}
+
+// PageFingerprint вычисляет отпечаток страницы по URL, заголовку и контексту страницы.
+func PageFingerprint(url, title, pageContext string) string {
+	h := sha1.New()
+	h.Write([]byte(url))
+	h.Write([]byte{0})
+	h.Write([]byte(title))
+	h.Write([]byte{0})
+	h.Write([]byte(pageContext))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Observe регистрирует выполненный шаг и возвращает обнаруженное зацикливание (или nil).
+// fingerprintBefore/fingerprintAfter - отпечатки страницы до и после действия.
+func (d *LoopDetector) Observe(plan *llm.StepPlan, urlAfter, fingerprintBefore, fingerprintAfter string) *LoopDetection {
+	obs := loopObservation{
+		key:       loopKey(plan),
+		url:       urlAfter,
+		unchanged: fingerprintBefore != "" && fingerprintBefore == fingerprintAfter && changesPage(plan.Action),
+	}
+	d.history = append(d.history, obs)
+	if len(d.history) > loopHistorySize {
+		d.history = d.history[len(d.history)-loopHistorySize:]
+	}
+
+	kind, detail := d.detect()
+	if kind == "" {
+		return nil
+	}
+
+	escalation := loopEscalations[min(d.level, len(loopEscalations)-1)]
+	d.level++
+	// Для следующего обнаружения нужны новые повторы
+	d.history = nil
+
+	return &LoopDetection{Kind: kind, Escalation: escalation, Detail: detail}
+}
+
+// Reset сбрасывает уровень эскалации (например, после подсказки пользователя).
+func (d *LoopDetector) Reset() {
+	d.level = 0
+	d.history = nil
+}
+
+func (d *LoopDetector) detect() (LoopKind, string) {
+	n := len(d.history)
+
+	if n >= d.repeatThreshold {
+		last := d.history[n-1].key
+		repeated := true
+		for _, obs := range d.history[n-d.repeatThreshold:] {
+			if obs.key != last {
+				repeated = false
+				break
+			}
+		}
+		if repeated {
+			return LoopRepeatedAction, fmt.Sprintf("действие %s повторено %d раз подряд", last, d.repeatThreshold)
+		}
+	}
+
+	if n >= 4 {
+		a, b, c, e := d.history[n-4].url, d.history[n-3].url, d.history[n-2].url, d.history[n-1].url
+		if a != "" && b != "" && a != b && a == c && b == e {
+			return LoopOscillation, fmt.Sprintf("переходы туда-обратно между %s и %s", a, b)
+		}
+	}
+
+	if n >= d.stagnationThreshold {
+		stagnant := true
+		for _, obs := range d.history[n-d.stagnationThreshold:] {
+			if !obs.unchanged {
+				stagnant = false
+				break
+			}
+		}
+		if stagnant {
+			return LoopNoProgress, fmt.Sprintf("страница не меняется %d шагов подряд", d.stagnationThreshold)
+		}
+	}
+
+	return "", ""
+}
+
+func loopKey(plan *llm.StepPlan) string {
+	parts := []string{plan.Action}
+	if plan.Selector != "" {
+		parts = append(parts, plan.Selector)
+	}
+	if plan.Value != "" {
+		parts = append(parts, plan.Value)
+	}
+	return strings.Join(parts, "|")
+}
+
+// changesPage возвращает true для действий, которые должны менять страницу.
+// extract_info и ask_user страницу не меняют, а при takeover страницу меняет пользователь,
+// поэтому застоем они не считаются.
+func changesPage(action string) bool {
+	switch action {
+	case "extract_info", "ask_user", "wait", "takeover":
+		return false
+	default:
+		return true
+	}
+}
+
+// handleLoop выполняет эскалацию обнаруженного зацикливания и возвращает наблюдение для модели.
+// На уровне replan сбрасывается история рассуждений, чтобы модель выработала новую стратегию.
+// На уровне ask_user задается вопрос пользователю; без провайдера ввода или при пустом ответе
+// возвращается ошибка, оборачивающая ErrLoopDetected.
+func (a *Agent) handleLoop(ctx context.Context, taskID *uint, stepNo int, d *LoopDetection) (string, error) {
+	a.log.Warn("Обнаружено зацикливание", a.contextFields(taskID, stepNo,
+		zap.String("kind", string(d.Kind)),
+		zap.String("escalation", string(d.Escalation)),
+		zap.String("detail", d.Detail))...)
+	fmt.Fprintf(outputFrom(ctx), "[Шаг %d] Зацикливание: %s (%s)\n", stepNo, d.Detail, d.Escalation)
+
+	switch d.Escalation {
+	case EscalateObserve:
+		return d.Observation(), nil
+	case EscalateReplan:
+		a.reasoningHistory = &llm.ReasoningHistory{}
+		return d.Observation(), nil
+	}
+
+	// При пробном запуске вопросы пользователю не задаются
+	if a.userInputProvider == nil || a.dryRun {
+		return "", fmt.Errorf("%w: %s", ErrLoopDetected, d.Detail)
+	}
+
+	question := fmt.Sprintf("Агент зациклился: %s. Подскажите, как продолжить (пустой ответ - остановить задачу):", d.Detail)
+	answer, err := a.userInputProvider.AskUser(ctx, question)
+	if err != nil {
+		return "", fmt.Errorf("ошибка запроса подсказки пользователя: %w", err)
+	}
+	answer = strings.TrimSpace(answer)
+	if answer == "" {
+		return "", fmt.Errorf("%w: %s", ErrLoopDetected, d.Detail)
+	}
+
+	a.loopDetector.Reset()
+	a.reasoningHistory = &llm.ReasoningHistory{}
+	return fmt.Sprintf("Агент зациклился (%s). Подсказка пользователя: %s", d.Detail, answer), nil
+}
+
+// applyLoopDetection переносит обнаруженное зацикливание в запись шага.
+func (a *Agent) applyLoopDetection(step *database.AgentStep, d *LoopDetection) {
+	if d == nil {
+		return
+	}
+	step.LoopKind = string(d.Kind)
+	step.LoopEscalation = string(d.Escalation)
+	step.LoopDetail = a.sanitizer.Sanitize(d.Detail)
+}
@@ -0,0 +1,127 @@
+package agent
+
+import (
+	"testing"
+
+	"aiAgent/internal/llm"
+)
+
+// loopStep - шаг для детектора: действие и отпечатки страницы до и после него.
+type loopStep struct {
+	plan   llm.StepPlan
+	url    string
+	before string
+	after  string
+}
+
+func TestLoopDetectorDetect(t *testing.T) {
+	click := llm.StepPlan{Action: "click", Selector: "#next"}
+	extract := llm.StepPlan{Action: "extract_info"}
+
+	tests := []struct {
+		name  string
+		steps []loopStep
+		want  LoopKind // Ожидается на последнем шаге; на предыдущих - ничего
+	}{
+		{
+			name: "одно действие три раза",
+			steps: []loopStep{
+				{click, "https://a.ru/1", "p1", "p2"},
+				{click, "https://a.ru/2", "p2", "p3"},
+				{click, "https://a.ru/3", "p3", "p4"},
+			},
+			want: LoopRepeatedAction,
+		},
+		{
+			name: "разные значения ввода - не повтор",
+			steps: []loopStep{
+				{llm.StepPlan{Action: "type", Selector: "#q", Value: "go"}, "https://a.ru", "p1", "p2"},
+				{llm.StepPlan{Action: "type", Selector: "#q", Value: "rust"}, "https://a.ru", "p2", "p3"},
+				{llm.StepPlan{Action: "type", Selector: "#q", Value: "zig"}, "https://a.ru", "p3", "p4"},
+			},
+		},
+		{
+			name: "туда-обратно между страницами",
+			steps: []loopStep{
+				{llm.StepPlan{Action: "click", Selector: "#a"}, "https://a.ru/list", "p1", "p2"},
+				{llm.StepPlan{Action: "click", Selector: "#b"}, "https://a.ru/item", "p2", "p3"},
+				{llm.StepPlan{Action: "click", Selector: "#c"}, "https://a.ru/list", "p3", "p4"},
+				{llm.StepPlan{Action: "click", Selector: "#d"}, "https://a.ru/item", "p4", "p5"},
+			},
+			want: LoopOscillation,
+		},
+		{
+			name: "страница не меняется",
+			steps: []loopStep{
+				{llm.StepPlan{Action: "click", Selector: "#a"}, "https://a.ru", "p1", "p1"},
+				{llm.StepPlan{Action: "click", Selector: "#b"}, "https://a.ru", "p1", "p1"},
+				{llm.StepPlan{Action: "click", Selector: "#c"}, "https://a.ru", "p1", "p1"},
+			},
+			want: LoopNoProgress,
+		},
+		{
+			name: "извлечение не считается застоем",
+			steps: []loopStep{
+				{llm.StepPlan{Action: "click", Selector: "#a"}, "https://a.ru", "p1", "p1"},
+				{extract, "https://a.ru", "p1", "p1"},
+				{llm.StepPlan{Action: "click", Selector: "#b"}, "https://a.ru", "p1", "p1"},
+			},
+		},
+		{
+			name: "без отпечатков застой не определяется",
+			steps: []loopStep{
+				{llm.StepPlan{Action: "click", Selector: "#a"}, "", "", ""},
+				{llm.StepPlan{Action: "click", Selector: "#b"}, "", "", ""},
+				{llm.StepPlan{Action: "click", Selector: "#c"}, "", "", ""},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewLoopDetector()
+			var got *LoopDetection
+			for i, step := range tt.steps {
+				got = d.Observe(&step.plan, step.url, step.before, step.after)
+				if i < len(tt.steps)-1 && got != nil {
+					t.Fatalf("шаг %d: неожиданное зацикливание %s", i+1, got.Kind)
+				}
+			}
+			switch {
+			case tt.want == "" && got != nil:
+				t.Errorf("неожиданное зацикливание %s (%s)", got.Kind, got.Detail)
+			case tt.want != "" && got == nil:
+				t.Errorf("зацикливание %s не обнаружено", tt.want)
+			case tt.want != "" && got.Kind != tt.want:
+				t.Errorf("вид = %s, ожидалось %s", got.Kind, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoopDetectorEscalation(t *testing.T) {
+	d := NewLoopDetector()
+	click := llm.StepPlan{Action: "click", Selector: "#next"}
+
+	want := []LoopEscalation{EscalateObserve, EscalateReplan, EscalateAskUser, EscalateAskUser}
+	for i, escalation := range want {
+		var got *LoopDetection
+		for range defaultRepeatThreshold {
+			got = d.Observe(&click, "", "", "")
+		}
+		if got == nil {
+			t.Fatalf("обнаружение %d: зацикливание не обнаружено", i+1)
+		}
+		if got.Escalation != escalation {
+			t.Errorf("обнаружение %d: эскалация = %s, ожидалось %s", i+1, got.Escalation, escalation)
+		}
+	}
+
+	d.Reset()
+	var got *LoopDetection
+	for range defaultRepeatThreshold {
+		got = d.Observe(&click, "", "", "")
+	}
+	if got == nil || got.Escalation != EscalateObserve {
+		t.Errorf("после Reset эскалация должна начинаться с %s, получено %v", EscalateObserve, got)
+	}
+}
@@ -6,123 +6,257 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"maps"
+	"math"
+	"slices"
 	"strings"
 	"sync"
 	"time"
 
 	"aiAgent/internal/database"
 	"aiAgent/internal/llm"
+	"aiAgent/internal/sanitizer"
 )
 
+// DefaultMemoryTTL - срок хранения записи памяти с последнего использования по умолчанию.
+const DefaultMemoryTTL = 30 * 24 * time.Hour
+
+const (
+	maxSitePatterns = 40 // Сколько назначений селекторов хранится для сайта
+	maxSiteForms    = 10 // Сколько форм хранится для сайта
+
+	// Уверенность в пути - скользящее среднее совпадения его шагов со страницей при воспроизведении
+	pathMatchWeight   = 0.3 // Вес последней проверки
+	minPathConfidence = 0.3 // Путь с меньшей уверенностью не воспроизводится
+
+	// pathValuePlaceholder заменяет в шагах пути введенное значение, которое нельзя хранить
+	// (см. redactSteps); при воспроизведении значение спрашивается у пользователя.
+	pathValuePlaceholder = "{{value}}"
+)
+
+// AgentMemory - долговременная память агента: успешные пути выполнения задач, повторяющиеся ошибки
+// и знания о сайтах. Каждое изменение сразу сохраняется в Postgres, при старте память загружается из БД.
+// Запись живет ttl с последнего использования: сайт мог измениться, и старый путь больше не работает.
+// Вес успешного пути убывает вдвое за ttl/4, поэтому недавно подтвержденный путь важнее давнего (кроме закрепленного).
+// Для каждой записи хранится вектор текста от embedder: по нему ищутся похожие задачи (см. Recall).
+// Изменения выполняются по одному под writeMu; mu защищает только карты, и запись в БД идет без него,
+// поэтому поиск по памяти не ждет Postgres.
 type AgentMemory struct {
-	successfulPaths  map[string][]SuccessfulPath
-	failurePatterns  map[string]FailurePattern
-	siteKnowledge    map[string]SiteInfo
-	mu               sync.RWMutex
-	repo             *database.TaskRepository
+	successfulPaths map[string][]SuccessfulPath
+	failurePatterns map[string]FailurePattern
+	siteKnowledge   map[string]SiteInfo
+	mu              sync.RWMutex
+	writeMu         sync.Mutex
+	repo            *database.TaskRepository
+	ttl             time.Duration
+	embedder        Embedder
+	sanitizer       *sanitizer.DataSanitizer
+	queries         map[string][]float32 // Векторы задач для Recall: задача ищется в памяти на каждом шаге
 }
 
+// Записи памяти сериализуются в JSON при экспорте (см. MemoryBundle). ID и векторы в экспорт не входят:
+// ID свои в каждой БД, а векторы пересчитываются эмбеддером, настроенным при импорте.
+
 type SuccessfulPath struct {
-	TaskHash      string
-	Steps         []llm.StepPlan
-	Strategy      string
-	SuccessCount  int
-	LastUsed      time.Time
-	AverageTime   time.Duration
-	Domain        string
+	ID           uint           `json:"-"` // ID записи в memory_paths (0 - еще не сохранена)
+	TaskHash     string         `json:"-"`
+	Task         string         `json:"task"`
+	Steps        []llm.StepPlan `json:"steps"`
+	Strategy     string         `json:"strategy,omitempty"`
+	SuccessCount int            `json:"success_count"`
+	LastUsed     time.Time      `json:"last_used"`
+	AverageTime  time.Duration  `json:"average_time_ns"`
+	Domain       string         `json:"domain"`
+	Confidence   float64        `json:"confidence,omitempty"` // Уверенность, что путь подходит к текущей верстке (0..1)
+	LastMatch    float64        `json:"last_match,omitempty"` // Доля шагов, совпавших со страницей при последнем воспроизведении
+	ExpiresAt    time.Time      `json:"expires_at,omitzero"`  // Нулевое значение - бессрочно (запись закреплена)
+	Embedding    []float32      `json:"-"`                    // Вектор текста задачи
 }
 
 type FailurePattern struct {
-	ErrorType     string
-	Action        string
-	Selector      string
-	Count         int
-	LastSeen      time.Time
-	Recovery      string
+	ID        uint      `json:"-"` // ID записи в memory_failures (0 - еще не сохранена)
+	ErrorType string    `json:"error_type"`
+	Action    string    `json:"action"`
+	Selector  string    `json:"selector"`
+	Count     int       `json:"count"`
+	LastSeen  time.Time `json:"last_seen"`
+	Recovery  string    `json:"recovery,omitempty"`
+	Task      string    `json:"task,omitempty"` // Задача, при которой ошибка случилась последний раз
+	ExpiresAt time.Time `json:"expires_at,omitzero"`
+	Embedding []float32 `json:"-"` // Вектор задачи и описания ошибки: Recall ищет ошибки по задаче
 }
 
 type SiteInfo struct {
-	Domain         string
-	CommonPatterns map[string]string
-	FormStructure  []string
-	LastVisited    time.Time
+	Domain         string            `json:"domain"`
+	CommonPatterns map[string]string `json:"common_patterns,omitempty"`
+	FormStructure  []string          `json:"form_structure,omitempty"`
+	LastVisited    time.Time         `json:"last_visited"`
+	ExpiresAt      time.Time         `json:"expires_at,omitzero"`
+	Embedding      []float32         `json:"-"` // Вектор описания сайта (домен, селекторы, формы)
 }
 
-func NewAgentMemory(repo *database.TaskRepository) *AgentMemory {
+// NewAgentMemory создает память агента. embedder == nil - локальный HashEmbedder.
+func NewAgentMemory(repo *database.TaskRepository, ttl time.Duration, embedder Embedder) *AgentMemory {
+	if ttl <= 0 {
+		ttl = DefaultMemoryTTL
+	}
+	if embedder == nil {
+		embedder = NewHashEmbedder()
+	}
 	return &AgentMemory{
 		successfulPaths: make(map[string][]SuccessfulPath),
 		failurePatterns: make(map[string]FailurePattern),
 		siteKnowledge:   make(map[string]SiteInfo),
 		repo:            repo,
+		ttl:             ttl,
+		embedder:        embedder,
+		sanitizer:       sanitizer.New(),
+		queries:         make(map[string][]float32),
 	}
 }
 
 func (m *AgentMemory) RecordSuccess(ctx context.Context, task string, steps []llm.StepPlan, strategy string, duration time.Duration, domain string) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+	// Эмбеддер может обращаться к внешнему сервису, поэтому вектор считается до блокировки
+	embedding := m.embed(ctx, task)
+	steps = m.redactSteps(task, steps)
 
+	m.writeMu.Lock()
+	defer m.writeMu.Unlock()
+
+	m.mu.Lock()
 	taskHash := m.hashTask(task)
+	now := time.Now()
+
+	paths := m.successfulPaths[taskHash]
+	idx := -1
+	for i, p := range paths {
+		if m.pathsAreSimilar(p.Steps, steps) {
+			idx = i
+			break
+		}
+	}
 
-	path := SuccessfulPath{
-		TaskHash:     taskHash,
-		Steps:        steps,
-		Strategy:     strategy,
-		SuccessCount: 1,
-		LastUsed:     time.Now(),
-		AverageTime:  duration,
-		Domain:       domain,
-	}
-
-	if existingPaths, ok := m.successfulPaths[taskHash]; ok {
-		found := false
-		for i, p := range existingPaths {
-			if m.pathsAreSimilar(p.Steps, steps) {
-				existingPaths[i].SuccessCount++
-				existingPaths[i].LastUsed = time.Now()
-				existingPaths[i].AverageTime = (existingPaths[i].AverageTime + duration) / 2
-				found = true
-				break
-			}
+	if idx >= 0 {
+		// Устаревший путь снова сработал - счет успехов начинается заново
+		if m.expired(paths[idx].ExpiresAt) {
+			paths[idx].SuccessCount = 0
 		}
-		if !found {
-			m.successfulPaths[taskHash] = append(existingPaths, path)
+		paths[idx].SuccessCount++
+		// Шаги могли быть исправлены под новую верстку при воспроизведении - храним последний рабочий
+		// вариант, и уверенность в нем восстанавливается
+		paths[idx].Steps = steps
+		paths[idx].Confidence = 1
+		paths[idx].LastUsed = now
+		paths[idx].AverageTime = (paths[idx].AverageTime + duration) / 2
+		paths[idx].ExpiresAt = m.extend(paths[idx].ExpiresAt, now)
+		if embedding != nil {
+			paths[idx].Embedding = embedding
 		}
 	} else {
-		m.successfulPaths[taskHash] = []SuccessfulPath{path}
+		paths = append(paths, SuccessfulPath{
+			TaskHash:     taskHash,
+			Task:         task,
+			Steps:        steps,
+			Strategy:     strategy,
+			SuccessCount: 1,
+			LastUsed:     now,
+			AverageTime:  duration,
+			Domain:       domain,
+			Confidence:   1,
+			ExpiresAt:    now.Add(m.ttl),
+			Embedding:    embedding,
+		})
+		idx = len(paths) - 1
 	}
+	m.successfulPaths[taskHash] = paths
+	m.mu.Unlock()
 
-	return nil
+	return m.persistPath(taskHash, idx)
 }
 
-func (m *AgentMemory) RecordFailure(ctx context.Context, action string, selector string, errorMsg string, recovery string) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+// RecordFailure учитывает ошибку вида kind при действии над селектором во время задачи task.
+func (m *AgentMemory) RecordFailure(ctx context.Context, task string, action string, selector string, kind ErrorKind, recovery string) error {
+	errorType := string(kind)
+	embedding := m.embed(ctx, failureText(task, errorType, action, selector))
 
-	errorType := m.classifyErrorType(errorMsg)
+	m.writeMu.Lock()
+	defer m.writeMu.Unlock()
+
+	m.mu.Lock()
 	key := fmt.Sprintf("%s:%s:%s", errorType, action, selector)
+	now := time.Now()
 
-	if pattern, ok := m.failurePatterns[key]; ok {
+	pattern, ok := m.failurePatterns[key]
+	if ok && !m.expired(pattern.ExpiresAt) {
 		pattern.Count++
-		pattern.LastSeen = time.Now()
+		pattern.LastSeen = now
+		pattern.Task = task
+		pattern.ExpiresAt = m.extend(pattern.ExpiresAt, now)
 		if recovery != "" {
 			pattern.Recovery = recovery
 		}
-		m.failurePatterns[key] = pattern
 	} else {
-		m.failurePatterns[key] = FailurePattern{
+		pattern = FailurePattern{
+			ID:        pattern.ID,
 			ErrorType: errorType,
 			Action:    action,
 			Selector:  selector,
 			Count:     1,
-			LastSeen:  time.Now(),
+			LastSeen:  now,
 			Recovery:  recovery,
+			Task:      task,
+			ExpiresAt: now.Add(m.ttl),
 		}
 	}
+	if embedding != nil {
+		pattern.Embedding = embedding
+	}
+	m.failurePatterns[key] = pattern
+	m.mu.Unlock()
 
-	return nil
+	return m.persistFailure(key, pattern)
+}
+
+// RecordPathMatch учитывает, насколько шаги пути совпали со страницей при воспроизведении:
+// quality - доля совпавших шагов (исправленный шаг считается за половину).
+func (m *AgentMemory) RecordPathMatch(id uint, quality float64) error {
+	m.writeMu.Lock()
+	defer m.writeMu.Unlock()
+
+	m.mu.Lock()
+	hash, idx := m.findPath(id)
+	if idx < 0 {
+		m.mu.Unlock()
+		return ErrMemoryEntryNotFound
+	}
+	path := &m.successfulPaths[hash][idx]
+	path.LastMatch = quality
+	path.Confidence = (1-pathMatchWeight)*path.Confidence + pathMatchWeight*quality
+	m.mu.Unlock()
+
+	return m.persistPath(hash, idx)
 }
 
+// FindSimilarSuccessfulPath ищет успешный путь для задачи: сначала среди путей той же задачи,
+// затем среди семантически похожих задач (близость не ниже minPathSimilarity).
 func (m *AgentMemory) FindSimilarSuccessfulPath(ctx context.Context, task string, domain string) *SuccessfulPath {
+	if path := m.findExactPath(task, domain); path != nil {
+		return path
+	}
+
+	query, err := m.embedQuery(ctx, task)
+	if err != nil {
+		return nil
+	}
+	if paths := m.recallPaths(query, domain, 1); len(paths) > 0 && paths[0].similarity >= minPathSimilarity && paths[0].path.Confidence >= minPathConfidence {
+		path := paths[0].path
+		return &path
+	}
+	return nil
+}
+
+// findExactPath выбирает лучший путь среди путей с тем же нормализованным текстом задачи.
+func (m *AgentMemory) findExactPath(task string, domain string) *SuccessfulPath {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
@@ -130,10 +264,14 @@ func (m *AgentMemory) FindSimilarSuccessfulPath(ctx context.Context, task string
 
 	if paths, ok := m.successfulPaths[taskHash]; ok {
 		var bestPath *SuccessfulPath
-		bestScore := 0
+		bestScore := 0.0
 
 		for _, path := range paths {
-			score := path.SuccessCount
+			if m.expired(path.ExpiresAt) || path.Confidence < minPathConfidence {
+				continue
+			}
+
+			score := m.pathWeight(path)
 			if path.Domain == domain {
 				score += 10
 			}
@@ -154,20 +292,62 @@ func (m *AgentMemory) FindSimilarSuccessfulPath(ctx context.Context, task string
 	return nil
 }
 
-func (m *AgentMemory) GetFailureRecovery(ctx context.Context, action string, selector string, errorMsg string) string {
+// RecordRecovery запоминает способ обхода ошибки действия. В отличие от RecordFailure
+// не увеличивает счетчик ошибок: ошибка уже учтена, когда произошла (и задача паттерна записана).
+func (m *AgentMemory) RecordRecovery(ctx context.Context, action string, selector string, kind ErrorKind, recovery string) error {
+	errorType := string(kind)
+	key := fmt.Sprintf("%s:%s:%s", errorType, action, selector)
+
+	m.mu.RLock()
+	task := m.failurePatterns[key].Task
+	m.mu.RUnlock()
+	embedding := m.embed(ctx, failureText(task, errorType, action, selector))
+
+	m.writeMu.Lock()
+	defer m.writeMu.Unlock()
+
+	m.mu.Lock()
+	now := time.Now()
+
+	pattern, ok := m.failurePatterns[key]
+	if !ok || m.expired(pattern.ExpiresAt) {
+		pattern = FailurePattern{
+			ID:        pattern.ID,
+			ErrorType: errorType,
+			Action:    action,
+			Selector:  selector,
+			Count:     1,
+			ExpiresAt: now.Add(m.ttl),
+		}
+	}
+	pattern.Recovery = recovery
+	pattern.LastSeen = now
+	pattern.ExpiresAt = m.extend(pattern.ExpiresAt, now)
+	if embedding != nil {
+		pattern.Embedding = embedding
+	}
+	m.failurePatterns[key] = pattern
+	m.mu.Unlock()
+
+	return m.persistFailure(key, pattern)
+}
+
+// GetFailureRecovery возвращает запомненный способ обхода ошибки вида kind ("" - неизвестен).
+func (m *AgentMemory) GetFailureRecovery(ctx context.Context, action string, selector string, kind ErrorKind) string {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	errorType := m.classifyErrorType(errorMsg)
+	errorType := string(kind)
 	key := fmt.Sprintf("%s:%s:%s", errorType, action, selector)
 
-	if pattern, ok := m.failurePatterns[key]; ok && pattern.Recovery != "" {
+	if pattern, ok := m.failurePatterns[key]; ok && pattern.Recovery != "" && !m.expired(pattern.ExpiresAt) {
 		return pattern.Recovery
 	}
 
+	// Обход, привязанный к селектору (другой селектор того же элемента), для других элементов не подходит
 	generalKey := fmt.Sprintf("%s:%s:", errorType, action)
 	for k, pattern := range m.failurePatterns {
-		if strings.HasPrefix(k, generalKey) && pattern.Recovery != "" {
+		if strings.HasPrefix(k, generalKey) && pattern.Recovery != "" && !m.expired(pattern.ExpiresAt) && recoveryTransferable(pattern.Recovery) {
 			return pattern.Recovery
 		}
 	}
@@ -176,36 +356,148 @@ func (m *AgentMemory) GetFailureRecovery(ctx context.Context, action string, sel
 }
 
 func (m *AgentMemory) UpdateSiteKnowledge(ctx context.Context, domain string, patterns map[string]string, forms []string) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+	embedding := m.embed(ctx, siteText(domain, patterns, forms))
+
+	m.writeMu.Lock()
+	defer m.writeMu.Unlock()
+	return m.storeSite(domain, patterns, forms, embedding)
+}
 
+// storeSite заменяет знания о сайте в памяти и БД. Вызывается под m.writeMu.
+func (m *AgentMemory) storeSite(domain string, patterns map[string]string, forms []string, embedding []float32) error {
+	m.mu.Lock()
+	now := time.Now()
+	expiresAt := now.Add(m.ttl)
+	if old, ok := m.siteKnowledge[domain]; ok {
+		expiresAt = m.extend(old.ExpiresAt, now)
+	}
 	info := SiteInfo{
 		Domain:         domain,
 		CommonPatterns: patterns,
 		FormStructure:  forms,
-		LastVisited:    time.Now(),
+		LastVisited:    now,
+		ExpiresAt:      expiresAt,
+		Embedding:      embedding,
 	}
 
 	m.siteKnowledge[domain] = info
-	return nil
+	m.mu.Unlock()
+
+	return m.saveSite(info)
+}
+
+// LearnSite дополняет знания о сайте: селекторы с тем же назначением и формы той же страницы
+// заменяются новыми, остальные сохраняются. Ничего нового - запись не сохраняется, срок не продлевается.
+// Чтение, объединение и запись идут под writeMu: параллельные задачи на одном сайте не теряют знания друг друга.
+// Назначения и селекторы с личными данными (почта, телефон, номер карты) не запоминаются, в формах они маскируются.
+func (m *AgentMemory) LearnSite(ctx context.Context, domain string, patterns map[string]string, forms []string) error {
+	if domain == "" || (len(patterns) == 0 && len(forms) == 0) {
+		return nil
+	}
+
+	m.writeMu.Lock()
+	defer m.writeMu.Unlock()
+
+	m.mu.RLock()
+	info, ok := m.siteKnowledge[domain]
+	m.mu.RUnlock()
+	if !ok || m.expired(info.ExpiresAt) {
+		info = SiteInfo{Domain: domain}
+	}
+
+	merged := maps.Clone(info.CommonPatterns)
+	if merged == nil {
+		merged = make(map[string]string)
+	}
+	changed := false
+	for purpose, selector := range patterns {
+		if merged[purpose] == selector || m.sanitizer.Sanitize(purpose) != purpose || m.sanitizer.Sanitize(selector) != selector {
+			continue
+		}
+		if _, known := merged[purpose]; !known && len(merged) >= maxSitePatterns {
+			continue
+		}
+		merged[purpose] = selector
+		changed = true
+	}
+
+	mergedForms := slices.Clone(info.FormStructure)
+	for _, form := range forms {
+		form = m.sanitizer.Sanitize(form)
+		if slices.Contains(mergedForms, form) {
+			continue
+		}
+		// Форма той же страницы заменяется: ключ - путь страницы до ": "
+		page, _, _ := strings.Cut(form, ": ")
+		mergedForms = slices.DeleteFunc(mergedForms, func(f string) bool {
+			p, _, _ := strings.Cut(f, ": ")
+			return p == page
+		})
+		mergedForms = append(mergedForms, form)
+		if len(mergedForms) > maxSiteForms {
+			mergedForms = mergedForms[len(mergedForms)-maxSiteForms:]
+		}
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+	embedding := m.embed(ctx, siteText(domain, merged, mergedForms))
+	return m.storeSite(domain, merged, mergedForms, embedding)
 }
 
 func (m *AgentMemory) GetSiteKnowledge(ctx context.Context, domain string) *SiteInfo {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	if info, ok := m.siteKnowledge[domain]; ok {
+	if info, ok := m.siteKnowledge[domain]; ok && !m.expired(info.ExpiresAt) {
 		return &info
 	}
 	return nil
 }
 
+// Counts возвращает количество успешных путей, паттернов ошибок и сайтов в памяти.
+func (m *AgentMemory) Counts() (paths, failures, sites int) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, p := range m.successfulPaths {
+		paths += len(p)
+	}
+	return paths, len(m.failurePatterns), len(m.siteKnowledge)
+}
+
 func (m *AgentMemory) hashTask(task string) string {
 	normalized := strings.ToLower(strings.TrimSpace(task))
 	hash := sha256.Sum256([]byte(normalized))
 	return hex.EncodeToString(hash[:])
 }
 
+// expired проверяет, истек ли срок хранения записи (нулевой срок - бессрочно).
+func (m *AgentMemory) expired(expiresAt time.Time) bool {
+	return !expiresAt.IsZero() && time.Now().After(expiresAt)
+}
+
+// extend продлевает срок хранения записи на ttl; закрепленная запись (без срока) остается бессрочной.
+func (m *AgentMemory) extend(expiresAt time.Time, now time.Time) time.Time {
+	if expiresAt.IsZero() {
+		return expiresAt
+	}
+	return now.Add(m.ttl)
+}
+
+// pathWeight - число успехов пути, убывающее вдвое за каждые ttl/4 без использования,
+// с учетом уверенности в том, что путь подходит к текущей верстке. Закрепленный путь не теряет вес.
+func (m *AgentMemory) pathWeight(path SuccessfulPath) float64 {
+	if path.Pinned() {
+		return path.Confidence * float64(path.SuccessCount)
+	}
+	halfLife := m.ttl / 4
+	age := time.Since(path.LastUsed)
+	return path.Confidence * float64(path.SuccessCount) * math.Pow(0.5, float64(age)/float64(halfLife))
+}
+
 func (m *AgentMemory) pathsAreSimilar(p1, p2 []llm.StepPlan) bool {
 	if len(p1) != len(p2) {
 		return false
@@ -221,52 +513,333 @@ func (m *AgentMemory) pathsAreSimilar(p1, p2 []llm.StepPlan) bool {
 	return float64(matchCount)/float64(len(p1)) >= 0.8
 }
 
-func (m *AgentMemory) classifyErrorType(errorMsg string) string {
-	errorLower := strings.ToLower(errorMsg)
+// SaveToDatabase сохраняет всю память в БД. Обычно не требуется: изменения сохраняются сразу.
+func (m *AgentMemory) SaveToDatabase(ctx context.Context) error {
+	m.writeMu.Lock()
+	defer m.writeMu.Unlock()
+
+	m.mu.RLock()
+	hashes := slices.Collect(maps.Keys(m.successfulPaths))
+	keys := slices.Collect(maps.Keys(m.failurePatterns))
+	sites := slices.Collect(maps.Values(m.siteKnowledge))
+	counts := make(map[string]int, len(hashes))
+	for _, hash := range hashes {
+		counts[hash] = len(m.successfulPaths[hash])
+	}
+	m.mu.RUnlock()
+
+	for _, hash := range hashes {
+		for i := range counts[hash] {
+			if err := m.persistPath(hash, i); err != nil {
+				return err
+			}
+		}
+	}
+	for _, key := range keys {
+		m.mu.RLock()
+		pattern := m.failurePatterns[key]
+		m.mu.RUnlock()
+		if err := m.persistFailure(key, pattern); err != nil {
+			return err
+		}
+	}
+	for _, info := range sites {
+		if err := m.saveSite(info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadFromDatabase удаляет из БД записи с истекшим сроком и загружает остальные в память.
+func (m *AgentMemory) LoadFromDatabase(ctx context.Context) error {
+	if m.repo == nil {
+		return nil
+	}
+
+	m.writeMu.Lock()
+	defer m.writeMu.Unlock()
+
+	if _, err := m.repo.DeleteExpiredMemory(time.Now()); err != nil {
+		return fmt.Errorf("ошибка удаления устаревшей памяти: %w", err)
+	}
 
-	if strings.Contains(errorLower, "timeout") {
-		return "timeout"
+	pathRows, err := m.repo.ListMemoryPaths()
+	if err != nil {
+		return fmt.Errorf("ошибка загрузки успешных путей: %w", err)
+	}
+	failureRows, err := m.repo.ListMemoryFailures()
+	if err != nil {
+		return fmt.Errorf("ошибка загрузки паттернов ошибок: %w", err)
+	}
+	siteRows, err := m.repo.ListMemorySites()
+	if err != nil {
+		return fmt.Errorf("ошибка загрузки знаний о сайтах: %w", err)
 	}
-	if strings.Contains(errorLower, "not found") || strings.Contains(errorLower, "no such element") {
-		return "element_not_found"
+
+	paths := make(map[string][]SuccessfulPath)
+	for _, row := range pathRows {
+		path, err := pathFromModel(row)
+		if err != nil {
+			return err
+		}
+		paths[path.TaskHash] = append(paths[path.TaskHash], path)
 	}
-	if strings.Contains(errorLower, "network") {
-		return "network"
+
+	failures := make(map[string]FailurePattern)
+	for _, row := range failureRows {
+		embedding, err := parseEmbedding(row.Embedding)
+		if err != nil {
+			return fmt.Errorf("ошибка разбора вектора паттерна ошибки #%d: %w", row.ID, err)
+		}
+		pattern := FailurePattern{
+			ID:        row.ID,
+			ErrorType: row.ErrorType,
+			Action:    row.Action,
+			Selector:  row.Selector,
+			Count:     row.Count,
+			LastSeen:  row.LastSeen,
+			Recovery:  row.Recovery,
+			Task:      row.Task,
+			ExpiresAt: timeOrZero(row.ExpiresAt),
+			Embedding: embedding,
+		}
+		failures[fmt.Sprintf("%s:%s:%s", pattern.ErrorType, pattern.Action, pattern.Selector)] = pattern
 	}
-	if strings.Contains(errorLower, "permission") || strings.Contains(errorLower, "access denied") {
-		return "permission"
+
+	sites := make(map[string]SiteInfo)
+	for _, row := range siteRows {
+		info, err := siteFromModel(row)
+		if err != nil {
+			return err
+		}
+		sites[info.Domain] = info
 	}
 
-	return "unknown"
+	m.embedMissing(ctx, paths, failures, sites)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.successfulPaths = paths
+	m.failurePatterns = failures
+	m.siteKnowledge = sites
+	return nil
 }
 
-func (m *AgentMemory) SaveToDatabase(ctx context.Context) error {
+// persistPath сохраняет в БД путь с индексом idx среди путей задачи и записывает в память ID новой записи.
+// Вызывается под m.writeMu без m.mu: другие изменения ждут, а поиск по памяти - нет.
+func (m *AgentMemory) persistPath(hash string, idx int) error {
 	m.mu.RLock()
-	defer m.mu.RUnlock()
+	path := m.successfulPaths[hash][idx]
+	m.mu.RUnlock()
 
-	data := struct {
-		SuccessfulPaths map[string][]SuccessfulPath
-		FailurePatterns map[string]FailurePattern
-		SiteKnowledge   map[string]SiteInfo
-	}{
-		SuccessfulPaths: m.successfulPaths,
-		FailurePatterns: m.failurePatterns,
-		SiteKnowledge:   m.siteKnowledge,
+	if err := m.savePath(&path); err != nil {
+		return err
 	}
 
-	jsonData, err := json.Marshal(data)
-	if err != nil {
-		return fmt.Errorf("failed to marshal memory: %w", err)
+	m.mu.Lock()
+	m.successfulPaths[hash][idx].ID = path.ID
+	m.mu.Unlock()
+	return nil
+}
+
+// persistFailure сохраняет в БД паттерн ошибки и записывает в память его ID. Вызывается под m.writeMu без m.mu.
+func (m *AgentMemory) persistFailure(key string, pattern FailurePattern) error {
+	if err := m.saveFailure(&pattern); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	if stored, ok := m.failurePatterns[key]; ok {
+		stored.ID = pattern.ID
+		m.failurePatterns[key] = stored
 	}
+	m.mu.Unlock()
+	return nil
+}
 
-	_ = jsonData
+// redactSteps возвращает копию шагов, в которой значения шагов ввода заменены на pathValuePlaceholder.
+// Значение остается, только если оно есть в тексте задачи (и так хранится вместе с путем),
+// не похоже на личные данные и вводится не в поле пароля, почты или телефона.
+func (m *AgentMemory) redactSteps(task string, steps []llm.StepPlan) []llm.StepPlan {
+	lowerTask := strings.ToLower(task)
+	redacted := slices.Clone(steps)
+	for i := range redacted {
+		step := &redacted[i]
+		step.Reasoning = m.sanitizer.Sanitize(step.Reasoning)
+		if step.Action != "type" || step.Value == "" || step.Value == pathValuePlaceholder {
+			continue
+		}
+		keep := strings.Contains(lowerTask, strings.ToLower(step.Value)) &&
+			m.sanitizer.SanitizeValue(step.Value) == step.Value &&
+			m.sanitizer.SanitizeSelector(step.Selector) == step.Selector
+		if !keep {
+			step.Value = pathValuePlaceholder
+		}
+	}
+	return redacted
+}
 
+// hasValuePlaceholders сообщает, что для воспроизведения пути нужны значения от пользователя.
+func hasValuePlaceholders(steps []llm.StepPlan) bool {
+	return slices.ContainsFunc(steps, func(step llm.StepPlan) bool {
+		return step.Action == "type" && step.Value == pathValuePlaceholder
+	})
+}
+
+// savePath сохраняет успешный путь и запоминает ID новой записи в path. Вызывается без m.mu.
+func (m *AgentMemory) savePath(path *SuccessfulPath) error {
+	if m.repo == nil {
+		return nil
+	}
+	steps, err := json.Marshal(path.Steps)
+	if err != nil {
+		return fmt.Errorf("ошибка сериализации шагов пути: %w", err)
+	}
+	embedding, err := json.Marshal(path.Embedding)
+	if err != nil {
+		return fmt.Errorf("ошибка сериализации вектора пути: %w", err)
+	}
+	row := &database.MemoryPath{
+		ID:           path.ID,
+		TaskHash:     path.TaskHash,
+		Task:         path.Task,
+		Domain:       path.Domain,
+		Steps:        string(steps),
+		Strategy:     path.Strategy,
+		Embedding:    string(embedding),
+		SuccessCount: path.SuccessCount,
+		AverageMs:    path.AverageTime.Milliseconds(),
+		Confidence:   path.Confidence,
+		LastMatch:    path.LastMatch,
+		LastUsed:     path.LastUsed,
+		ExpiresAt:    zeroOrTime(path.ExpiresAt),
+	}
+	if err := m.repo.SaveMemoryPath(row); err != nil {
+		return fmt.Errorf("ошибка сохранения успешного пути: %w", err)
+	}
+	path.ID = row.ID
 	return nil
 }
 
-func (m *AgentMemory) LoadFromDatabase(ctx context.Context) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+// saveFailure сохраняет паттерн ошибки и запоминает ID записи в pattern. Вызывается без m.mu.
+func (m *AgentMemory) saveFailure(pattern *FailurePattern) error {
+	if m.repo == nil {
+		return nil
+	}
+	embedding, err := json.Marshal(pattern.Embedding)
+	if err != nil {
+		return fmt.Errorf("ошибка сериализации вектора паттерна ошибки: %w", err)
+	}
+	row := &database.MemoryFailure{
+		ErrorType: pattern.ErrorType,
+		Action:    pattern.Action,
+		Selector:  pattern.Selector,
+		Count:     pattern.Count,
+		Recovery:  pattern.Recovery,
+		Task:      pattern.Task,
+		Embedding: string(embedding),
+		LastSeen:  pattern.LastSeen,
+		ExpiresAt: zeroOrTime(pattern.ExpiresAt),
+	}
+	if err := m.repo.SaveMemoryFailure(row); err != nil {
+		return fmt.Errorf("ошибка сохранения паттерна ошибки: %w", err)
+	}
+	pattern.ID = row.ID
+	return nil
+}
 
+func (m *AgentMemory) saveSite(info SiteInfo) error {
+	if m.repo == nil {
+		return nil
+	}
+	patterns, err := json.Marshal(info.CommonPatterns)
+	if err != nil {
+		return fmt.Errorf("ошибка сериализации селекторов сайта: %w", err)
+	}
+	forms, err := json.Marshal(info.FormStructure)
+	if err != nil {
+		return fmt.Errorf("ошибка сериализации форм сайта: %w", err)
+	}
+	embedding, err := json.Marshal(info.Embedding)
+	if err != nil {
+		return fmt.Errorf("ошибка сериализации вектора сайта: %w", err)
+	}
+	err = m.repo.SaveMemorySite(&database.MemorySite{
+		Domain:         info.Domain,
+		CommonPatterns: string(patterns),
+		FormStructure:  string(forms),
+		Embedding:      string(embedding),
+		LastVisited:    info.LastVisited,
+		ExpiresAt:      zeroOrTime(info.ExpiresAt),
+	})
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения знаний о сайте: %w", err)
+	}
 	return nil
 }
+
+func pathFromModel(row database.MemoryPath) (SuccessfulPath, error) {
+	var steps []llm.StepPlan
+	if err := json.Unmarshal([]byte(row.Steps), &steps); err != nil {
+		return SuccessfulPath{}, fmt.Errorf("ошибка разбора шагов пути #%d: %w", row.ID, err)
+	}
+	embedding, err := parseEmbedding(row.Embedding)
+	if err != nil {
+		return SuccessfulPath{}, fmt.Errorf("ошибка разбора вектора пути #%d: %w", row.ID, err)
+	}
+	return SuccessfulPath{
+		ID:           row.ID,
+		TaskHash:     row.TaskHash,
+		Task:         row.Task,
+		Steps:        steps,
+		Strategy:     row.Strategy,
+		SuccessCount: row.SuccessCount,
+		LastUsed:     row.LastUsed,
+		AverageTime:  time.Duration(row.AverageMs) * time.Millisecond,
+		Domain:       row.Domain,
+		Confidence:   row.Confidence,
+		LastMatch:    row.LastMatch,
+		ExpiresAt:    timeOrZero(row.ExpiresAt),
+		Embedding:    embedding,
+	}, nil
+}
+
+func siteFromModel(row database.MemorySite) (SiteInfo, error) {
+	info := SiteInfo{
+		Domain:      row.Domain,
+		LastVisited: row.LastVisited,
+		ExpiresAt:   timeOrZero(row.ExpiresAt),
+	}
+	if row.CommonPatterns != "" {
+		if err := json.Unmarshal([]byte(row.CommonPatterns), &info.CommonPatterns); err != nil {
+			return SiteInfo{}, fmt.Errorf("ошибка разбора селекторов сайта %s: %w", row.Domain, err)
+		}
+	}
+	if row.FormStructure != "" {
+		if err := json.Unmarshal([]byte(row.FormStructure), &info.FormStructure); err != nil {
+			return SiteInfo{}, fmt.Errorf("ошибка разбора форм сайта %s: %w", row.Domain, err)
+		}
+	}
+	embedding, err := parseEmbedding(row.Embedding)
+	if err != nil {
+		return SiteInfo{}, fmt.Errorf("ошибка разбора вектора сайта %s: %w", row.Domain, err)
+	}
+	info.Embedding = embedding
+	return info, nil
+}
+
+// zeroOrTime переводит нулевое время (бессрочно) в NULL для БД.
+func zeroOrTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func timeOrZero(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return *t
+}
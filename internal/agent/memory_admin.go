@@ -0,0 +1,479 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"time"
+)
+
+// Просмотр и правка памяти пользователем: неудачный путь, записанный однажды, иначе повторялся бы
+// в ExecuteTaskMultiStep до истечения срока. Закрепленная запись (ExpiresAt нулевой) не устаревает
+// и не продлевается при использовании. Экспорт и импорт переносят обученную память между агентами.
+
+// MemoryBundleVersion - версия формата MemoryBundle.
+const MemoryBundleVersion = 1
+
+// ErrMemoryEntryNotFound возвращается, если записи памяти с указанным ID или доменом нет.
+var ErrMemoryEntryNotFound = errors.New("запись памяти не найдена")
+
+// MemoryBundle - выгрузка памяти агента в JSON.
+type MemoryBundle struct {
+	Version    int              `json:"version"`
+	ExportedAt time.Time        `json:"exported_at"`
+	Paths      []SuccessfulPath `json:"paths"`
+	Failures   []FailurePattern `json:"failures"`
+	Sites      []SiteInfo       `json:"sites"`
+}
+
+// ImportResult - сколько записей каждого вида добавлено или объединено с существующими при импорте.
+type ImportResult struct {
+	Paths    int
+	Failures int
+	Sites    int
+	Skipped  int // Устаревшие и неполные записи
+}
+
+// Pinned сообщает, что путь закреплен: не устаревает и не теряет вес
+func (p SuccessfulPath) Pinned() bool {
+	return p.ExpiresAt.IsZero()
+}
+
+// Pinned сообщает, что паттерн ошибки закреплен
+func (p FailurePattern) Pinned() bool {
+	return p.ExpiresAt.IsZero()
+}
+
+// Pinned сообщает, что знания о сайте закреплены
+func (s SiteInfo) Pinned() bool {
+	return s.ExpiresAt.IsZero()
+}
+
+// ListPaths возвращает живые успешные пути (domain == "" - всех сайтов), последние использованные первыми.
+func (m *AgentMemory) ListPaths(domain string) []SuccessfulPath {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var result []SuccessfulPath
+	for _, paths := range m.successfulPaths {
+		for _, path := range paths {
+			if m.expired(path.ExpiresAt) || (domain != "" && path.Domain != domain) {
+				continue
+			}
+			result = append(result, path)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].LastUsed.After(result[j].LastUsed)
+	})
+	return result
+}
+
+// GetPath возвращает успешный путь по ID
+func (m *AgentMemory) GetPath(id uint) (*SuccessfulPath, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	hash, idx := m.findPath(id)
+	if idx < 0 {
+		return nil, ErrMemoryEntryNotFound
+	}
+	path := m.successfulPaths[hash][idx]
+	return &path, nil
+}
+
+// ListFailures возвращает живые паттерны ошибок, самые частые первыми.
+func (m *AgentMemory) ListFailures() []FailurePattern {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var result []FailurePattern
+	for _, pattern := range m.failurePatterns {
+		if !m.expired(pattern.ExpiresAt) {
+			result = append(result, pattern)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].LastSeen.After(result[j].LastSeen)
+	})
+	return result
+}
+
+// ListSites возвращает живые знания о сайтах по алфавиту доменов.
+func (m *AgentMemory) ListSites() []SiteInfo {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var result []SiteInfo
+	for _, domain := range slices.Sorted(maps.Keys(m.siteKnowledge)) {
+		if info := m.siteKnowledge[domain]; !m.expired(info.ExpiresAt) {
+			result = append(result, info)
+		}
+	}
+	return result
+}
+
+// DeletePath удаляет успешный путь из памяти и БД
+func (m *AgentMemory) DeletePath(id uint) error {
+	m.writeMu.Lock()
+	defer m.writeMu.Unlock()
+
+	m.mu.RLock()
+	hash, idx := m.findPath(id)
+	m.mu.RUnlock()
+	if idx < 0 {
+		return ErrMemoryEntryNotFound
+	}
+	if m.repo != nil {
+		if err := m.repo.DeleteMemoryPath(id); err != nil {
+			return fmt.Errorf("ошибка удаления успешного пути: %w", err)
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	paths := slices.Delete(m.successfulPaths[hash], idx, idx+1)
+	if len(paths) == 0 {
+		delete(m.successfulPaths, hash)
+	} else {
+		m.successfulPaths[hash] = paths
+	}
+	return nil
+}
+
+// DeleteFailure удаляет паттерн ошибки из памяти и БД
+func (m *AgentMemory) DeleteFailure(id uint) error {
+	m.writeMu.Lock()
+	defer m.writeMu.Unlock()
+
+	m.mu.RLock()
+	key, ok := m.findFailure(id)
+	m.mu.RUnlock()
+	if !ok {
+		return ErrMemoryEntryNotFound
+	}
+	if m.repo != nil {
+		if err := m.repo.DeleteMemoryFailure(id); err != nil {
+			return fmt.Errorf("ошибка удаления паттерна ошибки: %w", err)
+		}
+	}
+
+	m.mu.Lock()
+	delete(m.failurePatterns, key)
+	m.mu.Unlock()
+	return nil
+}
+
+// DeleteSite удаляет знания о сайте из памяти и БД
+func (m *AgentMemory) DeleteSite(domain string) error {
+	m.writeMu.Lock()
+	defer m.writeMu.Unlock()
+
+	m.mu.RLock()
+	_, ok := m.siteKnowledge[domain]
+	m.mu.RUnlock()
+	if !ok {
+		return ErrMemoryEntryNotFound
+	}
+	if m.repo != nil {
+		if err := m.repo.DeleteMemorySite(domain); err != nil {
+			return fmt.Errorf("ошибка удаления знаний о сайте: %w", err)
+		}
+	}
+
+	m.mu.Lock()
+	delete(m.siteKnowledge, domain)
+	m.mu.Unlock()
+	return nil
+}
+
+// SetPathPinned закрепляет успешный путь или снимает закрепление (срок хранения - ttl с этого момента).
+func (m *AgentMemory) SetPathPinned(id uint, pinned bool) error {
+	m.writeMu.Lock()
+	defer m.writeMu.Unlock()
+
+	m.mu.Lock()
+	hash, idx := m.findPath(id)
+	if idx < 0 {
+		m.mu.Unlock()
+		return ErrMemoryEntryNotFound
+	}
+	m.successfulPaths[hash][idx].ExpiresAt = m.pinnedExpiry(pinned)
+	m.mu.Unlock()
+
+	return m.persistPath(hash, idx)
+}
+
+// SetFailurePinned закрепляет паттерн ошибки или снимает закрепление
+func (m *AgentMemory) SetFailurePinned(id uint, pinned bool) error {
+	m.writeMu.Lock()
+	defer m.writeMu.Unlock()
+
+	m.mu.Lock()
+	key, ok := m.findFailure(id)
+	if !ok {
+		m.mu.Unlock()
+		return ErrMemoryEntryNotFound
+	}
+	pattern := m.failurePatterns[key]
+	pattern.ExpiresAt = m.pinnedExpiry(pinned)
+	m.failurePatterns[key] = pattern
+	m.mu.Unlock()
+
+	return m.persistFailure(key, pattern)
+}
+
+// SetSitePinned закрепляет знания о сайте или снимает закрепление
+func (m *AgentMemory) SetSitePinned(domain string, pinned bool) error {
+	m.writeMu.Lock()
+	defer m.writeMu.Unlock()
+
+	m.mu.Lock()
+	info, ok := m.siteKnowledge[domain]
+	if !ok {
+		m.mu.Unlock()
+		return ErrMemoryEntryNotFound
+	}
+	info.ExpiresAt = m.pinnedExpiry(pinned)
+	m.siteKnowledge[domain] = info
+	m.mu.Unlock()
+
+	return m.saveSite(info)
+}
+
+// Export выгружает живые записи памяти. Значения шагов ввода, которые нельзя восстановить из задачи,
+// заменяются на pathValuePlaceholder (см. redactSteps): выгрузка уходит за пределы агента,
+// а пути, сохраненные до скрытия значений, могут содержать личные данные.
+func (m *AgentMemory) Export() *MemoryBundle {
+	paths := m.ListPaths("")
+	for i := range paths {
+		paths[i].Steps = m.redactSteps(paths[i].Task, paths[i].Steps)
+	}
+	return &MemoryBundle{
+		Version:    MemoryBundleVersion,
+		ExportedAt: time.Now(),
+		Paths:      paths,
+		Failures:   m.ListFailures(),
+		Sites:      m.ListSites(),
+	}
+}
+
+// Import добавляет записи выгрузки в память. Записи, которые уже есть, объединяются:
+// у пути берется большее число успехов, у паттерна ошибки - большее число повторений,
+// у сайта сохраняются свои селекторы и добавляются недостающие. Закрепление переносится.
+func (m *AgentMemory) Import(ctx context.Context, bundle *MemoryBundle) (ImportResult, error) {
+	var result ImportResult
+	if bundle.Version != MemoryBundleVersion {
+		return result, fmt.Errorf("неподдерживаемая версия выгрузки памяти: %d (ожидается %d)", bundle.Version, MemoryBundleVersion)
+	}
+
+	// Векторов в выгрузке нет - они считаются своим эмбеддером до блокировки
+	paths := make([]SuccessfulPath, 0, len(bundle.Paths))
+	for _, path := range bundle.Paths {
+		if path.Task == "" || len(path.Steps) == 0 || m.expired(path.ExpiresAt) {
+			result.Skipped++
+			continue
+		}
+		path.ID = 0
+		path.TaskHash = m.hashTask(path.Task)
+		// Выгрузка без уверенности - путь еще не проверялся на странице
+		if path.Confidence <= 0 {
+			path.Confidence = 1
+		}
+		path.Steps = m.redactSteps(path.Task, path.Steps)
+		path.Embedding = m.embed(ctx, path.Task)
+		paths = append(paths, path)
+	}
+	failures := make([]FailurePattern, 0, len(bundle.Failures))
+	for _, pattern := range bundle.Failures {
+		if pattern.Action == "" || pattern.ErrorType == "" || m.expired(pattern.ExpiresAt) {
+			result.Skipped++
+			continue
+		}
+		pattern.ID = 0
+		pattern.Embedding = m.embed(ctx, failureText(pattern.Task, pattern.ErrorType, pattern.Action, pattern.Selector))
+		failures = append(failures, pattern)
+	}
+	sites := make([]SiteInfo, 0, len(bundle.Sites))
+	for _, info := range bundle.Sites {
+		if info.Domain == "" || m.expired(info.ExpiresAt) {
+			result.Skipped++
+			continue
+		}
+		info.Embedding = m.embed(ctx, siteText(info.Domain, info.CommonPatterns, info.FormStructure))
+		sites = append(sites, info)
+	}
+
+	m.writeMu.Lock()
+	defer m.writeMu.Unlock()
+
+	for _, path := range paths {
+		if err := m.importPath(path); err != nil {
+			return result, err
+		}
+		result.Paths++
+	}
+	for _, pattern := range failures {
+		if err := m.importFailure(pattern); err != nil {
+			return result, err
+		}
+		result.Failures++
+	}
+	for _, info := range sites {
+		if err := m.importSite(info); err != nil {
+			return result, err
+		}
+		result.Sites++
+	}
+	return result, nil
+}
+
+// importPath объединяет путь выгрузки с похожим путем той же задачи или добавляет новый. Вызывается под m.writeMu.
+func (m *AgentMemory) importPath(path SuccessfulPath) error {
+	m.mu.Lock()
+	paths := m.successfulPaths[path.TaskHash]
+	for i := range paths {
+		existing := &paths[i]
+		if !m.pathsAreSimilar(existing.Steps, path.Steps) {
+			continue
+		}
+		if m.expired(existing.ExpiresAt) {
+			existing.SuccessCount = 0
+		}
+		existing.SuccessCount = max(existing.SuccessCount, path.SuccessCount)
+		if path.LastUsed.After(existing.LastUsed) {
+			existing.LastUsed = path.LastUsed
+		}
+		existing.ExpiresAt = laterExpiry(existing.ExpiresAt, path.ExpiresAt)
+		if existing.Embedding == nil {
+			existing.Embedding = path.Embedding
+		}
+		m.mu.Unlock()
+		return m.persistPath(path.TaskHash, i)
+	}
+
+	m.successfulPaths[path.TaskHash] = append(paths, path)
+	m.mu.Unlock()
+	return m.persistPath(path.TaskHash, len(paths))
+}
+
+// importFailure объединяет паттерн ошибки выгрузки с тем же паттерном или добавляет новый. Вызывается под m.writeMu.
+func (m *AgentMemory) importFailure(pattern FailurePattern) error {
+	key := fmt.Sprintf("%s:%s:%s", pattern.ErrorType, pattern.Action, pattern.Selector)
+	m.mu.Lock()
+	if existing, ok := m.failurePatterns[key]; ok && !m.expired(existing.ExpiresAt) {
+		existing.Count = max(existing.Count, pattern.Count)
+		if pattern.LastSeen.After(existing.LastSeen) {
+			existing.LastSeen = pattern.LastSeen
+		}
+		if existing.Recovery == "" {
+			existing.Recovery = pattern.Recovery
+		}
+		if existing.Task == "" {
+			existing.Task = pattern.Task
+		}
+		existing.ExpiresAt = laterExpiry(existing.ExpiresAt, pattern.ExpiresAt)
+		pattern = existing
+	} else if ok {
+		pattern.ID = existing.ID
+	}
+	m.failurePatterns[key] = pattern
+	m.mu.Unlock()
+
+	return m.persistFailure(key, pattern)
+}
+
+// importSite дополняет знания о сайте селекторами и формами выгрузки. Вызывается под m.writeMu.
+// Вектор существующей записи сохраняется: новые селекторы дополняют ее, а не заменяют.
+func (m *AgentMemory) importSite(info SiteInfo) error {
+	m.mu.Lock()
+	if existing, ok := m.siteKnowledge[info.Domain]; ok && !m.expired(existing.ExpiresAt) {
+		patterns := maps.Clone(existing.CommonPatterns)
+		if patterns == nil {
+			patterns = make(map[string]string)
+		}
+		for purpose, selector := range info.CommonPatterns {
+			if _, known := patterns[purpose]; !known && len(patterns) < maxSitePatterns {
+				patterns[purpose] = selector
+			}
+		}
+		forms := slices.Clone(existing.FormStructure)
+		for _, form := range info.FormStructure {
+			if !slices.Contains(forms, form) && len(forms) < maxSiteForms {
+				forms = append(forms, form)
+			}
+		}
+		if info.LastVisited.Before(existing.LastVisited) {
+			info.LastVisited = existing.LastVisited
+		}
+		info.CommonPatterns = patterns
+		info.FormStructure = forms
+		info.ExpiresAt = laterExpiry(existing.ExpiresAt, info.ExpiresAt)
+		if existing.Embedding != nil {
+			info.Embedding = existing.Embedding
+		}
+	}
+
+	m.siteKnowledge[info.Domain] = info
+	m.mu.Unlock()
+
+	return m.saveSite(info)
+}
+
+// findPath возвращает хеш задачи и индекс пути с указанным ID (-1, если пути нет). Вызывается под m.mu.
+func (m *AgentMemory) findPath(id uint) (string, int) {
+	if id == 0 {
+		return "", -1
+	}
+	for hash, paths := range m.successfulPaths {
+		for i := range paths {
+			if paths[i].ID == id {
+				return hash, i
+			}
+		}
+	}
+	return "", -1
+}
+
+// findFailure возвращает ключ паттерна ошибки с указанным ID. Вызывается под m.mu.
+func (m *AgentMemory) findFailure(id uint) (string, bool) {
+	if id == 0 {
+		return "", false
+	}
+	for key, pattern := range m.failurePatterns {
+		if pattern.ID == id {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+// pinnedExpiry - срок хранения записи после закрепления (бессрочно) или снятия закрепления (ttl).
+func (m *AgentMemory) pinnedExpiry(pinned bool) time.Time {
+	if pinned {
+		return time.Time{}
+	}
+	return time.Now().Add(m.ttl)
+}
+
+// laterExpiry возвращает более поздний срок хранения; нулевой срок (закреплено) позже любого.
+func laterExpiry(a, b time.Time) time.Time {
+	if a.IsZero() || b.IsZero() {
+		return time.Time{}
+	}
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// Memory возвращает память агента (nil, если память выключена)
+func (a *Agent) Memory() *AgentMemory {
+	return a.memory
+}
@@ -0,0 +1,286 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+
+	"aiAgent/internal/llm"
+)
+
+// Семантический поиск по памяти: задача и записи памяти сравниваются по косинусной близости векторов,
+// поэтому "удали спам в почте" находит путь, записанный для "удалить спам из почты".
+
+const (
+	// minRecallSimilarity - минимальная близость записи к задаче, чтобы попасть в контекст рассуждения.
+	minRecallSimilarity = 0.35
+	// minPathSimilarity - минимальная близость задач, чтобы повторить чужой успешный путь целиком.
+	minPathSimilarity = 0.5
+	// sameDomainBonus - прибавка к близости пути, пройденного на текущем домене.
+	sameDomainBonus = 0.1
+	// DefaultRecallLimit - сколько записей каждого вида попадает в контекст рассуждения.
+	DefaultRecallLimit = 3
+	// maxCachedQueries - сколько векторов задач хранится в кэше Recall.
+	maxCachedQueries = 64
+)
+
+// MemoryRecall - записи памяти, релевантные текущей задаче и домену.
+type MemoryRecall struct {
+	Paths    []RecalledPath
+	Failures []FailurePattern
+	Site     *SiteInfo
+}
+
+// RecalledPath - успешный путь похожей задачи с близостью к текущей.
+type RecalledPath struct {
+	Path       SuccessfulPath
+	Similarity float64
+}
+
+type scoredPath struct {
+	path       SuccessfulPath
+	similarity float64
+	score      float64
+}
+
+// Recall возвращает до k успешных путей и паттернов ошибок, близких к задаче, и знания о домене.
+func (m *AgentMemory) Recall(ctx context.Context, task string, domain string, k int) (*MemoryRecall, error) {
+	query, err := m.embedQuery(ctx, task)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка вычисления вектора задачи: %w", err)
+	}
+
+	recall := &MemoryRecall{Site: m.GetSiteKnowledge(ctx, domain)}
+	for _, p := range m.recallPaths(query, domain, k) {
+		recall.Paths = append(recall.Paths, RecalledPath{Path: p.path, Similarity: p.similarity})
+	}
+	recall.Failures = m.recallFailures(query, k)
+	return recall, nil
+}
+
+// recallPaths ранжирует живые пути по близости к запросу с учетом домена и веса пути.
+func (m *AgentMemory) recallPaths(query []float32, domain string, k int) []scoredPath {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var scored []scoredPath
+	for _, paths := range m.successfulPaths {
+		for _, path := range paths {
+			if m.expired(path.ExpiresAt) {
+				continue
+			}
+			similarity := cosineSimilarity(query, path.Embedding)
+			if similarity < minRecallSimilarity {
+				continue
+			}
+			score := similarity + 0.05*math.Log1p(m.pathWeight(path))
+			if domain != "" && path.Domain == domain {
+				score += sameDomainBonus
+			}
+			scored = append(scored, scoredPath{path: path, similarity: similarity, score: score})
+		}
+	}
+
+	sort.SliceStable(scored, func(i, j int) bool {
+		return scored[i].score > scored[j].score
+	})
+	if len(scored) > k {
+		scored = scored[:k]
+	}
+	return scored
+}
+
+// recallFailures ранжирует живые паттерны ошибок по близости к запросу и числу повторений.
+func (m *AgentMemory) recallFailures(query []float32, k int) []FailurePattern {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	type scoredFailure struct {
+		pattern FailurePattern
+		score   float64
+	}
+	var scored []scoredFailure
+	for _, pattern := range m.failurePatterns {
+		if m.expired(pattern.ExpiresAt) {
+			continue
+		}
+		similarity := cosineSimilarity(query, pattern.Embedding)
+		if similarity < minRecallSimilarity {
+			continue
+		}
+		scored = append(scored, scoredFailure{pattern: pattern, score: similarity + 0.05*math.Log1p(float64(pattern.Count))})
+	}
+
+	sort.SliceStable(scored, func(i, j int) bool {
+		return scored[i].score > scored[j].score
+	})
+	failures := make([]FailurePattern, 0, min(k, len(scored)))
+	for i := 0; i < len(scored) && i < k; i++ {
+		failures = append(failures, scored[i].pattern)
+	}
+	return failures
+}
+
+// Empty сообщает, что релевантных записей нет.
+func (r *MemoryRecall) Empty() bool {
+	return len(r.Paths) == 0 && len(r.Failures) == 0 && r.Site == nil
+}
+
+// Format форматирует записи как memoryContext для рассуждения модели.
+func (r *MemoryRecall) Format() string {
+	var sb strings.Builder
+
+	if len(r.Paths) > 0 {
+		sb.WriteString("Успешные пути похожих задач:\n")
+		for i, recalled := range r.Paths {
+			path := recalled.Path
+			fmt.Fprintf(&sb, "%d. \"%s\" (сайт: %s, успехов: %d, близость %.2f)\n",
+				i+1, path.Task, path.Domain, path.SuccessCount, recalled.Similarity)
+			if path.Strategy != "" {
+				fmt.Fprintf(&sb, "   Стратегия: %s\n", path.Strategy)
+			}
+			steps := make([]string, 0, len(path.Steps))
+			for _, step := range path.Steps {
+				steps = append(steps, formatStepBrief(step))
+			}
+			fmt.Fprintf(&sb, "   Шаги: %s\n", strings.Join(steps, " -> "))
+		}
+	}
+
+	if len(r.Failures) > 0 {
+		sb.WriteString("Повторяющиеся ошибки:\n")
+		for _, pattern := range r.Failures {
+			fmt.Fprintf(&sb, "- %s", pattern.Action)
+			if pattern.Selector != "" {
+				fmt.Fprintf(&sb, " %s", pattern.Selector)
+			}
+			fmt.Fprintf(&sb, ": %s (%d раз)", pattern.ErrorType, pattern.Count)
+			if pattern.Recovery != "" {
+				fmt.Fprintf(&sb, ", помогло: %s", pattern.Recovery)
+			}
+			sb.WriteString("\n")
+		}
+	}
+
+	if r.Site != nil {
+		fmt.Fprintf(&sb, "Знания о сайте %s:\n", r.Site.Domain)
+		writeSiteInfo(&sb, r.Site)
+	}
+
+	return strings.TrimSpace(sb.String())
+}
+
+// writeSiteInfo перечисляет селекторы сайта по назначению и его формы.
+func writeSiteInfo(sb *strings.Builder, info *SiteInfo) {
+	purposes := slices.Sorted(maps.Keys(info.CommonPatterns))
+	for _, purpose := range purposes {
+		fmt.Fprintf(sb, "- %s: %s\n", purpose, info.CommonPatterns[purpose])
+	}
+	for _, form := range info.FormStructure {
+		fmt.Fprintf(sb, "- форма %s\n", form)
+	}
+}
+
+// formatStepBrief кратко описывает шаг пути: действие и селектор, для навигации - адрес.
+// Введенные значения не показываются: среди них могут быть личные данные прошлой задачи.
+func formatStepBrief(step llm.StepPlan) string {
+	parts := []string{step.Action}
+	if step.Selector != "" {
+		parts = append(parts, step.Selector)
+	}
+	if step.Action == "navigate" && step.Value != "" {
+		parts = append(parts, truncateRunes(step.Value, 80))
+	}
+	return strings.Join(parts, " ")
+}
+
+// embedQuery возвращает вектор задачи из кэша или вычисляет его: внешний эмбеддер
+// не вызывается повторно на каждом шаге одной задачи.
+func (m *AgentMemory) embedQuery(ctx context.Context, task string) ([]float32, error) {
+	m.mu.RLock()
+	query, ok := m.queries[task]
+	m.mu.RUnlock()
+	if ok {
+		return query, nil
+	}
+
+	query, err := m.embedder.Embed(ctx, task)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	if len(m.queries) >= maxCachedQueries {
+		clear(m.queries)
+	}
+	m.queries[task] = query
+	m.mu.Unlock()
+	return query, nil
+}
+
+// embed возвращает вектор текста или nil, если эмбеддер недоступен: запись сохранится
+// и будет находиться по точному совпадению задачи, а вектор пересчитается при следующей загрузке.
+func (m *AgentMemory) embed(ctx context.Context, text string) []float32 {
+	embedding, err := m.embedder.Embed(ctx, text)
+	if err != nil {
+		return nil
+	}
+	return embedding
+}
+
+// embedMissing пересчитывает векторы записей, загруженных без вектора или с вектором другого эмбеддера.
+func (m *AgentMemory) embedMissing(ctx context.Context, paths map[string][]SuccessfulPath, failures map[string]FailurePattern, sites map[string]SiteInfo) {
+	dims := m.embedder.Dimensions()
+	for hash := range paths {
+		for i := range paths[hash] {
+			if len(paths[hash][i].Embedding) != dims {
+				paths[hash][i].Embedding = m.embed(ctx, paths[hash][i].Task)
+			}
+		}
+	}
+	for key, pattern := range failures {
+		if len(pattern.Embedding) != dims {
+			pattern.Embedding = m.embed(ctx, failureText(pattern.Task, pattern.ErrorType, pattern.Action, pattern.Selector))
+			failures[key] = pattern
+		}
+	}
+	for domain, info := range sites {
+		if len(info.Embedding) != dims {
+			info.Embedding = m.embed(ctx, siteText(info.Domain, info.CommonPatterns, info.FormStructure))
+			sites[domain] = info
+		}
+	}
+}
+
+// failureText - описание паттерна ошибки для вектора: задача, при которой ошибка случилась, и сама ошибка.
+// Recall ищет по тексту задачи, поэтому без задачи ошибка не нашлась бы никогда. Способ обхода
+// не входит в описание, чтобы вектор не менялся при его обновлении.
+func failureText(task, errorType, action, selector string) string {
+	return strings.Join([]string{task, action, selector, strings.ReplaceAll(errorType, "_", " ")}, " ")
+}
+
+// siteText - описание сайта для вектора: домен, назначения и селекторы элементов, поля форм.
+func siteText(domain string, patterns map[string]string, forms []string) string {
+	parts := []string{domain}
+	for purpose, selector := range patterns {
+		parts = append(parts, purpose, selector)
+	}
+	parts = append(parts, forms...)
+	return strings.Join(parts, " ")
+}
+
+func parseEmbedding(data string) ([]float32, error) {
+	if data == "" {
+		return nil, nil
+	}
+	var embedding []float32
+	if err := json.Unmarshal([]byte(data), &embedding); err != nil {
+		return nil, err
+	}
+	return embedding, nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net/url"
+	"slices"
 	"strings"
 	"time"
 
@@ -14,8 +15,10 @@ import (
 )
 
 func (a *Agent) ExecuteTaskMultiStep(ctx context.Context, taskText string, maxSteps int) error {
+	a.loopDetector = NewLoopDetector()
+
 	var pageSnapshot *browser.PageSnapshot
-	err := retryAction(ctx, a.retries, a.retryDelay, func() error {
+	err := a.retry(ctx, RetryScopeDefault, func() error {
 		snapshot, err := a.browser.GetPageSnapshot(ctx)
 		if err != nil {
 			return err
@@ -35,29 +38,60 @@ func (a *Agent) ExecuteTaskMultiStep(ctx context.Context, taskText string, maxSt
 
 	domain := extractDomain(pageSnapshot.URL)
 
+	a.criteria = nil
+	a.results = nil
+	if taskID := llm.TaskIDFromContext(ctx); taskID != nil {
+		if task, err := a.repo.GetTaskByID(*taskID); err == nil {
+			a.criteria = ParseCriteria(task.SuccessCriteria)
+			a.initResults(taskID, taskText, task.OutputSchema, task.Result, false)
+		}
+	}
+	if len(a.criteria) == 0 {
+		a.criteria = a.deriveCriteria(ctx, taskText, pageContext, llm.TaskIDFromContext(ctx))
+	}
+
 	if a.memory != nil {
 		existingPath := a.memory.FindSimilarSuccessfulPath(ctx, taskText, domain)
+		// Скрытые значения шагов спрашиваются у пользователя; без него путь не воспроизвести
+		if existingPath != nil && hasValuePlaceholders(existingPath.Steps) && a.userInputProvider == nil {
+			a.log.Info("Путь из памяти требует значений от пользователя, планируем заново", a.contextFields(nil, 0)...)
+			existingPath = nil
+		}
 		if existingPath != nil {
 			a.log.Info("Найден успешный путь в памяти",
 				a.contextFields(nil, 0,
 					zap.String("strategy", existingPath.Strategy),
 					zap.Int("success_count", existingPath.SuccessCount))...)
 
-			fmt.Printf("\n[Memory] Найден проверенный путь (использован %d раз)\n", existingPath.SuccessCount)
-			fmt.Printf("[Стратегия] %s\n\n", existingPath.Strategy)
+			fmt.Fprintf(outputFrom(ctx), "\n[Memory] Найден проверенный путь (использован %d раз)\n", existingPath.SuccessCount)
+			fmt.Fprintf(outputFrom(ctx), "[Стратегия] %s\n\n", existingPath.Strategy)
 
 			plan := &llm.MultiStepPlan{
-				Steps:            existingPath.Steps,
+				Steps:            slices.Clone(existingPath.Steps),
 				OverallStrategy:  existingPath.Strategy,
 				FallbackStrategy: "Replan if step fails",
 				EstimatedSteps:   len(existingPath.Steps),
 			}
 
+			// Шаги пути сверяются со страницей перед выполнением (см. preflightReplayStep)
+			a.replay = &pathReplay{path: existingPath, plan: plan}
+			defer a.finishReplay(ctx)
+
 			return a.executeMultiStepPlanWithMemory(ctx, taskText, plan, maxSteps, domain)
 		}
 	}
 
-	plan, err := a.llmClient.PlanMultiStep(ctx, taskText, pageContext, maxSteps, nil, nil)
+	var plan *llm.MultiStepPlan
+	err = a.retry(ctx, RetryScopeLLM, func() error {
+		return a.callLLM(ctx, func() error {
+			p, e := a.llmClient.PlanMultiStep(ctx, taskText, pageContext, maxSteps, nil, nil)
+			if e != nil {
+				return e
+			}
+			plan = p
+			return nil
+		})
+	})
 	if err != nil {
 		a.log.Error("Ошибка планирования multi-step", a.contextFields(nil, 0, zap.Error(err))...)
 		return fmt.Errorf("failed to plan multi-step: %w", err)
@@ -68,8 +102,8 @@ func (a *Agent) ExecuteTaskMultiStep(ctx context.Context, taskText string, maxSt
 			zap.Int("steps", len(plan.Steps)),
 			zap.String("strategy", plan.OverallStrategy))...)
 
-	fmt.Printf("\n[Стратегия] %s\n", plan.OverallStrategy)
-	fmt.Printf("[Запланировано шагов] %d\n\n", len(plan.Steps))
+	fmt.Fprintf(outputFrom(ctx), "\n[Стратегия] %s\n", plan.OverallStrategy)
+	fmt.Fprintf(outputFrom(ctx), "[Запланировано шагов] %d\n\n", len(plan.Steps))
 
 	return a.executeMultiStepPlanWithMemory(ctx, taskText, plan, maxSteps, domain)
 }
@@ -98,17 +132,36 @@ func (a *Agent) executeMultiStepPlan(ctx context.Context, taskText string, plan
 		}
 
 		stepNumber := stepNo + 1
-		fmt.Printf("[Шаг %d/%d] %s: %s\n", stepNumber, len(plan.Steps), step.Action, step.Reasoning)
+		a.attempts = nil
+
+		if err := a.stopIfBudgetExceeded(llm.TaskIDFromContext(ctx), stepNumber, true); err != nil {
+			return err
+		}
+
+		if takeoverRequested(ctx) {
+			takeoverStep := userTakeoverPlan()
+			observation, err := a.takeover(ctx, takeoverStep.Value)
+			if err == nil {
+				return a.replanAfterTakeover(ctx, taskText, plan, takeoverStep, observation, stepNumber-1, maxSteps, domain)
+			}
+			a.log.Warn("Передача управления не выполнена", a.contextFields(nil, stepNumber, zap.Error(err))...)
+		}
+
+		fmt.Fprintf(outputFrom(ctx), "[Шаг %d/%d] %s: %s\n", stepNumber, len(plan.Steps), step.Action, step.Reasoning)
 
 		if step.Action == "complete" {
 			a.log.Info("Задача завершена согласно плану", a.contextFields(nil, stepNumber)...)
-			return nil
+			return a.finishMultiStepPlan(ctx, taskText, plan, &step, stepNumber-1, maxSteps, domain)
 		}
 
 		pageSnapshot, err := a.browser.GetPageSnapshot(ctx)
 		if err != nil {
 			a.log.Warn("Не удалось получить snapshot перед шагом", a.contextFields(nil, stepNumber, zap.Error(err))...)
 		}
+		a.preflightReplayStep(ctx, taskText, plan, stepNo, &step, pageSnapshot)
+		if err := a.fillPathValue(ctx, &step, stepNumber); err != nil {
+			return err
+		}
 
 		isDangerous, llmMessage, err := a.securityChecker.IsDangerousAction(ctx, step.Action, step.Selector, step.Value, step.Reasoning)
 		if err != nil {
@@ -126,17 +179,27 @@ func (a *Agent) executeMultiStepPlan(ctx context.Context, taskText string, plan
 				answerLower := strings.ToLower(strings.TrimSpace(answer))
 				if answerLower != "yes" && answerLower != "y" && answerLower != "да" && answerLower != "д" {
 					a.log.Info("Пользователь отменил опасное действие", a.contextFields(nil, stepNumber, zap.String("action", step.Action))...)
-					fmt.Printf("[Шаг %d] Действие отменено пользователем\n", stepNumber)
+					fmt.Fprintf(outputFrom(ctx), "[Шаг %d] Действие отменено пользователем\n", stepNumber)
 					continue
 				}
 			}
 		}
 
-		_, err = a.executeActionWithRetry(ctx, &step)
+		var element *browser.ElementInfo
+		if pageSnapshot != nil {
+			// Обходы ошибок ищут альтернативные селекторы в снимке страницы перед шагом
+			a.snapshot = pageSnapshot
+			element = a.snapshotElement(step.Selector)
+		}
+		result, err := a.executeActionWithRecovery(ctx, &step, element)
+		if err == nil && step.Action == "takeover" {
+			return a.replanAfterTakeover(ctx, taskText, plan, &step, result, stepNumber, maxSteps, domain)
+		}
 		if err != nil {
 			a.log.Error("Ошибка выполнения шага", a.contextFields(nil, stepNumber, zap.String("action", step.Action), zap.Error(err))...)
 
-			if isCriticalError(err) {
+			// Заблокированную предохранителем цель бесполезно трогать следующими шагами плана
+			if isCriticalError(err) || ErrorKindOf(err) == ErrorKindCircuitOpen {
 				a.log.Error("Критическая ошибка, требуется replan", a.contextFields(nil, stepNumber, zap.Error(err))...)
 
 				var currentContext string
@@ -144,34 +207,140 @@ func (a *Agent) executeMultiStepPlan(ctx context.Context, taskText string, plan
 					currentContext = a.limitContextFromSnapshot(pageSnapshot)
 				}
 
-				newPlan, replanErr := a.llmClient.Replan(ctx, taskText, currentContext, plan, &step, err.Error(), maxSteps-stepNumber, nil, nil)
+				observation := err.Error()
+				if circuit := circuitObservation(err); circuit != "" {
+					observation += ". " + circuit
+				}
+				newPlan, replanErr := a.replan(ctx, taskText, currentContext, plan, &step, observation, maxSteps-stepNumber)
 				if replanErr != nil {
 					a.log.Error("Не удалось создать новый план", a.contextFields(nil, stepNumber, zap.Error(replanErr))...)
 					return fmt.Errorf("failed to replan after error: %w", replanErr)
 				}
 
 				a.log.Info("Новый план создан после ошибки", a.contextFields(nil, stepNumber, zap.Int("new_steps", len(newPlan.Steps)))...)
-				fmt.Printf("\n[Replan] Новая стратегия: %s\n", newPlan.OverallStrategy)
-				fmt.Printf("[Новых шагов] %d\n\n", len(newPlan.Steps))
+				fmt.Fprintf(outputFrom(ctx), "\n[Replan] Новая стратегия: %s\n", newPlan.OverallStrategy)
+				fmt.Fprintf(outputFrom(ctx), "[Новых шагов] %d\n\n", len(newPlan.Steps))
 
 				return a.executeMultiStepPlan(ctx, taskText, newPlan, maxSteps-stepNumber, domain)
 			}
 
 			a.log.Warn("Некритическая ошибка, продолжаем выполнение", a.contextFields(nil, stepNumber, zap.Error(err))...)
-			fmt.Printf("[Шаг %d] Ошибка: %v (продолжаем)\n", stepNumber, err)
+			fmt.Fprintf(outputFrom(ctx), "[Шаг %d] Ошибка: %v (продолжаем)\n", stepNumber, err)
 
+			// Известные и стандартные обходы уже не помогли (см. executeActionWithRecovery)
 			if a.memory != nil {
-				recovery := a.memory.GetFailureRecovery(ctx, step.Action, step.Selector, err.Error())
-				if recovery != "" {
-					a.log.Info("Найдена стратегия восстановления в памяти", a.contextFields(nil, stepNumber, zap.String("recovery", recovery))...)
-					fmt.Printf("[Memory] Применяем известную стратегию восстановления: %s\n", recovery)
+				if err := a.memory.RecordFailure(ctx, taskText, step.Action, step.Selector, ErrorKindOf(err), ""); err != nil {
+					a.log.Warn("Не удалось сохранить неудачный паттерн", a.contextFields(nil, stepNumber, zap.Error(err))...)
 				}
-				a.memory.RecordFailure(ctx, step.Action, step.Selector, err.Error(), "")
 			}
 		}
+
+		// Зацикливание в фиксированном плане нельзя исправить подсказкой модели на следующем шаге,
+		// поэтому любое обнаружение приводит к перепланированию с наблюдением о цикле
+		var fingerprintBefore, currentContext string
+		if pageSnapshot != nil {
+			fingerprintBefore = PageFingerprint(pageSnapshot.URL, pageSnapshot.Title, a.limitContextFromSnapshot(pageSnapshot))
+		}
+		var urlAfter, fingerprintAfter string
+		if snapshotAfter, snapErr := a.browser.GetPageSnapshot(ctx); snapErr == nil && snapshotAfter != nil {
+			currentContext = a.limitContextFromSnapshot(snapshotAfter)
+			urlAfter = snapshotAfter.URL
+			fingerprintAfter = PageFingerprint(snapshotAfter.URL, snapshotAfter.Title, currentContext)
+		}
+
+		loop := a.loopDetector.Observe(&step, urlAfter, fingerprintBefore, fingerprintAfter)
+		if loop == nil {
+			continue
+		}
+		observation, loopErr := a.handleLoop(ctx, llm.TaskIDFromContext(ctx), stepNumber, loop)
+		if loopErr != nil {
+			return loopErr
+		}
+
+		newPlan, replanErr := a.replan(ctx, taskText, currentContext, plan, &step, observation, maxSteps-stepNumber)
+		if replanErr != nil {
+			a.log.Error("Не удалось создать новый план после зацикливания", a.contextFields(nil, stepNumber, zap.Error(replanErr))...)
+			return fmt.Errorf("failed to replan after loop: %w", replanErr)
+		}
+
+		a.log.Info("Новый план создан после зацикливания", a.contextFields(nil, stepNumber, zap.Int("new_steps", len(newPlan.Steps)))...)
+		fmt.Fprintf(outputFrom(ctx), "\n[Replan] Новая стратегия: %s\n", newPlan.OverallStrategy)
+		fmt.Fprintf(outputFrom(ctx), "[Новых шагов] %d\n\n", len(newPlan.Steps))
+
+		return a.executeMultiStepPlan(ctx, taskText, newPlan, maxSteps-stepNumber, domain)
 	}
 
 	a.log.Info("Все шаги выполнены", a.contextFields(nil, 0)...)
+	executed := min(len(plan.Steps), maxSteps)
+	if executed == 0 {
+		return nil
+	}
+	return a.finishMultiStepPlan(ctx, taskText, plan, &plan.Steps[executed-1], executed, maxSteps, domain)
+}
+
+// replan перестраивает план на remaining шагов с учетом наблюдения о шаге step.
+func (a *Agent) replan(ctx context.Context, taskText, pageContext string, plan *llm.MultiStepPlan, step *llm.StepPlan, observation string, remaining int) (*llm.MultiStepPlan, error) {
+	var newPlan *llm.MultiStepPlan
+	err := a.retry(ctx, RetryScopeLLM, func() error {
+		return a.callLLM(ctx, func() error {
+			p, e := a.llmClient.Replan(ctx, taskText, pageContext, plan, step, observation, remaining, nil, nil)
+			if e != nil {
+				return e
+			}
+			newPlan = p
+			return nil
+		})
+	})
+	return newPlan, err
+}
+
+// finishMultiStepPlan проверяет критерии успеха после выполнения плана.
+// Если критерии не выполнены, план перестраивается на оставшиеся шаги; иначе задача
+// помечается выполненной, а вердикт с доказательствами сохраняется в ResultSummary.
+func (a *Agent) finishMultiStepPlan(ctx context.Context, taskText string, plan *llm.MultiStepPlan, lastStep *llm.StepPlan, executed, maxSteps int, domain string) error {
+	taskID := llm.TaskIDFromContext(ctx)
+
+	var currentContext string
+	if snapshot, err := a.browser.GetPageSnapshot(ctx); err == nil && snapshot != nil {
+		currentContext = a.limitContextFromSnapshot(snapshot)
+	}
+
+	verdict, verifyErr := a.verifyCompletion(ctx, taskText, a.criteria, currentContext, taskID, executed)
+	if verifyErr != nil {
+		return fmt.Errorf("ошибка проверки критериев успеха: %w", verifyErr)
+	}
+
+	if verdict != nil && !verdict.Passed {
+		observation := unmetObservation(verdict)
+		fmt.Fprintf(outputFrom(ctx), "[Шаг %d] Завершение отклонено: критерии успеха не выполнены (%d)\n", executed, len(verdict.Unmet()))
+
+		remaining := maxSteps - executed
+		if remaining <= 0 {
+			return fmt.Errorf("достигнут лимит шагов, критерии успеха не выполнены: %s", observation)
+		}
+
+		newPlan, replanErr := a.replan(ctx, taskText, currentContext, plan, lastStep, observation, remaining)
+		if replanErr != nil {
+			a.log.Error("Не удалось создать новый план после проверки критериев", a.contextFields(taskID, executed, zap.Error(replanErr))...)
+			return fmt.Errorf("failed to replan after unmet criteria: %w", replanErr)
+		}
+
+		a.log.Info("Новый план создан после проверки критериев", a.contextFields(taskID, executed, zap.Int("new_steps", len(newPlan.Steps)))...)
+		fmt.Fprintf(outputFrom(ctx), "\n[Replan] Новая стратегия: %s\n", newPlan.OverallStrategy)
+		fmt.Fprintf(outputFrom(ctx), "[Новых шагов] %d\n\n", len(newPlan.Steps))
+
+		return a.executeMultiStepPlan(ctx, taskText, newPlan, remaining, domain)
+	}
+
+	if taskID != nil {
+		reasoning := lastStep.Reasoning
+		if reasoning == "" {
+			reasoning = plan.OverallStrategy
+		}
+		if err := a.repo.UpdateTaskStatus(*taskID, "completed", a.completionSummary(reasoning, verdict)); err != nil {
+			a.log.Error("Ошибка обновления статуса", a.contextFields(taskID, executed, zap.Error(err))...)
+		}
+	}
 	return nil
 }
 
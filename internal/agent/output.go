@@ -0,0 +1,23 @@
+package agent
+
+import (
+	"context"
+	"io"
+	"os"
+)
+
+type outputKey struct{}
+
+// WithOutput возвращает контекст, в котором пошаговый вывод агента направляется в w.
+// Используется для фоновых задач: каждая задача пишет в собственный буфер.
+func WithOutput(ctx context.Context, w io.Writer) context.Context {
+	return context.WithValue(ctx, outputKey{}, w)
+}
+
+// outputFrom возвращает writer для пошагового вывода агента (по умолчанию stdout).
+func outputFrom(ctx context.Context) io.Writer {
+	if w, ok := ctx.Value(outputKey{}).(io.Writer); ok && w != nil {
+		return w
+	}
+	return os.Stdout
+}
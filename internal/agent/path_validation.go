@@ -0,0 +1,180 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"aiAgent/internal/browser"
+	"aiAgent/internal/llm"
+
+	"go.uber.org/zap"
+)
+
+// Путь из памяти воспроизводится с проверкой: перед каждым шагом с селектором селектор сверяется
+// с живой страницей - элемент есть, видим и единственный. Если верстка изменилась, модель подбирает
+// новый селектор по намерению шага (step.Reasoning), и шаг исправляется на месте, без отказа от пути.
+// Исправленный путь попадает в память, только если задача завершится успешно (см. RecordSuccess).
+// Доля совпавших шагов обновляет уверенность в пути (см. AgentMemory.RecordPathMatch).
+// Введенные значения, которые память не хранит, спрашиваются у пользователя перед шагом (см. fillPathValue).
+
+// stepMatch - результат проверки шага пути.
+type stepMatch int
+
+const (
+	stepMatched    stepMatch = iota // Селектор совпал со страницей
+	stepPatched                     // Селектор исправлен по намерению шага
+	stepMismatched                  // Не совпал, исправить не удалось - шаг выполняется как есть
+)
+
+// pathReplay - воспроизведение пути из памяти.
+type pathReplay struct {
+	path    *SuccessfulPath
+	plan    *llm.MultiStepPlan // План из шагов пути; шаги планов после перепланирования не проверяются
+	checked int
+	matched int
+	patched int
+}
+
+// quality - доля шагов, совпавших со страницей; исправленный шаг считается за половину.
+func (r *pathReplay) quality() float64 {
+	if r.checked == 0 {
+		return 1
+	}
+	return (float64(r.matched) + 0.5*float64(r.patched)) / float64(r.checked)
+}
+
+// preflightReplayStep проверяет шаг воспроизводимого пути перед выполнением и при необходимости
+// исправляет его в step и в плане. Шаги без селектора и шаги других планов не проверяются.
+func (a *Agent) preflightReplayStep(ctx context.Context, taskText string, plan *llm.MultiStepPlan, stepNo int, step *llm.StepPlan, snapshot *browser.PageSnapshot) {
+	if a.replay == nil || a.replay.plan != plan || step.Selector == "" {
+		return
+	}
+	switch step.Action {
+	case "click", "type":
+	default:
+		return
+	}
+
+	a.replay.checked++
+	switch a.preflightStep(ctx, taskText, step, snapshot, stepNo+1) {
+	case stepMatched:
+		a.replay.matched++
+	case stepPatched:
+		a.replay.patched++
+		plan.Steps[stepNo] = *step
+	}
+}
+
+// fillPathValue спрашивает у пользователя значение шага ввода, скрытое в памяти (см. AgentMemory.redactSteps).
+// Значение подставляется только в выполняемый шаг: в план и в память оно не попадает.
+func (a *Agent) fillPathValue(ctx context.Context, step *llm.StepPlan, stepNumber int) error {
+	if step.Action != "type" || step.Value != pathValuePlaceholder || a.dryRun {
+		return nil
+	}
+	if a.userInputProvider == nil {
+		return fmt.Errorf("шаг %d пути из памяти требует значения, а провайдер пользовательского ввода не настроен", stepNumber)
+	}
+
+	question := fmt.Sprintf("Шаг %d пути из памяти вводит значение в %s (%s). Какое значение ввести?", stepNumber, step.Selector, step.Reasoning)
+	answer, err := a.userInputProvider.AskUser(ctx, question)
+	if err != nil {
+		return fmt.Errorf("ошибка запроса значения шага: %w", err)
+	}
+	step.Value = strings.TrimSpace(answer)
+	a.log.Info("Значение шага пути получено от пользователя", a.contextFields(llm.TaskIDFromContext(ctx), stepNumber, zap.String("selector", step.Selector))...)
+	return nil
+}
+
+// preflightStep сверяет селектор шага со страницей и при несовпадении просит модель исправить шаг.
+func (a *Agent) preflightStep(ctx context.Context, taskText string, step *llm.StepPlan, snapshot *browser.PageSnapshot, stepNumber int) stepMatch {
+	taskID := llm.TaskIDFromContext(ctx)
+
+	match, err := a.browser.InspectSelector(ctx, step.Selector)
+	if err == nil && match.Unique() {
+		return stepMatched
+	}
+	problem := selectorProblem(match, err)
+	a.log.Info("Шаг пути из памяти не совпал со страницей", a.contextFields(taskID, stepNumber,
+		zap.String("action", step.Action),
+		zap.String("selector", step.Selector),
+		zap.String("problem", problem))...)
+
+	if a.llmClient == nil || snapshot == nil {
+		return stepMismatched
+	}
+
+	pageContext := a.limitContextFromSnapshot(snapshot)
+	var patched *llm.StepPlan
+	err = a.retry(ctx, RetryScopeLLM, func() error {
+		return a.callLLM(ctx, func() error {
+			p, e := a.llmClient.PatchStep(ctx, taskText, pageContext, step, problem, taskID, nil)
+			if e != nil {
+				return e
+			}
+			patched = p
+			return nil
+		})
+	})
+	if err != nil {
+		a.log.Warn("Ошибка исправления шага пути, выполняем как есть", a.contextFields(taskID, stepNumber, zap.Error(err))...)
+		return stepMismatched
+	}
+	if patched == nil {
+		a.log.Info("Модель не нашла элемент шага на странице", a.contextFields(taskID, stepNumber, zap.String("selector", step.Selector))...)
+		return stepMismatched
+	}
+
+	// Исправление принимается, только если новый селектор сам проходит проверку
+	if match, err := a.browser.InspectSelector(ctx, patched.Selector); err != nil || !match.Unique() {
+		a.log.Info("Исправленный селектор не прошел проверку", a.contextFields(taskID, stepNumber,
+			zap.String("selector", patched.Selector),
+			zap.String("problem", selectorProblem(match, err)))...)
+		return stepMismatched
+	}
+
+	fmt.Fprintf(outputFrom(ctx), "[Memory] Шаг %d исправлен под страницу: %s -> %s\n", stepNumber, step.Selector, patched.Selector)
+	a.log.Info("Шаг пути из памяти исправлен", a.contextFields(taskID, stepNumber,
+		zap.String("old_selector", step.Selector),
+		zap.String("new_selector", patched.Selector))...)
+	*step = *patched
+	return stepPatched
+}
+
+// selectorProblem описывает для модели, чем селектор не подошел.
+func selectorProblem(match browser.SelectorMatch, err error) string {
+	switch {
+	case err != nil:
+		return fmt.Sprintf("селектор не удалось проверить: %v", err)
+	case match.Count == 0:
+		return "селектор не нашел на странице ни одного элемента"
+	case match.Visible == 0:
+		return fmt.Sprintf("селектор нашел %d элемент(ов), но ни один не видим", match.Count)
+	default:
+		return fmt.Sprintf("селектор нашел %d элемент(ов), из них видимых %d - нужен ровно один", match.Count, match.Visible)
+	}
+}
+
+// finishReplay сохраняет в память, насколько путь совпал со страницей.
+func (a *Agent) finishReplay(ctx context.Context) {
+	replay := a.replay
+	a.replay = nil
+	if replay == nil || replay.checked == 0 || a.memory == nil {
+		return
+	}
+
+	taskID := llm.TaskIDFromContext(ctx)
+	quality := replay.quality()
+	a.log.Info("Проверка пути из памяти завершена", a.contextFields(taskID, 0,
+		zap.Uint("path_id", replay.path.ID),
+		zap.Int("checked", replay.checked),
+		zap.Int("matched", replay.matched),
+		zap.Int("patched", replay.patched),
+		zap.Float64("quality", quality))...)
+	fmt.Fprintf(outputFrom(ctx), "[Memory] Совпадение пути со страницей: %d из %d шагов, исправлено %d\n",
+		replay.matched, replay.checked, replay.patched)
+
+	if err := a.memory.RecordPathMatch(replay.path.ID, quality); err != nil {
+		a.log.Warn("Не удалось обновить уверенность в пути", a.contextFields(taskID, 0, zap.Error(err))...)
+	}
+}
@@ -0,0 +1,51 @@
+package agent
+
+import (
+	"errors"
+	"testing"
+
+	"aiAgent/internal/browser"
+)
+
+func TestPathReplayQuality(t *testing.T) {
+	tests := []struct {
+		name                      string
+		checked, matched, patched int
+		want                      float64
+	}{
+		{"ничего не проверено", 0, 0, 0, 1},
+		{"все совпали", 4, 4, 0, 1},
+		{"исправленный за половину", 2, 1, 1, 0.75},
+		{"ничего не совпало", 3, 0, 0, 0},
+		{"только исправленные", 2, 0, 2, 0.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &pathReplay{checked: tt.checked, matched: tt.matched, patched: tt.patched}
+			if got := r.quality(); got != tt.want {
+				t.Errorf("quality() = %v, ожидалось %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectorProblem(t *testing.T) {
+	tests := []struct {
+		name  string
+		match browser.SelectorMatch
+		err   error
+		want  string
+	}{
+		{"ошибка проверки", browser.SelectorMatch{}, errors.New("невалидный селектор"), "селектор не удалось проверить: невалидный селектор"},
+		{"не найден", browser.SelectorMatch{}, nil, "селектор не нашел на странице ни одного элемента"},
+		{"не видим", browser.SelectorMatch{Count: 2}, nil, "селектор нашел 2 элемент(ов), но ни один не видим"},
+		{"несколько видимых", browser.SelectorMatch{Count: 3, Visible: 2}, nil, "селектор нашел 3 элемент(ов), из них видимых 2 - нужен ровно один"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := selectorProblem(tt.match, tt.err); got != tt.want {
+				t.Errorf("selectorProblem() = %q, ожидалось %q", got, tt.want)
+			}
+		})
+	}
+}
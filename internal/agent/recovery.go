@@ -0,0 +1,348 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"aiAgent/internal/browser"
+	"aiAgent/internal/llm"
+
+	"go.uber.org/zap"
+)
+
+// Восстановление после ошибки действия: если клик, ввод или переход не удался даже после повторов,
+// агент пробует обходы - сначала известный из памяти для этой ошибки, действия и селектора, затем
+// стандартные. Сработавший обход сохраняется как Recovery паттерна ошибки, и в следующий раз
+// применяется первым.
+
+// Способы обхода ошибки действия (значение FailurePattern.Recovery)
+const (
+	RecoveryAlternateSelector = "alternate_selector" // Другой селектор того же элемента: "alternate_selector:<селектор>"
+	RecoveryScrollFirst       = "scroll_first"       // Прокрутить к элементу (или вниз, если его еще нет) и повторить
+	RecoveryCloseOverlay      = "close_overlay"      // Закрыть попап или оверлей и повторить
+	RecoveryWaitNetwork       = "wait_network"       // Дождаться завершения сетевых запросов и повторить
+)
+
+const (
+	maxRecoveryAttempts  = 4               // Сколько обходов пробуется для одной ошибки
+	maxAlternateSelector = 2               // Сколько альтернативных селекторов среди них
+	recoveryWaitTimeout  = 5 * time.Second // Ожидание сети для wait_network
+	recoveryScrollStep   = 800             // Прокрутка вниз, если элемента еще нет на странице, px
+)
+
+// recoveryPolicy - после обхода действие выполняется одной попыткой: повторы по политике действия
+// уже исчерпаны исходной ошибкой, и каждый обход не должен заново получать MaxElapsed.
+var recoveryPolicy = RetryPolicy{MaxAttempts: 1}
+
+// Recovery - способ обхода ошибки действия.
+type Recovery struct {
+	Kind     string
+	Selector string // Селектор для alternate_selector
+}
+
+// ParseRecovery разбирает сохраненный способ обхода. Произвольный текст (записи старого формата) не распознается.
+func ParseRecovery(s string) (Recovery, bool) {
+	kind, selector, _ := strings.Cut(strings.TrimSpace(s), ":")
+	switch kind {
+	case RecoveryScrollFirst, RecoveryCloseOverlay, RecoveryWaitNetwork:
+		return Recovery{Kind: kind}, true
+	case RecoveryAlternateSelector:
+		if selector == "" {
+			return Recovery{}, false
+		}
+		return Recovery{Kind: kind, Selector: selector}, true
+	}
+	return Recovery{}, false
+}
+
+// String возвращает способ обхода в формате FailurePattern.Recovery
+func (r Recovery) String() string {
+	if r.Kind == RecoveryAlternateSelector {
+		return r.Kind + ":" + r.Selector
+	}
+	return r.Kind
+}
+
+// Describe возвращает описание способа обхода для пользователя и транскрипта
+func (r Recovery) Describe() string {
+	switch r.Kind {
+	case RecoveryAlternateSelector:
+		return "другой селектор " + r.Selector
+	case RecoveryScrollFirst:
+		return "прокрутка к элементу"
+	case RecoveryCloseOverlay:
+		return "закрытие оверлея"
+	case RecoveryWaitNetwork:
+		return "ожидание загрузки"
+	}
+	return r.Kind
+}
+
+// recoveryTransferable сообщает, подходит ли обход для других селекторов того же действия.
+// Альтернативный селектор относится к конкретному элементу.
+func recoveryTransferable(recovery string) bool {
+	r, ok := ParseRecovery(recovery)
+	return !ok || r.Kind != RecoveryAlternateSelector
+}
+
+// executeActionWithRecovery выполняет действие с повторами, а при ошибке пробует обходы (каждый - одной попыткой).
+// element - элемент действия в снимке страницы (nil, если его нет в снимке).
+func (a *Agent) executeActionWithRecovery(ctx context.Context, plan *llm.StepPlan, element *browser.ElementInfo) (string, error) {
+	result, err := a.executeActionWithRetry(ctx, plan)
+	if err == nil || !a.canRecover(ctx, plan, err) {
+		return result, err
+	}
+
+	taskID := llm.TaskIDFromContext(ctx)
+	for _, recovery := range a.recoveryCandidates(ctx, plan, element, err) {
+		res, recErr := a.applyRecovery(ctx, plan, element, recovery)
+		if recErr != nil {
+			a.log.Debug("Обход ошибки не помог", a.contextFields(taskID, 0,
+				zap.String("action", plan.Action),
+				zap.String("recovery", recovery.String()),
+				zap.Error(recErr))...)
+			if ctx.Err() != nil {
+				return "", ctx.Err()
+			}
+			continue
+		}
+
+		a.log.Info("Ошибка действия обойдена", a.contextFields(taskID, 0,
+			zap.String("action", plan.Action),
+			zap.String("selector", plan.Selector),
+			zap.String("recovery", recovery.String()),
+			zap.NamedError("original_error", err))...)
+		fmt.Fprintf(outputFrom(ctx), "[Recovery] %s: %s\n", plan.Action, recovery.Describe())
+		a.rememberRecovery(ctx, plan, err, recovery)
+		return fmt.Sprintf("%s (после ошибки помогло: %s)", res, recovery.Describe()), nil
+	}
+
+	return "", err
+}
+
+// canRecover проверяет, имеет ли смысл обходить ошибку: отмена задачи, критичные ошибки
+// и заблокированная предохранителем цель не обходятся.
+func (a *Agent) canRecover(ctx context.Context, plan *llm.StepPlan, err error) bool {
+	if a.dryRun || ctx.Err() != nil || ErrorKindOf(err) == ErrorKindCircuitOpen || isCriticalError(err) {
+		return false
+	}
+	switch plan.Action {
+	case "click", "type", "navigate":
+		return true
+	}
+	return false
+}
+
+// recoveryCandidates возвращает обходы в порядке попыток: известный из памяти, затем стандартные.
+func (a *Agent) recoveryCandidates(ctx context.Context, plan *llm.StepPlan, element *browser.ElementInfo, err error) []Recovery {
+	var candidates []Recovery
+	seen := make(map[string]bool)
+	add := func(r Recovery) {
+		if len(candidates) < maxRecoveryAttempts && !seen[r.String()] && (r.Kind != RecoveryAlternateSelector || r.Selector != plan.Selector) {
+			seen[r.String()] = true
+			candidates = append(candidates, r)
+		}
+	}
+
+	if a.memory != nil {
+		if known, ok := ParseRecovery(a.memory.GetFailureRecovery(ctx, plan.Action, plan.Selector, ErrorKindOf(err))); ok {
+			add(known)
+		}
+	}
+
+	if plan.Action == "navigate" {
+		add(Recovery{Kind: RecoveryWaitNetwork})
+		return candidates
+	}
+
+	kind := ErrorKindOf(err)
+	timeout := kind == ErrorKindTimeout || kind == ErrorKindNavigationTimeout
+	if timeout {
+		add(Recovery{Kind: RecoveryWaitNetwork})
+	}
+	add(Recovery{Kind: RecoveryCloseOverlay})
+	add(Recovery{Kind: RecoveryScrollFirst})
+	for _, selector := range a.alternateSelectors(ctx, plan, element) {
+		add(Recovery{Kind: RecoveryAlternateSelector, Selector: selector})
+	}
+	if !timeout {
+		add(Recovery{Kind: RecoveryWaitNetwork})
+	}
+	return candidates
+}
+
+// alternateSelectors ищет другие селекторы того же элемента: запомненный для сайта по назначению
+// элемента, селекторы по id, name и пути в DOM и селекторы элементов снимка с той же подписью.
+// Принимаются только селекторы, которые на странице указывают на тот же элемент (см. sameElement):
+// одинаковая подпись бывает у кнопок «Удалить» в разных строках.
+func (a *Agent) alternateSelectors(ctx context.Context, plan *llm.StepPlan, element *browser.ElementInfo) []string {
+	if element == nil {
+		return nil
+	}
+
+	var selectors []string
+	var tried []string
+	add := func(selector string) {
+		if selector == "" || selector == plan.Selector || len(selectors) >= maxAlternateSelector || slices.Contains(tried, selector) {
+			return
+		}
+		tried = append(tried, selector)
+		if a.sameElement(ctx, selector, element) {
+			selectors = append(selectors, selector)
+		}
+	}
+
+	if info := a.currentSiteKnowledge(ctx); info != nil {
+		if purpose := elementPurpose(plan.Action, element); purpose != "" {
+			add(info.CommonPatterns[purpose])
+		}
+	}
+
+	if element.ID != "" {
+		add(fmt.Sprintf(`%s[id=%q]`, element.Tag, element.ID))
+	}
+	if element.Name != "" {
+		add(fmt.Sprintf(`%s[name=%q]`, element.Tag, element.Name))
+	}
+	add(element.Path)
+
+	if a.snapshot != nil {
+		for _, other := range a.snapshot.Elements {
+			if other.Visible && other.Tag == element.Tag && sameCaption(other, *element) {
+				add(other.Selector)
+			}
+		}
+	}
+	return selectors
+}
+
+// sameElement проверяет, что селектор указывает на странице ровно на один видимый элемент и это
+// элемент снимка: совпадает id, name или подпись (если они уникальны в снимке) либо путь в DOM
+// вместе с подписью. Путь без подписи не подходит - после удаления строки на ее место встает соседняя.
+func (a *Agent) sameElement(ctx context.Context, selector string, element *browser.ElementInfo) bool {
+	match, err := a.browser.InspectSelector(ctx, selector)
+	if err != nil || !match.Unique() || match.Tag != element.Tag {
+		return false
+	}
+
+	switch {
+	case element.ID != "" && match.ID == element.ID:
+		return true
+	case element.Name != "" && match.Name == element.Name && a.snapshotUnique(func(e browser.ElementInfo) bool { return e.Name == element.Name }):
+		return true
+	case element.Label != "" && match.Label == element.Label && a.snapshotUnique(func(e browser.ElementInfo) bool { return e.Label == element.Label }):
+		return true
+	case element.Path != "" && match.Path == element.Path:
+		return sameCaption(browser.ElementInfo{Label: match.Label, Text: match.Text}, *element)
+	}
+	return false
+}
+
+// snapshotUnique сообщает, что в снимке страницы перед шагом ровно один элемент подходит под условие.
+func (a *Agent) snapshotUnique(matches func(browser.ElementInfo) bool) bool {
+	if a.snapshot == nil {
+		return false
+	}
+	n := 0
+	for _, e := range a.snapshot.Elements {
+		if matches(e) {
+			n++
+		}
+	}
+	return n == 1
+}
+
+// applyRecovery выполняет подготовку обхода и повторяет действие одной попыткой (см. recoveryPolicy)
+// через предохранители сайта и действия.
+func (a *Agent) applyRecovery(ctx context.Context, plan *llm.StepPlan, element *browser.ElementInfo, recovery Recovery) (string, error) {
+	switch recovery.Kind {
+	case RecoveryAlternateSelector:
+		// Обход из памяти мог быть записан для другого элемента - проверяется так же, как найденные сейчас
+		if element == nil || !a.sameElement(ctx, recovery.Selector, element) {
+			return "", fmt.Errorf("селектор %s указывает не на тот же элемент", recovery.Selector)
+		}
+		alternate := *plan
+		alternate.Selector = recovery.Selector
+		// Подтверждение относилось к исходному селектору - замена проверяется заново
+		confirmed, err := a.checkSecurityAndConfirm(ctx, &alternate, 0)
+		if err != nil {
+			return "", err
+		}
+		if !confirmed {
+			return "", fmt.Errorf("действие с селектором %s отменено пользователем", recovery.Selector)
+		}
+		return a.executeActionWithPolicy(ctx, &alternate, recoveryPolicy)
+
+	case RecoveryScrollFirst:
+		if err := a.browser.ScrollToElement(ctx, plan.Selector); err != nil {
+			// Элемента еще нет на странице - возможно, он подгружается при прокрутке
+			if err := a.browser.ScrollByAmount(ctx, 0, recoveryScrollStep); err != nil {
+				return "", err
+			}
+		}
+
+	case RecoveryCloseOverlay:
+		if err := a.browser.ClosePopups(ctx); err != nil {
+			return "", err
+		}
+		if info := a.currentSiteKnowledge(ctx); info != nil {
+			if selector := info.CommonPatterns[closePopupPurpose]; selector != "" {
+				_ = a.browser.Click(ctx, selector)
+			}
+		}
+
+	case RecoveryWaitNetwork:
+		// Сеть может так и не успокоиться (long polling) - действие все равно повторяется
+		_ = a.browser.WaitForNetworkIdle(ctx, recoveryWaitTimeout)
+	}
+
+	return a.executeActionWithPolicy(ctx, plan, recoveryPolicy)
+}
+
+// rememberRecovery сохраняет сработавший обход как способ восстановления паттерна ошибки.
+func (a *Agent) rememberRecovery(ctx context.Context, plan *llm.StepPlan, err error, recovery Recovery) {
+	if a.memory == nil {
+		return
+	}
+	if saveErr := a.memory.RecordRecovery(ctx, plan.Action, plan.Selector, ErrorKindOf(err), recovery.String()); saveErr != nil {
+		a.log.Warn("Не удалось сохранить способ восстановления", zap.Error(saveErr))
+	}
+}
+
+// failedAction - действие шага, завершившееся ошибкой.
+type failedAction struct {
+	plan llm.StepPlan
+	err  error
+}
+
+// rememberWorkaround сохраняет обход, найденный моделью: если после ошибки следующий шаг выполнил
+// то же действие с другим селектором и цель шага достигнута, этот селектор - рабочая замена.
+func (a *Agent) rememberWorkaround(ctx context.Context, failed *failedAction, plan *llm.StepPlan) {
+	if failed == nil || failed.plan.Action != plan.Action || plan.Selector == "" || failed.plan.Selector == "" || failed.plan.Selector == plan.Selector {
+		return
+	}
+	a.rememberRecovery(ctx, &failed.plan, failed.err, Recovery{Kind: RecoveryAlternateSelector, Selector: plan.Selector})
+}
+
+// currentSiteKnowledge возвращает знания о сайте текущей страницы.
+func (a *Agent) currentSiteKnowledge(ctx context.Context) *SiteInfo {
+	if a.memory == nil {
+		return nil
+	}
+	url, _, _ := a.browser.GetPageInfo(ctx)
+	domain := extractDomain(url)
+	if domain == "" {
+		return nil
+	}
+	return a.memory.GetSiteKnowledge(ctx, domain)
+}
+
+// sameCaption сравнивает подписи элементов (aria-label или текст).
+func sameCaption(a, b browser.ElementInfo) bool {
+	captionA := strings.TrimSpace(a.Label + " " + a.Text)
+	captionB := strings.TrimSpace(b.Label + " " + b.Text)
+	return captionA != "" && captionA == captionB
+}
@@ -0,0 +1,57 @@
+package agent
+
+import "testing"
+
+func TestParseRecovery(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		want   Recovery
+		wantOK bool
+	}{
+		{"прокрутка", "scroll_first", Recovery{Kind: RecoveryScrollFirst}, true},
+		{"закрытие оверлея", "close_overlay", Recovery{Kind: RecoveryCloseOverlay}, true},
+		{"ожидание сети", "wait_network", Recovery{Kind: RecoveryWaitNetwork}, true},
+		{"пробелы по краям", "  wait_network\n", Recovery{Kind: RecoveryWaitNetwork}, true},
+		{"другой селектор", "alternate_selector:#submit", Recovery{Kind: RecoveryAlternateSelector, Selector: "#submit"}, true},
+		{"селектор с двоеточием", "alternate_selector:a:nth-of-type(2)", Recovery{Kind: RecoveryAlternateSelector, Selector: "a:nth-of-type(2)"}, true},
+		{"другой селектор без селектора", "alternate_selector", Recovery{}, false},
+		{"другой селектор с пустым селектором", "alternate_selector:", Recovery{}, false},
+		{"старый формат", "Закрыл попап и повторил", Recovery{}, false},
+		{"пусто", "", Recovery{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ParseRecovery(tt.input)
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("ParseRecovery(%q) = %+v, %v; ожидалось %+v, %v", tt.input, got, ok, tt.want, tt.wantOK)
+			}
+			if ok {
+				if again, _ := ParseRecovery(got.String()); again != got {
+					t.Errorf("ParseRecovery(%q.String()) = %+v, ожидалось %+v", tt.input, again, got)
+				}
+			}
+		})
+	}
+}
+
+func TestRecoveryTransferable(t *testing.T) {
+	tests := []struct {
+		recovery string
+		want     bool
+	}{
+		{"scroll_first", true},
+		{"close_overlay", true},
+		{"wait_network", true},
+		{"alternate_selector:#submit", false},
+		// Текст старого формата не привязан к селектору
+		{"Закрыл попап и повторил", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.recovery, func(t *testing.T) {
+			if got := recoveryTransferable(tt.recovery); got != tt.want {
+				t.Errorf("recoveryTransferable(%q) = %v, ожидалось %v", tt.recovery, got, tt.want)
+			}
+		})
+	}
+}
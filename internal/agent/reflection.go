@@ -0,0 +1,149 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"aiAgent/internal/database"
+	"aiAgent/internal/llm"
+
+	"go.uber.org/zap"
+)
+
+// performReflection оценивает результат выполненного действия.
+// Ошибки выполнения классифицируются без обращения к LLM. Для успешно выполненных
+// действий LLM сравнивает страницу до и после и решает, достигнута ли цель шага.
+// Если LLM недоступен, используется эвристика: неизменившаяся страница = no_effect.
+func (a *Agent) performReflection(ctx context.Context, task string, stepNo int, plan *llm.StepPlan, pageBefore, pageAfter, result string, execErr error, taskID *uint) *llm.Reflection {
+	if execErr != nil {
+		actionErr := classifyError(plan.Action, execErr)
+		return &llm.Reflection{
+			StepNo:      stepNo,
+			Verdict:     llm.VerdictError,
+			ErrorCause:  string(actionErr.Kind),
+			Explanation: execErr.Error(),
+			Confidence:  1.0,
+		}
+	}
+
+	// При пробном запуске действие не выполнялось - оценивать нечего
+	if a.dryRun {
+		return &llm.Reflection{
+			StepNo:         stepNo,
+			Verdict:        llm.VerdictSuccess,
+			IntentAchieved: true,
+			Explanation:    "Действие не выполнялось (dry-run)",
+			Confidence:     1.0,
+		}
+	}
+
+	// ask_user и extract_info не должны менять страницу - оценивать нечего
+	if plan.Action == "ask_user" || plan.Action == "extract_info" {
+		return &llm.Reflection{
+			StepNo:         stepNo,
+			Verdict:        llm.VerdictSuccess,
+			IntentAchieved: true,
+			Explanation:    "Действие не предполагает изменения страницы",
+			Confidence:     1.0,
+		}
+	}
+
+	// При передаче управления страницу менял пользователь - изменения описаны в результате шага
+	if plan.Action == "takeover" {
+		return &llm.Reflection{
+			StepNo:         stepNo,
+			Verdict:        llm.VerdictSuccess,
+			IntentAchieved: true,
+			Explanation:    "Управление возвращено пользователем",
+			Confidence:     1.0,
+		}
+	}
+
+	if a.llmClient != nil {
+		var reflection *llm.Reflection
+		err := a.callLLM(ctx, func() error {
+			var e error
+			reflection, e = a.llmClient.Reflect(ctx, task, plan, pageBefore, pageAfter, result, taskID, nil)
+			return e
+		})
+		if err == nil {
+			reflection.StepNo = stepNo
+			return reflection
+		}
+		a.log.Warn("Ошибка LLM рефлексии, используем эвристику", a.contextFields(taskID, stepNo, zap.Error(err))...)
+	}
+
+	return heuristicReflection(stepNo, pageBefore, pageAfter)
+}
+
+func heuristicReflection(stepNo int, pageBefore, pageAfter string) *llm.Reflection {
+	if pageBefore != "" && pageBefore == pageAfter {
+		return &llm.Reflection{
+			StepNo:      stepNo,
+			Verdict:     llm.VerdictNoEffect,
+			ErrorCause:  "page_unchanged",
+			Explanation: "Страница не изменилась после действия",
+			Confidence:  0.5,
+		}
+	}
+
+	return &llm.Reflection{
+		StepNo:         stepNo,
+		Verdict:        llm.VerdictSuccess,
+		IntentAchieved: true,
+		Explanation:    "Страница изменилась после действия",
+		Confidence:     0.5,
+	}
+}
+
+// recordReflection сохраняет неудачный вердикт в память агента как failure pattern.
+// Успешные шаги накапливаются в executeSteps и сохраняются целиком при завершении задачи.
+func (a *Agent) recordReflection(ctx context.Context, task string, plan *llm.StepPlan, reflection *llm.Reflection) {
+	if a.memory == nil || reflection == nil || reflection.Succeeded() {
+		return
+	}
+
+	if err := a.memory.RecordFailure(ctx, task, plan.Action, plan.Selector, reflectionKind(reflection), ""); err != nil {
+		a.log.Warn("Не удалось сохранить неудачный паттерн", zap.Error(err))
+	}
+}
+
+// reflectionKind возвращает вид неудачи по вердикту: для ошибок выполнения - вид ошибки из причины,
+// для остальных вердиктов - сам вердикт (причину модель пишет свободным текстом).
+func reflectionKind(reflection *llm.Reflection) ErrorKind {
+	if reflection.Verdict == llm.VerdictError {
+		return ParseErrorKind(reflection.ErrorCause)
+	}
+	return ErrorKind(reflection.Verdict)
+}
+
+// recordSuccessfulPath сохраняет в память шаги, подтвержденные рефлексией, после успешного завершения задачи.
+func (a *Agent) recordSuccessfulPath(ctx context.Context, task string, steps []llm.StepPlan, duration time.Duration) {
+	if a.memory == nil || len(steps) == 0 || a.dryRun {
+		return
+	}
+
+	strategy := ""
+	if last := a.reasoningHistory.GetLastStep(); last != nil {
+		strategy = last.Strategy
+	}
+
+	url, _, _ := a.browser.GetPageInfo(ctx)
+	if err := a.memory.RecordSuccess(ctx, task, steps, strategy, duration, extractDomain(url)); err != nil {
+		a.log.Warn("Не удалось сохранить успешный путь", zap.Error(err))
+	}
+}
+
+// applyReflection переносит вердикт рефлексии в запись шага.
+func (a *Agent) applyReflection(step *database.AgentStep, reflection *llm.Reflection) {
+	if reflection == nil {
+		return
+	}
+	step.Verdict = string(reflection.Verdict)
+	if reflection.Succeeded() {
+		step.VerdictReason = a.sanitizer.Sanitize(reflection.Explanation)
+	} else {
+		step.VerdictReason = a.sanitizer.Sanitize(fmt.Sprintf("%s: %s", reflection.ErrorCause, reflection.Explanation))
+	}
+}
@@ -0,0 +1,287 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"aiAgent/internal/llm"
+
+	"go.uber.org/zap"
+)
+
+// Области политик повторов: кроме имен действий (navigate, click, type, extract_info, ...)
+// есть обращения к LLM из цикла шагов и политика по умолчанию для всего остального.
+const (
+	RetryScopeLLM     = "llm"
+	RetryScopeDefault = "default"
+)
+
+// RetryPolicy - правило повторов одной области.
+type RetryPolicy struct {
+	MaxAttempts int           // Всего попыток, включая первую (1 - без повторов)
+	BaseDelay   time.Duration // Задержка перед первым повтором
+	MaxDelay    time.Duration // Потолок задержки (0 - без потолка)
+	Backoff     float64       // Множитель задержки на каждый следующий повтор (1 - постоянная задержка)
+	Jitter      float64       // Случайный разброс задержки, доля от нее (0.2 - ±20%)
+	MaxElapsed  time.Duration // Предел общего времени попыток вместе с задержками (0 - без предела)
+	RetryOn     []ErrorKind   // Повторяемые виды ошибок (пусто - все виды класса retryable)
+}
+
+// RetryAttempt - попытка действия или обращения к LLM в записи шага.
+type RetryAttempt struct {
+	Scope   string    `json:"scope"`
+	Attempt int       `json:"attempt"`         // Номер попытки с 1
+	DelayMs int64     `json:"delay_ms"`        // Задержка перед попыткой (0 для первой)
+	Error   ErrorKind `json:"error,omitempty"` // Вид ошибки попытки (пусто - успех)
+	Elapsed int64     `json:"elapsed_ms"`      // Длительность самой попытки
+}
+
+// DefaultRetryPolicies возвращает политики по умолчанию. Навигация повторяется один раз с длинной
+// паузой и ограничена по времени: мертвый сайт не должен держать шаг минутами. Клик и ввод
+// повторяются быстро - элемент обычно просто еще не отрисован. extract_info не повторяется: извлечение
+// записей по схеме обращается к LLM через политику "llm" (как планирование и проверка критериев),
+// а без схемы только читает страницу. retries и delay задают политику по умолчанию.
+func DefaultRetryPolicies(retries int, delay time.Duration) map[string]RetryPolicy {
+	return map[string]RetryPolicy{
+		RetryScopeDefault: {MaxAttempts: retries, BaseDelay: delay, MaxDelay: 30 * time.Second, Backoff: 2, Jitter: 0.2},
+		RetryScopeLLM: {MaxAttempts: 3, BaseDelay: 2 * time.Second, MaxDelay: 20 * time.Second, Backoff: 2, Jitter: 0.2, MaxElapsed: time.Minute,
+			RetryOn: []ErrorKind{ErrorKindRateLimited, ErrorKindMalformedResponse, ErrorKindTimeout, ErrorKindNetwork}},
+		"navigate": {MaxAttempts: 2, BaseDelay: 3 * time.Second, Backoff: 1, Jitter: 0.2, MaxElapsed: 45 * time.Second,
+			RetryOn: []ErrorKind{ErrorKindNavigationTimeout, ErrorKindTimeout, ErrorKindNetwork}},
+		"click":        {MaxAttempts: 3, BaseDelay: 500 * time.Millisecond, MaxDelay: 2 * time.Second, Backoff: 2, Jitter: 0.2, MaxElapsed: 20 * time.Second},
+		"type":         {MaxAttempts: 3, BaseDelay: 500 * time.Millisecond, MaxDelay: 2 * time.Second, Backoff: 2, Jitter: 0.2, MaxElapsed: 20 * time.Second},
+		"extract_info": {MaxAttempts: 1},
+	}
+}
+
+// retryable сообщает, что ошибку этого вида имеет смысл повторить.
+func (p RetryPolicy) retryable(kind ErrorKind) bool {
+	// Открытый предохранитель не закроется за время повторов, отмененную задачу повторять некому
+	if kind == ErrorKindCircuitOpen || kind.Class() == ErrorTypeCritical {
+		return false
+	}
+	if len(p.RetryOn) == 0 {
+		return kind.Class() == ErrorTypeRetryable
+	}
+	return slices.Contains(p.RetryOn, kind)
+}
+
+// delay возвращает задержку перед повтором номер retry (с 1) с учетом разброса.
+func (p RetryPolicy) delay(retry int) time.Duration {
+	backoff := p.Backoff
+	if backoff < 1 {
+		backoff = 1
+	}
+	d := float64(p.BaseDelay) * math.Pow(backoff, float64(retry-1))
+	if p.MaxDelay > 0 && d > float64(p.MaxDelay) {
+		d = float64(p.MaxDelay)
+	}
+	if p.Jitter > 0 {
+		d += d * p.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(d)
+}
+
+// Run выполняет fn по политике и возвращает все попытки. Повтор не начинается, если задержка
+// выведет общее время за MaxElapsed.
+func (p RetryPolicy) Run(ctx context.Context, scope string, fn func() error) ([]RetryAttempt, error) {
+	attempts := max(p.MaxAttempts, 1)
+	start := time.Now()
+
+	var log []RetryAttempt
+	var delay time.Duration
+	for attempt := 1; ; attempt++ {
+		if delay > 0 {
+			select {
+			case <-ctx.Done():
+				return log, ctx.Err()
+			case <-time.After(delay):
+			}
+		}
+
+		attemptStart := time.Now()
+		err := fn()
+		log = append(log, RetryAttempt{
+			Scope:   scope,
+			Attempt: attempt,
+			DelayMs: delay.Milliseconds(),
+			Error:   ErrorKindOf(err),
+			Elapsed: time.Since(attemptStart).Milliseconds(),
+		})
+		if err == nil {
+			return log, nil
+		}
+		if !p.retryable(ErrorKindOf(err)) {
+			return log, err
+		}
+		if attempt >= attempts {
+			return log, fmt.Errorf("max retries exceeded: %w", err)
+		}
+
+		delay = p.delay(attempt)
+		if p.MaxElapsed > 0 && time.Since(start)+delay > p.MaxElapsed {
+			return log, fmt.Errorf("превышено время повторов (%s): %w", p.MaxElapsed, err)
+		}
+	}
+}
+
+// retryPolicy возвращает политику области (политику по умолчанию, если своей нет).
+func (a *Agent) retryPolicy(scope string) RetryPolicy {
+	if policy, ok := a.retryPolicies[scope]; ok {
+		return policy
+	}
+	return a.retryPolicies[RetryScopeDefault]
+}
+
+// retry выполняет fn по политике области и добавляет попытки в запись текущего шага.
+func (a *Agent) retry(ctx context.Context, scope string, fn func() error) error {
+	return a.retryWith(ctx, a.retryPolicy(scope), scope, fn)
+}
+
+// retryWith выполняет fn по политике policy и добавляет попытки области scope в запись текущего шага.
+func (a *Agent) retryWith(ctx context.Context, policy RetryPolicy, scope string, fn func() error) error {
+	attempts, err := policy.Run(ctx, scope, fn)
+	a.attempts = append(a.attempts, attempts...)
+	if len(attempts) > 1 {
+		last := attempts[len(attempts)-1]
+		a.log.Debug("Выполнены повторы", a.contextFields(llm.TaskIDFromContext(ctx), 0,
+			zap.String("scope", scope),
+			zap.Int("attempts", len(attempts)),
+			zap.String("last_error", string(last.Error)))...)
+	}
+	return err
+}
+
+// actionAttempts возвращает число попыток действия (0 - действие не выполнялось).
+func actionAttempts(attempts []RetryAttempt, action string) int {
+	n := 0
+	for _, attempt := range attempts {
+		if attempt.Scope == action {
+			n++
+		}
+	}
+	return n
+}
+
+// attemptLog сериализует попытки шага для записи шага.
+func attemptLog(attempts []RetryAttempt) string {
+	data, err := json.Marshal(attempts)
+	if err != nil {
+		return "null"
+	}
+	return string(data)
+}
+
+// ParseRetryPolicies накладывает правила из окружения на политики по умолчанию (см. ParseRetryPolicy).
+// Ключ - область: действие, "llm" или "default".
+func ParseRetryPolicies(defaults map[string]RetryPolicy, specs map[string]string) (map[string]RetryPolicy, error) {
+	policies := make(map[string]RetryPolicy, len(defaults)+len(specs))
+	for scope, policy := range defaults {
+		policies[scope] = policy
+	}
+
+	scopes := make([]string, 0, len(specs))
+	for scope := range specs {
+		scopes = append(scopes, scope)
+	}
+	sort.Strings(scopes)
+
+	for _, scope := range scopes {
+		base, ok := policies[scope]
+		if !ok {
+			base = policies[RetryScopeDefault]
+		}
+		policy, err := ParseRetryPolicy(specs[scope], base)
+		if err != nil {
+			return nil, fmt.Errorf("политика повторов %s: %w", scope, err)
+		}
+		policies[scope] = policy
+	}
+	return policies, nil
+}
+
+// ParseRetryPolicy разбирает правило вида "attempts=2,delay=2s,max_delay=10s,backoff=2,jitter=0.2,
+// max_elapsed=45s,on=timeout|network". Не указанные поля берутся из base.
+func ParseRetryPolicy(spec string, base RetryPolicy) (RetryPolicy, error) {
+	policy := base
+	for _, field := range strings.Split(spec, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return base, fmt.Errorf("ожидается ключ=значение: %q", field)
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+
+		var err error
+		switch key {
+		case "attempts":
+			policy.MaxAttempts, err = strconv.Atoi(value)
+			if err == nil && policy.MaxAttempts < 1 {
+				err = fmt.Errorf("должно быть не меньше 1")
+			}
+		case "delay":
+			policy.BaseDelay, err = parseRetryDuration(value)
+		case "max_delay":
+			policy.MaxDelay, err = parseRetryDuration(value)
+		case "max_elapsed":
+			policy.MaxElapsed, err = parseRetryDuration(value)
+		case "backoff":
+			policy.Backoff, err = strconv.ParseFloat(value, 64)
+			if err == nil && policy.Backoff < 1 {
+				err = fmt.Errorf("должно быть не меньше 1")
+			}
+		case "jitter":
+			policy.Jitter, err = strconv.ParseFloat(value, 64)
+			if err == nil && (policy.Jitter < 0 || policy.Jitter > 1) {
+				err = fmt.Errorf("должно быть от 0 до 1")
+			}
+		case "on":
+			policy.RetryOn, err = parseRetryKinds(value)
+		default:
+			return base, fmt.Errorf("неизвестный параметр %q", key)
+		}
+		if err != nil {
+			return base, fmt.Errorf("%s: %w", key, err)
+		}
+	}
+	return policy, nil
+}
+
+func parseRetryDuration(value string) (time.Duration, error) {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("не может быть отрицательным")
+	}
+	return d, nil
+}
+
+// parseRetryKinds разбирает список видов ошибок через "|" ("retryable" - все виды класса retryable).
+func parseRetryKinds(value string) ([]ErrorKind, error) {
+	if value == "retryable" {
+		return nil, nil
+	}
+	var kinds []ErrorKind
+	for _, name := range strings.Split(value, "|") {
+		name = strings.TrimSpace(name)
+		kind := ParseErrorKind(name)
+		if kind == ErrorKindUnknown && name != string(ErrorKindUnknown) {
+			return nil, fmt.Errorf("неизвестный вид ошибки %q", name)
+		}
+		kinds = append(kinds, kind)
+	}
+	return kinds, nil
+}
@@ -0,0 +1,204 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"aiAgent/internal/llm"
+)
+
+func TestParseRetryPolicy(t *testing.T) {
+	base := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: 10 * time.Second, Backoff: 2, Jitter: 0.2}
+
+	tests := []struct {
+		name    string
+		spec    string
+		want    RetryPolicy
+		wantErr bool
+	}{
+		{"пустое правило", "", base, false},
+		{"все поля", "attempts=2, delay=2s, max_delay=5s, backoff=1.5, jitter=0, max_elapsed=45s, on=timeout|network",
+			RetryPolicy{MaxAttempts: 2, BaseDelay: 2 * time.Second, MaxDelay: 5 * time.Second, Backoff: 1.5,
+				MaxElapsed: 45 * time.Second, RetryOn: []ErrorKind{ErrorKindTimeout, ErrorKindNetwork}}, false},
+		{"остальное из base", "attempts=5",
+			RetryPolicy{MaxAttempts: 5, BaseDelay: time.Second, MaxDelay: 10 * time.Second, Backoff: 2, Jitter: 0.2}, false},
+		{"все retryable", "on=retryable", base, false},
+		{"лишние запятые", ",attempts=1,,", RetryPolicy{MaxAttempts: 1, BaseDelay: time.Second, MaxDelay: 10 * time.Second, Backoff: 2, Jitter: 0.2}, false},
+		{"без значения", "attempts", base, true},
+		{"ноль попыток", "attempts=0", base, true},
+		{"попытки не число", "attempts=два", base, true},
+		{"отрицательная задержка", "delay=-1s", base, true},
+		{"задержка без единиц", "delay=5", base, true},
+		{"множитель меньше 1", "backoff=0.5", base, true},
+		{"разброс больше 1", "jitter=1.5", base, true},
+		{"неизвестный вид ошибки", "on=timeout|oops", base, true},
+		{"неизвестный параметр", "retries=3", base, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRetryPolicy(tt.spec, base)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRetryPolicy(%q) ошибка = %v, ожидалась ошибка: %v", tt.spec, err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseRetryPolicy(%q) = %+v, ожидалось %+v", tt.spec, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRetryPolicies(t *testing.T) {
+	defaults := DefaultRetryPolicies(3, time.Second)
+
+	policies, err := ParseRetryPolicies(defaults, map[string]string{
+		"navigate": "attempts=4",
+		"scroll":   "attempts=1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := policies["navigate"]; got.MaxAttempts != 4 || got.BaseDelay != defaults["navigate"].BaseDelay {
+		t.Errorf("navigate = %+v, ожидалась политика по умолчанию с 4 попытками", got)
+	}
+	want := defaults[RetryScopeDefault]
+	want.MaxAttempts = 1
+	if got := policies["scroll"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("scroll = %+v, ожидалась политика default с 1 попыткой: %+v", got, want)
+	}
+	if got := policies["click"]; !reflect.DeepEqual(got, defaults["click"]) {
+		t.Errorf("click = %+v, политика без правила изменилась", got)
+	}
+	if defaults["navigate"].MaxAttempts != 2 {
+		t.Error("ParseRetryPolicies изменил политики по умолчанию")
+	}
+
+	if _, err := ParseRetryPolicies(defaults, map[string]string{"click": "attempts=0"}); err == nil {
+		t.Error("ожидалась ошибка для некорректного правила")
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy RetryPolicy
+		delays []time.Duration // Задержки перед повторами 1, 2, ...
+	}{
+		{"экспонента", RetryPolicy{BaseDelay: time.Second, Backoff: 2},
+			[]time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second}},
+		{"потолок", RetryPolicy{BaseDelay: time.Second, MaxDelay: 3 * time.Second, Backoff: 2},
+			[]time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second}},
+		{"постоянная", RetryPolicy{BaseDelay: 500 * time.Millisecond, Backoff: 1},
+			[]time.Duration{500 * time.Millisecond, 500 * time.Millisecond, 500 * time.Millisecond}},
+		{"множитель меньше 1 как 1", RetryPolicy{BaseDelay: time.Second},
+			[]time.Duration{time.Second, time.Second}},
+		{"дробный множитель", RetryPolicy{BaseDelay: time.Second, Backoff: 1.5},
+			[]time.Duration{time.Second, 1500 * time.Millisecond, 2250 * time.Millisecond}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i, want := range tt.delays {
+				if got := tt.policy.delay(i + 1); got != want {
+					t.Errorf("delay(%d) = %s, ожидалось %s", i+1, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryPolicyJitter(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: time.Second, MaxDelay: 4 * time.Second, Backoff: 2, Jitter: 0.2}
+	for retry := 1; retry <= 4; retry++ {
+		nominal := min(time.Second<<(retry-1), 4*time.Second)
+		for range 100 {
+			got := policy.delay(retry)
+			if got < nominal*8/10 || got > nominal*12/10 {
+				t.Fatalf("delay(%d) = %s, ожидалось %s ±20%%", retry, got, nominal)
+			}
+		}
+	}
+}
+
+func TestRetryPolicyRetryable(t *testing.T) {
+	navigate := RetryPolicy{RetryOn: []ErrorKind{ErrorKindNavigationTimeout, ErrorKindNetwork}}
+
+	tests := []struct {
+		name   string
+		policy RetryPolicy
+		kind   ErrorKind
+		want   bool
+	}{
+		{"retryable по умолчанию", RetryPolicy{}, ErrorKindTimeout, true},
+		{"временная ошибка не повторяется", RetryPolicy{}, ErrorKindInvalidSelector, false},
+		{"неизвестная ошибка не повторяется", RetryPolicy{}, ErrorKindUnknown, false},
+		{"вид из списка", navigate, ErrorKindNetwork, true},
+		{"вид не из списка", navigate, ErrorKindElementNotFound, false},
+		{"открытый предохранитель", RetryPolicy{RetryOn: []ErrorKind{ErrorKindCircuitOpen}}, ErrorKindCircuitOpen, false},
+		{"критичная ошибка", RetryPolicy{RetryOn: []ErrorKind{ErrorKindCanceled}}, ErrorKindCanceled, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.retryable(tt.kind); got != tt.want {
+				t.Errorf("retryable(%s) = %v, ожидалось %v", tt.kind, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyRun(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   RetryPolicy
+		errs     []error // Ошибки попыток по порядку; после них - успех
+		attempts int
+		wantErr  bool
+	}{
+		{"успех с первой попытки", RetryPolicy{MaxAttempts: 3}, nil, 1, false},
+		{"успех после повтора", RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond},
+			[]error{llm.ErrRateLimited}, 2, false},
+		{"попытки исчерпаны", RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond},
+			[]error{llm.ErrRateLimited, llm.ErrRateLimited, llm.ErrRateLimited}, 2, true},
+		{"неповторяемая ошибка", RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond},
+			[]error{llm.ErrContextTooLong}, 1, true},
+		{"превышено время повторов", RetryPolicy{MaxAttempts: 3, BaseDelay: time.Second, MaxElapsed: 100 * time.Millisecond},
+			[]error{llm.ErrRateLimited}, 1, true},
+		{"обход ошибки одной попыткой", recoveryPolicy,
+			[]error{llm.ErrRateLimited}, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			log, err := tt.policy.Run(context.Background(), "test", func() error {
+				calls++
+				if calls <= len(tt.errs) {
+					return tt.errs[calls-1]
+				}
+				return nil
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Run() ошибка = %v, ожидалась ошибка: %v", err, tt.wantErr)
+			}
+			if calls != tt.attempts || len(log) != tt.attempts {
+				t.Errorf("вызовов %d, попыток в журнале %d; ожидалось %d", calls, len(log), tt.attempts)
+			}
+			if tt.wantErr && calls <= len(tt.errs) && !errors.Is(err, tt.errs[calls-1]) {
+				t.Errorf("Run() = %v, ожидалась обернутая ошибка попытки", err)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyRunCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Minute}
+
+	log, err := policy.Run(ctx, "test", func() error {
+		cancel()
+		return llm.ErrRateLimited
+	})
+	if !errors.Is(err, context.Canceled) || len(log) != 1 {
+		t.Errorf("Run() = %v, попыток %d; ожидалась отмена после первой попытки", err, len(log))
+	}
+}
@@ -0,0 +1,75 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"aiAgent/internal/database"
+	"aiAgent/internal/llm"
+
+	"go.uber.org/zap"
+)
+
+// RoutingRecord - решение маршрутизации, сохраняемое в задаче (tasks.routing).
+type RoutingRecord struct {
+	Agent      string            `json:"agent"`
+	Method     string            `json:"method"`
+	Confidence float64           `json:"confidence"`
+	Rationale  string            `json:"rationale"`
+	Ranking    []llm.RouteChoice `json:"ranking,omitempty"`
+}
+
+// Record преобразует решение маршрутизации в запись для сохранения.
+func (d *RoutingDecision) Record() *RoutingRecord {
+	record := &RoutingRecord{
+		Agent:      string(d.Agent.GetType()),
+		Method:     d.Method,
+		Confidence: d.Confidence,
+		Rationale:  d.Rationale,
+	}
+	for _, candidate := range d.Ranking {
+		record.Ranking = append(record.Ranking, llm.RouteChoice{
+			Agent:      string(candidate.Agent.GetType()),
+			Confidence: candidate.Confidence,
+			Rationale:  candidate.Rationale,
+		})
+	}
+	return record
+}
+
+// ParseRouting разбирает сохраненное решение маршрутизации задачи.
+func ParseRouting(data string) (*RoutingRecord, error) {
+	var record RoutingRecord
+	if err := json.Unmarshal([]byte(data), &record); err != nil {
+		return nil, fmt.Errorf("ошибка разбора решения маршрутизации: %w", err)
+	}
+	return &record, nil
+}
+
+// routeTask выбирает специализированного агента для задачи и сохраняет решение в задаче.
+func (a *Agent) routeTask(ctx context.Context, task *database.Task) (*RoutingDecision, error) {
+	pageContext, _ := a.getPageContext(ctx)
+	decision, err := a.router.Route(ctx, task.UserInput, pageContext, &task.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	a.log.Info("Задача маршрутизирована", a.contextFields(&task.ID, 0,
+		zap.String("agent_type", string(decision.Agent.GetType())),
+		zap.String("method", decision.Method),
+		zap.Float64("confidence", decision.Confidence),
+		zap.String("rationale", decision.Rationale))...)
+	fmt.Fprintf(outputFrom(ctx), "[Агент] %s (%s, уверенность %.2f): %s\n",
+		decision.Agent.GetType(), decision.Method, decision.Confidence, decision.Rationale)
+
+	data, err := json.Marshal(decision.Record())
+	if err != nil {
+		a.log.Warn("Ошибка сериализации решения маршрутизации", a.contextFields(&task.ID, 0, zap.Error(err))...)
+		return decision, nil
+	}
+	if err := a.repo.UpdateTaskRouting(task.ID, string(decision.Agent.GetType()), string(data)); err != nil {
+		a.log.Warn("Ошибка сохранения решения маршрутизации", a.contextFields(&task.ID, 0, zap.Error(err))...)
+	}
+	return decision, nil
+}
@@ -0,0 +1,30 @@
+package agent
+
+import "aiAgent/internal/llm"
+
+// forTask возвращает копию агента для выполнения одной задачи.
+// Копия получает собственную сессию браузера (если задан Config.NewBrowser),
+// историю рассуждений, транскрипт, предохранители целей действий и подагентов.
+// LLM клиент (с общим rate limiter), репозиторий, память, предохранители сайтов и LLM
+// и проверка безопасности разделяются.
+func (a *Agent) forTask() *Agent {
+	task := *a
+
+	if a.cfg.NewBrowser != nil {
+		task.browser = a.cfg.NewBrowser()
+	}
+	task.reasoningHistory = &llm.ReasoningHistory{}
+	task.transcript = llm.NewActionTranscript(a.cfg.TranscriptWindow)
+	task.attempts = nil
+	task.replay = nil
+	if a.targetBreakers != nil {
+		task.targetBreakers = NewCircuitBreakerPool()
+	}
+
+	// Подагенты ссылаются на базового агента, поэтому роутер создается заново для копии
+	if a.router != nil {
+		task.router = task.newRouter()
+	}
+
+	return &task
+}
@@ -0,0 +1,209 @@
+package agent
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"aiAgent/internal/browser"
+	"aiAgent/internal/llm"
+
+	"go.uber.org/zap"
+)
+
+// Знания о сайтах накапливаются после каждого успешного шага: какой селектор сработал для какого
+// элемента ("кнопка \"Удалить\""), где страница входа, какие формы на страницах и чем закрывается попап.
+// При следующем визите на домен они добавляются к контексту страницы (см. getPageContext),
+// и модель не ищет те же элементы заново.
+
+// Назначения служебных записей в SiteInfo.CommonPatterns
+const (
+	loginPagePurpose  = "страница входа"
+	closePopupPurpose = "закрыть попап"
+)
+
+// maxPurposeLen - максимальная длина подписи элемента в назначении селектора; более длинная подпись -
+// скорее содержимое страницы, чем имя элемента.
+const maxPurposeLen = 40
+
+// siteLesson - то, что шаг узнал об одном домене.
+type siteLesson struct {
+	patterns map[string]string
+	forms    []string
+}
+
+// snapshotElement возвращает элемент последнего снимка страницы по селектору.
+func (a *Agent) snapshotElement(selector string) *browser.ElementInfo {
+	if a.snapshot == nil || selector == "" {
+		return nil
+	}
+	for i := range a.snapshot.Elements {
+		if a.snapshot.Elements[i].Selector == selector {
+			return &a.snapshot.Elements[i]
+		}
+	}
+	return nil
+}
+
+// learnSite запоминает знания о сайте после успешного шага. element - элемент шага в снимке
+// страницы до действия (nil, если его нет в снимке).
+func (a *Agent) learnSite(ctx context.Context, plan *llm.StepPlan, element *browser.ElementInfo, urlBefore, urlAfter string, taskID *uint, stepNo int) {
+	if a.memory == nil || a.dryRun {
+		return
+	}
+
+	lessons := make(map[string]*siteLesson)
+	lesson := func(domain string) *siteLesson {
+		if lessons[domain] == nil {
+			lessons[domain] = &siteLesson{patterns: make(map[string]string)}
+		}
+		return lessons[domain]
+	}
+
+	// Элемент и попапы относятся к странице, на которой выполнялось действие; для навигации - к новой
+	actionDomain := extractDomain(urlBefore)
+	if plan.Action == "navigate" {
+		actionDomain = extractDomain(urlAfter)
+	}
+	if actionDomain != "" {
+		if purpose := elementPurpose(plan.Action, element); purpose != "" {
+			lesson(actionDomain).patterns[purpose] = plan.Selector
+		}
+		for _, selector := range a.browser.ClosedPopups() {
+			lesson(actionDomain).patterns[closePopupPurpose] = selector
+		}
+	}
+
+	// Формы ищутся на новой странице и при вводе: поле, в которое вводили, принадлежит форме
+	if domain := extractDomain(urlAfter); domain != "" && (urlAfter != urlBefore || plan.Action == "type") {
+		if fields, err := a.browser.FindFormFields(ctx, ""); err == nil && len(fields) > 0 {
+			page := pagePath(urlAfter)
+			lesson(domain).forms = append(lesson(domain).forms, describeForm(page, fields))
+			if hasPasswordField(fields) {
+				lesson(domain).patterns[loginPagePurpose] = pageURL(urlAfter)
+			}
+		}
+	}
+
+	for domain, l := range lessons {
+		if err := a.memory.LearnSite(ctx, domain, l.patterns, l.forms); err != nil {
+			a.log.Warn("Не удалось сохранить знания о сайте", a.contextFields(taskID, stepNo, zap.String("domain", domain), zap.Error(err))...)
+		}
+	}
+}
+
+// siteNotes форматирует знания о домене страницы для контекста страницы.
+func (a *Agent) siteNotes(ctx context.Context, pageURL string) string {
+	if a.memory == nil || !a.cfg.UseMemory {
+		return ""
+	}
+	domain := extractDomain(pageURL)
+	if domain == "" {
+		return ""
+	}
+	info := a.memory.GetSiteKnowledge(ctx, domain)
+	if info == nil || (len(info.CommonPatterns) == 0 && len(info.FormStructure) == 0) {
+		return ""
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "\n\nИзвестно о сайте %s из прошлых визитов (селекторы могли измениться, проверяй по странице):\n", domain)
+	writeSiteInfo(&sb, info)
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// elementPurpose описывает назначение постоянного элемента управления по его роли и подписи:
+// "кнопка \"Удалить\"", "поле \"Поиск\"", "ссылка \"Настройки\"". Запоминаются только кнопки,
+// поля и навигация с явным именем: текст ссылок и элементов списков - это содержимое страницы
+// (темы писем, названия товаров), а текст поля - введенное значение. Пустая строка - запоминать нечего.
+func elementPurpose(action string, element *browser.ElementInfo) string {
+	if element == nil || (action != "click" && action != "type") {
+		return ""
+	}
+
+	var kind, name string
+	switch {
+	case action == "type" || element.Tag == "input" || element.Tag == "textarea" || element.Tag == "select" ||
+		element.Role == "textbox" || element.Role == "searchbox" || element.Role == "combobox":
+		kind, name = "поле", cmp.Or(element.Label, element.Name)
+	case element.Tag == "button" || element.Role == "button":
+		kind, name = "кнопка", cmp.Or(element.Label, element.Text)
+	case element.Role == "tab" || element.Role == "menuitem":
+		kind, name = "пункт меню", cmp.Or(element.Label, element.Text)
+	case element.Tag == "a" || element.Role == "link":
+		kind, name = "ссылка", element.Label
+	default:
+		return ""
+	}
+
+	name = strings.Join(strings.Fields(name), " ")
+	if !stableCaption(name) {
+		return ""
+	}
+	return fmt.Sprintf("%s \"%s\"", kind, name)
+}
+
+// stableCaption сообщает, что подпись похожа на постоянное имя элемента: короткая и без цифр
+// (счетчики, цены и даты меняются от визита к визиту). Личные данные отсеивает AgentMemory.LearnSite.
+func stableCaption(name string) bool {
+	if name == "" || utf8.RuneCountInString(name) > maxPurposeLen {
+		return false
+	}
+	return !strings.ContainsFunc(name, unicode.IsDigit)
+}
+
+// describeForm описывает форму страницы: "путь: поле (тип), ...". Значения полей не сохраняются.
+func describeForm(page string, fields []browser.FormField) string {
+	parts := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if field.Type == "hidden" {
+			continue
+		}
+		name := field.Label
+		if name == "" {
+			name = field.Name
+		}
+		if name == "" {
+			name = field.Selector
+		}
+		part := fmt.Sprintf("%s (%s)", name, field.Type)
+		if field.Required {
+			part += " *"
+		}
+		parts = append(parts, part)
+	}
+	return fmt.Sprintf("%s: %s", page, strings.Join(parts, ", "))
+}
+
+func hasPasswordField(fields []browser.FormField) bool {
+	for _, field := range fields {
+		if field.Type == "password" {
+			return true
+		}
+	}
+	return false
+}
+
+// pagePath возвращает путь страницы без параметров запроса.
+func pagePath(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Path == "" {
+		return "/"
+	}
+	return parsed.Path
+}
+
+// pageURL возвращает адрес страницы без параметров запроса и фрагмента: в них бывают токены сессии.
+func pageURL(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	parsed.RawQuery = ""
+	parsed.Fragment = ""
+	return parsed.String()
+}
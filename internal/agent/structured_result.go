@@ -0,0 +1,308 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"aiAgent/internal/llm"
+
+	"github.com/sashabaranov/go-openai/jsonschema"
+	"go.uber.org/zap"
+)
+
+// ParseOutputSchema разбирает JSON схему структурированного результата задачи.
+// Верхний уровень - object (схема одной записи) или array с items (схема всего результата).
+// Поддерживается подмножество JSON Schema: type, properties, required, items, enum.
+// Тип обязателен у каждого узла; из объединений типов допускается только ["тип", "null"]
+// (см. normalizeSchema).
+func ParseOutputSchema(s string) (*jsonschema.Definition, error) {
+	var raw map[string]any
+	if err := json.Unmarshal([]byte(s), &raw); err != nil {
+		return nil, fmt.Errorf("неверная JSON схема: %w", err)
+	}
+	if _, err := normalizeSchema(raw, "$"); err != nil {
+		return nil, fmt.Errorf("неверная JSON схема: %w", err)
+	}
+	normalized, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка сериализации схемы: %w", err)
+	}
+
+	var schema jsonschema.Definition
+	if err := json.Unmarshal(normalized, &schema); err != nil {
+		return nil, fmt.Errorf("неверная JSON схема: %w", err)
+	}
+	switch schema.Type {
+	case jsonschema.Object:
+	case jsonschema.Array:
+		if schema.Items == nil {
+			return nil, fmt.Errorf("схема массива должна содержать items")
+		}
+	default:
+		return nil, fmt.Errorf("верхний уровень схемы должен быть object или array, получено %q", schema.Type)
+	}
+	return &schema, nil
+}
+
+// normalizeSchema проверяет узел схемы path и приводит его к виду, который понимает jsonschema.Validate:
+// узел без type (кроме ссылки $ref) отклонялся бы при каждой проверке записи, а объединение типов
+// не разбирается вовсе. Тип ["T", "null"] заменяется на T, и свойство становится необязательным:
+// null в записи равен отсутствию поля (см. dropNulls). Возвращает, допускал ли узел null.
+func normalizeSchema(node map[string]any, path string) (nullable bool, err error) {
+	if _, ok := node["$ref"]; ok {
+		return false, nil
+	}
+
+	switch t := node["type"].(type) {
+	case nil:
+		return false, fmt.Errorf("%s: не указан type", path)
+	case string:
+	case []any:
+		var types []string
+		for _, item := range t {
+			name, ok := item.(string)
+			if !ok {
+				return false, fmt.Errorf("%s: type должен содержать имена типов", path)
+			}
+			if name == string(jsonschema.Null) {
+				nullable = true
+				continue
+			}
+			types = append(types, name)
+		}
+		if len(types) != 1 {
+			return false, fmt.Errorf("%s: объединение типов %v не поддерживается, допускается только [\"тип\", \"null\"]", path, t)
+		}
+		node["type"] = types[0]
+	default:
+		return false, fmt.Errorf("%s: type должен быть строкой", path)
+	}
+
+	if defs, ok := node["$defs"].(map[string]any); ok {
+		for name, def := range defs {
+			child, ok := def.(map[string]any)
+			if !ok {
+				return false, fmt.Errorf("%s.$defs.%s: определение должно быть объектом", path, name)
+			}
+			if _, err := normalizeSchema(child, path+".$defs."+name); err != nil {
+				return false, err
+			}
+		}
+	}
+
+	switch node["type"] {
+	case string(jsonschema.Object):
+		properties, _ := node["properties"].(map[string]any)
+		for name, property := range properties {
+			child, ok := property.(map[string]any)
+			if !ok {
+				return false, fmt.Errorf("%s.%s: свойство должно быть объектом схемы", path, name)
+			}
+			childNullable, err := normalizeSchema(child, path+"."+name)
+			if err != nil {
+				return false, err
+			}
+			if childNullable {
+				removeRequired(node, name)
+			}
+		}
+	case string(jsonschema.Array):
+		if items, ok := node["items"].(map[string]any); ok {
+			if _, err := normalizeSchema(items, path+"[]"); err != nil {
+				return false, err
+			}
+		}
+	}
+	return nullable, nil
+}
+
+// removeRequired убирает свойство name из списка required узла схемы.
+func removeRequired(node map[string]any, name string) {
+	required, _ := node["required"].([]any)
+	kept := make([]any, 0, len(required))
+	for _, r := range required {
+		if r != name {
+			kept = append(kept, r)
+		}
+	}
+	node["required"] = kept
+}
+
+// dropNulls удаляет из объектов записи поля со значением null: схема допускает null
+// только у необязательных полей (см. normalizeSchema), и отсутствие поля проходит проверку.
+func dropNulls(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			if item == nil {
+				delete(v, key)
+				continue
+			}
+			v[key] = dropNulls(item)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = dropNulls(item)
+		}
+	}
+	return value
+}
+
+// resultCollector накапливает записи, извлеченные на шагах extract_info.
+// Каждая запись проверяется по схеме записи, дубликаты отбрасываются.
+// Итоговый результат задачи - массив принятых записей.
+type resultCollector struct {
+	task         string
+	recordSchema jsonschema.Definition
+	schemaJSON   string // Схема записи для промпта
+	records      []json.RawMessage
+	seen         map[string]bool
+}
+
+// newResultCollector создает сборщик по схеме задачи. existing - ранее сохраненный результат
+// (при возобновлении задачи), пустая строка - начать с нуля.
+func newResultCollector(task, schemaJSON, existing string) (*resultCollector, error) {
+	schema, err := ParseOutputSchema(schemaJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	recordSchema := *schema
+	if schema.Type == jsonschema.Array {
+		recordSchema = *schema.Items
+	}
+	recordJSON, err := json.Marshal(&recordSchema)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка сериализации схемы записи: %w", err)
+	}
+
+	c := &resultCollector{
+		task:         task,
+		recordSchema: recordSchema,
+		schemaJSON:   string(recordJSON),
+		seen:         make(map[string]bool),
+	}
+
+	if existing != "" {
+		var records []json.RawMessage
+		if err := json.Unmarshal([]byte(existing), &records); err != nil {
+			return nil, fmt.Errorf("ошибка чтения сохраненного результата: %w", err)
+		}
+		c.Add(records)
+	}
+	return c, nil
+}
+
+// Add проверяет записи по схеме и добавляет новые. Возвращает количество принятых и отклоненных схемой записей.
+func (c *resultCollector) Add(records []json.RawMessage) (accepted, rejected int) {
+	for _, raw := range records {
+		var value any
+		if err := json.Unmarshal(raw, &value); err != nil || !jsonschema.Validate(c.recordSchema, dropNulls(value)) {
+			rejected++
+			continue
+		}
+
+		// Повторная сериализация дает канонический вид (ключи отсортированы) для поиска дубликатов
+		canonical, err := json.Marshal(value)
+		if err != nil {
+			rejected++
+			continue
+		}
+		if c.seen[string(canonical)] {
+			continue
+		}
+		c.seen[string(canonical)] = true
+		c.records = append(c.records, canonical)
+		accepted++
+	}
+	return accepted, rejected
+}
+
+// Len возвращает количество накопленных записей.
+func (c *resultCollector) Len() int {
+	return len(c.records)
+}
+
+// JSON возвращает итоговый результат - массив записей.
+func (c *resultCollector) JSON() string {
+	if len(c.records) == 0 {
+		return "[]"
+	}
+	data, _ := json.Marshal(c.records)
+	return string(data)
+}
+
+// initResults создает сборщик структурированного результата, если у задачи задана схема.
+// resume - продолжить с ранее сохраненными записями. Ошибка схемы не критична: задача
+// выполняется без структурированного результата. Пробный запуск результат не меняет.
+func (a *Agent) initResults(taskID *uint, userInput, schemaJSON, existing string, resume bool) {
+	a.results = nil
+	if schemaJSON == "" || a.llmClient == nil || a.dryRun {
+		return
+	}
+	if !resume {
+		existing = ""
+	}
+	collector, err := newResultCollector(userInput, schemaJSON, existing)
+	if err != nil {
+		a.log.Warn("Структурированный результат отключен", a.contextFields(taskID, 0, zap.Error(err))...)
+		return
+	}
+	a.results = collector
+
+	// Новый запуск не должен отдавать записи предыдущего
+	if !resume && taskID != nil {
+		if err := a.repo.UpdateTaskResult(*taskID, collector.JSON()); err != nil {
+			a.log.Error("Ошибка сохранения результата задачи", a.contextFields(taskID, 0, zap.Error(err))...)
+		}
+	}
+}
+
+// extractRecords извлекает записи со страницы по схеме задачи и сохраняет накопленный результат.
+func (a *Agent) extractRecords(ctx context.Context, pageContext string) (string, error) {
+	taskID := llm.TaskIDFromContext(ctx)
+
+	var records []json.RawMessage
+	err := a.retry(ctx, RetryScopeLLM, func() error {
+		return a.callLLM(ctx, func() error {
+			r, e := a.llmClient.ExtractRecords(ctx, a.results.task, pageContext, a.results.schemaJSON, taskID, nil)
+			if e != nil {
+				return e
+			}
+			records = r
+			return nil
+		})
+	})
+	if err != nil {
+		return "", fmt.Errorf("извлечение записей: %w", err)
+	}
+
+	before := a.results.Len()
+	accepted, rejected := a.results.Add(records)
+	if rejected > 0 {
+		a.log.Warn("Записи не прошли проверку схемы", a.contextFields(taskID, 0, zap.Int("rejected", rejected))...)
+	}
+
+	if taskID != nil && accepted > 0 {
+		if err := a.repo.UpdateTaskResult(*taskID, a.results.JSON()); err != nil {
+			a.log.Error("Ошибка сохранения результата задачи", a.contextFields(taskID, 0, zap.Error(err))...)
+		}
+	}
+
+	summary := fmt.Sprintf("Извлечено записей: %d новых, %d отклонено схемой, всего %d",
+		accepted, rejected, a.results.Len())
+	if accepted == 0 {
+		return summary, nil
+	}
+	newRecords, _ := json.Marshal(a.results.records[before:])
+	return fmt.Sprintf("%s. Новые: %s", summary, truncateRunes(string(newRecords), maxResultLen)), nil
+}
+
+func truncateRunes(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max]) + "..."
+}
@@ -0,0 +1,101 @@
+package agent
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseOutputSchema(t *testing.T) {
+	tests := []struct {
+		name    string
+		schema  string
+		wantErr bool
+	}{
+		{"объект", `{"type":"object","properties":{"title":{"type":"string"}},"required":["title"]}`, false},
+		{"массив записей", `{"type":"array","items":{"type":"object","properties":{"n":{"type":"integer"}}}}`, false},
+		{"поле с null", `{"type":"object","properties":{"salary":{"type":["string","null"]}}}`, false},
+		{"null первым", `{"type":"object","properties":{"salary":{"type":["null","number"]}}}`, false},
+		{"ссылка без type", `{"type":"object","$defs":{"c":{"type":"string"}},"properties":{"company":{"$ref":"#/$defs/c"}}}`, false},
+		{"не JSON", `{"type":`, true},
+		{"строка на верхнем уровне", `{"type":"string"}`, true},
+		{"массив без items", `{"type":"array"}`, true},
+		{"нет type у корня", `{"properties":{"a":{"type":"string"}}}`, true},
+		{"нет type у свойства", `{"type":"object","properties":{"a":{"description":"название"}}}`, true},
+		{"нет type у вложенного свойства", `{"type":"array","items":{"type":"object","properties":{"a":{}}}}`, true},
+		{"объединение двух типов", `{"type":"object","properties":{"a":{"type":["string","integer"]}}}`, true},
+		{"только null в объединении", `{"type":"object","properties":{"a":{"type":["null"]}}}`, true},
+		{"type не строка", `{"type":"object","properties":{"a":{"type":5}}}`, true},
+		{"свойство не объект", `{"type":"object","properties":{"a":"string"}}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseOutputSchema(tt.schema)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseOutputSchema() ошибка = %v, ожидалась ошибка: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestResultCollectorAdd(t *testing.T) {
+	const schema = `{"type":"array","items":{"type":"object","required":["title","salary"],
+		"properties":{"title":{"type":"string"},"salary":{"type":["integer","null"]},"level":{"type":"string","enum":["junior","senior"]}}}}`
+
+	tests := []struct {
+		name     string
+		record   string
+		accepted bool
+	}{
+		{"все поля", `{"title":"Go","salary":100,"level":"senior"}`, true},
+		{"null в необязательном поле", `{"title":"Rust","salary":null}`, true},
+		{"поле с null пропущено", `{"title":"Zig"}`, true},
+		{"нет обязательного поля", `{"salary":100}`, false},
+		{"неверный тип", `{"title":"C","salary":"много"}`, false},
+		{"дробное вместо целого", `{"title":"C","salary":1.5}`, false},
+		{"значение вне enum", `{"title":"C","level":"middle"}`, false},
+		{"null в обязательном поле", `{"title":null}`, false},
+		{"не объект", `["Go"]`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := newResultCollector("задача", schema, "")
+			if err != nil {
+				t.Fatalf("newResultCollector: %v", err)
+			}
+			accepted, rejected := c.Add([]json.RawMessage{json.RawMessage(tt.record)})
+			if got := accepted == 1; got != tt.accepted || accepted+rejected != 1 {
+				t.Errorf("Add(%s) = принято %d, отклонено %d; ожидалось принято: %v", tt.record, accepted, rejected, tt.accepted)
+			}
+		})
+	}
+}
+
+func TestResultCollectorDuplicates(t *testing.T) {
+	c, err := newResultCollector("задача", `{"type":"object","properties":{"a":{"type":"string"},"b":{"type":"string"}}}`, `[{"a":"1","b":"2"}]`)
+	if err != nil {
+		t.Fatalf("newResultCollector: %v", err)
+	}
+
+	// Порядок ключей не важен: запись приводится к каноническому виду
+	accepted, rejected := c.Add([]json.RawMessage{
+		json.RawMessage(`{"b":"2","a":"1"}`),
+		json.RawMessage(`{"a":"3"}`),
+		json.RawMessage(`{"a":"3"}`),
+	})
+	if accepted != 1 || rejected != 0 {
+		t.Errorf("Add() = принято %d, отклонено %d; ожидалось 1 и 0", accepted, rejected)
+	}
+	if got, want := c.JSON(), `[{"a":"1","b":"2"},{"a":"3"}]`; got != want {
+		t.Errorf("JSON() = %s, ожидалось %s", got, want)
+	}
+}
+
+func TestResultCollectorEmptyJSON(t *testing.T) {
+	c, err := newResultCollector("задача", `{"type":"object"}`, "")
+	if err != nil {
+		t.Fatalf("newResultCollector: %v", err)
+	}
+	if got := c.JSON(); got != "[]" {
+		t.Errorf("JSON() = %s, ожидалось []", got)
+	}
+}
@@ -1,9 +1,11 @@
 package agent
 
 import (
+	"aiAgent/internal/database"
 	"aiAgent/internal/llm"
 	"context"
 	"fmt"
+	"sort"
 )
 
 type LLMClient = llm.LLMClient
@@ -19,17 +21,42 @@ const (
 
 type SpecializedAgent interface {
 	CanHandle(ctx context.Context, task string, pageContext string) (confidence float64, err error)
-	Execute(ctx context.Context, task string, maxSteps int) error
+	Execute(ctx context.Context, task *database.Task, maxSteps int) error
 	GetExpertise() []string
 	GetType() TaskType
 	GetDescription() string
 }
 
+// Способы выбора агента при маршрутизации
+const (
+	RoutingKeywords = "keywords"          // Однозначное совпадение ключевых слов, LLM не вызывалась
+	RoutingLLM      = "llm"               // Ранжирование моделью по описаниям и экспертизе агентов
+	RoutingFallback = "keywords_fallback" // Маршрутизация LLM не удалась, ранжирование по ключевым словам
+	RoutingDefault  = "default"           // Ни один агент не набрал минимальную уверенность
+)
+
+// RankedAgent - агент-кандидат с уверенностью и обоснованием
+type RankedAgent struct {
+	Agent      SpecializedAgent
+	Confidence float64
+	Rationale  string
+}
+
+// RoutingDecision - выбранный агент и ранжированный список кандидатов
+type RoutingDecision struct {
+	Agent      SpecializedAgent
+	Confidence float64
+	Rationale  string
+	Method     string
+	Ranking    []RankedAgent // Кандидаты по убыванию уверенности
+}
+
 type AgentRouter struct {
 	agents        map[TaskType]SpecializedAgent
 	defaultAgent  SpecializedAgent
 	llmClient     LLMClient
 	confidenceMin float64
+	useLLM        bool
 }
 
 func NewAgentRouter(llmClient LLMClient, confidenceMin float64) *AgentRouter {
@@ -52,35 +79,133 @@ func (r *AgentRouter) SetDefaultAgent(agent SpecializedAgent) {
 	r.defaultAgent = agent
 }
 
+// SetLLMRouting включает выбор агента моделью. Ключевые слова остаются быстрым фильтром:
+// если совпадение однозначное, модель не вызывается, а при ошибке модели используется ранжирование по ним.
+func (r *AgentRouter) SetLLMRouting(enabled bool) {
+	r.useLLM = enabled
+}
+
 func (r *AgentRouter) RouteTask(ctx context.Context, task string, pageContext string) (SpecializedAgent, error) {
-	bestAgent := r.defaultAgent
-	bestConfidence := 0.0
+	decision, err := r.Route(ctx, task, pageContext, nil)
+	if err != nil {
+		return nil, err
+	}
+	return decision.Agent, nil
+}
+
+// Route выбирает агента для задачи и объясняет выбор.
+// taskID используется для учета запроса маршрутизации в расходе задачи.
+func (r *AgentRouter) Route(ctx context.Context, task string, pageContext string, taskID *uint) (*RoutingDecision, error) {
+	ranking := r.rankByKeywords(ctx, task, pageContext)
+
+	if !r.useLLM || r.llmClient == nil {
+		return r.decide(task, ranking, RoutingKeywords)
+	}
+
+	// Однозначное совпадение ключевых слов: только один агент набрал минимальную уверенность
+	if len(ranking) > 0 && ranking[0].Confidence >= r.confidenceMin &&
+		(len(ranking) == 1 || ranking[1].Confidence < r.confidenceMin) {
+		return r.decide(task, ranking, RoutingKeywords)
+	}
 
+	llmRanking, err := r.rankByLLM(ctx, task, pageContext, taskID)
+	if err != nil {
+		decision, decideErr := r.decide(task, ranking, RoutingFallback)
+		if decideErr != nil {
+			return nil, decideErr
+		}
+		decision.Rationale = fmt.Sprintf("%s (маршрутизация LLM не удалась: %v)", decision.Rationale, err)
+		return decision, nil
+	}
+	return r.decide(task, llmRanking, RoutingLLM)
+}
+
+// rankByKeywords ранжирует агентов по их собственной оценке CanHandle (ключевые слова и контекст страницы).
+func (r *AgentRouter) rankByKeywords(ctx context.Context, task string, pageContext string) []RankedAgent {
+	ranking := make([]RankedAgent, 0, len(r.agents))
 	for _, agent := range r.agents {
 		confidence, err := agent.CanHandle(ctx, task, pageContext)
-		if err != nil {
+		if err != nil || confidence <= 0 {
 			continue
 		}
+		ranking = append(ranking, RankedAgent{
+			Agent:      agent,
+			Confidence: min(confidence, 1),
+			Rationale:  fmt.Sprintf("совпадение ключевых слов (оценка %.2f)", confidence),
+		})
+	}
 
-		if confidence > bestConfidence {
-			bestConfidence = confidence
-			bestAgent = agent
+	sort.SliceStable(ranking, func(i, j int) bool {
+		if ranking[i].Confidence != ranking[j].Confidence {
+			return ranking[i].Confidence > ranking[j].Confidence
 		}
+		return ranking[i].Agent.GetType() < ranking[j].Agent.GetType()
+	})
+	return ranking
+}
+
+// rankByLLM передает модели описания и экспертизу всех агентов и возвращает ее ранжирование.
+func (r *AgentRouter) rankByLLM(ctx context.Context, task string, pageContext string, taskID *uint) ([]RankedAgent, error) {
+	candidates := make([]llm.AgentCandidate, 0, len(r.agents))
+	for _, agent := range r.agents {
+		candidates = append(candidates, llm.AgentCandidate{
+			Type:        string(agent.GetType()),
+			Description: agent.GetDescription(),
+			Expertise:   agent.GetExpertise(),
+		})
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].Type < candidates[j].Type
+	})
+
+	choices, err := r.llmClient.RouteTask(ctx, task, pageContext, candidates, taskID, nil)
+	if err != nil {
+		return nil, err
 	}
 
-	if bestConfidence < r.confidenceMin && r.defaultAgent != nil {
-		return r.defaultAgent, nil
+	ranking := make([]RankedAgent, 0, len(choices))
+	for _, choice := range choices {
+		ranking = append(ranking, RankedAgent{
+			Agent:      r.agents[TaskType(choice.Agent)],
+			Confidence: choice.Confidence,
+			Rationale:  choice.Rationale,
+		})
+	}
+	return ranking, nil
+}
+
+// decide выбирает лучшего кандидата или агента по умолчанию, если уверенность ниже минимальной.
+func (r *AgentRouter) decide(task string, ranking []RankedAgent, method string) (*RoutingDecision, error) {
+	if len(ranking) > 0 && (ranking[0].Confidence >= r.confidenceMin || r.defaultAgent == nil) {
+		best := ranking[0]
+		return &RoutingDecision{
+			Agent:      best.Agent,
+			Confidence: best.Confidence,
+			Rationale:  best.Rationale,
+			Method:     method,
+			Ranking:    ranking,
+		}, nil
 	}
 
-	if bestAgent == nil {
+	if r.defaultAgent == nil {
 		return nil, fmt.Errorf("no suitable agent found for task: %s", task)
 	}
 
-	return bestAgent, nil
+	best := 0.0
+	if len(ranking) > 0 {
+		best = ranking[0].Confidence
+	}
+	return &RoutingDecision{
+		Agent:      r.defaultAgent,
+		Confidence: best,
+		Rationale:  fmt.Sprintf("ни один агент не набрал уверенность %.2f (лучшая %.2f), выбран агент по умолчанию", r.confidenceMin, best),
+		Method:     RoutingDefault,
+		Ranking:    ranking,
+	}, nil
 }
 
-func (r *AgentRouter) ExecuteWithRouting(ctx context.Context, task string, pageContext string, maxSteps int) error {
-	agent, err := r.RouteTask(ctx, task, pageContext)
+func (r *AgentRouter) ExecuteWithRouting(ctx context.Context, task *database.Task, pageContext string, maxSteps int) error {
+	agent, err := r.RouteTask(ctx, task.UserInput, pageContext)
 	if err != nil {
 		return fmt.Errorf("failed to route task: %w", err)
 	}
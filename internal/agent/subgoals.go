@@ -0,0 +1,458 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"aiAgent/internal/database"
+	"aiAgent/internal/llm"
+
+	"go.uber.org/zap"
+)
+
+// Иерархическая декомпозиция: в начале задачи LLM разбивает ее на упорядоченное дерево подзадач
+// (не глубже двух уровней), которое сохраняется в task_subgoals. Листовые подзадачи выполняются
+// по очереди ReAct циклом executeSteps, каждая со своим бюджетом шагов и проверкой завершения.
+// Если подзадача не уложилась в бюджет, перестраиваются только невыполненные подзадачи -
+// выполненные остаются в дереве, а замененные помечаются replaced.
+
+// Статусы подзадач.
+const (
+	SubgoalPending   = "pending"
+	SubgoalRunning   = "running"
+	SubgoalCompleted = "completed"
+	SubgoalFailed    = "failed"
+	SubgoalReplaced  = "replaced"
+)
+
+const (
+	defaultSubgoalSteps = 10 // Бюджет шагов подзадачи, если модель его не указала
+	maxSubgoalReplans   = 3  // Сколько раз дерево подзадач может быть перестроено за один запуск
+	maxProgressResult   = 200
+)
+
+// subgoalTree - дерево подзадач задачи. nodes упорядочены по position:
+// родительская подзадача идет перед своими дочерними, поэтому порядок nodes - порядок выполнения.
+type subgoalTree struct {
+	nodes    []*database.TaskSubgoal
+	children map[uint][]*database.TaskSubgoal
+	byID     map[uint]*database.TaskSubgoal
+}
+
+func newSubgoalTree(subgoals []database.TaskSubgoal) *subgoalTree {
+	t := &subgoalTree{
+		children: make(map[uint][]*database.TaskSubgoal),
+		byID:     make(map[uint]*database.TaskSubgoal),
+	}
+	for i := range subgoals {
+		sg := &subgoals[i]
+		t.nodes = append(t.nodes, sg)
+		t.byID[sg.ID] = sg
+		if sg.ParentID != nil {
+			t.children[*sg.ParentID] = append(t.children[*sg.ParentID], sg)
+		}
+	}
+	return t
+}
+
+// next возвращает первую невыполненную листовую подзадачу (nil если все выполнены).
+func (t *subgoalTree) next() *database.TaskSubgoal {
+	for _, sg := range t.nodes {
+		if len(t.children[sg.ID]) > 0 {
+			continue
+		}
+		if sg.Status == SubgoalPending || sg.Status == SubgoalRunning {
+			return sg
+		}
+	}
+	return nil
+}
+
+func (t *subgoalTree) parent(sg *database.TaskSubgoal) *database.TaskSubgoal {
+	if sg.ParentID == nil {
+		return nil
+	}
+	return t.byID[*sg.ParentID]
+}
+
+// leaves возвращает актуальные (не замененные) листовые подзадачи.
+func (t *subgoalTree) leaves() []*database.TaskSubgoal {
+	var leaves []*database.TaskSubgoal
+	for _, sg := range t.nodes {
+		if len(t.children[sg.ID]) == 0 && sg.Status != SubgoalReplaced {
+			leaves = append(leaves, sg)
+		}
+	}
+	return leaves
+}
+
+func (t *subgoalTree) revision() int {
+	revision := 0
+	for _, sg := range t.nodes {
+		revision = max(revision, sg.Revision)
+	}
+	return revision
+}
+
+func (t *subgoalTree) nextPosition() int {
+	position := 0
+	for _, sg := range t.nodes {
+		position = max(position, sg.Position+1)
+	}
+	return position
+}
+
+// progress описывает ход выполнения для перепланирования (без замененных подзадач).
+func (t *subgoalTree) progress() []llm.SubgoalProgress {
+	var progress []llm.SubgoalProgress
+	for _, sg := range t.nodes {
+		if sg.Status == SubgoalReplaced {
+			continue
+		}
+		title := sg.Title
+		if parent := t.parent(sg); parent != nil {
+			title = parent.Title + " / " + title
+		}
+		progress = append(progress, llm.SubgoalProgress{
+			Title:  title,
+			Status: sg.Status,
+			Result: truncateRunes(sg.Result, maxProgressResult),
+		})
+	}
+	return progress
+}
+
+// subgoalCriteria возвращает критерий завершения подзадачи.
+func subgoalCriteria(sg *database.TaskSubgoal) []string {
+	if sg.SuccessCheck != "" {
+		return []string{sg.SuccessCheck}
+	}
+	return []string{sg.Title}
+}
+
+// subgoalInput формирует задачу для ReAct цикла подзадачи: общая цель, что уже сделано и текущий этап.
+func subgoalInput(task *database.Task, tree *subgoalTree, sg *database.TaskSubgoal) string {
+	var sb strings.Builder
+	sb.WriteString(task.UserInput)
+
+	var done []string
+	for _, leaf := range tree.leaves() {
+		if leaf.Status == SubgoalCompleted {
+			done = append(done, leaf.Title)
+		}
+	}
+	if len(done) > 0 {
+		sb.WriteString("\n\nУже выполнено:")
+		for _, title := range done {
+			fmt.Fprintf(&sb, "\n- %s", title)
+		}
+	}
+
+	fmt.Fprintf(&sb, "\n\nТекущая подзадача: %s", sg.Title)
+	if parent := tree.parent(sg); parent != nil {
+		fmt.Fprintf(&sb, " (этап: %s)", parent.Title)
+	}
+	if sg.SuccessCheck != "" {
+		fmt.Fprintf(&sb, "\nПодзадача выполнена, когда: %s", sg.SuccessCheck)
+	}
+	sb.WriteString("\nВыполняй только текущую подзадачу и используй complete, как только она выполнена.")
+	return sb.String()
+}
+
+// decomposeTask разбивает задачу на подзадачи и сохраняет дерево, удаляя дерево прошлого запуска.
+func (a *Agent) decomposeTask(ctx context.Context, task *database.Task, maxSteps int) error {
+	pageContext, _ := a.getPageContext(ctx)
+	var subgoals []llm.Subgoal
+	err := a.retry(ctx, RetryScopeLLM, func() error {
+		return a.callLLM(ctx, func() error {
+			s, e := a.llmClient.DecomposeTask(ctx, task.UserInput, pageContext, maxSteps, &task.ID, nil)
+			if e != nil {
+				return e
+			}
+			subgoals = s
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := a.repo.DeleteSubgoals(task.ID); err != nil {
+		return fmt.Errorf("ошибка удаления подзадач прошлого запуска: %w", err)
+	}
+	if err := a.saveSubgoals(task.ID, subgoals, 0, 0); err != nil {
+		return err
+	}
+
+	a.log.Info("Задача разбита на подзадачи", a.contextFields(&task.ID, 0, zap.Int("subgoals", len(subgoals)))...)
+	fmt.Fprintf(outputFrom(ctx), "Задача разбита на подзадачи (%d):\n", len(subgoals))
+	for i, sg := range subgoals {
+		fmt.Fprintf(outputFrom(ctx), "  %d. %s\n", i+1, sg.Title)
+		for _, child := range sg.Subgoals {
+			fmt.Fprintf(outputFrom(ctx), "     - %s\n", child.Title)
+		}
+	}
+	return nil
+}
+
+// saveSubgoals сохраняет подзадачи, начиная с позиции position. Бюджет подзадачи с дочерними -
+// сумма бюджетов дочерних.
+func (a *Agent) saveSubgoals(taskID uint, subgoals []llm.Subgoal, revision, position int) error {
+	newSubgoal := func(s llm.Subgoal, parentID *uint) *database.TaskSubgoal {
+		sg := &database.TaskSubgoal{
+			TaskID:       taskID,
+			ParentID:     parentID,
+			Position:     position,
+			Revision:     revision,
+			Title:        a.sanitizer.Sanitize(s.Title),
+			SuccessCheck: a.sanitizer.Sanitize(s.SuccessCheck),
+			StepBudget:   subgoalBudget(s),
+			Status:       SubgoalPending,
+		}
+		position++
+		return sg
+	}
+
+	for _, s := range subgoals {
+		parent := newSubgoal(s, nil)
+		if err := a.repo.CreateSubgoals([]*database.TaskSubgoal{parent}); err != nil {
+			return fmt.Errorf("ошибка сохранения подзадачи: %w", err)
+		}
+
+		children := make([]*database.TaskSubgoal, 0, len(s.Subgoals))
+		for _, child := range s.Subgoals {
+			children = append(children, newSubgoal(child, &parent.ID))
+		}
+		if err := a.repo.CreateSubgoals(children); err != nil {
+			return fmt.Errorf("ошибка сохранения подзадачи: %w", err)
+		}
+	}
+	return nil
+}
+
+func subgoalBudget(s llm.Subgoal) int {
+	if len(s.Subgoals) > 0 {
+		budget := 0
+		for _, child := range s.Subgoals {
+			budget += subgoalBudget(child)
+		}
+		return budget
+	}
+	if s.StepBudget <= 0 {
+		return defaultSubgoalSteps
+	}
+	return s.StepBudget
+}
+
+// runSubgoals выполняет сохраненное дерево подзадач начиная с шага firstStep.
+// Выполненные подзадачи пропускаются, поэтому так же продолжается прерванная задача:
+// cp - checkpoint прерванной задачи (nil при обычном запуске), из него восстанавливается
+// история прерванной подзадачи. Когда все подзадачи выполнены, проверяются критерии успеха задачи целиком.
+func (a *Agent) runSubgoals(ctx context.Context, task *database.Task, firstStep, maxSteps int, cp *database.TaskCheckpoint) error {
+	a.initResults(&task.ID, task.UserInput, task.OutputSchema, task.Result, firstStep > 1)
+
+	criteria := ParseCriteria(task.SuccessCriteria)
+	if len(criteria) == 0 {
+		pageContext, _ := a.getPageContext(ctx)
+		criteria = a.deriveCriteria(ctx, task.UserInput, pageContext, &task.ID)
+	}
+
+	stepNo := firstStep
+	replans := 0
+	for {
+		subgoals, err := a.repo.ListSubgoals(task.ID)
+		if err != nil {
+			return fmt.Errorf("ошибка чтения подзадач: %w", err)
+		}
+		tree := newSubgoalTree(subgoals)
+
+		var failed, reason string
+		if current := tree.next(); current != nil {
+			if stepNo > maxSteps {
+				return fmt.Errorf("%w (%d)", ErrStepLimit, maxSteps)
+			}
+			used, err := a.runSubgoal(ctx, task, tree, current, stepNo, maxSteps-stepNo+1, cp)
+			cp = nil
+			stepNo += used
+			if err == nil {
+				continue
+			}
+			if !errors.Is(err, ErrStepLimit) {
+				return err
+			}
+			failed, reason = current.Title, current.Result
+		} else {
+			pageContext, _ := a.getPageContext(ctx)
+			verdict, verifyErr := a.verifyCompletion(ctx, task.UserInput, criteria, pageContext, &task.ID, stepNo)
+			if verifyErr != nil {
+				return fmt.Errorf("ошибка проверки критериев успеха: %w", verifyErr)
+			}
+			if verdict == nil || verdict.Passed {
+				return a.completeSubgoals(task, tree, verdict)
+			}
+			failed, reason = "проверка задачи целиком", unmetObservation(verdict)
+		}
+
+		if stepNo > maxSteps {
+			return fmt.Errorf("%w (%d)", ErrStepLimit, maxSteps)
+		}
+		if replans >= maxSubgoalReplans {
+			return fmt.Errorf("подзадача «%s» не выполнена после %d перепланирований: %s", failed, replans, reason)
+		}
+		replans++
+		if err := a.replanSubgoals(ctx, task, tree, failed, reason, tree.revision()+1, maxSteps-stepNo+1); err != nil {
+			return err
+		}
+	}
+}
+
+// runSubgoal выполняет листовую подзадачу в пределах ее оставшегося бюджета (но не больше remaining шагов).
+// Возвращает количество израсходованных шагов. ErrStepLimit означает, что подзадача не уложилась в бюджет.
+// Подзадача, прерванная на середине (running), продолжается с историей из cp; checkpoint,
+// сохраненный между подзадачами, относится к предыдущей и не восстанавливается.
+func (a *Agent) runSubgoal(ctx context.Context, task *database.Task, tree *subgoalTree, sg *database.TaskSubgoal, stepNo, remaining int, cp *database.TaskCheckpoint) (int, error) {
+	if sg.Status != SubgoalRunning {
+		cp = nil
+	}
+	budget := min(sg.StepBudget-sg.StepsUsed, remaining)
+	if budget <= 0 {
+		sg.Status = SubgoalFailed
+		sg.Result = fmt.Sprintf("Бюджет шагов исчерпан (%d)", sg.StepBudget)
+		a.updateSubgoal(sg)
+		return 0, fmt.Errorf("%w (%d)", ErrStepLimit, sg.StepBudget)
+	}
+
+	parent := tree.parent(sg)
+	sg.Status = SubgoalRunning
+	a.updateSubgoal(sg)
+	if parent != nil && parent.Status == SubgoalPending {
+		parent.Status = SubgoalRunning
+		a.updateSubgoal(parent)
+	}
+
+	a.log.Info("Выполнение подзадачи", a.contextFields(&task.ID, stepNo,
+		zap.String("subgoal", sg.Title),
+		zap.Int("budget", budget))...)
+	fmt.Fprintf(outputFrom(ctx), "\n[Подзадача] %s (бюджет: %d шагов)\n", sg.Title, budget)
+
+	usedBefore := sg.StepsUsed
+	err := a.executeSteps(executeStepsParams{
+		ctx:        ctx,
+		userInput:  subgoalInput(task, tree, sg),
+		maxSteps:   stepNo + budget - 1,
+		taskID:     &task.ID,
+		saveSteps:  true,
+		updateTask: true,
+		checkpoint: cp,
+		firstStep:  stepNo,
+		subgoal:    sg,
+	})
+	used := sg.StepsUsed - usedBefore
+
+	switch {
+	case err == nil:
+		sg.Status = SubgoalCompleted
+		fmt.Fprintf(outputFrom(ctx), "[Подзадача] Выполнена: %s\n", sg.Title)
+	case errors.Is(err, ErrStepLimit):
+		sg.Status = SubgoalFailed
+		sg.Result = fmt.Sprintf("Бюджет шагов исчерпан (%d)", sg.StepBudget)
+		fmt.Fprintf(outputFrom(ctx), "[Подзадача] Не выполнена: %s\n", sg.Title)
+	case ctx.Err() != nil || errors.Is(err, ErrBudgetExceeded):
+		// Подзадача остается running и продолжится при resume
+	default:
+		sg.Status = SubgoalFailed
+		sg.Result = a.sanitizer.Sanitize(err.Error())
+	}
+	a.updateSubgoal(sg)
+	if parent == nil {
+		return used, err
+	}
+
+	// Шаги подзадачи с дочерними - сумма шагов дочерних (включая прерванные запуски);
+	// она выполнена, когда выполнены все дочерние
+	parent.StepsUsed = 0
+	completed := true
+	for _, child := range tree.children[parent.ID] {
+		parent.StepsUsed += child.StepsUsed
+		if child.Status != SubgoalCompleted {
+			completed = false
+		}
+	}
+	if completed {
+		parent.Status = SubgoalCompleted
+	}
+	a.updateSubgoal(parent)
+	return used, err
+}
+
+// replanSubgoals заменяет невыполненные подзадачи новыми, составленными с учетом причины неудачи.
+func (a *Agent) replanSubgoals(ctx context.Context, task *database.Task, tree *subgoalTree, failed, reason string, revision, remaining int) error {
+	a.log.Warn("Перепланирование подзадач", a.contextFields(&task.ID, 0,
+		zap.String("failed", failed),
+		zap.String("reason", reason))...)
+
+	pageContext, _ := a.getPageContext(ctx)
+	var subgoals []llm.Subgoal
+	err := a.retry(ctx, RetryScopeLLM, func() error {
+		return a.callLLM(ctx, func() error {
+			s, e := a.llmClient.ReplanSubgoals(ctx, task.UserInput, pageContext, tree.progress(), failed, reason, remaining, &task.ID, nil)
+			if e != nil {
+				return e
+			}
+			subgoals = s
+			return nil
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("ошибка перепланирования подзадач: %w", err)
+	}
+
+	if err := a.repo.ReplaceSubgoals(task.ID); err != nil {
+		return fmt.Errorf("ошибка замены подзадач: %w", err)
+	}
+	if err := a.saveSubgoals(task.ID, subgoals, revision, tree.nextPosition()); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(outputFrom(ctx), "\n[Replan] Подзадача «%s» не выполнена, новых подзадач: %d\n", failed, len(subgoals))
+	for i, sg := range subgoals {
+		fmt.Fprintf(outputFrom(ctx), "  %d. %s\n", i+1, sg.Title)
+	}
+	return nil
+}
+
+// completeSubgoals завершает задачу после выполнения всех подзадач.
+func (a *Agent) completeSubgoals(task *database.Task, tree *subgoalTree, verdict *llm.CompletionVerdict) error {
+	var sb strings.Builder
+	sb.WriteString("Выполнены подзадачи:")
+	for _, leaf := range tree.leaves() {
+		if leaf.Status == SubgoalCompleted {
+			fmt.Fprintf(&sb, "\n- %s", leaf.Title)
+		}
+	}
+	summary := a.completionSummary(sb.String(), verdict)
+
+	if err := a.repo.UpdateTaskStatus(task.ID, "completed", summary); err != nil {
+		a.log.Error("Ошибка обновления статуса", a.contextFields(&task.ID, 0, zap.Error(err))...)
+	}
+	if err := a.repo.DeleteCheckpoint(task.ID); err != nil {
+		a.log.Warn("Ошибка удаления checkpoint", a.contextFields(&task.ID, 0, zap.Error(err))...)
+	}
+	return nil
+}
+
+// saveSubgoalProgress сохраняет израсходованные подзадачей шаги вместе с checkpoint, чтобы
+// после сбоя продолженная подзадача не получила бюджет заново. nil - шаги идут не в подзадаче.
+func (a *Agent) saveSubgoalProgress(sg *database.TaskSubgoal) {
+	if sg != nil {
+		a.updateSubgoal(sg)
+	}
+}
+
+func (a *Agent) updateSubgoal(sg *database.TaskSubgoal) {
+	if err := a.repo.UpdateSubgoal(sg); err != nil {
+		a.log.Error("Ошибка сохранения подзадачи", a.contextFields(&sg.TaskID, 0, zap.Error(err))...)
+	}
+}
@@ -0,0 +1,180 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"aiAgent/internal/browser"
+	"aiAgent/internal/llm"
+
+	"go.uber.org/zap"
+)
+
+// Передача управления (takeover): агент приостанавливает цикл шагов и оставляет окно Firefox
+// пользователю - например, для ввода кода 2FA, капчи или работы с нестандартным виджетом.
+// Запросить передачу может модель (действие takeover) или пользователь (команда takeover <id>).
+// После Enter в консоли агент снимает свежий снимок страницы и сообщает модели, что изменилось.
+
+// maxTakeoverChanges - сколько появившихся и исчезнувших элементов перечисляется в наблюдении.
+const maxTakeoverChanges = 5
+
+type jobKey struct{}
+
+// withJob возвращает контекст, через который агент получает запросы передачи управления от фоновой задачи.
+func withJob(ctx context.Context, job *Job) context.Context {
+	return context.WithValue(ctx, jobKey{}, job)
+}
+
+// takeoverRequested возвращает true и сбрасывает запрос, если пользователь запросил управление браузером.
+func takeoverRequested(ctx context.Context) bool {
+	job, ok := ctx.Value(jobKey{}).(*Job)
+	return ok && job != nil && job.takeTakeover()
+}
+
+// userTakeoverPlan - шаг передачи управления, запрошенной пользователем.
+func userTakeoverPlan() *llm.StepPlan {
+	return &llm.StepPlan{
+		Action:    "takeover",
+		Value:     "пользователь запросил управление браузером",
+		Reasoning: "Передача управления по команде пользователя",
+	}
+}
+
+// takeover выводит окно браузера на передний план и ждет, пока пользователь вернет управление.
+// Ответ пользователя (кроме пустой строки) передается модели как комментарий.
+func (a *Agent) takeover(ctx context.Context, reason string) (string, error) {
+	if a.userInputProvider == nil {
+		return "", fmt.Errorf("провайдер пользовательского ввода не настроен")
+	}
+	if err := a.browser.BringToFront(ctx); err != nil {
+		return "", fmt.Errorf("передача управления: %w", err)
+	}
+
+	taskID := llm.TaskIDFromContext(ctx)
+	before, err := a.browser.GetPageSnapshot(ctx)
+	if err != nil {
+		a.log.Warn("Ошибка снимка страницы перед передачей управления", a.contextFields(taskID, 0, zap.Error(err))...)
+	}
+
+	a.log.Info("Управление браузером передано пользователю", a.contextFields(taskID, 0, zap.String("reason", reason))...)
+	fmt.Fprintf(outputFrom(ctx), "Управление браузером передано пользователю: %s\n", reason)
+
+	question := fmt.Sprintf("Агент передал вам управление браузером: %s\n"+
+		"Выполните нужные действия в окне Firefox и ответьте, чтобы вернуть управление (в ответе можно оставить комментарий для агента)", reason)
+	answer, err := a.userInputProvider.AskUser(ctx, question)
+	if err != nil {
+		return "", fmt.Errorf("ошибка ожидания пользователя: %w", err)
+	}
+
+	after, err := a.browser.GetPageSnapshot(ctx)
+	if err != nil {
+		return "", fmt.Errorf("снимок страницы после передачи управления: %w", err)
+	}
+
+	a.log.Info("Пользователь вернул управление браузером", a.contextFields(taskID, 0, zap.String("url", after.URL))...)
+	fmt.Fprintln(outputFrom(ctx), "Управление браузером возвращено агенту")
+
+	observation := "Пользователь вернул управление. " + describePageChanges(before, after)
+	if comment := strings.TrimSpace(answer); comment != "" {
+		observation += fmt.Sprintf(" Комментарий пользователя: %s", comment)
+	}
+	return observation, nil
+}
+
+// describePageChanges описывает, что изменилось на странице между двумя снимками:
+// URL, заголовок и появившиеся или исчезнувшие элементы.
+func describePageChanges(before, after *browser.PageSnapshot) string {
+	if before == nil {
+		return fmt.Sprintf("Текущая страница: %s (%s).", after.URL, after.Title)
+	}
+
+	var changes []string
+	if before.URL != after.URL {
+		changes = append(changes, fmt.Sprintf("URL: %s -> %s", before.URL, after.URL))
+	}
+	if before.Title != after.Title {
+		changes = append(changes, fmt.Sprintf("заголовок: '%s' -> '%s'", before.Title, after.Title))
+	}
+
+	added, removed := diffElements(before.Elements, after.Elements)
+	if len(added) > 0 {
+		changes = append(changes, fmt.Sprintf("появились элементы (%d): %s", len(added), formatElementList(added)))
+	}
+	if len(removed) > 0 {
+		changes = append(changes, fmt.Sprintf("исчезли элементы (%d): %s", len(removed), formatElementList(removed)))
+	}
+
+	if len(changes) == 0 {
+		return "Страница не изменилась."
+	}
+	return "Изменения: " + strings.Join(changes, "; ") + "."
+}
+
+// diffElements возвращает видимые элементы, которые есть только во втором или только в первом снимке.
+// Элементы сравниваются по тегу и тексту: селекторы после действий пользователя могут смениться.
+func diffElements(before, after []browser.ElementInfo) (added, removed []browser.ElementInfo) {
+	key := func(el browser.ElementInfo) string {
+		return el.Tag + "|" + strings.TrimSpace(el.Text)
+	}
+	count := func(elements []browser.ElementInfo) map[string]int {
+		m := make(map[string]int)
+		for _, el := range elements {
+			if el.Visible {
+				m[key(el)]++
+			}
+		}
+		return m
+	}
+
+	beforeCount, afterCount := count(before), count(after)
+	for _, el := range after {
+		if el.Visible && beforeCount[key(el)] == 0 {
+			added = append(added, el)
+		}
+	}
+	for _, el := range before {
+		if el.Visible && afterCount[key(el)] == 0 {
+			removed = append(removed, el)
+		}
+	}
+	return added, removed
+}
+
+func formatElementList(elements []browser.ElementInfo) string {
+	parts := make([]string, 0, maxTakeoverChanges)
+	for i, el := range elements {
+		if i == maxTakeoverChanges {
+			parts = append(parts, "...")
+			break
+		}
+		text := truncateRunes(strings.TrimSpace(el.Text), 40)
+		if text == "" {
+			text = el.Selector
+		}
+		parts = append(parts, fmt.Sprintf("%s '%s'", el.Tag, text))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// replanAfterTakeover перестраивает multi-step план после возврата управления:
+// пользователь мог изменить страницу, поэтому оставшиеся шаги плана устарели.
+// executed - количество шагов плана, выполненных до передачи управления.
+func (a *Agent) replanAfterTakeover(ctx context.Context, taskText string, plan *llm.MultiStepPlan, step *llm.StepPlan, observation string, executed, maxSteps int, domain string) error {
+	var currentContext string
+	if snapshot, err := a.browser.GetPageSnapshot(ctx); err == nil && snapshot != nil {
+		currentContext = a.limitContextFromSnapshot(snapshot)
+	}
+
+	newPlan, err := a.replan(ctx, taskText, currentContext, plan, step, observation, maxSteps-executed)
+	if err != nil {
+		a.log.Error("Не удалось создать новый план после передачи управления", a.contextFields(nil, executed, zap.Error(err))...)
+		return fmt.Errorf("failed to replan after takeover: %w", err)
+	}
+
+	a.log.Info("Новый план создан после передачи управления", a.contextFields(nil, executed, zap.Int("new_steps", len(newPlan.Steps)))...)
+	fmt.Fprintf(outputFrom(ctx), "\n[Replan] Новая стратегия: %s\n", newPlan.OverallStrategy)
+	fmt.Fprintf(outputFrom(ctx), "[Новых шагов] %d\n\n", len(newPlan.Steps))
+
+	return a.executeMultiStepPlan(ctx, taskText, newPlan, maxSteps-executed, domain)
+}
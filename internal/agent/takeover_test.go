@@ -0,0 +1,75 @@
+package agent
+
+import (
+	"reflect"
+	"testing"
+
+	"aiAgent/internal/browser"
+)
+
+func TestDiffElements(t *testing.T) {
+	button := browser.ElementInfo{Tag: "button", Text: "Войти", Selector: "#login", Visible: true}
+	moved := browser.ElementInfo{Tag: "button", Text: " Войти ", Selector: "form > button", Visible: true}
+	link := browser.ElementInfo{Tag: "a", Text: "Профиль", Selector: "#profile", Visible: true}
+	hidden := browser.ElementInfo{Tag: "div", Text: "Загрузка", Selector: "#spinner"}
+
+	tests := []struct {
+		name           string
+		before, after  []browser.ElementInfo
+		added, removed []browser.ElementInfo
+	}{
+		{"без изменений", []browser.ElementInfo{button}, []browser.ElementInfo{button}, nil, nil},
+		{"новый элемент", []browser.ElementInfo{button}, []browser.ElementInfo{button, link}, []browser.ElementInfo{link}, nil},
+		{"исчезнувший элемент", []browser.ElementInfo{button, link}, []browser.ElementInfo{link}, nil, []browser.ElementInfo{button}},
+		{"смена селектора не изменение", []browser.ElementInfo{button}, []browser.ElementInfo{moved}, nil, nil},
+		{"невидимые не учитываются", []browser.ElementInfo{hidden}, []browser.ElementInfo{link}, []browser.ElementInfo{link}, nil},
+		{"пустые снимки", nil, nil, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			added, removed := diffElements(tt.before, tt.after)
+			if !reflect.DeepEqual(added, tt.added) || !reflect.DeepEqual(removed, tt.removed) {
+				t.Errorf("diffElements() = %v, %v; ожидалось %v, %v", added, removed, tt.added, tt.removed)
+			}
+		})
+	}
+}
+
+func TestDescribePageChanges(t *testing.T) {
+	page := func(url, title string, elements ...browser.ElementInfo) *browser.PageSnapshot {
+		return &browser.PageSnapshot{URL: url, Title: title, Elements: elements}
+	}
+	button := browser.ElementInfo{Tag: "button", Text: "Войти", Selector: "#login", Visible: true}
+	menu := browser.ElementInfo{Tag: "a", Selector: "#menu", Visible: true}
+
+	tests := []struct {
+		name          string
+		before, after *browser.PageSnapshot
+		want          string
+	}{
+		{"нет снимка до", nil, page("https://a.ru", "Главная"), "Текущая страница: https://a.ru (Главная)."},
+		{"ничего не изменилось", page("https://a.ru", "Главная", button), page("https://a.ru", "Главная", button), "Страница не изменилась."},
+		{"переход", page("https://a.ru", "Главная"), page("https://a.ru/me", "Профиль"),
+			"Изменения: URL: https://a.ru -> https://a.ru/me; заголовок: 'Главная' -> 'Профиль'."},
+		{"элементы", page("https://a.ru", "Главная", button), page("https://a.ru", "Главная", menu),
+			"Изменения: появились элементы (1): a '#menu'; исчезли элементы (1): button 'Войти'."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := describePageChanges(tt.before, tt.after); got != tt.want {
+				t.Errorf("describePageChanges() = %q, ожидалось %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatElementListLimit(t *testing.T) {
+	elements := make([]browser.ElementInfo, maxTakeoverChanges+2)
+	for i := range elements {
+		elements[i] = browser.ElementInfo{Tag: "li", Text: "пункт", Visible: true}
+	}
+	want := "li 'пункт', li 'пункт', li 'пункт', li 'пункт', li 'пункт', ..."
+	if got := formatElementList(elements); got != want {
+		t.Errorf("formatElementList() = %q, ожидалось %q", got, want)
+	}
+}
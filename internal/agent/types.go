@@ -30,30 +30,50 @@ type Agent struct {
 	log               *logger.Zap
 	maxSteps          int
 	maxTokens         int
-	retries           int
-	retryDelay        time.Duration
+	retryPolicies     map[string]RetryPolicy // Политики повторов по областям (см. DefaultRetryPolicies)
 	userInputProvider UserInputProvider
 	securityChecker   *SecurityChecker
 	sanitizer         *sanitizer.DataSanitizer
 	router            *AgentRouter
+	specs             []*AgentSpec // Описания декларативных агентов, загруженные из Config.AgentsDir
 	cfg               Config
 	memory            *AgentMemory
-	circuitBreakers   *CircuitBreakerPool
+	circuitBreakers   *CircuitBreakerPool   // Предохранители сайтов и LLM, общие для всех задач
+	targetBreakers    *CircuitBreakerPool   // Предохранители целей действий текущей задачи
 	reasoningHistory  *llm.ReasoningHistory // История рассуждений для текущей задачи (ReAct pattern)
+	transcript        *llm.ActionTranscript // История действий и их результатов для текущей задачи
+	loopDetector      *LoopDetector         // Детектор зацикливания для текущей задачи
+	criteria          []string              // Критерии успеха текущей задачи (multi-step режим)
+	results           *resultCollector      // Структурированный результат текущей задачи (nil если схема не задана)
+	dryRun            bool                  // Пробный запуск: действия, меняющие страницу, только симулируются
+	spec              *AgentSpec            // Профиль декларативного агента, выполняющего текущую задачу (nil - без ограничений)
+	stepOffset        int                   // Номер последнего шага предыдущих попыток цепочки fallback
+	snapshot          *browser.PageSnapshot // Снимок страницы, по которому построен последний контекст (nil - контекст из HTML)
+	attempts          []RetryAttempt        // Попытки действия и обращений к LLM текущего шага (для записи шага)
+	replay            *pathReplay           // Воспроизводимый путь из памяти (nil - план построен моделью)
 }
 
 // Config содержит конфигурацию для агента.
 type Config struct {
-	MaxSteps          int               // Максимальное количество шагов для выполнения задачи
-	MaxTokens         int               // Максимальное количество токенов для LLM запросов
-	Retries           int               // Количество попыток при ошибках
-	RetryDelay        time.Duration     // Задержка между попытками
-	UserInputProvider UserInputProvider // Провайдер для взаимодействия с пользователем
-	UseSubAgents      bool              // Использовать специализированных подагентов
-	ConfidenceMin     float64           // Минимальный уровень уверенности для действий
-	UseMultiStep      bool              // Использовать многошаговое планирование
-	MultiStepSize     int               // Размер пакета шагов для многошагового планирования
-	UseMemory         bool              // Использовать память агента для контекста
+	MaxSteps          int                    // Максимальное количество шагов для выполнения задачи
+	MaxTokens         int                    // Максимальное количество токенов для LLM запросов
+	Retries           int                    // Количество попыток при ошибках для областей без своей политики
+	RetryDelay        time.Duration          // Задержка перед первым повтором для областей без своей политики
+	RetryPolicies     map[string]RetryPolicy // Политики повторов по действиям и "llm" (недостающие - из DefaultRetryPolicies)
+	UserInputProvider UserInputProvider      // Провайдер для взаимодействия с пользователем
+	UseSubAgents      bool                   // Использовать специализированных подагентов
+	AgentsDir         string                 // Каталог YAML/JSON описаний декларативных агентов (пусто - не загружать)
+	ConfidenceMin     float64                // Минимальный уровень уверенности для действий
+	LLMRouting        bool                   // Выбирать специализированного агента с помощью LLM (ключевые слова - фильтр и запасной вариант)
+	UseMultiStep      bool                   // Использовать многошаговое планирование
+	MultiStepSize     int                    // Размер пакета шагов для многошагового планирования
+	UseSubgoals       bool                   // Разбивать задачу на дерево подзадач с отдельными бюджетами шагов
+	UseMemory         bool                   // Использовать память агента для контекста
+	MemoryTTL         time.Duration          // Срок хранения записи памяти с последнего использования (0 - 30 дней)
+	Embedder          Embedder               // Эмбеддер для семантического поиска по памяти (nil - локальный HashEmbedder)
+	TranscriptWindow  int                    // Количество последних шагов, передаваемых в LLM полностью
+	Budget            BudgetLimits           // Глобальные лимиты расхода LLM (0 - без лимита)
+	NewBrowser        func() browser.Browser // Фабрика сессий браузера: каждая задача получает свою (nil - общий браузер)
 }
 
 // ElementPriority определяет приоритет элемента на странице.
@@ -11,7 +11,7 @@ import (
 
 func (b *PlaywrightBrowser) WaitForNavigation(ctx context.Context, options ...WaitNavigationOption) error {
 	if b.page == nil {
-		return fmt.Errorf("браузер не запущен")
+		return ErrNotStarted
 	}
 
 	opts := WaitNavigationOptions{
@@ -28,7 +28,7 @@ func (b *PlaywrightBrowser) WaitForNavigation(ctx context.Context, options ...Wa
 
 func (b *PlaywrightBrowser) WaitForRequest(ctx context.Context, urlPattern string, timeout time.Duration) error {
 	if b.page == nil {
-		return fmt.Errorf("браузер не запущен")
+		return ErrNotStarted
 	}
 
 	if timeout == 0 {
@@ -56,7 +56,7 @@ func (b *PlaywrightBrowser) WaitForRequest(ctx context.Context, urlPattern strin
 
 	select {
 	case <-ctx.Done():
-		return fmt.Errorf("таймаут ожидания запроса с паттерном %s", urlPattern)
+		return fmt.Errorf("%w ожидания запроса с паттерном %s", ErrTimeout, urlPattern)
 	case err := <-ch:
 		return err
 	}
@@ -64,7 +64,7 @@ func (b *PlaywrightBrowser) WaitForRequest(ctx context.Context, urlPattern strin
 
 func (b *PlaywrightBrowser) WaitForResponse(ctx context.Context, urlPattern string, timeout time.Duration) error {
 	if b.page == nil {
-		return fmt.Errorf("браузер не запущен")
+		return ErrNotStarted
 	}
 
 	if timeout == 0 {
@@ -96,7 +96,7 @@ func (b *PlaywrightBrowser) WaitForResponse(ctx context.Context, urlPattern stri
 
 	select {
 	case <-ctx.Done():
-		return fmt.Errorf("таймаут ожидания ответа с паттерном %s", urlPattern)
+		return fmt.Errorf("%w ожидания ответа с паттерном %s", ErrTimeout, urlPattern)
 	case err := <-ch:
 		return err
 	}
@@ -104,7 +104,7 @@ func (b *PlaywrightBrowser) WaitForResponse(ctx context.Context, urlPattern stri
 
 func (b *PlaywrightBrowser) WaitForNetworkIdle(ctx context.Context, timeout time.Duration) error {
 	if b.page == nil {
-		return fmt.Errorf("браузер не запущен")
+		return ErrNotStarted
 	}
 
 	if timeout == 0 {
@@ -2,13 +2,20 @@ package browser
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/playwright-community/playwright-go"
 )
 
+// New создает браузер с собственным движком Playwright.
+// Для параллельных задач используйте NewEngine и Engine.NewSession.
 func New(cfg Config) *PlaywrightBrowser {
+	return NewEngine(cfg).NewSession()
+}
+
+func withDefaultTimeouts(cfg Config) Config {
 	// Установка дефолтных таймаутов
 	if cfg.Timeout == 0 {
 		cfg.Timeout = 30 * time.Second
@@ -19,10 +26,7 @@ func New(cfg Config) *PlaywrightBrowser {
 	if cfg.ActionTimeout == 0 {
 		cfg.ActionTimeout = 10 * time.Second // Click/Type обычно быстрые
 	}
-
-	return &PlaywrightBrowser{
-		cfg: cfg,
-	}
+	return cfg
 }
 
 func (b *PlaywrightBrowser) SetPopupDetector(detector PopupDetector) {
@@ -36,110 +40,42 @@ func (b *PlaywrightBrowser) getPage() playwright.Page {
 	return b.page
 }
 
-// setPage безопасно устанавливает страницу с write lock
-func (b *PlaywrightBrowser) setPage(page playwright.Page) {
-	b.mu.Lock()
-	defer b.mu.Unlock()
-	b.page = page
-}
-
-func (b *PlaywrightBrowser) getBrowserArgs() []string {
+func browserArgs() []string {
 	return []string{
 		"--no-sandbox",
 	}
 }
 
-func (b *PlaywrightBrowser) getEnvMap() map[string]string {
-	if b.cfg.Display != "" {
+func envMap(cfg Config) map[string]string {
+	if cfg.Display != "" {
 		return map[string]string{
-			"DISPLAY": b.cfg.Display,
+			"DISPLAY": cfg.Display,
 		}
 	}
 	return nil
 }
 
-func (b *PlaywrightBrowser) launchPersistent(pw *playwright.Playwright) error {
-	opts := playwright.BrowserTypeLaunchPersistentContextOptions{
-		Headless: playwright.Bool(b.cfg.Headless),
-		Args:     b.getBrowserArgs(),
-	}
-
-	if env := b.getEnvMap(); env != nil {
-		opts.Env = env
-	}
-
-	browserContext, err := pw.Firefox.LaunchPersistentContext(b.cfg.UserDataDir, opts)
+// Launch открывает сессию: запускает общий движок при необходимости
+// и создает для сессии собственный контекст браузера и страницу.
+func (b *PlaywrightBrowser) Launch(ctx context.Context) error {
+	browserContext, page, err := b.engine.openContext()
 	if err != nil {
 		return err
 	}
 
 	b.mu.Lock()
 	b.context = browserContext
+	b.page = page
 	b.mu.Unlock()
 
-	pages := browserContext.Pages()
-	var page playwright.Page
-	if len(pages) == 0 {
-		page, err = browserContext.NewPage()
-		if err != nil {
-			return err
-		}
-	} else {
-		page = pages[0]
-	}
-
-	b.setPage(page)
-	page.SetDefaultTimeout(float64(b.cfg.Timeout.Milliseconds()))
-	return nil
-}
-
-func (b *PlaywrightBrowser) launchStandard(pw *playwright.Playwright) error {
-	opts := playwright.BrowserTypeLaunchOptions{
-		Headless: playwright.Bool(b.cfg.Headless),
-		Args:     b.getBrowserArgs(),
-	}
-
-	if env := b.getEnvMap(); env != nil {
-		opts.Env = env
-	}
-
-	browser, err := pw.Firefox.Launch(opts)
-	if err != nil {
-		return err
-	}
-
-	b.mu.Lock()
-	b.browser = browser
-	b.mu.Unlock()
-
-	page, err := browser.NewPage()
-	if err != nil {
-		return err
-	}
-
-	b.setPage(page)
 	page.SetDefaultTimeout(float64(b.cfg.Timeout.Milliseconds()))
 	return nil
 }
 
-func (b *PlaywrightBrowser) Launch(ctx context.Context) error {
-	pw, err := playwright.Run()
-	if err != nil {
-		return err
-	}
-	b.pw = pw
-
-	if b.cfg.UserDataDir != "" {
-		return b.launchPersistent(pw)
-	}
-
-	return b.launchStandard(pw)
-}
-
 func (b *PlaywrightBrowser) Navigate(ctx context.Context, url string) error {
 	page := b.getPage()
 	if page == nil {
-		return fmt.Errorf("браузер не запущен")
+		return ErrNotStarted
 	}
 
 	// Создаем context с timeout для navigate операции
@@ -159,10 +95,16 @@ func (b *PlaywrightBrowser) Navigate(ctx context.Context, url string) error {
 	// Ждем результат или timeout
 	select {
 	case <-navCtx.Done():
-		return fmt.Errorf("navigate timeout after %v", b.cfg.NavigateTimeout)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("%w: %v", ErrNavigationTimeout, b.cfg.NavigateTimeout)
 	case err := <-errChan:
+		if errors.Is(err, playwright.ErrTimeout) {
+			return fmt.Errorf("%w: %w", ErrNavigationTimeout, err)
+		}
 		if err != nil {
-			return err
+			return wrapError(err)
 		}
 	}
 
@@ -176,12 +118,12 @@ func (b *PlaywrightBrowser) Navigate(ctx context.Context, url string) error {
 func (b *PlaywrightBrowser) Click(ctx context.Context, selector string) error {
 	page := b.getPage()
 	if page == nil {
-		return fmt.Errorf("браузер не запущен")
+		return ErrNotStarted
 	}
 
 	// Валидируем селектор (проверяем, что это не URL)
 	if err := ValidateSelector(selector); err != nil {
-		return fmt.Errorf("невалидный селектор: %w", err)
+		return fmt.Errorf("%w: %w", ErrInvalidSelector, err)
 	}
 
 	// Нормализуем селектор (преобразуем :contains() в :has-text())
@@ -192,7 +134,7 @@ func (b *PlaywrightBrowser) Click(ctx context.Context, selector string) error {
 	}
 
 	if err := b.WaitForSelector(ctx, selector); err != nil {
-		return fmt.Errorf("элемент не найден: %w", err)
+		return err
 	}
 
 	if err := b.ClosePopups(ctx); err != nil {
@@ -207,7 +149,7 @@ func (b *PlaywrightBrowser) Click(ctx context.Context, selector string) error {
 
 	err := page.Click(selector)
 	if err != nil {
-		return err
+		return wrapError(err)
 	}
 
 	if err := b.WaitForNetworkIdle(ctx, 2*time.Second); err != nil {
@@ -220,12 +162,12 @@ func (b *PlaywrightBrowser) Click(ctx context.Context, selector string) error {
 func (b *PlaywrightBrowser) Type(ctx context.Context, selector, text string) error {
 	page := b.getPage()
 	if page == nil {
-		return fmt.Errorf("браузер не запущен")
+		return ErrNotStarted
 	}
 
 	// Валидируем селектор (проверяем, что это не URL)
 	if err := ValidateSelector(selector); err != nil {
-		return fmt.Errorf("невалидный селектор: %w", err)
+		return fmt.Errorf("%w: %w", ErrInvalidSelector, err)
 	}
 
 	// Нормализуем селектор (преобразуем :contains() в :has-text())
@@ -236,7 +178,7 @@ func (b *PlaywrightBrowser) Type(ctx context.Context, selector, text string) err
 	}
 
 	if err := b.WaitForSelector(ctx, selector); err != nil {
-		return fmt.Errorf("элемент не найден: %w", err)
+		return err
 	}
 
 	if err := b.ClosePopups(ctx); err != nil {
@@ -249,13 +191,13 @@ func (b *PlaywrightBrowser) Type(ctx context.Context, selector, text string) err
 	// 	return fmt.Errorf("ошибка прокрутки к элементу: %w", err)
 	// }
 
-	return page.Fill(selector, text)
+	return wrapError(page.Fill(selector, text))
 }
 
 func (b *PlaywrightBrowser) GetPageContext(ctx context.Context) (string, error) {
 	page := b.getPage()
 	if page == nil {
-		return "", fmt.Errorf("браузер не запущен")
+		return "", ErrNotStarted
 	}
 
 	if err := b.WaitForLoadState(ctx, "networkidle"); err != nil {
@@ -269,22 +211,61 @@ func (b *PlaywrightBrowser) GetPageContext(ctx context.Context) (string, error)
 	return content, nil
 }
 
+// GetPageInfo возвращает URL и заголовок текущей страницы без построения полного snapshot.
+func (b *PlaywrightBrowser) GetPageInfo(ctx context.Context) (string, string, error) {
+	page := b.getPage()
+	if page == nil {
+		return "", "", ErrNotStarted
+	}
+
+	title, err := page.Title()
+	if err != nil {
+		return page.URL(), "", err
+	}
+	return page.URL(), title, nil
+}
+
+// BringToFront выводит вкладку сессии на передний план, чтобы пользователь мог работать в ней вручную.
+// В headless режиме окна нет, поэтому возвращается ошибка.
+func (b *PlaywrightBrowser) BringToFront(ctx context.Context) error {
+	page := b.getPage()
+	if page == nil {
+		return ErrNotStarted
+	}
+	if b.cfg.Headless {
+		return fmt.Errorf("браузер запущен в headless режиме (PW_HEADLESS), окно недоступно")
+	}
+	if err := page.BringToFront(); err != nil {
+		return fmt.Errorf("ошибка вывода вкладки на передний план: %w", err)
+	}
+	return nil
+}
+
+// ProfilePath возвращает файл, в котором persistent режим хранит логины ("" без UserDataDir).
+func (b *PlaywrightBrowser) ProfilePath() string {
+	return b.engine.ProfilePath()
+}
+
+// Close закрывает контекст сессии, предварительно сохранив ее cookies и localStorage в профиль (в persistent режиме).
+// Движок останавливается после закрытия последней сессии.
 func (b *PlaywrightBrowser) Close() error {
 	b.mu.Lock()
-	defer b.mu.Unlock()
+	browserContext, page := b.context, b.page
+	b.context = nil
+	b.page = nil
+	b.mu.Unlock()
 
-	if b.context != nil {
-		if err := b.context.Close(); err != nil {
-			return err
-		}
+	if page == nil {
+		return nil
 	}
-	if b.browser != nil {
-		if err := b.browser.Close(); err != nil {
-			return err
-		}
+
+	closeErr := b.engine.saveToProfile(browserContext)
+	if err := browserContext.Close(); err != nil && closeErr == nil {
+		closeErr = err
 	}
-	if b.pw != nil {
-		return b.pw.Stop()
+
+	if err := b.engine.release(); err != nil && closeErr == nil {
+		closeErr = err
 	}
-	return nil
+	return closeErr
 }
@@ -0,0 +1,277 @@
+package browser
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/playwright-community/playwright-go"
+)
+
+// Engine владеет процессом Playwright и экземпляром Firefox, общими для всех сессий.
+// Каждая сессия (PlaywrightBrowser) получает собственный BrowserContext и страницу,
+// поэтому параллельные задачи не делят cookies, localStorage и текущую страницу.
+//
+// В persistent режиме (UserDataDir) логины хранятся в файле storage state в UserDataDir
+// (cookies и localStorage по origin): контекст новой сессии открывается с сохраненным состоянием,
+// а при закрытии сессии ее состояние сливается с файлом (см. mergeStorageState).
+// Второй браузер для профиля не запускается.
+//
+// Engine запускается при первом Launch любой сессии и останавливается после закрытия последней.
+type Engine struct {
+	cfg      Config
+	mu       sync.Mutex
+	pw       *playwright.Playwright
+	browser  playwright.Browser
+	profile  *playwright.StorageState                               // Сохраненное состояние профиля (nil - еще не прочитано)
+	opened   map[playwright.BrowserContext]*playwright.StorageState // Состояние профиля, с которым открыт контекст сессии
+	sessions int
+}
+
+// profileFile - файл storage state профиля в UserDataDir.
+const profileFile = "storage_state.json"
+
+// NewEngine создает общий движок браузера для нескольких сессий.
+func NewEngine(cfg Config) *Engine {
+	return &Engine{cfg: withDefaultTimeouts(cfg)}
+}
+
+// NewSession создает новую изолированную сессию браузера поверх общего движка.
+func (e *Engine) NewSession() *PlaywrightBrowser {
+	return &PlaywrightBrowser{
+		cfg:    e.cfg,
+		engine: e,
+	}
+}
+
+// Persistent возвращает true если сессии получают логины из профиля браузера.
+func (e *Engine) Persistent() bool {
+	return e.cfg.UserDataDir != ""
+}
+
+// ProfilePath возвращает файл, в котором persistent режим хранит логины ("" без UserDataDir).
+func (e *Engine) ProfilePath() string {
+	if !e.Persistent() {
+		return ""
+	}
+	return filepath.Join(e.cfg.UserDataDir, profileFile)
+}
+
+// openContext запускает движок при необходимости и создает для новой сессии собственный контекст и страницу.
+func (e *Engine) openContext() (browserContext playwright.BrowserContext, page playwright.Page, err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if err := e.startLocked(); err != nil {
+		return nil, nil, err
+	}
+
+	var opts playwright.BrowserNewContextOptions
+	if e.Persistent() {
+		if e.profile == nil {
+			profile, err := readStorageState(e.ProfilePath())
+			if err != nil {
+				e.stopIfIdleLocked()
+				return nil, nil, fmt.Errorf("ошибка чтения профиля браузера: %w", err)
+			}
+			e.profile = profile
+		}
+		opts.StorageState = e.profile.ToOptionalStorageState()
+	}
+
+	browserContext, err = e.browser.NewContext(opts)
+	if err != nil {
+		e.stopIfIdleLocked()
+		return nil, nil, fmt.Errorf("ошибка создания контекста браузера: %w", err)
+	}
+	page, err = browserContext.NewPage()
+	if err != nil {
+		browserContext.Close()
+		e.stopIfIdleLocked()
+		return nil, nil, fmt.Errorf("ошибка создания страницы: %w", err)
+	}
+	if e.Persistent() {
+		e.opened[browserContext] = e.profile
+	}
+	e.sessions++
+	return browserContext, page, nil
+}
+
+// saveToProfile сохраняет в профиль storage state закрываемой сессии (cookies и localStorage),
+// чтобы логины пережили перезапуск. Изменения сливаются с тем, что успели сохранить другие сессии.
+func (e *Engine) saveToProfile(browserContext playwright.BrowserContext) error {
+	e.mu.Lock()
+	opened, ok := e.opened[browserContext]
+	delete(e.opened, browserContext)
+	e.mu.Unlock()
+	if !ok {
+		return nil
+	}
+
+	state, err := browserContext.StorageState()
+	if err != nil {
+		return fmt.Errorf("ошибка чтения storage state сессии: %w", err)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	merged := mergeStorageState(e.profile, opened, state)
+	if err := writeStorageState(e.ProfilePath(), merged); err != nil {
+		return fmt.Errorf("ошибка сохранения профиля браузера: %w", err)
+	}
+	e.profile = merged
+	return nil
+}
+
+// readStorageState читает storage state из файла. Отсутствующий файл - пустое состояние.
+func readStorageState(path string) (*playwright.StorageState, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &playwright.StorageState{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var state playwright.StorageState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &state, nil
+}
+
+// writeStorageState записывает storage state через временный файл: прерванная запись
+// не портит сохраненные логины. Файл содержит сессионные cookies и доступен только владельцу.
+func writeStorageState(path string, state *playwright.StorageState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// mergeStorageState сливает состояние закрываемой сессии с профилем. opened - профиль на момент
+// открытия сессии: cookies и origins, которые сессия изменила или добавила, переносятся в профиль,
+// удаленные сессией (выход из аккаунта) удаляются, а сохраненные другими сессиями после ее открытия
+// остаются.
+func mergeStorageState(profile, opened, session *playwright.StorageState) *playwright.StorageState {
+	cookieKey := func(c playwright.Cookie) string {
+		return c.Name + "\x00" + c.Domain + "\x00" + c.Path
+	}
+	originKey := func(o playwright.Origin) string {
+		return o.Origin
+	}
+	return &playwright.StorageState{
+		Cookies: mergeByKey(profile.Cookies, opened.Cookies, session.Cookies, cookieKey),
+		Origins: mergeByKey(profile.Origins, opened.Origins, session.Origins, originKey),
+	}
+}
+
+// mergeByKey - слияние для mergeStorageState: элементы профиля в прежнем порядке с заменой
+// на элементы сессии, затем новые элементы сессии.
+func mergeByKey[T any](profile, opened, session []T, key func(T) string) []T {
+	inSession := make(map[string]T, len(session))
+	for _, item := range session {
+		inSession[key(item)] = item
+	}
+	removed := make(map[string]bool)
+	for _, item := range opened {
+		if _, ok := inSession[key(item)]; !ok {
+			removed[key(item)] = true
+		}
+	}
+
+	merged := make([]T, 0, len(profile)+len(session))
+	seen := make(map[string]bool, len(profile))
+	for _, item := range profile {
+		k := key(item)
+		if removed[k] {
+			continue
+		}
+		if updated, ok := inSession[k]; ok {
+			item = updated
+		}
+		merged = append(merged, item)
+		seen[k] = true
+	}
+	for _, item := range session {
+		if !seen[key(item)] {
+			merged = append(merged, item)
+		}
+	}
+	return merged
+}
+
+// release уменьшает счетчик сессий и останавливает движок после закрытия последней.
+func (e *Engine) release() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.sessions > 0 {
+		e.sessions--
+	}
+	return e.stopIfIdleLocked()
+}
+
+func (e *Engine) startLocked() error {
+	if e.pw != nil {
+		return nil
+	}
+
+	pw, err := playwright.Run()
+	if err != nil {
+		return err
+	}
+
+	opts := playwright.BrowserTypeLaunchOptions{
+		Headless: playwright.Bool(e.cfg.Headless),
+		Args:     browserArgs(),
+	}
+	if env := envMap(e.cfg); env != nil {
+		opts.Env = env
+	}
+
+	browser, err := pw.Firefox.Launch(opts)
+	if err != nil {
+		pw.Stop()
+		return err
+	}
+
+	e.pw = pw
+	e.browser = browser
+	e.opened = make(map[playwright.BrowserContext]*playwright.StorageState)
+	return nil
+}
+
+func (e *Engine) stopIfIdleLocked() error {
+	if e.sessions > 0 || e.pw == nil {
+		return nil
+	}
+
+	// Профиль перечитывается из файла при следующем запуске
+	e.profile = nil
+
+	var firstErr error
+	if e.browser != nil {
+		if err := e.browser.Close(); err != nil {
+			firstErr = err
+		}
+		e.browser = nil
+	}
+	if err := e.pw.Stop(); err != nil && firstErr == nil {
+		firstErr = err
+	}
+	e.pw = nil
+	return firstErr
+}
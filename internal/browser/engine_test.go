@@ -0,0 +1,91 @@
+package browser
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/playwright-community/playwright-go"
+)
+
+func TestMergeStorageState(t *testing.T) {
+	cookie := func(name, value string) playwright.Cookie {
+		return playwright.Cookie{Name: name, Value: value, Domain: "a.ru", Path: "/"}
+	}
+	origin := func(name, token string) playwright.Origin {
+		return playwright.Origin{Origin: name, LocalStorage: []playwright.NameValue{{Name: "token", Value: token}}}
+	}
+	state := func(cookies []playwright.Cookie, origins ...playwright.Origin) *playwright.StorageState {
+		return &playwright.StorageState{Cookies: cookies, Origins: origins}
+	}
+
+	tests := []struct {
+		name                     string
+		profile, opened, session *playwright.StorageState
+		want                     *playwright.StorageState
+	}{
+		{
+			name:    "новый логин",
+			profile: state(nil), opened: state(nil),
+			session: state([]playwright.Cookie{cookie("sid", "1")}, origin("https://a.ru", "t1")),
+			want:    state([]playwright.Cookie{cookie("sid", "1")}, origin("https://a.ru", "t1")),
+		},
+		{
+			name:    "обновленное значение",
+			profile: state([]playwright.Cookie{cookie("sid", "1")}, origin("https://a.ru", "t1")),
+			opened:  state([]playwright.Cookie{cookie("sid", "1")}, origin("https://a.ru", "t1")),
+			session: state([]playwright.Cookie{cookie("sid", "2")}, origin("https://a.ru", "t2")),
+			want:    state([]playwright.Cookie{cookie("sid", "2")}, origin("https://a.ru", "t2")),
+		},
+		{
+			name:    "выход из аккаунта удаляет",
+			profile: state([]playwright.Cookie{cookie("sid", "1")}, origin("https://a.ru", "t1")),
+			opened:  state([]playwright.Cookie{cookie("sid", "1")}, origin("https://a.ru", "t1")),
+			session: state(nil),
+			want:    state(nil),
+		},
+		{
+			name:    "логин другой сессии сохраняется",
+			profile: state([]playwright.Cookie{cookie("sid", "1"), cookie("other", "x")}, origin("https://b.ru", "b")),
+			opened:  state([]playwright.Cookie{cookie("sid", "1")}),
+			session: state([]playwright.Cookie{cookie("sid", "1")}),
+			want:    state([]playwright.Cookie{cookie("sid", "1"), cookie("other", "x")}, origin("https://b.ru", "b")),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := mergeStorageState(tt.profile, tt.opened, tt.session)
+			if !sameItems(got.Cookies, tt.want.Cookies) || !sameItems(got.Origins, tt.want.Origins) {
+				t.Errorf("mergeStorageState() = %+v, ожидалось %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// sameItems сравнивает срезы, не различая nil и пустой.
+func sameItems[T any](a, b []T) bool {
+	return len(a) == 0 && len(b) == 0 || reflect.DeepEqual(a, b)
+}
+
+func TestStorageStateFile(t *testing.T) {
+	path := t.TempDir() + "/profile/" + profileFile
+
+	empty, err := readStorageState(path)
+	if err != nil || len(empty.Cookies) != 0 {
+		t.Fatalf("readStorageState() без файла = %+v, %v; ожидалось пустое состояние", empty, err)
+	}
+
+	saved := &playwright.StorageState{
+		Cookies: []playwright.Cookie{{Name: "sid", Value: "1", Domain: "a.ru", Path: "/"}},
+		Origins: []playwright.Origin{{Origin: "https://a.ru", LocalStorage: []playwright.NameValue{{Name: "token", Value: "t"}}}},
+	}
+	if err := writeStorageState(path, saved); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := readStorageState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, saved) {
+		t.Errorf("readStorageState() = %+v, ожидалось %+v", loaded, saved)
+	}
+}
@@ -0,0 +1,62 @@
+package browser
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/playwright-community/playwright-go"
+)
+
+// Ошибки браузера. Методы Browser оборачивают в них ошибки Playwright (fmt.Errorf с %w),
+// поэтому вызывающий код различает их через errors.Is, а исходное сообщение сохраняется.
+var (
+	ErrElementNotFound   = errors.New("элемент не найден")
+	ErrElementNotVisible = errors.New("элемент не видим")
+	ErrElementDetached   = errors.New("элемент удален со страницы")
+	ErrInvalidSelector   = errors.New("невалидный селектор")
+	ErrNavigationTimeout = errors.New("таймаут навигации")
+	ErrTimeout           = errors.New("таймаут")
+	ErrBlockedByPolicy   = errors.New("заблокировано политикой браузера или сайта")
+	ErrBrowserCrashed    = errors.New("браузер упал или страница закрыта")
+	ErrNotStarted        = errors.New("браузер не запущен")
+)
+
+// wrapError переводит ошибку Playwright в ошибку браузера. Причину Playwright сообщает
+// только текстом, поэтому сообщения разбираются здесь, в одном месте.
+func wrapError(err error) error {
+	if err == nil || isBrowserError(err) {
+		return err
+	}
+
+	msg := err.Error()
+	switch {
+	case errors.Is(err, playwright.ErrTargetClosed) ||
+		strings.Contains(msg, "Target crashed") ||
+		strings.Contains(msg, "has been closed") ||
+		strings.Contains(msg, "has disconnected"):
+		return fmt.Errorf("%w: %w", ErrBrowserCrashed, err)
+	case strings.Contains(msg, "not attached to the DOM") || strings.Contains(msg, "detached"):
+		return fmt.Errorf("%w: %w", ErrElementDetached, err)
+	case strings.Contains(msg, "not visible"):
+		return fmt.Errorf("%w: %w", ErrElementNotVisible, err)
+	case strings.Contains(msg, "ERR_BLOCKED_BY"):
+		return fmt.Errorf("%w: %w", ErrBlockedByPolicy, err)
+	case errors.Is(err, playwright.ErrTimeout):
+		return fmt.Errorf("%w: %w", ErrTimeout, err)
+	}
+	return err
+}
+
+// isBrowserError сообщает, что ошибка уже переведена в ошибку браузера.
+func isBrowserError(err error) bool {
+	for _, target := range []error{
+		ErrElementNotFound, ErrElementNotVisible, ErrElementDetached, ErrInvalidSelector,
+		ErrNavigationTimeout, ErrTimeout, ErrBlockedByPolicy, ErrBrowserCrashed, ErrNotStarted,
+	} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
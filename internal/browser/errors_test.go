@@ -0,0 +1,55 @@
+package browser
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/playwright-community/playwright-go"
+)
+
+func TestWrapError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want error // nil - ошибка возвращается как есть
+	}{
+		{"закрытая страница", fmt.Errorf("%w: page.click", playwright.ErrTargetClosed), ErrBrowserCrashed},
+		{"упавшая вкладка", errors.New("Target crashed"), ErrBrowserCrashed},
+		{"закрытый браузер", errors.New("Browser has been closed"), ErrBrowserCrashed},
+		{"отключение", errors.New("Browser has disconnected"), ErrBrowserCrashed},
+		{"элемент удален", errors.New("Element is not attached to the DOM"), ErrElementDetached},
+		{"элемент не видим", errors.New("element is not visible"), ErrElementNotVisible},
+		{"блокировка", errors.New("net::ERR_BLOCKED_BY_CLIENT"), ErrBlockedByPolicy},
+		{"таймаут", fmt.Errorf("%w: Timeout 30000ms exceeded", playwright.ErrTimeout), ErrTimeout},
+		{"прочая ошибка", errors.New("strict mode violation"), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := wrapError(tt.err)
+			if !errors.Is(got, tt.err) {
+				t.Errorf("wrapError(%v) = %v, исходная ошибка потеряна", tt.err, got)
+			}
+			if tt.want == nil {
+				if got != tt.err {
+					t.Errorf("wrapError(%v) = %v, ожидалась исходная ошибка", tt.err, got)
+				}
+				return
+			}
+			if !errors.Is(got, tt.want) {
+				t.Errorf("wrapError(%v) = %v, ожидалось %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWrapErrorKeepsBrowserErrors(t *testing.T) {
+	if wrapError(nil) != nil {
+		t.Error("wrapError(nil) != nil")
+	}
+	// Уже переведенная ошибка не оборачивается повторно, даже если ее текст подходит под другой вид
+	err := fmt.Errorf("%w: element is not visible", ErrElementNotFound)
+	if got := wrapError(err); got != err {
+		t.Errorf("wrapError(%v) = %v, ожидалась та же ошибка", err, got)
+	}
+}
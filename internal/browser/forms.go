@@ -18,7 +18,7 @@ type FormField struct {
 
 func (b *PlaywrightBrowser) FindFormFields(ctx context.Context, formSelector string) ([]FormField, error) {
 	if b.page == nil {
-		return nil, fmt.Errorf("браузер не запущен")
+		return nil, ErrNotStarted
 	}
 
 	var form playwright.ElementHandle
@@ -175,7 +175,7 @@ func (b *PlaywrightBrowser) buildSelector(id, name, inputType, tagName string) s
 
 func (b *PlaywrightBrowser) FillFormField(ctx context.Context, selector, value string) error {
 	if b.page == nil {
-		return fmt.Errorf("браузер не запущен")
+		return ErrNotStarted
 	}
 
 	if err := b.WaitForSelector(ctx, selector); err != nil {
@@ -203,7 +203,7 @@ func (b *PlaywrightBrowser) FillFormField(ctx context.Context, selector, value s
 
 func (b *PlaywrightBrowser) SubmitForm(ctx context.Context, formSelector string) error {
 	if b.page == nil {
-		return fmt.Errorf("браузер не запущен")
+		return ErrNotStarted
 	}
 
 	selector := formSelector
@@ -228,7 +228,7 @@ func (b *PlaywrightBrowser) SubmitForm(ctx context.Context, formSelector string)
 
 func (b *PlaywrightBrowser) ValidateForm(ctx context.Context, formSelector string) (bool, []string, error) {
 	if b.page == nil {
-		return false, nil, fmt.Errorf("браузер не запущен")
+		return false, nil, ErrNotStarted
 	}
 
 	var form playwright.ElementHandle
@@ -0,0 +1,89 @@
+package browser
+
+import (
+	"context"
+	"fmt"
+)
+
+// SelectorMatch - совпадение селектора с живой страницей.
+type SelectorMatch struct {
+	Count   int // Сколько элементов нашел селектор
+	Visible int // Сколько из них видимы
+
+	// Признаки элемента, если селектор нашел ровно один (как в ElementInfo снимка)
+	Tag   string
+	Text  string
+	Label string
+	ID    string
+	Name  string
+	Path  string
+}
+
+// Unique сообщает, что селектор указывает ровно на один элемент и он видим.
+func (m SelectorMatch) Unique() bool {
+	return m.Count == 1 && m.Visible == 1
+}
+
+// elementIdentityScript возвращает признаки элемента так же, как их собирает снимок страницы.
+const elementIdentityScript = `el => {
+	const parts = [];
+	for (let node = el; node && node.nodeType === 1; node = node.parentElement) {
+		const tag = node.tagName.toLowerCase();
+		const parent = node.parentElement;
+		if (!parent) {
+			parts.unshift(tag);
+			break;
+		}
+		const index = Array.from(parent.children).filter(child => child.tagName === node.tagName).indexOf(node) + 1;
+		parts.unshift(tag + ':nth-of-type(' + index + ')');
+	}
+	return {
+		tag: el.tagName.toLowerCase(),
+		text: (el.textContent || '').trim().substring(0, 200),
+		label: el.getAttribute('aria-label') || el.getAttribute('title') || el.getAttribute('alt') || '',
+		id: el.id || '',
+		name: el.getAttribute('name') || '',
+		path: parts.join(' > ')
+	};
+}`
+
+// InspectSelector проверяет селектор на текущей странице, не дожидаясь появления элементов.
+func (b *PlaywrightBrowser) InspectSelector(ctx context.Context, selector string) (SelectorMatch, error) {
+	page := b.getPage()
+	if page == nil {
+		return SelectorMatch{}, ErrNotStarted
+	}
+
+	if err := ValidateSelector(selector); err != nil {
+		return SelectorMatch{}, fmt.Errorf("%w: %w", ErrInvalidSelector, err)
+	}
+	selector, _ = NormalizeSelector(selector)
+
+	elements, err := page.QuerySelectorAll(selector)
+	if err != nil {
+		return SelectorMatch{}, wrapError(err)
+	}
+
+	match := SelectorMatch{Count: len(elements)}
+	for _, element := range elements {
+		if visible, err := element.IsVisible(); err == nil && visible {
+			match.Visible++
+		}
+	}
+
+	if len(elements) == 1 {
+		identity, err := elements[0].Evaluate(elementIdentityScript)
+		if err != nil {
+			return match, wrapError(err)
+		}
+		if fields, ok := identity.(map[string]interface{}); ok {
+			match.Tag, _ = fields["tag"].(string)
+			match.Text, _ = fields["text"].(string)
+			match.Label, _ = fields["label"].(string)
+			match.ID, _ = fields["id"].(string)
+			match.Name, _ = fields["name"].(string)
+			match.Path, _ = fields["path"].(string)
+		}
+	}
+	return match, nil
+}
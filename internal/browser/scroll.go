@@ -10,12 +10,12 @@ import (
 
 func (b *PlaywrightBrowser) ScrollToElement(ctx context.Context, selector string) error {
 	if b.page == nil {
-		return fmt.Errorf("браузер не запущен")
+		return ErrNotStarted
 	}
 
 	// Валидируем селектор (проверяем, что это не URL)
 	if err := ValidateSelector(selector); err != nil {
-		return fmt.Errorf("невалидный селектор: %w", err)
+		return fmt.Errorf("%w: %w", ErrInvalidSelector, err)
 	}
 
 	// Нормализуем селектор (преобразуем :contains() в :has-text())
@@ -27,11 +27,11 @@ func (b *PlaywrightBrowser) ScrollToElement(ctx context.Context, selector string
 
 	element, err := b.page.QuerySelector(selector)
 	if err != nil {
-		return fmt.Errorf("элемент не найден: %w", err)
+		return wrapError(err)
 	}
 
 	if element == nil {
-		return fmt.Errorf("элемент с селектором %s не найден", selector)
+		return fmt.Errorf("%w: %s", ErrElementNotFound, selector)
 	}
 
 	// Проверяем, виден ли элемент (IsVisible проверяет и видимость, и наличие в DOM)
@@ -102,7 +102,7 @@ func (b *PlaywrightBrowser) isElementInViewport(element playwright.ElementHandle
 
 func (b *PlaywrightBrowser) ScrollToTop(ctx context.Context) error {
 	if b.page == nil {
-		return fmt.Errorf("браузер не запущен")
+		return ErrNotStarted
 	}
 
 	_, err := b.page.Evaluate(`() => {
@@ -122,7 +122,7 @@ func (b *PlaywrightBrowser) ScrollToTop(ctx context.Context) error {
 
 func (b *PlaywrightBrowser) ScrollToBottom(ctx context.Context) error {
 	if b.page == nil {
-		return fmt.Errorf("браузер не запущен")
+		return ErrNotStarted
 	}
 
 	_, err := b.page.Evaluate(`() => {
@@ -142,7 +142,7 @@ func (b *PlaywrightBrowser) ScrollToBottom(ctx context.Context) error {
 
 func (b *PlaywrightBrowser) ScrollByAmount(ctx context.Context, x, y int) error {
 	if b.page == nil {
-		return fmt.Errorf("браузер не запущен")
+		return ErrNotStarted
 	}
 
 	_, err := b.page.Evaluate(`(coords) => {
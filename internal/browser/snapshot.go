@@ -9,7 +9,7 @@ import (
 
 func (b *PlaywrightBrowser) GetPageSnapshot(ctx context.Context) (*PageSnapshot, error) {
 	if b.page == nil {
-		return nil, fmt.Errorf("браузер не запущен")
+		return nil, ErrNotStarted
 	}
 
 	if err := b.WaitForLoadState(ctx, "networkidle"); err != nil {
@@ -18,7 +18,7 @@ func (b *PlaywrightBrowser) GetPageSnapshot(ctx context.Context) (*PageSnapshot,
 
 	snapshot, err := extractor.ExtractPageSnapshot(ctx, b.page)
 	if err != nil {
-		return nil, fmt.Errorf("ошибка извлечения snapshot: %w", err)
+		return nil, fmt.Errorf("ошибка извлечения snapshot: %w", wrapError(err))
 	}
 
 	elements := make([]ElementInfo, len(snapshot.Elements))
@@ -38,6 +38,9 @@ func (b *PlaywrightBrowser) GetPageSnapshot(ctx context.Context) (*PageSnapshot,
 			},
 			Role:     elem.Role,
 			Label:    elem.Label,
+			ID:       elem.ID,
+			Name:     elem.Name,
+			Path:     elem.Path,
 			Priority: elem.Priority,
 		}
 	}
@@ -0,0 +1,83 @@
+package browser
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/playwright-community/playwright-go"
+)
+
+// StorageState возвращает состояние хранилища текущего контекста браузера
+// (cookies и localStorage) в формате JSON.
+func (b *PlaywrightBrowser) StorageState(ctx context.Context) (string, error) {
+	page := b.getPage()
+	if page == nil {
+		return "", ErrNotStarted
+	}
+
+	state, err := page.Context().StorageState()
+	if err != nil {
+		return "", fmt.Errorf("ошибка получения storage state: %w", err)
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return "", fmt.Errorf("ошибка сериализации storage state: %w", err)
+	}
+	return string(data), nil
+}
+
+// RestoreStorageState восстанавливает cookies и localStorage из JSON, полученного через StorageState.
+// Cookies добавляются в текущий контекст, localStorage восстанавливается init-скриптом
+// при следующей загрузке страницы соответствующего origin.
+func (b *PlaywrightBrowser) RestoreStorageState(ctx context.Context, stateJSON string) error {
+	page := b.getPage()
+	if page == nil {
+		return ErrNotStarted
+	}
+
+	if stateJSON == "" {
+		return nil
+	}
+
+	var state playwright.StorageState
+	if err := json.Unmarshal([]byte(stateJSON), &state); err != nil {
+		return fmt.Errorf("ошибка парсинга storage state: %w", err)
+	}
+
+	browserContext := page.Context()
+
+	if len(state.Cookies) > 0 {
+		cookies := make([]playwright.OptionalCookie, len(state.Cookies))
+		for i, cookie := range state.Cookies {
+			cookies[i] = cookie.ToOptionalCookie()
+		}
+		if err := browserContext.AddCookies(cookies); err != nil {
+			return fmt.Errorf("ошибка восстановления cookies: %w", err)
+		}
+	}
+
+	if len(state.Origins) > 0 {
+		originsJSON, err := json.Marshal(state.Origins)
+		if err != nil {
+			return fmt.Errorf("ошибка сериализации localStorage: %w", err)
+		}
+
+		script := fmt.Sprintf(`(() => {
+			const origins = %s;
+			for (const o of origins) {
+				if (o.origin !== window.location.origin) continue;
+				for (const item of (o.localStorage || [])) {
+					try { window.localStorage.setItem(item.name, item.value); } catch (e) {}
+				}
+			}
+		})();`, string(originsJSON))
+
+		if err := browserContext.AddInitScript(playwright.Script{Content: playwright.String(script)}); err != nil {
+			return fmt.Errorf("ошибка восстановления localStorage: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -19,9 +19,14 @@ type Browser interface {
 	Type(ctx context.Context, selector, text string) error
 	GetPageContext(ctx context.Context) (string, error)
 	GetPageSnapshot(ctx context.Context) (*PageSnapshot, error)
+	GetPageInfo(ctx context.Context) (url string, title string, err error)
 	WaitForSelector(ctx context.Context, selector string) error
 	WaitForLoadState(ctx context.Context, state string) error
 	ClosePopups(ctx context.Context) error
+	ClosedPopups() []string
+	InspectSelector(ctx context.Context, selector string) (SelectorMatch, error)
+	ScrollToElement(ctx context.Context, selector string) error
+	ScrollByAmount(ctx context.Context, x, y int) error
 	FindFormFields(ctx context.Context, formSelector string) ([]FormField, error)
 	FillFormField(ctx context.Context, selector, value string) error
 	SubmitForm(ctx context.Context, formSelector string) error
@@ -30,6 +35,10 @@ type Browser interface {
 	WaitForRequest(ctx context.Context, urlPattern string, timeout time.Duration) error
 	WaitForResponse(ctx context.Context, urlPattern string, timeout time.Duration) error
 	WaitForNetworkIdle(ctx context.Context, timeout time.Duration) error
+	StorageState(ctx context.Context) (string, error)
+	RestoreStorageState(ctx context.Context, stateJSON string) error
+	BringToFront(ctx context.Context) error
+	ProfilePath() string
 	Close() error
 }
 
@@ -54,6 +63,9 @@ type ElementInfo struct {
 	Bounds      ViewportBounds // Координаты и размеры
 	Role        string         // ARIA роль
 	Label       string         // ARIA label
+	ID          string         // Атрибут id
+	Name        string         // Атрибут name
+	Path        string         // Путь в DOM от html (сам является CSS селектором)
 	Priority    int            // Приоритет элемента
 }
 
@@ -66,15 +78,17 @@ type ViewportBounds struct {
 }
 
 // PlaywrightBrowser реализует интерфейс Browser используя Playwright.
+// Каждый экземпляр - отдельная сессия с собственным контекстом и страницей поверх общего Engine.
 // Поддерживает concurrent доступ через sync.RWMutex.
 type PlaywrightBrowser struct {
-	pw             *playwright.Playwright
-	browser        playwright.Browser
+	engine         *Engine
 	context        playwright.BrowserContext
 	page           playwright.Page
 	cfg            Config
 	popupDetector  PopupDetector
-	mu             sync.RWMutex // Защита от concurrent доступа к page, browser, context
+	mu             sync.RWMutex // Защита от concurrent доступа к page и context
+	popupMu        sync.Mutex   // Защита closedPopups
+	closedPopups   []string     // Селекторы, которыми закрыты попапы с последнего вызова ClosedPopups
 }
 
 // Config содержит конфигурацию для браузера.
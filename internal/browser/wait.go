@@ -2,7 +2,9 @@ package browser
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
 	"strings"
 	"time"
 
@@ -11,12 +13,12 @@ import (
 
 func (b *PlaywrightBrowser) WaitForSelector(ctx context.Context, selector string) error {
 	if b.page == nil {
-		return fmt.Errorf("браузер не запущен")
+		return ErrNotStarted
 	}
 
 	// Валидируем селектор (проверяем, что это не URL)
 	if err := ValidateSelector(selector); err != nil {
-		return fmt.Errorf("невалидный селектор: %w", err)
+		return fmt.Errorf("%w: %w", ErrInvalidSelector, err)
 	}
 
 	// Нормализуем селектор (преобразуем :contains() в :has-text())
@@ -32,12 +34,15 @@ func (b *PlaywrightBrowser) WaitForSelector(ctx context.Context, selector string
 	}
 
 	_, err := b.page.WaitForSelector(selector, opts)
-	return err
+	if errors.Is(err, playwright.ErrTimeout) {
+		return fmt.Errorf("%w: %w", ErrElementNotFound, err)
+	}
+	return wrapError(err)
 }
 
 func (b *PlaywrightBrowser) WaitForLoadState(ctx context.Context, state string) error {
 	if b.page == nil {
-		return fmt.Errorf("браузер не запущен")
+		return ErrNotStarted
 	}
 
 	var loadState *playwright.LoadState
@@ -57,12 +62,12 @@ func (b *PlaywrightBrowser) WaitForLoadState(ctx context.Context, state string)
 		Timeout: playwright.Float(b.cfg.Timeout.Seconds() * 1000),
 	}
 
-	return b.page.WaitForLoadState(opts)
+	return wrapError(b.page.WaitForLoadState(opts))
 }
 
 func (b *PlaywrightBrowser) ClosePopups(ctx context.Context) error {
 	if b.page == nil {
-		return fmt.Errorf("браузер не запущен")
+		return ErrNotStarted
 	}
 
 	if b.popupDetector == nil {
@@ -94,12 +99,31 @@ func (b *PlaywrightBrowser) ClosePopups(ctx context.Context) error {
 	}
 
 	if err := element.Click(); err == nil {
+		b.recordClosedPopup(popupInfo.CloseSelector)
 		time.Sleep(500 * time.Millisecond)
 	}
 
 	return nil
 }
 
+// ClosedPopups возвращает селекторы, которыми были закрыты попапы с прошлого вызова, и очищает список.
+// Агент запоминает их как способ закрыть попап на этом сайте.
+func (b *PlaywrightBrowser) ClosedPopups() []string {
+	b.popupMu.Lock()
+	defer b.popupMu.Unlock()
+	closed := b.closedPopups
+	b.closedPopups = nil
+	return closed
+}
+
+func (b *PlaywrightBrowser) recordClosedPopup(selector string) {
+	b.popupMu.Lock()
+	defer b.popupMu.Unlock()
+	if !slices.Contains(b.closedPopups, selector) {
+		b.closedPopups = append(b.closedPopups, selector)
+	}
+}
+
 func (b *PlaywrightBrowser) closePopupsLegacy(ctx context.Context) error {
 	popupSelectors := []string{
 		"[role='dialog'] button[aria-label*='close' i]",
@@ -127,6 +151,7 @@ func (b *PlaywrightBrowser) closePopupsLegacy(ctx context.Context) error {
 			}
 
 			if err := element.Click(); err == nil {
+				b.recordClosedPopup(selector)
 				time.Sleep(500 * time.Millisecond)
 			}
 		}
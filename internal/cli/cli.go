@@ -3,9 +3,12 @@ package cli
 import (
 	"bufio"
 	"context"
+	"fmt"
 	"io"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"aiAgent/internal/agent"
 	"aiAgent/internal/browser"
@@ -19,35 +22,42 @@ import (
 )
 
 type CLI struct {
-	repo           *database.TaskRepository
-	log            *logger.Zap
-	llmClient      llm.LLMClient
-	browser        browser.Browser
-	agent          *agent.Agent
-	rl             *readline.Instance
-	taskHandler    *commands.TaskHandler
-	showHandler    *commands.ShowHandler
-	logsHandler    *commands.LogsHandler
-	browserHandler *commands.BrowserHandler
-	llmHandler     *commands.LLMHandler
+	repo            *database.TaskRepository
+	log             *logger.Zap
+	llmClient       llm.LLMClient
+	browser         browser.Browser
+	agent           *agent.Agent
+	rl              *readline.Instance
+	out             io.Writer
+	userInput       *userInputProvider
+	jobs            *agent.JobManager
+	taskHandler     *commands.TaskHandler
+	jobsHandler     *commands.JobsHandler
+	scheduleHandler *commands.ScheduleHandler
+	showHandler     *commands.ShowHandler
+	usageHandler    *commands.UsageHandler
+	logsHandler     *commands.LogsHandler
+	browserHandler  *commands.BrowserHandler
+	llmHandler      *commands.LLMHandler
+	memoryHandler   *commands.MemoryHandler
+	healthHandler   *commands.HealthHandler
 }
 
-func New(repo *database.TaskRepository, log *logger.Zap, llmClient llm.LLMClient, br browser.Browser, ag *agent.Agent) *CLI {
+// jobShutdownTimeout - сколько ждать завершения фоновых задач при выходе
+const jobShutdownTimeout = 10 * time.Second
+
+func New(repo *database.TaskRepository, log *logger.Zap, llmClient llm.LLMClient, br browser.Browser, ag *agent.Agent, userInput *userInputProvider, jobs *agent.JobManager) *CLI {
 	cli := &CLI{
 		repo:      repo,
 		log:       log,
 		llmClient: llmClient,
 		browser:   br,
 		agent:     ag,
+		out:       os.Stdout,
+		userInput: userInput,
+		jobs:      jobs,
 	}
 
-	// Инициализация handlers
-	cli.taskHandler = commands.NewTaskHandler(repo, ag, log.Logger)
-	cli.showHandler = commands.NewShowHandler(repo, log.Logger)
-	cli.logsHandler = commands.NewLogsHandler(repo, log.Logger)
-	cli.browserHandler = commands.NewBrowserHandler(br, cli.readLine)
-	cli.llmHandler = commands.NewLLMHandler(llmClient)
-
 	// Инициализация readline
 	rl, err := readline.NewEx(&readline.Config{
 		Prompt:          "> ",
@@ -59,11 +69,54 @@ func New(repo *database.TaskRepository, log *logger.Zap, llmClient llm.LLMClient
 		log.Warn("Не удалось инициализировать readline, будет использован fallback режим")
 	} else {
 		cli.rl = rl
+		// Вывод фоновых задач идет через readline, чтобы не ломать строку ввода
+		cli.out = rl.Stdout()
+	}
+	if userInput != nil {
+		userInput.SetOutput(cli.out)
 	}
 
+	// Инициализация handlers
+	cli.taskHandler = commands.NewTaskHandler(repo, ag, cli.jobs, cli.out, log.Logger)
+	cli.jobsHandler = commands.NewJobsHandler(cli.jobs, repo, cli.out, cli.readLine, cli.answerAgent)
+	cli.scheduleHandler = commands.NewScheduleHandler(repo, log.Logger)
+	cli.showHandler = commands.NewShowHandler(repo, ag, log.Logger)
+	cli.usageHandler = commands.NewUsageHandler(ag)
+	cli.memoryHandler = commands.NewMemoryHandler(ag, log.Logger)
+	cli.healthHandler = commands.NewHealthHandler(ag)
+	cli.logsHandler = commands.NewLogsHandler(repo, log.Logger)
+	cli.browserHandler = commands.NewBrowserHandler(br, cli.readLine)
+	cli.llmHandler = commands.NewLLMHandler(llmClient, ag)
+
 	return cli
 }
 
+// answerAgent передает строку задаче taskID, если она ждет ответа пользователя
+func (c *CLI) answerAgent(taskID uint, line string) bool {
+	if c.userInput == nil {
+		return false
+	}
+	return c.userInput.Answer(taskID, line)
+}
+
+// answer обрабатывает "answer <id> <текст>"
+func (c *CLI) answer(args string) {
+	idStr, text, _ := strings.Cut(strings.TrimSpace(args), " ")
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		fmt.Println(ui.ColorRed + ui.IconCross + " Использование: answer <id> <текст>" + ui.ColorReset)
+		return
+	}
+	if !c.answerAgent(uint(id), strings.TrimSpace(text)) {
+		fmt.Printf(ui.ColorRed+ui.IconCross+" Задача #%d не ждет ответа"+ui.ColorReset+"\n", id)
+	}
+}
+
+// shutdownJobs отменяет фоновые задачи, чтобы их статус сохранился как прерванный
+func (c *CLI) shutdownJobs() {
+	c.jobs.Shutdown(jobShutdownTimeout)
+}
+
 func (c *CLI) readLine() (string, error) {
 	if c.rl != nil {
 		return c.rl.Readline()
@@ -87,6 +140,7 @@ func (c *CLI) closeReadline() {
 func (c *CLI) Run(ctx context.Context) {
 	ui.PrintWelcome()
 	defer c.closeReadline()
+	defer c.shutdownJobs()
 
 	for {
 		// Проверка отмены контекста
@@ -121,6 +175,7 @@ func (c *CLI) Run(ctx context.Context) {
 func (c *CLI) handleCommand(ctx context.Context, line string) {
 	switch {
 	case line == "exit":
+		c.shutdownJobs()
 		println(ui.ColorCyan + ui.IconWave + " До свидания!" + ui.ColorReset)
 		os.Exit(0)
 
@@ -138,10 +193,17 @@ func (c *CLI) handleCommand(ctx context.Context, line string) {
 		idStr := strings.TrimPrefix(line, "status ")
 		c.taskHandler.Status(idStr)
 
+	case strings.HasPrefix(line, "show ") && strings.HasSuffix(line, " --json"):
+		idStr := strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(line, "show "), " --json"))
+		c.showHandler.ShowJSON(idStr)
+
 	case strings.HasPrefix(line, "show "):
 		idStr := strings.TrimPrefix(line, "show ")
 		c.showHandler.Show(idStr)
 
+	case strings.HasPrefix(line, "export "):
+		c.showHandler.Export(strings.TrimPrefix(line, "export "))
+
 	case strings.HasPrefix(line, "logs "):
 		idStr := strings.TrimPrefix(line, "logs ")
 		c.logsHandler.Show(idStr)
@@ -150,6 +212,76 @@ func (c *CLI) handleCommand(ctx context.Context, line string) {
 		idStr := strings.TrimPrefix(line, "run ")
 		c.taskHandler.Run(ctx, idStr)
 
+	case strings.HasPrefix(line, "resume "):
+		idStr := strings.TrimPrefix(line, "resume ")
+		c.taskHandler.Resume(ctx, idStr)
+
+	case line == "usage":
+		c.usageHandler.Show()
+
+	case line == "health":
+		c.healthHandler.Show()
+
+	case line == "jobs":
+		c.jobsHandler.List()
+
+	case strings.HasPrefix(line, "cancel "):
+		idStr := strings.TrimPrefix(line, "cancel ")
+		c.jobsHandler.Cancel(idStr)
+
+	case strings.HasPrefix(line, "takeover "):
+		idStr := strings.TrimPrefix(line, "takeover ")
+		c.jobsHandler.Takeover(idStr)
+
+	case strings.HasPrefix(line, "answer "):
+		c.answer(strings.TrimPrefix(line, "answer "))
+
+	case strings.HasPrefix(line, "attach "):
+		idStr := strings.TrimPrefix(line, "attach ")
+		c.jobsHandler.Attach(idStr)
+
+	case line == "schedule list":
+		c.scheduleHandler.List()
+
+	case strings.HasPrefix(line, "schedule add "):
+		c.scheduleHandler.Add(strings.TrimPrefix(line, "schedule add "))
+
+	case strings.HasPrefix(line, "schedule remove "):
+		c.scheduleHandler.Remove(strings.TrimPrefix(line, "schedule remove "))
+
+	case strings.HasPrefix(line, "schedule enable "):
+		c.scheduleHandler.SetEnabled(strings.TrimPrefix(line, "schedule enable "), true)
+
+	case strings.HasPrefix(line, "schedule disable "):
+		c.scheduleHandler.SetEnabled(strings.TrimPrefix(line, "schedule disable "), false)
+
+	case line == "memory paths" || strings.HasPrefix(line, "memory paths "):
+		c.memoryHandler.Paths(strings.TrimPrefix(line, "memory paths"))
+
+	case strings.HasPrefix(line, "memory path "):
+		c.memoryHandler.Path(strings.TrimPrefix(line, "memory path "))
+
+	case line == "memory failures":
+		c.memoryHandler.Failures()
+
+	case line == "memory sites":
+		c.memoryHandler.Sites()
+
+	case strings.HasPrefix(line, "memory delete "):
+		c.memoryHandler.Delete(strings.TrimPrefix(line, "memory delete "))
+
+	case strings.HasPrefix(line, "memory pin "):
+		c.memoryHandler.SetPinned(strings.TrimPrefix(line, "memory pin "), true)
+
+	case strings.HasPrefix(line, "memory unpin "):
+		c.memoryHandler.SetPinned(strings.TrimPrefix(line, "memory unpin "), false)
+
+	case line == "memory export" || strings.HasPrefix(line, "memory export "):
+		c.memoryHandler.Export(strings.TrimPrefix(line, "memory export"))
+
+	case strings.HasPrefix(line, "memory import "):
+		c.memoryHandler.Import(ctx, strings.TrimPrefix(line, "memory import "))
+
 	case strings.HasPrefix(line, "test-llm "):
 		taskText := strings.TrimPrefix(line, "test-llm ")
 		c.llmHandler.TestPlan(ctx, taskText)
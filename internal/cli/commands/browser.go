@@ -40,8 +40,15 @@ func (h *BrowserHandler) OpenPersistent(ctx context.Context) {
 	fmt.Println(ui.ColorGray + "Затем используйте команду '" + ui.ColorYellow + "run <id>" + ui.ColorGray + "' для выполнения задач" + ui.ColorReset)
 	fmt.Println(ui.ColorYellow + "⏎ Нажмите Enter для закрытия браузера..." + ui.ColorReset)
 	h.readLine()
-	h.browser.Close()
-	fmt.Println(ui.ColorGreen + ui.IconCheckmark + " Сессия сохранена в ./browser-data" + ui.ColorReset)
+	if err := h.browser.Close(); err != nil {
+		fmt.Printf(ui.ColorRed+ui.IconCross+" Ошибка сохранения сессии:"+ui.ColorReset+" %v\n", err)
+		return
+	}
+	if path := h.browser.ProfilePath(); path != "" {
+		fmt.Println(ui.ColorGreen + ui.IconCheckmark + " Сессия сохранена в " + path + ui.ColorReset)
+	} else {
+		fmt.Println(ui.ColorYellow + "Persistent режим выключен (PW_USER_DATA_DIR не задан): сессия не сохранена" + ui.ColorReset)
+	}
 }
 
 // Open открывает URL в браузере
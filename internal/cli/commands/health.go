@@ -0,0 +1,55 @@
+package commands
+
+import (
+	"fmt"
+	"time"
+
+	"aiAgent/internal/agent"
+	"aiAgent/internal/cli/ui"
+)
+
+// HealthHandler показывает состояние предохранителей агента: сайтов, целей действий и LLM
+type HealthHandler struct {
+	agent *agent.Agent
+}
+
+func NewHealthHandler(agent *agent.Agent) *HealthHandler {
+	return &HealthHandler{agent: agent}
+}
+
+// Show выводит открытые предохранители и предохранители с ошибками; исправные сворачиваются в счетчик
+func (h *HealthHandler) Show() {
+	if h.agent == nil {
+		fmt.Println(ui.ColorRed + ui.IconCross + " Агент не инициализирован" + ui.ColorReset)
+		return
+	}
+	stats := h.agent.BreakerStats()
+
+	fmt.Println("\n" + ui.ColorBold + ui.IconChart + " Предохранители:" + ui.ColorReset)
+	healthy := 0
+	for _, s := range stats {
+		if s.State == agent.StateClosed && s.Failures == 0 {
+			healthy++
+			continue
+		}
+		switch s.State {
+		case agent.StateOpen:
+			fmt.Printf("  "+ui.ColorRed+ui.IconCross+" %s"+ui.ColorReset+" - открыт, %d ошибок подряд, пробный вызов через %s\n",
+				s.Key, s.Failures, s.RetryAfter.Round(time.Second))
+		case agent.StateHalfOpen:
+			fmt.Printf("  "+ui.ColorYellow+ui.IconPause+" %s"+ui.ColorReset+" - пробный режим\n", s.Key)
+		default:
+			fmt.Printf("  "+ui.ColorYellow+"%s"+ui.ColorReset+" - %d ошибок подряд, последняя в %s\n",
+				s.Key, s.Failures, s.LastFailure.Format("15:04:05"))
+		}
+	}
+	switch {
+	case len(stats) == 0:
+		fmt.Println("  " + ui.ColorGray + "Действий и обращений к LLM еще не было" + ui.ColorReset)
+	case healthy == len(stats):
+		fmt.Printf("  "+ui.ColorGreen+ui.IconCheckmark+" Все в норме (%d)"+ui.ColorReset+"\n", healthy)
+	case healthy > 0:
+		fmt.Printf("  "+ui.ColorGray+"Остальные в норме: %d"+ui.ColorReset+"\n", healthy)
+	}
+	fmt.Println()
+}
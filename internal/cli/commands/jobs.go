@@ -0,0 +1,137 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"aiAgent/internal/agent"
+	"aiAgent/internal/cli/ui"
+	"aiAgent/internal/database"
+)
+
+// JobsHandler обрабатывает команды управления фоновыми задачами
+type JobsHandler struct {
+	jobs     *agent.JobManager
+	repo     *database.TaskRepository
+	out      io.Writer
+	readLine func() (string, error)
+	answer   func(taskID uint, line string) bool // Передает строку задаче, если она ждет ответа пользователя
+}
+
+func NewJobsHandler(jobs *agent.JobManager, repo *database.TaskRepository, out io.Writer, readLine func() (string, error), answer func(taskID uint, line string) bool) *JobsHandler {
+	return &JobsHandler{
+		jobs:     jobs,
+		repo:     repo,
+		out:      out,
+		readLine: readLine,
+		answer:   answer,
+	}
+}
+
+// List выводит список фоновых задач текущей сессии
+func (h *JobsHandler) List() {
+	jobs := h.jobs.List()
+	if len(jobs) == 0 {
+		fmt.Println(ui.ColorGray + "Нет фоновых задач" + ui.ColorReset)
+		return
+	}
+
+	fmt.Println("\n" + ui.ColorBold + ui.IconList + " Фоновые задачи:" + ui.ColorReset)
+	fmt.Println()
+	for _, job := range jobs {
+		icon, color, text := ui.FormatStatus(string(job.Status()))
+
+		var duration time.Duration
+		switch started, finished := job.StartedAt(), job.FinishedAt(); {
+		case started.IsZero():
+			duration = time.Since(job.QueuedAt) // В очереди
+		case finished.IsZero():
+			duration = time.Since(started)
+		default:
+			duration = finished.Sub(started)
+		}
+
+		fmt.Printf("  "+ui.ColorBold+"#%d"+ui.ColorReset+" %s%s %s"+ui.ColorReset+" "+ui.ColorGray+"(%s)"+ui.ColorReset+"\n",
+			job.TaskID, color, icon, text, duration.Round(time.Second))
+		if task, err := h.repo.GetTaskByID(job.TaskID); err == nil {
+			fmt.Printf("  "+ui.ColorGray+"└─"+ui.ColorReset+" %s\n", task.UserInput)
+		}
+		if err := job.Err(); err != nil && job.Status() == agent.JobFailed {
+			fmt.Printf("  "+ui.ColorRed+"   %v"+ui.ColorReset+"\n", err)
+		}
+		fmt.Println()
+	}
+}
+
+// Cancel отменяет выполняющуюся фоновую задачу
+func (h *JobsHandler) Cancel(idStr string) {
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		fmt.Println(ui.ColorRed + ui.IconCross + " Неверный ID задачи" + ui.ColorReset)
+		return
+	}
+	if err := h.jobs.Cancel(uint(id)); err != nil {
+		fmt.Printf(ui.ColorRed+ui.IconCross+" Ошибка:"+ui.ColorReset+" %v\n", err)
+		return
+	}
+	fmt.Printf(ui.ColorYellow+ui.IconPause+" Отмена задачи #%d..."+ui.ColorReset+"\n", id)
+}
+
+// Takeover просит фоновую задачу передать управление браузером пользователю.
+// Агент остановится перед следующим шагом и задаст вопрос в консоли: answer <id> возвращает управление
+func (h *JobsHandler) Takeover(idStr string) {
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		fmt.Println(ui.ColorRed + ui.IconCross + " Неверный ID задачи" + ui.ColorReset)
+		return
+	}
+	if err := h.jobs.Takeover(uint(id)); err != nil {
+		fmt.Printf(ui.ColorRed+ui.IconCross+" Ошибка:"+ui.ColorReset+" %v\n", err)
+		return
+	}
+	fmt.Printf(ui.ColorYellow+ui.IconPause+" Задача #%d передаст управление браузером после текущего шага"+ui.ColorReset+"\n", id)
+}
+
+// Attach показывает вывод фоновой задачи в реальном времени до нажатия Enter
+func (h *JobsHandler) Attach(idStr string) {
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		fmt.Println(ui.ColorRed + ui.IconCross + " Неверный ID задачи" + ui.ColorReset)
+		return
+	}
+	job, ok := h.jobs.Get(uint(id))
+	if !ok {
+		fmt.Printf(ui.ColorRed+ui.IconCross+" Фоновая задача #%d не найдена"+ui.ColorReset+"\n", id)
+		return
+	}
+
+	history, lines, unsubscribe := job.Output.Subscribe()
+	defer unsubscribe()
+
+	fmt.Printf(ui.ColorCyan+ui.IconChat+" Вывод задачи #%d"+ui.ColorReset+" "+ui.ColorGray+"(Enter - отключиться)"+ui.ColorReset+"\n", id)
+	for _, line := range history {
+		fmt.Fprint(h.out, line)
+	}
+
+	go func() {
+		for line := range lines {
+			fmt.Fprint(h.out, line)
+		}
+		if job.Status() != agent.JobRunning {
+			icon, color, text := ui.FormatStatus(string(job.Status()))
+			fmt.Fprintf(h.out, "%s%s Задача #%d %s"+ui.ColorReset+" "+ui.ColorGray+"(Enter - вернуться)"+ui.ColorReset+"\n", color, icon, id, text)
+		}
+	}()
+
+	// Пока задача подключена, введенная строка - ответ на ее вопрос; пустой Enter отключает
+	for {
+		line, err := h.readLine()
+		line = strings.TrimSpace(line)
+		if err != nil || line == "" || !h.answer(uint(id), line) {
+			break
+		}
+	}
+}
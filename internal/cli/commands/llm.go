@@ -2,29 +2,42 @@ package commands
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 
+	"aiAgent/internal/agent"
 	"aiAgent/internal/cli/ui"
 	"aiAgent/internal/llm"
 )
 
+// dryRunMaxSteps - лимит шагов пробного запуска из test-llm
+const dryRunMaxSteps = 10
+
 // LLMHandler обрабатывает команды тестирования LLM
 type LLMHandler struct {
 	llmClient llm.LLMClient
+	agent     *agent.Agent
 }
 
-func NewLLMHandler(llmClient llm.LLMClient) *LLMHandler {
+func NewLLMHandler(llmClient llm.LLMClient, agent *agent.Agent) *LLMHandler {
 	return &LLMHandler{
 		llmClient: llmClient,
+		agent:     agent,
 	}
 }
 
-// TestPlan тестирует планирование LLM
+// TestPlan тестирует планирование LLM.
+// С флагом --dry-run агент проходит полный цикл на реальной странице без выполнения действий.
 func (h *LLMHandler) TestPlan(ctx context.Context, taskText string) {
 	if h.llmClient == nil {
 		fmt.Println(ui.ColorRed + ui.IconCross + " LLM клиент не инициализирован" + ui.ColorReset)
 		return
 	}
+	if rest, ok := strings.CutPrefix(taskText, "--dry-run "); ok {
+		h.dryRun(ctx, strings.TrimSpace(rest))
+		return
+	}
 	pageContext := "Страница: https://example.com\nЭлементы: кнопка 'Найти', поле ввода 'Поиск'"
 
 	fmt.Println(ui.ColorCyan + ui.IconRobot + " Запрос к OpenAI..." + ui.ColorReset)
@@ -46,3 +59,27 @@ func (h *LLMHandler) TestPlan(ctx context.Context, taskText string) {
 		fmt.Printf("  "+ui.ColorCyan+"Обоснование:"+ui.ColorReset+" %s\n", plan.Reasoning)
 	}
 }
+
+// dryRun выполняет пробный запуск задачи: Reason -> PlanActionWithReasoning -> SecurityChecker
+// на текущей странице браузера, действия только симулируются ("would click X")
+func (h *LLMHandler) dryRun(ctx context.Context, taskText string) {
+	if h.agent == nil {
+		fmt.Println(ui.ColorRed + ui.IconCross + " Агент не инициализирован" + ui.ColorReset)
+		return
+	}
+	if taskText == "" {
+		fmt.Println(ui.ColorRed + ui.IconCross + " Использование: test-llm --dry-run <задача>" + ui.ColorReset)
+		return
+	}
+
+	fmt.Println(ui.ColorCyan + ui.IconRobot + " Пробный запуск (действия не выполняются)..." + ui.ColorReset)
+	err := h.agent.DryRun(ctx, taskText, dryRunMaxSteps)
+	switch {
+	case err == nil:
+		fmt.Println(ui.ColorGreen + ui.IconCheckmark + " Пробный запуск завершен: агент считает задачу выполненной" + ui.ColorReset)
+	case errors.Is(err, context.Canceled):
+		fmt.Println(ui.ColorYellow + ui.IconPause + " Пробный запуск прерван" + ui.ColorReset)
+	default:
+		fmt.Printf(ui.ColorYellow+ui.IconPause+" Пробный запуск остановлен:"+ui.ColorReset+" %v\n", err)
+	}
+}
@@ -0,0 +1,346 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"aiAgent/internal/agent"
+	"aiAgent/internal/cli/ui"
+
+	"go.uber.org/zap"
+)
+
+// MemoryHandler обрабатывает команды просмотра и правки памяти агента
+type MemoryHandler struct {
+	agent *agent.Agent
+	log   *zap.Logger
+}
+
+func NewMemoryHandler(agent *agent.Agent, log *zap.Logger) *MemoryHandler {
+	return &MemoryHandler{
+		agent: agent,
+		log:   log,
+	}
+}
+
+// Paths выводит успешные пути, опционально только для домена
+func (h *MemoryHandler) Paths(domain string) {
+	memory := h.memory()
+	if memory == nil {
+		return
+	}
+	domain = strings.TrimSpace(domain)
+	paths := memory.ListPaths(domain)
+	if len(paths) == 0 {
+		fmt.Println(ui.ColorGray + "Успешных путей нет" + ui.ColorReset)
+		return
+	}
+
+	fmt.Println("\n" + ui.ColorBold + ui.IconList + " Успешные пути:" + ui.ColorReset)
+	fmt.Println()
+	for _, path := range paths {
+		fmt.Printf("  "+ui.ColorBold+"#%d"+ui.ColorReset+" %s%s\n", path.ID, path.Task, pinnedMark(path.Pinned()))
+		fmt.Printf("  "+ui.ColorGray+"├─"+ui.ColorReset+" Сайт: %s, шагов: %d, уверенность: %.0f%%\n", path.Domain, len(path.Steps), path.Confidence*100)
+		fmt.Printf("  "+ui.ColorGray+"└─"+ui.ColorReset+" Успехов: %d, последний раз: %s, %s\n",
+			path.SuccessCount, path.LastUsed.Format("2006-01-02 15:04"), formatExpiry(path.ExpiresAt))
+		fmt.Println()
+	}
+}
+
+// Path выводит шаги успешного пути
+func (h *MemoryHandler) Path(idStr string) {
+	memory := h.memory()
+	if memory == nil {
+		return
+	}
+	id, ok := parseMemoryID(idStr, "memory path <id>")
+	if !ok {
+		return
+	}
+	path, err := memory.GetPath(id)
+	if err != nil {
+		fmt.Println(ui.ColorRed + ui.IconCross + " Путь не найден" + ui.ColorReset)
+		return
+	}
+
+	fmt.Printf("\n"+ui.ColorBold+"Путь #%d:"+ui.ColorReset+" %s%s\n", path.ID, path.Task, pinnedMark(path.Pinned()))
+	fmt.Printf("  Сайт: %s\n", path.Domain)
+	if path.Strategy != "" {
+		fmt.Printf("  Стратегия: %s\n", path.Strategy)
+	}
+	fmt.Printf("  Успехов: %d, среднее время: %s, %s\n", path.SuccessCount, path.AverageTime.Round(time.Second), formatExpiry(path.ExpiresAt))
+	fmt.Printf("  Уверенность: %.0f%%", path.Confidence*100)
+	if path.LastMatch > 0 {
+		fmt.Printf(", совпадение со страницей при последнем запуске: %.0f%%", path.LastMatch*100)
+	}
+	fmt.Println()
+	fmt.Println()
+	for i, step := range path.Steps {
+		fmt.Printf("  "+ui.ColorYellow+"%d."+ui.ColorReset+" %s", i+1, step.Action)
+		if step.Selector != "" {
+			fmt.Printf(" %s", step.Selector)
+		}
+		if step.Value != "" {
+			fmt.Printf(" "+ui.ColorGray+"= %q"+ui.ColorReset, step.Value)
+		}
+		fmt.Println()
+		if step.Reasoning != "" {
+			fmt.Printf("     "+ui.ColorGray+"%s"+ui.ColorReset+"\n", step.Reasoning)
+		}
+	}
+	fmt.Println()
+}
+
+// Failures выводит паттерны ошибок, самые частые первыми
+func (h *MemoryHandler) Failures() {
+	memory := h.memory()
+	if memory == nil {
+		return
+	}
+	failures := memory.ListFailures()
+	if len(failures) == 0 {
+		fmt.Println(ui.ColorGray + "Паттернов ошибок нет" + ui.ColorReset)
+		return
+	}
+
+	fmt.Println("\n" + ui.ColorBold + ui.IconList + " Паттерны ошибок:" + ui.ColorReset)
+	fmt.Println()
+	for _, pattern := range failures {
+		fmt.Printf("  "+ui.ColorBold+"#%d"+ui.ColorReset+" "+ui.ColorRed+"%s"+ui.ColorReset+" %s %s "+ui.ColorYellow+"(%d раз)"+ui.ColorReset+"%s\n",
+			pattern.ID, pattern.ErrorType, pattern.Action, pattern.Selector, pattern.Count, pinnedMark(pattern.Pinned()))
+		if pattern.Recovery != "" {
+			fmt.Printf("  "+ui.ColorGray+"├─"+ui.ColorReset+" Помогло: %s\n", pattern.Recovery)
+		}
+		fmt.Printf("  "+ui.ColorGray+"└─"+ui.ColorReset+" Последний раз: %s, %s\n", pattern.LastSeen.Format("2006-01-02 15:04"), formatExpiry(pattern.ExpiresAt))
+	}
+	fmt.Println()
+}
+
+// Sites выводит знания о сайтах
+func (h *MemoryHandler) Sites() {
+	memory := h.memory()
+	if memory == nil {
+		return
+	}
+	sites := memory.ListSites()
+	if len(sites) == 0 {
+		fmt.Println(ui.ColorGray + "Знаний о сайтах нет" + ui.ColorReset)
+		return
+	}
+
+	fmt.Println("\n" + ui.ColorBold + ui.IconGlobe + " Знания о сайтах:" + ui.ColorReset)
+	fmt.Println()
+	for _, info := range sites {
+		fmt.Printf("  "+ui.ColorBold+"%s"+ui.ColorReset+"%s\n", info.Domain, pinnedMark(info.Pinned()))
+		for _, purpose := range slices.Sorted(maps.Keys(info.CommonPatterns)) {
+			fmt.Printf("  "+ui.ColorGray+"├─"+ui.ColorReset+" %s: %s\n", purpose, info.CommonPatterns[purpose])
+		}
+		for _, form := range info.FormStructure {
+			fmt.Printf("  "+ui.ColorGray+"├─"+ui.ColorReset+" форма %s\n", form)
+		}
+		fmt.Printf("  "+ui.ColorGray+"└─"+ui.ColorReset+" Последний визит: %s, %s\n", info.LastVisited.Format("2006-01-02 15:04"), formatExpiry(info.ExpiresAt))
+		fmt.Println()
+	}
+}
+
+// Delete удаляет запись памяти: "path|failure <id>" или "site <домен>"
+func (h *MemoryHandler) Delete(args string) {
+	memory := h.memory()
+	if memory == nil {
+		return
+	}
+	kind, target, found := strings.Cut(strings.TrimSpace(args), " ")
+	target = strings.TrimSpace(target)
+	if !found || target == "" {
+		fmt.Println(ui.ColorRed + ui.IconCross + " Использование: memory delete path|failure <id> или memory delete site <домен>" + ui.ColorReset)
+		return
+	}
+
+	var err error
+	switch kind {
+	case "path", "failure":
+		id, ok := parseMemoryID(target, "memory delete "+kind+" <id>")
+		if !ok {
+			return
+		}
+		if kind == "path" {
+			err = memory.DeletePath(id)
+		} else {
+			err = memory.DeleteFailure(id)
+		}
+	case "site":
+		err = memory.DeleteSite(target)
+	default:
+		fmt.Println(ui.ColorRed + ui.IconCross + " Неизвестный вид записи: " + kind + " (path, failure или site)" + ui.ColorReset)
+		return
+	}
+	if !h.report(err) {
+		return
+	}
+	fmt.Printf(ui.ColorGreen+ui.IconCheckmark+" Запись %s %s удалена из памяти"+ui.ColorReset+"\n", kind, target)
+}
+
+// SetPinned закрепляет запись памяти или снимает закрепление: "path|failure <id>" или "site <домен>"
+func (h *MemoryHandler) SetPinned(args string, pinned bool) {
+	memory := h.memory()
+	if memory == nil {
+		return
+	}
+	command := "memory pin"
+	if !pinned {
+		command = "memory unpin"
+	}
+	kind, target, found := strings.Cut(strings.TrimSpace(args), " ")
+	target = strings.TrimSpace(target)
+	if !found || target == "" {
+		fmt.Println(ui.ColorRed + ui.IconCross + " Использование: " + command + " path|failure <id> или " + command + " site <домен>" + ui.ColorReset)
+		return
+	}
+
+	var err error
+	switch kind {
+	case "path", "failure":
+		id, ok := parseMemoryID(target, command+" "+kind+" <id>")
+		if !ok {
+			return
+		}
+		if kind == "path" {
+			err = memory.SetPathPinned(id, pinned)
+		} else {
+			err = memory.SetFailurePinned(id, pinned)
+		}
+	case "site":
+		err = memory.SetSitePinned(target, pinned)
+	default:
+		fmt.Println(ui.ColorRed + ui.IconCross + " Неизвестный вид записи: " + kind + " (path, failure или site)" + ui.ColorReset)
+		return
+	}
+	if !h.report(err) {
+		return
+	}
+	if pinned {
+		fmt.Printf(ui.ColorGreen+ui.IconCheckmark+" Запись %s %s закреплена: не устаревает"+ui.ColorReset+"\n", kind, target)
+	} else {
+		fmt.Printf(ui.ColorGreen+ui.IconCheckmark+" Закрепление записи %s %s снято"+ui.ColorReset+"\n", kind, target)
+	}
+}
+
+// Export сохраняет память в JSON файл (по умолчанию memory.json)
+func (h *MemoryHandler) Export(args string) {
+	memory := h.memory()
+	if memory == nil {
+		return
+	}
+	path := strings.TrimSpace(args)
+	if path == "" {
+		path = "memory.json"
+	}
+
+	bundle := memory.Export()
+	data, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		fmt.Printf(ui.ColorRed+ui.IconCross+" Ошибка:"+ui.ColorReset+" %v\n", err)
+		return
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		h.log.Error("Ошибка экспорта памяти", zap.Error(err))
+		fmt.Printf(ui.ColorRed+ui.IconCross+" Ошибка записи файла:"+ui.ColorReset+" %v\n", err)
+		return
+	}
+	fmt.Printf(ui.ColorGreen+ui.IconCheckmark+" Память сохранена в %s: путей %d, ошибок %d, сайтов %d"+ui.ColorReset+"\n",
+		path, len(bundle.Paths), len(bundle.Failures), len(bundle.Sites))
+}
+
+// Import загружает память из JSON файла и объединяет с текущей
+func (h *MemoryHandler) Import(ctx context.Context, args string) {
+	memory := h.memory()
+	if memory == nil {
+		return
+	}
+	path := strings.TrimSpace(args)
+	if path == "" {
+		fmt.Println(ui.ColorRed + ui.IconCross + " Использование: memory import <файл>" + ui.ColorReset)
+		return
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Printf(ui.ColorRed+ui.IconCross+" Ошибка чтения файла:"+ui.ColorReset+" %v\n", err)
+		return
+	}
+	var bundle agent.MemoryBundle
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		fmt.Printf(ui.ColorRed+ui.IconCross+" Неверный формат файла памяти:"+ui.ColorReset+" %v\n", err)
+		return
+	}
+
+	result, err := memory.Import(ctx, &bundle)
+	if err != nil {
+		h.log.Error("Ошибка импорта памяти", zap.String("file", path), zap.Error(err))
+		fmt.Printf(ui.ColorRed+ui.IconCross+" Ошибка импорта:"+ui.ColorReset+" %v\n", err)
+		return
+	}
+	fmt.Printf(ui.ColorGreen+ui.IconCheckmark+" Память импортирована из %s: путей %d, ошибок %d, сайтов %d"+ui.ColorReset+"\n",
+		path, result.Paths, result.Failures, result.Sites)
+	if result.Skipped > 0 {
+		fmt.Printf("  "+ui.ColorGray+"Пропущено устаревших и неполных записей: %d"+ui.ColorReset+"\n", result.Skipped)
+	}
+}
+
+// memory возвращает память агента или сообщает, что она выключена
+func (h *MemoryHandler) memory() *agent.AgentMemory {
+	if h.agent == nil {
+		fmt.Println(ui.ColorRed + ui.IconCross + " Агент не инициализирован" + ui.ColorReset)
+		return nil
+	}
+	memory := h.agent.Memory()
+	if memory == nil {
+		fmt.Println(ui.ColorGray + "Память агента выключена" + ui.ColorReset)
+	}
+	return memory
+}
+
+// report выводит ошибку операции с памятью; false - операция не удалась
+func (h *MemoryHandler) report(err error) bool {
+	if err == nil {
+		return true
+	}
+	if errors.Is(err, agent.ErrMemoryEntryNotFound) {
+		fmt.Println(ui.ColorRed + ui.IconCross + " Запись не найдена" + ui.ColorReset)
+		return false
+	}
+	h.log.Error("Ошибка изменения памяти", zap.Error(err))
+	fmt.Printf(ui.ColorRed+ui.IconCross+" Ошибка:"+ui.ColorReset+" %v\n", err)
+	return false
+}
+
+func parseMemoryID(s string, usage string) (uint, bool) {
+	id, err := strconv.ParseUint(strings.TrimSpace(s), 10, 64)
+	if err != nil || id == 0 {
+		fmt.Println(ui.ColorRed + ui.IconCross + " Неверный ID. Использование: " + usage + ui.ColorReset)
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func pinnedMark(pinned bool) string {
+	if pinned {
+		return " " + ui.ColorCyan + "[закреплен]" + ui.ColorReset
+	}
+	return ""
+}
+
+func formatExpiry(expiresAt time.Time) string {
+	if expiresAt.IsZero() {
+		return "бессрочно"
+	}
+	return "хранится до " + expiresAt.Format("2006-01-02")
+}
@@ -0,0 +1,164 @@
+package commands
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"aiAgent/internal/cli/ui"
+	"aiAgent/internal/database"
+	"aiAgent/internal/scheduler"
+
+	"go.uber.org/zap"
+)
+
+// ScheduleHandler обрабатывает команды управления расписаниями задач
+type ScheduleHandler struct {
+	repo *database.TaskRepository
+	log  *zap.Logger
+}
+
+func NewScheduleHandler(repo *database.TaskRepository, log *zap.Logger) *ScheduleHandler {
+	return &ScheduleHandler{
+		repo: repo,
+		log:  log,
+	}
+}
+
+// Add привязывает cron расписание к задаче: "<task_id> <cron выражение>"
+func (h *ScheduleHandler) Add(args string) {
+	idStr, expr, found := strings.Cut(strings.TrimSpace(args), " ")
+	if !found {
+		fmt.Println(ui.ColorRed + ui.IconCross + " Использование: schedule add <task_id> <cron>" + ui.ColorReset)
+		fmt.Println(ui.ColorGray + "  Пример: schedule add 3 0 9 * * *   (каждый день в 9:00)" + ui.ColorReset)
+		return
+	}
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		fmt.Println(ui.ColorRed + ui.IconCross + " Неверный ID задачи" + ui.ColorReset)
+		return
+	}
+	task, err := h.repo.GetTaskByID(uint(id))
+	if err != nil {
+		fmt.Println(ui.ColorRed + ui.IconCross + " Задача не найдена" + ui.ColorReset)
+		return
+	}
+
+	cron, err := scheduler.ParseCron(expr)
+	if err != nil {
+		fmt.Printf(ui.ColorRed+ui.IconCross+" Неверное расписание:"+ui.ColorReset+" %v\n", err)
+		return
+	}
+	next := cron.Next(time.Now())
+	if next.IsZero() {
+		fmt.Println(ui.ColorRed + ui.IconCross + " Расписание никогда не сработает" + ui.ColorReset)
+		return
+	}
+
+	sch := database.TaskSchedule{
+		TaskID:    task.ID,
+		CronExpr:  cron.String(),
+		Enabled:   true,
+		NextRunAt: next,
+	}
+	if err := h.repo.CreateSchedule(&sch); err != nil {
+		h.log.Error("Ошибка создания расписания", zap.Error(err))
+		fmt.Printf(ui.ColorRed+ui.IconCross+" Ошибка:"+ui.ColorReset+" %v\n", err)
+		return
+	}
+	fmt.Printf(ui.ColorGreen+ui.IconCheckmark+" Создано расписание #%d для задачи #%d"+ui.ColorReset+"\n", sch.ID, task.ID)
+	fmt.Printf("  "+ui.ColorGray+ui.IconTime+ui.ColorReset+" Следующий запуск: %s\n", next.Format("2006-01-02 15:04"))
+}
+
+// List выводит все расписания
+func (h *ScheduleHandler) List() {
+	schedules, err := h.repo.ListSchedules()
+	if err != nil {
+		h.log.Error("Ошибка чтения расписаний", zap.Error(err))
+		fmt.Println(ui.ColorRed + ui.IconCross + " Ошибка чтения расписаний" + ui.ColorReset)
+		return
+	}
+	if len(schedules) == 0 {
+		fmt.Println(ui.ColorGray + "Расписаний нет" + ui.ColorReset)
+		return
+	}
+
+	fmt.Println("\n" + ui.ColorBold + ui.IconTime + " Расписания:" + ui.ColorReset)
+	fmt.Println()
+	for _, sch := range schedules {
+		state := ui.ColorGreen + "вкл" + ui.ColorReset
+		if !sch.Enabled {
+			state = ui.ColorGray + "выкл" + ui.ColorReset
+		}
+		fmt.Printf("  "+ui.ColorBold+"#%d"+ui.ColorReset+" [%s] "+ui.ColorYellow+"%s"+ui.ColorReset+" → задача #%d\n", sch.ID, state, sch.CronExpr, sch.TaskID)
+		if task, err := h.repo.GetTaskByID(sch.TaskID); err == nil {
+			fmt.Printf("  "+ui.ColorGray+"├─"+ui.ColorReset+" %s\n", task.UserInput)
+		}
+		if sch.Enabled {
+			fmt.Printf("  "+ui.ColorGray+"├─"+ui.ColorReset+" Следующий запуск: %s\n", sch.NextRunAt.Format("2006-01-02 15:04"))
+		}
+		if sch.LastRunAt != nil {
+			last := fmt.Sprintf("%s (%s)", sch.LastRunAt.Format("2006-01-02 15:04"), sch.LastStatus)
+			if sch.LastTaskID != nil {
+				last += fmt.Sprintf(", задача #%d", *sch.LastTaskID)
+			}
+			fmt.Printf("  "+ui.ColorGray+"└─"+ui.ColorReset+" Последний запуск: %s\n", last)
+		} else {
+			fmt.Println("  " + ui.ColorGray + "└─ Еще не запускалось" + ui.ColorReset)
+		}
+		if sch.LastError != "" {
+			fmt.Printf("     "+ui.ColorRed+"%s"+ui.ColorReset+"\n", sch.LastError)
+		}
+		fmt.Println()
+	}
+}
+
+// Remove удаляет расписание
+func (h *ScheduleHandler) Remove(idStr string) {
+	id, err := strconv.Atoi(strings.TrimSpace(idStr))
+	if err != nil {
+		fmt.Println(ui.ColorRed + ui.IconCross + " Неверный ID расписания" + ui.ColorReset)
+		return
+	}
+	if err := h.repo.DeleteSchedule(uint(id)); err != nil {
+		fmt.Println(ui.ColorRed + ui.IconCross + " Расписание не найдено" + ui.ColorReset)
+		return
+	}
+	fmt.Printf(ui.ColorGreen+ui.IconCheckmark+" Расписание #%d удалено"+ui.ColorReset+"\n", id)
+}
+
+// SetEnabled включает или выключает расписание
+func (h *ScheduleHandler) SetEnabled(idStr string, enabled bool) {
+	id, err := strconv.Atoi(strings.TrimSpace(idStr))
+	if err != nil {
+		fmt.Println(ui.ColorRed + ui.IconCross + " Неверный ID расписания" + ui.ColorReset)
+		return
+	}
+	sch, err := h.repo.GetSchedule(uint(id))
+	if err != nil {
+		fmt.Println(ui.ColorRed + ui.IconCross + " Расписание не найдено" + ui.ColorReset)
+		return
+	}
+
+	// При включении следующий запуск считается от текущего момента, пропущенные не догоняются
+	next := sch.NextRunAt
+	if enabled {
+		cron, err := scheduler.ParseCron(sch.CronExpr)
+		if err != nil {
+			fmt.Printf(ui.ColorRed+ui.IconCross+" Неверное расписание:"+ui.ColorReset+" %v\n", err)
+			return
+		}
+		next = cron.Next(time.Now())
+	}
+	if err := h.repo.SetScheduleEnabled(sch.ID, enabled, next); err != nil {
+		fmt.Printf(ui.ColorRed+ui.IconCross+" Ошибка:"+ui.ColorReset+" %v\n", err)
+		return
+	}
+
+	if enabled {
+		fmt.Printf(ui.ColorGreen+ui.IconCheckmark+" Расписание #%d включено, следующий запуск: %s"+ui.ColorReset+"\n", sch.ID, next.Format("2006-01-02 15:04"))
+	} else {
+		fmt.Printf(ui.ColorYellow+ui.IconPause+" Расписание #%d выключено"+ui.ColorReset+"\n", sch.ID)
+	}
+}
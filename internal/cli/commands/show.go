@@ -1,10 +1,14 @@
 package commands
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
 	"strconv"
 	"strings"
+	"time"
 
+	"aiAgent/internal/agent"
 	"aiAgent/internal/cli/ui"
 	"aiAgent/internal/database"
 
@@ -13,14 +17,16 @@ import (
 
 // ShowHandler обрабатывает команды просмотра деталей
 type ShowHandler struct {
-	repo *database.TaskRepository
-	log  *zap.Logger
+	repo  *database.TaskRepository
+	agent *agent.Agent
+	log   *zap.Logger
 }
 
-func NewShowHandler(repo *database.TaskRepository, log *zap.Logger) *ShowHandler {
+func NewShowHandler(repo *database.TaskRepository, agent *agent.Agent, log *zap.Logger) *ShowHandler {
 	return &ShowHandler{
-		repo: repo,
-		log:  log,
+		repo:  repo,
+		agent: agent,
+		log:   log,
 	}
 }
 
@@ -43,9 +49,45 @@ func (h *ShowHandler) Show(idStr string) {
 	fmt.Printf(ui.ColorCyan+ui.IconDocument+" Описание:"+ui.ColorReset+" %s\n", task.UserInput)
 	fmt.Printf(ui.ColorCyan+ui.IconChart+" Статус:"+ui.ColorReset+" %s\n", statusText)
 	fmt.Printf(ui.ColorCyan+ui.IconTime+" Создана:"+ui.ColorReset+" %s\n", task.CreatedAt.Format("2006-01-02 15:04:05"))
+	if criteria := agent.ParseCriteria(task.SuccessCriteria); len(criteria) > 0 {
+		fmt.Println(ui.ColorCyan + ui.IconCheckmark + " Критерии успеха:" + ui.ColorReset)
+		for _, criterion := range criteria {
+			fmt.Printf("  - %s\n", criterion)
+		}
+	}
+	if task.RoutedAgent != "" {
+		printRouting(task)
+	}
 	if task.ResultSummary != "" {
 		fmt.Printf(ui.ColorCyan+ui.IconChat+" Результат:"+ui.ColorReset+" %s\n", task.ResultSummary)
 	}
+	if task.Result != "" {
+		var records []json.RawMessage
+		if err := json.Unmarshal([]byte(task.Result), &records); err == nil {
+			fmt.Printf(ui.ColorCyan+ui.IconDocument+" Структурированный результат:"+ui.ColorReset+" %d записей (show %d --json, export %d)\n", len(records), task.ID, task.ID)
+		}
+	}
+	if h.agent != nil {
+		if usage, err := h.agent.TaskUsage(task.ID); err == nil {
+			fmt.Printf(ui.ColorCyan+ui.IconChart+" Расход LLM:"+ui.ColorReset+" %d токенов, ~$%.4f", usage.Tokens, usage.Cost)
+			if task.TokenBudget > 0 || task.CostBudget > 0 {
+				fmt.Printf(" "+ui.ColorGray+"(бюджет: %s)"+ui.ColorReset, ui.FormatBudget(int64(task.TokenBudget), task.CostBudget))
+			}
+			fmt.Println()
+		}
+	}
+
+	if subgoals, err := h.repo.ListSubgoals(task.ID); err != nil {
+		h.log.Error("Ошибка получения подзадач", zap.Error(err))
+	} else if len(subgoals) > 0 {
+		printSubgoals(subgoals)
+	}
+
+	if attempts, err := h.repo.ListAttempts(task.ID); err != nil {
+		h.log.Error("Ошибка получения попыток", zap.Error(err))
+	} else if len(attempts) > 0 {
+		printAttempts(attempts)
+	}
 
 	steps, err := h.repo.GetStepsByTaskID(task.ID)
 	if err != nil {
@@ -57,7 +99,11 @@ func (h *ShowHandler) Show(idStr string) {
 	if len(steps) > 0 {
 		fmt.Printf("\n"+ui.ColorYellow+ui.IconLoop+" Шаги выполнения (%d):"+ui.ColorReset+"\n", len(steps))
 		for _, step := range steps {
-			fmt.Printf("\n"+ui.ColorBold+"[Шаг %d]"+ui.ColorReset+" "+ui.ColorCyan+"%s"+ui.ColorReset+"\n", step.StepNo, step.ActionType)
+			fmt.Printf("\n"+ui.ColorBold+"[Шаг %d]"+ui.ColorReset+" "+ui.ColorCyan+"%s"+ui.ColorReset, step.StepNo, step.ActionType)
+			if step.DryRun {
+				fmt.Print(" " + ui.ColorGray + "(dry-run)" + ui.ColorReset)
+			}
+			fmt.Println()
 			if step.TargetSelector != "" {
 				fmt.Printf("  "+ui.ColorGray+"Селектор:"+ui.ColorReset+" %s\n", step.TargetSelector)
 			}
@@ -71,6 +117,25 @@ func (h *ShowHandler) Show(idStr string) {
 				}
 				fmt.Printf("  %sРезультат:"+ui.ColorReset+" %s\n", resultColor, step.Result)
 			}
+			if step.Verdict != "" {
+				verdictColor := ui.ColorGreen
+				if step.Verdict != "success" {
+					verdictColor = ui.ColorYellow
+				}
+				fmt.Printf("  %sРефлексия:"+ui.ColorReset+" %s", verdictColor, step.Verdict)
+				if step.VerdictReason != "" {
+					fmt.Printf(" - %s", step.VerdictReason)
+				}
+				fmt.Println()
+			}
+			printRetries(step.AttemptLog)
+			if step.LoopKind != "" {
+				fmt.Printf("  %sЗацикливание:"+ui.ColorReset+" %s (%s)", ui.ColorPurple, step.LoopKind, step.LoopEscalation)
+				if step.LoopDetail != "" {
+					fmt.Printf(" - %s", step.LoopDetail)
+				}
+				fmt.Println()
+			}
 			fmt.Printf("  "+ui.ColorGray+ui.IconTime+" %s"+ui.ColorReset+"\n", step.CreatedAt.Format("15:04:05"))
 		}
 	} else {
@@ -78,3 +143,192 @@ func (h *ShowHandler) Show(idStr string) {
 	}
 	fmt.Println()
 }
+
+// printRetries выводит области шага, где понадобились повторы: попытки, паузы перед ними и ошибки
+func printRetries(attemptLog string) {
+	var attempts []agent.RetryAttempt
+	if attemptLog == "" || json.Unmarshal([]byte(attemptLog), &attempts) != nil {
+		return
+	}
+
+	var scopes []string
+	byScope := make(map[string][]agent.RetryAttempt)
+	for _, attempt := range attempts {
+		if _, ok := byScope[attempt.Scope]; !ok {
+			scopes = append(scopes, attempt.Scope)
+		}
+		byScope[attempt.Scope] = append(byScope[attempt.Scope], attempt)
+	}
+
+	for _, scope := range scopes {
+		scopeAttempts := byScope[scope]
+		if len(scopeAttempts) < 2 {
+			continue
+		}
+		var parts []string
+		for _, attempt := range scopeAttempts {
+			part := fmt.Sprintf("#%d", attempt.Attempt)
+			if attempt.DelayMs > 0 {
+				part += fmt.Sprintf(" через %s", time.Duration(attempt.DelayMs)*time.Millisecond)
+			}
+			if attempt.Error != "" {
+				part += " " + string(attempt.Error)
+			} else {
+				part += " ok"
+			}
+			parts = append(parts, part)
+		}
+		fmt.Printf("  "+ui.ColorYellow+"Повторы %s:"+ui.ColorReset+" %s\n", scope, strings.Join(parts, ", "))
+	}
+}
+
+// printSubgoals выводит дерево подзадач: дочерние подзадачи с отступом под родительской
+func printSubgoals(subgoals []database.TaskSubgoal) {
+	var roots []database.TaskSubgoal
+	children := make(map[uint][]database.TaskSubgoal)
+	for _, sg := range subgoals {
+		if sg.ParentID != nil {
+			children[*sg.ParentID] = append(children[*sg.ParentID], sg)
+		} else {
+			roots = append(roots, sg)
+		}
+	}
+
+	fmt.Printf("\n"+ui.ColorYellow+ui.IconList+" Подзадачи (%d):"+ui.ColorReset+"\n", len(subgoals))
+	for i, root := range roots {
+		last := i == len(roots)-1
+		printSubgoal(root, "", last)
+
+		indent := "│  "
+		if last {
+			indent = "   "
+		}
+		for j, child := range children[root.ID] {
+			printSubgoal(child, indent, j == len(children[root.ID])-1)
+		}
+	}
+}
+
+func printSubgoal(sg database.TaskSubgoal, indent string, last bool) {
+	branch := "├─ "
+	if last {
+		branch = "└─ "
+	}
+	icon, color, text := ui.FormatStatus(sg.Status)
+	fmt.Printf("  "+ui.ColorGray+"%s%s"+ui.ColorReset+"%s%s"+ui.ColorReset+" %s "+ui.ColorGray+"(%s, шагов %d/%d)"+ui.ColorReset+"\n",
+		indent, branch, color, icon, sg.Title, text, sg.StepsUsed, sg.StepBudget)
+	if sg.Status == "failed" && sg.Result != "" {
+		fmt.Printf("  "+ui.ColorGray+"%s   "+ui.ColorReset+ui.ColorRed+"%s"+ui.ColorReset+"\n", indent, sg.Result)
+	}
+}
+
+// printAttempts выводит попытки цепочки fallback: какой агент выполнял какие шаги и чем закончил
+func printAttempts(attempts []database.TaskAttempt) {
+	fmt.Printf("\n"+ui.ColorYellow+ui.IconRobot+" Попытки (%d):"+ui.ColorReset+"\n", len(attempts))
+	for _, at := range attempts {
+		icon, color, text := ui.FormatStatus(at.Status)
+		steps := "без шагов"
+		if at.LastStep >= at.FirstStep {
+			steps = fmt.Sprintf("шаги %d-%d", at.FirstStep, at.LastStep)
+		}
+		fmt.Printf("  %d. %s%s"+ui.ColorReset+" %s "+ui.ColorGray+"(%s, %s)"+ui.ColorReset+"\n",
+			at.AttemptNo, color, icon, at.Agent, text, steps)
+		if at.Error != "" && at.Status != "completed" {
+			fmt.Printf("     "+ui.ColorRed+"%s"+ui.ColorReset+"\n", at.Error)
+		}
+	}
+}
+
+// taskExport - структурированный результат задачи для других инструментов.
+type taskExport struct {
+	ID       uint            `json:"id"`
+	Task     string          `json:"task"`
+	Status   string          `json:"status"`
+	Criteria []string        `json:"criteria,omitempty"`
+	Summary  string          `json:"summary,omitempty"`
+	Result   json.RawMessage `json:"result"`
+}
+
+func (h *ShowHandler) exportTask(idStr string) ([]byte, error) {
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return nil, fmt.Errorf("неверный ID задачи")
+	}
+	task, err := h.repo.GetTaskByID(uint(id))
+	if err != nil {
+		return nil, fmt.Errorf("задача не найдена")
+	}
+
+	result := json.RawMessage("null")
+	if task.Result != "" {
+		result = json.RawMessage(task.Result)
+	}
+	return json.MarshalIndent(taskExport{
+		ID:       task.ID,
+		Task:     task.UserInput,
+		Status:   task.Status,
+		Criteria: agent.ParseCriteria(task.SuccessCriteria),
+		Summary:  task.ResultSummary,
+		Result:   result,
+	}, "", "  ")
+}
+
+// ShowJSON выводит задачу и ее структурированный результат в формате JSON
+func (h *ShowHandler) ShowJSON(idStr string) {
+	data, err := h.exportTask(idStr)
+	if err != nil {
+		fmt.Println(ui.ColorRed + ui.IconCross + " " + err.Error() + ui.ColorReset)
+		return
+	}
+	fmt.Println(string(data))
+}
+
+// Export сохраняет задачу и ее структурированный результат в JSON файл.
+// Формат ввода: <id> [файл], по умолчанию task_<id>.json
+func (h *ShowHandler) Export(args string) {
+	parts := strings.Fields(args)
+	if len(parts) == 0 || len(parts) > 2 {
+		fmt.Println(ui.ColorRed + ui.IconCross + " Использование: export <id> [файл]" + ui.ColorReset)
+		return
+	}
+	data, err := h.exportTask(parts[0])
+	if err != nil {
+		fmt.Println(ui.ColorRed + ui.IconCross + " " + err.Error() + ui.ColorReset)
+		return
+	}
+
+	path := fmt.Sprintf("task_%s.json", parts[0])
+	if len(parts) == 2 {
+		path = parts[1]
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		h.log.Error("Ошибка экспорта результата", zap.Error(err))
+		fmt.Printf(ui.ColorRed+ui.IconCross+" Ошибка записи файла:"+ui.ColorReset+" %v\n", err)
+		return
+	}
+	fmt.Printf(ui.ColorGreen+ui.IconCheckmark+" Результат задачи #%s сохранен в %s"+ui.ColorReset+"\n", parts[0], path)
+}
+
+// printRouting выводит агента, выбранного роутером, обоснование выбора и остальных кандидатов
+func printRouting(task *database.Task) {
+	if task.Routing == "" {
+		fmt.Printf(ui.ColorCyan+ui.IconRobot+" Агент:"+ui.ColorReset+" %s\n", task.RoutedAgent)
+		return
+	}
+	routing, err := agent.ParseRouting(task.Routing)
+	if err != nil {
+		fmt.Printf(ui.ColorCyan+ui.IconRobot+" Агент:"+ui.ColorReset+" %s\n", task.RoutedAgent)
+		return
+	}
+	fmt.Printf(ui.ColorCyan+ui.IconRobot+" Агент:"+ui.ColorReset+" %s "+ui.ColorGray+"(%s, уверенность %.2f)"+ui.ColorReset+"\n",
+		routing.Agent, routing.Method, routing.Confidence)
+	if routing.Rationale != "" {
+		fmt.Printf("  "+ui.ColorGray+"└─ %s"+ui.ColorReset+"\n", routing.Rationale)
+	}
+	for _, candidate := range routing.Ranking {
+		if candidate.Agent == routing.Agent {
+			continue
+		}
+		fmt.Printf("  "+ui.ColorGray+"   %s %.2f: %s"+ui.ColorReset+"\n", candidate.Agent, candidate.Confidence, candidate.Rationale)
+	}
+}
@@ -2,8 +2,13 @@ package commands
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"regexp"
 	"strconv"
+	"strings"
 
 	"aiAgent/internal/agent"
 	"aiAgent/internal/cli/ui"
@@ -16,26 +21,98 @@ import (
 type TaskHandler struct {
 	repo  *database.TaskRepository
 	agent *agent.Agent
+	jobs  *agent.JobManager
+	out   io.Writer // Консоль для уведомлений о завершении фоновых задач
 	log   *zap.Logger
 }
 
-func NewTaskHandler(repo *database.TaskRepository, agent *agent.Agent, log *zap.Logger) *TaskHandler {
+func NewTaskHandler(repo *database.TaskRepository, agent *agent.Agent, jobs *agent.JobManager, out io.Writer, log *zap.Logger) *TaskHandler {
 	return &TaskHandler{
 		repo:  repo,
 		agent: agent,
+		jobs:  jobs,
+		out:   out,
 		log:   log,
 	}
 }
 
-// Create создает новую задачу
-func (h *TaskHandler) Create(userInput string) {
-	task := database.Task{UserInput: userInput, Status: "pending"}
-	if err := h.repo.CreateTask(&task); err != nil {
+// Create создает новую задачу.
+// Перед текстом можно указать бюджет и схему результата: task --tokens 50000 --cost 0.5 --schema schema.json <текст>
+// После текста можно указать критерии успеха через ';': task <текст> --criteria <критерий>; <критерий>
+func (h *TaskHandler) Create(input string) {
+	task, err := parseTaskInput(input)
+	if err != nil {
+		fmt.Printf(ui.ColorRed+ui.IconCross+" Ошибка:"+ui.ColorReset+" %v\n", err)
+		return
+	}
+	if err := h.repo.CreateTask(task); err != nil {
 		h.log.Error("Ошибка создания задачи", zap.Error(err))
 		fmt.Printf(ui.ColorRed+ui.IconCross+" Ошибка:"+ui.ColorReset+" %v\n", err)
 		return
 	}
 	fmt.Printf(ui.ColorGreen+ui.IconCheckmark+" Создана задача #%d"+ui.ColorReset+"\n", task.ID)
+	if task.TokenBudget > 0 || task.CostBudget > 0 {
+		fmt.Printf("  "+ui.ColorGray+"Бюджет: %s"+ui.ColorReset+"\n", ui.FormatBudget(int64(task.TokenBudget), task.CostBudget))
+	}
+	if task.OutputSchema != "" {
+		fmt.Println("  " + ui.ColorGray + "Структурированный результат: по JSON схеме" + ui.ColorReset)
+	}
+	for _, criterion := range agent.ParseCriteria(task.SuccessCriteria) {
+		fmt.Printf("  "+ui.ColorGray+"Критерий: %s"+ui.ColorReset+"\n", criterion)
+	}
+}
+
+// criteriaFlag - опция --criteria отдельным словом (не часть слова вроде --criteria=x или a--criteria).
+var criteriaFlag = regexp.MustCompile(`(?:^|\s)--criteria(?:\s|$)`)
+
+// parseTaskInput разбирает опции бюджета (--tokens N, --cost USD) и схемы результата (--schema файл)
+// в начале текста задачи и критерии успеха (--criteria) после него
+func parseTaskInput(input string) (*database.Task, error) {
+	task := &database.Task{Status: "pending"}
+	if loc := criteriaFlag.FindStringIndex(input); loc != nil {
+		criteria := agent.ParseCriteria(input[loc[1]:])
+		if len(criteria) == 0 {
+			return nil, fmt.Errorf("пустой список критериев --criteria")
+		}
+		task.SuccessCriteria = strings.Join(criteria, "\n")
+		input = input[:loc[0]]
+	}
+	fields := strings.Fields(input)
+
+	i := 0
+	for ; i+1 < len(fields) && strings.HasPrefix(fields[i], "--"); i += 2 {
+		switch fields[i] {
+		case "--tokens":
+			n, err := strconv.Atoi(fields[i+1])
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("неверное значение --tokens: %s", fields[i+1])
+			}
+			task.TokenBudget = n
+		case "--cost":
+			cost, err := strconv.ParseFloat(fields[i+1], 64)
+			if err != nil || cost < 0 {
+				return nil, fmt.Errorf("неверное значение --cost: %s", fields[i+1])
+			}
+			task.CostBudget = cost
+		case "--schema":
+			data, err := os.ReadFile(fields[i+1])
+			if err != nil {
+				return nil, fmt.Errorf("ошибка чтения схемы: %w", err)
+			}
+			if _, err := agent.ParseOutputSchema(string(data)); err != nil {
+				return nil, err
+			}
+			task.OutputSchema = string(data)
+		default:
+			return nil, fmt.Errorf("неизвестная опция %s (доступны --tokens, --cost, --schema)", fields[i])
+		}
+	}
+
+	task.UserInput = strings.Join(fields[i:], " ")
+	if task.UserInput == "" {
+		return nil, fmt.Errorf("пустой текст задачи")
+	}
+	return task, nil
 }
 
 // List выводит список всех задач
@@ -72,8 +149,66 @@ func (h *TaskHandler) Status(idStr string) {
 	fmt.Println()
 }
 
-// Run выполняет задачу
-func (h *TaskHandler) Run(ctx context.Context, idStr string) {
+// Run запускает задачи в фоне. Можно передать несколько ID через пробел:
+// задачи сверх числа воркеров ждут в очереди. --dry-run [--url <адрес>] - пробный запуск
+// на стартовой странице (по умолчанию - на странице, открытой командой open).
+func (h *TaskHandler) Run(ctx context.Context, idsStr string) {
+	if h.agent == nil {
+		fmt.Println(ui.ColorRed + ui.IconCross + " Агент не инициализирован" + ui.ColorReset)
+		return
+	}
+	dryRun := false
+	startURL := ""
+	var ids []string
+	fields := strings.Fields(idsStr)
+	for i := 0; i < len(fields); i++ {
+		switch fields[i] {
+		case "--dry-run":
+			dryRun = true
+		case "--url":
+			if i+1 >= len(fields) {
+				fmt.Println(ui.ColorRed + ui.IconCross + " Не указан адрес после --url" + ui.ColorReset)
+				return
+			}
+			i++
+			startURL = fields[i]
+			if !strings.HasPrefix(startURL, "http://") && !strings.HasPrefix(startURL, "https://") {
+				startURL = "https://" + startURL
+			}
+		default:
+			ids = append(ids, fields[i])
+		}
+	}
+	if startURL != "" && !dryRun {
+		fmt.Println(ui.ColorRed + ui.IconCross + " --url используется только с --dry-run" + ui.ColorReset)
+		return
+	}
+
+	for _, idStr := range ids {
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			fmt.Printf(ui.ColorRed+ui.IconCross+" Неверный ID задачи: %s"+ui.ColorReset+"\n", idStr)
+			continue
+		}
+		task, err := h.repo.GetTaskByID(uint(id))
+		if err != nil {
+			fmt.Printf(ui.ColorRed+ui.IconCross+" Задача #%d не найдена"+ui.ColorReset+"\n", id)
+			continue
+		}
+		if dryRun {
+			h.start(ctx, task, "Пробный запуск", func(ctx context.Context) error {
+				return h.agent.DryRunTask(ctx, task, startURL)
+			}, h.reportDryRun)
+			continue
+		}
+		h.start(ctx, task, "Запуск", func(ctx context.Context) error {
+			return h.agent.ExecuteTask(ctx, task)
+		}, h.reportResult)
+	}
+}
+
+// Resume продолжает прерванную задачу с последнего checkpoint
+func (h *TaskHandler) Resume(ctx context.Context, idStr string) {
 	if h.agent == nil {
 		fmt.Println(ui.ColorRed + ui.IconCross + " Агент не инициализирован" + ui.ColorReset)
 		return
@@ -88,11 +223,64 @@ func (h *TaskHandler) Run(ctx context.Context, idStr string) {
 		fmt.Println(ui.ColorRed + ui.IconCross + " Задача не найдена" + ui.ColorReset)
 		return
 	}
-	fmt.Printf(ui.ColorCyan+ui.IconPlay+" Запуск задачи #%d:"+ui.ColorReset+" %s\n", task.ID, task.UserInput)
-	if err := h.agent.ExecuteTask(ctx, task); err != nil {
+	if task.Status == "completed" {
+		fmt.Println(ui.ColorYellow + ui.IconCheckmark + " Задача уже завершена" + ui.ColorReset)
+		return
+	}
+	h.start(ctx, task, "Возобновление", func(ctx context.Context) error {
+		return h.agent.ResumeTask(ctx, task)
+	}, h.reportResult)
+}
+
+// start запускает выполнение задачи в фоновой горутине, консоль остается доступной
+func (h *TaskHandler) start(ctx context.Context, task *database.Task, verb string, run func(ctx context.Context) error, report func(*database.Task, *agent.Job)) {
+	job, err := h.jobs.Start(ctx, task.ID, run, func(job *agent.Job) {
+		report(task, job)
+	})
+	if err != nil {
 		fmt.Printf(ui.ColorRed+ui.IconCross+" Ошибка:"+ui.ColorReset+" %v\n", err)
+		return
+	}
+	fmt.Printf(ui.ColorCyan+ui.IconPlay+" %s задачи #%d в фоне:"+ui.ColorReset+" %s\n", verb, task.ID, task.UserInput)
+	if job.Status() == agent.JobQueued {
+		fmt.Printf(ui.ColorGray+"  Все воркеры заняты (%d), задача поставлена в очередь"+ui.ColorReset+"\n", h.jobs.Workers())
+	}
+	fmt.Printf(ui.ColorGray+"  Вывод: attach %d, остановка: cancel %d, список: jobs"+ui.ColorReset+"\n", task.ID, task.ID)
+}
+
+// reportDryRun выводит итог пробного запуска. Статус задачи не меняется
+func (h *TaskHandler) reportDryRun(task *database.Task, job *agent.Job) {
+	switch job.Status() {
+	case agent.JobCompleted:
+		fmt.Fprintf(h.out, ui.ColorGreen+ui.IconCheckmark+" Пробный запуск задачи #%d завершен, план действий: show %d"+ui.ColorReset+"\n", task.ID, task.ID)
+	case agent.JobCanceled:
+		fmt.Fprintf(h.out, ui.ColorYellow+ui.IconPause+" Пробный запуск задачи #%d прерван"+ui.ColorReset+"\n", task.ID)
+	default:
+		fmt.Fprintf(h.out, ui.ColorRed+ui.IconCross+" Пробный запуск задачи #%d завершился ошибкой:"+ui.ColorReset+" %v\n", task.ID, job.Err())
+	}
+}
+
+// reportResult выводит итог выполнения фоновой задачи и обновляет статус при ошибке
+func (h *TaskHandler) reportResult(task *database.Task, job *agent.Job) {
+	err := job.Err()
+	if job.Status() == agent.JobCanceled && job.StartedAt().IsZero() {
+		// Задача снята с очереди до запуска - checkpoint нет, статус не меняем
+		fmt.Fprintf(h.out, ui.ColorYellow+ui.IconPause+" Задача #%d снята с очереди"+ui.ColorReset+"\n", task.ID)
+		return
+	}
+	if errors.Is(err, agent.ErrBudgetExceeded) {
+		// Статус budget_exceeded уже выставлен агентом
+		fmt.Fprintf(h.out, ui.ColorPurple+ui.IconPause+" Задача #%d остановлена:"+ui.ColorReset+" %v\n", task.ID, err)
+		return
+	}
+	switch job.Status() {
+	case agent.JobCompleted:
+		fmt.Fprintf(h.out, ui.ColorGreen+ui.IconCheckmark+" Задача #%d выполнена успешно!"+ui.ColorReset+"\n", task.ID)
+	case agent.JobCanceled:
+		fmt.Fprintf(h.out, ui.ColorYellow+ui.IconPause+" Задача #%d прервана, продолжить: resume %d"+ui.ColorReset+"\n", task.ID, task.ID)
+		h.repo.UpdateTaskStatus(task.ID, "interrupted", "")
+	default:
+		fmt.Fprintf(h.out, ui.ColorRed+ui.IconCross+" Задача #%d завершилась ошибкой:"+ui.ColorReset+" %v\n", task.ID, err)
 		h.repo.UpdateTaskStatus(task.ID, "failed", err.Error())
-	} else {
-		fmt.Println(ui.ColorGreen + ui.IconCheckmark + " Задача выполнена успешно!" + ui.ColorReset)
 	}
 }
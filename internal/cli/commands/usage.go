@@ -0,0 +1,39 @@
+package commands
+
+import (
+	"fmt"
+	"time"
+
+	"aiAgent/internal/agent"
+	"aiAgent/internal/cli/ui"
+)
+
+// UsageHandler показывает расход LLM относительно глобальных бюджетов
+type UsageHandler struct {
+	agent *agent.Agent
+}
+
+func NewUsageHandler(agent *agent.Agent) *UsageHandler {
+	return &UsageHandler{agent: agent}
+}
+
+// Show выводит расход токенов и стоимость за сутки и месяц
+func (h *UsageHandler) Show() {
+	if h.agent == nil {
+		fmt.Println(ui.ColorRed + ui.IconCross + " Агент не инициализирован" + ui.ColorReset)
+		return
+	}
+	daily, monthly, err := h.agent.GlobalUsage(time.Now())
+	if err != nil {
+		fmt.Printf(ui.ColorRed+ui.IconCross+" Ошибка:"+ui.ColorReset+" %v\n", err)
+		return
+	}
+	limits := h.agent.BudgetLimits()
+
+	fmt.Println("\n" + ui.ColorBold + ui.IconChart + " Расход LLM:" + ui.ColorReset)
+	fmt.Printf("  За сутки: %d токенов, ~$%.4f "+ui.ColorGray+"(лимит: %s)"+ui.ColorReset+"\n",
+		daily.Tokens, daily.Cost, ui.FormatBudget(limits.DailyTokens, limits.DailyCost))
+	fmt.Printf("  За месяц: %d токенов, ~$%.4f "+ui.ColorGray+"(лимит: %s)"+ui.ColorReset+"\n",
+		monthly.Tokens, monthly.Cost, ui.FormatBudget(limits.MonthlyTokens, limits.MonthlyCost))
+	fmt.Println()
+}
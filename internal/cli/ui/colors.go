@@ -31,4 +31,5 @@ const (
 	IconTime      = "🕐"
 	IconChat      = "💬"
 	IconLoop      = "🔄"
+	IconPause     = "⏸"
 )
@@ -13,11 +13,34 @@ func FormatStatus(status string) (icon, color, text string) {
 		return IconPlay, ColorCyan, "выполняется"
 	case "pending":
 		return IconClock, ColorYellow, "ожидает"
+	case "interrupted":
+		return IconPause, ColorYellow, "прервана"
+	case "budget_exceeded":
+		return IconPause, ColorPurple, "бюджет исчерпан"
+	case "queued":
+		return IconClock, ColorGray, "в очереди"
+	case "canceled":
+		return IconPause, ColorYellow, "отменена"
+	case "replaced":
+		return IconLoop, ColorGray, "заменена"
 	default:
 		return IconClock, ColorYellow, status
 	}
 }
 
+// FormatBudget форматирует лимиты токенов и стоимости (0 - без лимита)
+func FormatBudget(tokens int64, cost float64) string {
+	tokensText := "∞"
+	if tokens > 0 {
+		tokensText = fmt.Sprintf("%d", tokens)
+	}
+	costText := "∞"
+	if cost > 0 {
+		costText = fmt.Sprintf("$%.2f", cost)
+	}
+	return fmt.Sprintf("%s токенов, %s", tokensText, costText)
+}
+
 // ClearScreen очищает терминал
 func ClearScreen() {
 	fmt.Print("\033[H\033[2J")
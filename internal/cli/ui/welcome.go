@@ -25,13 +25,37 @@ func PrintWelcome() {
 // PrintHelp выводит список доступных команд
 func PrintHelp() {
 	fmt.Println(ColorYellow + IconList + " Доступные команды:" + ColorReset)
-	fmt.Println("  " + ColorGreen + "task" + ColorReset + " <текст>        - Создать новую задачу")
+	fmt.Println("  " + ColorGreen + "task" + ColorReset + " [--tokens N] [--cost USD] [--schema файл] <текст> [--criteria к1; к2] - Создать задачу")
 	fmt.Println("  " + ColorGreen + "tasks" + ColorReset + "               - Список всех задач")
-	fmt.Println("  " + ColorGreen + "run" + ColorReset + " <id>            - Выполнить задачу")
+	fmt.Println("  " + ColorGreen + "run" + ColorReset + " <id> [id...]    - Выполнить задачи в фоне")
+	fmt.Println("  " + ColorGreen + "run" + ColorReset + " --dry-run <id> [--url адрес] - Пробный запуск: план действий без выполнения")
+	fmt.Println("  " + ColorGreen + "resume" + ColorReset + " <id>         - Продолжить прерванную задачу")
+	fmt.Println("  " + ColorGreen + "usage" + ColorReset + "               - Расход LLM за сутки и месяц")
+	fmt.Println("  " + ColorGreen + "health" + ColorReset + "              - Состояние предохранителей сайтов и LLM")
+	fmt.Println("  " + ColorGreen + "jobs" + ColorReset + "                - Фоновые задачи текущей сессии")
+	fmt.Println("  " + ColorGreen + "attach" + ColorReset + " <id>         - Смотреть вывод фоновой задачи")
+	fmt.Println("  " + ColorGreen + "answer" + ColorReset + " <id> <текст>  - Ответить на вопрос задачи")
+	fmt.Println("  " + ColorGreen + "cancel" + ColorReset + " <id>         - Остановить фоновую задачу")
+	fmt.Println("  " + ColorGreen + "takeover" + ColorReset + " <id>       - Взять управление браузером задачи (answer <id> - вернуть)")
+	fmt.Println("  " + ColorGreen + "schedule add" + ColorReset + " <id> <cron> - Запускать задачу по расписанию")
+	fmt.Println("  " + ColorGreen + "schedule list" + ColorReset + "       - Список расписаний")
+	fmt.Println("  " + ColorGreen + "schedule remove" + ColorReset + " <id> - Удалить расписание")
+	fmt.Println("  " + ColorGreen + "schedule enable|disable" + ColorReset + " <id> - Включить/выключить расписание")
+	fmt.Println("  " + ColorGreen + "memory paths" + ColorReset + " [домен] - Успешные пути из памяти агента")
+	fmt.Println("  " + ColorGreen + "memory path" + ColorReset + " <id>    - Шаги успешного пути")
+	fmt.Println("  " + ColorGreen + "memory failures" + ColorReset + "     - Паттерны ошибок по частоте")
+	fmt.Println("  " + ColorGreen + "memory sites" + ColorReset + "        - Знания о сайтах")
+	fmt.Println("  " + ColorGreen + "memory delete" + ColorReset + " path|failure <id>|site <домен> - Удалить запись памяти")
+	fmt.Println("  " + ColorGreen + "memory pin|unpin" + ColorReset + " path|failure <id>|site <домен> - Закрепить запись (не устаревает)")
+	fmt.Println("  " + ColorGreen + "memory export" + ColorReset + " [файл] - Выгрузить память в JSON (по умолчанию memory.json)")
+	fmt.Println("  " + ColorGreen + "memory import" + ColorReset + " <файл> - Загрузить память из JSON и объединить с текущей")
 	fmt.Println("  " + ColorGreen + "status" + ColorReset + " <id>         - Статус задачи")
 	fmt.Println("  " + ColorGreen + "show" + ColorReset + " <id>           - Детали задачи")
+	fmt.Println("  " + ColorGreen + "show" + ColorReset + " <id> --json    - Задача и структурированный результат в JSON")
+	fmt.Println("  " + ColorGreen + "export" + ColorReset + " <id> [файл]  - Сохранить результат задачи в JSON файл")
 	fmt.Println("  " + ColorGreen + "logs" + ColorReset + " <id>           - LLM логи задачи")
 	fmt.Println("  " + ColorGreen + "test-llm" + ColorReset + " <задача>   - Тест планирования LLM")
+	fmt.Println("  " + ColorGreen + "test-llm" + ColorReset + " --dry-run <задача> - Пробный запуск на текущей странице")
 	fmt.Println("  " + ColorGreen + "open" + ColorReset + " <url>          - Открыть URL в браузере")
 	fmt.Println("  " + ColorGreen + "open-persistent" + ColorReset + "     - Открыть браузер для ручной настройки")
 	fmt.Println("  " + ColorGreen + "clear" + ColorReset + "               - Очистить экран")
@@ -1,45 +1,102 @@
 package cli
 
 import (
-	"bufio"
 	"context"
 	"fmt"
+	"io"
 	"os"
-	"strings"
+	"sync"
+
+	"aiAgent/internal/llm"
 )
 
+// pendingQuestion - вопрос агента, ожидающий ответа в консоли.
+type pendingQuestion struct {
+	taskID   uint // Задача, которая спрашивает (0 - вне задачи)
+	question string
+	answer   chan string
+}
+
+// userInputProvider передает вопросы агента в консоль.
+// Задачи выполняются в фоне, и вопросы нескольких задач могут ждать одновременно, поэтому
+// ответ всегда адресован задаче: командой "answer <id> <текст>" или вводом при attach к задаче.
 type userInputProvider struct {
-	reader *bufio.Reader
+	mu      sync.Mutex
+	out     io.Writer
+	pending []*pendingQuestion
 }
 
 func NewUserInputProvider() *userInputProvider {
 	return &userInputProvider{
-		reader: bufio.NewReader(os.Stdin),
+		out: os.Stdout,
 	}
 }
 
-func (p *userInputProvider) AskUser(ctx context.Context, question string) (string, error) {
-	fmt.Printf("\n[Агент спрашивает] %s\n", question)
-	fmt.Print("Ваш ответ: ")
+// SetOutput задает writer для вывода вопросов (например, stdout readline).
+func (p *userInputProvider) SetOutput(w io.Writer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.out = w
+}
 
-	answerChan := make(chan string, 1)
-	errChan := make(chan error, 1)
+func (p *userInputProvider) AskUser(ctx context.Context, question string) (string, error) {
+	q := &pendingQuestion{
+		question: question,
+		answer:   make(chan string, 1),
+	}
+	if taskID := llm.TaskIDFromContext(ctx); taskID != nil {
+		q.taskID = *taskID
+	}
 
-	go func() {
-		answer, err := p.reader.ReadString('\n')
-		if err != nil {
-			errChan <- err
-			return
-		}
-		answerChan <- strings.TrimSpace(answer)
-	}()
+	p.mu.Lock()
+	p.pending = append(p.pending, q)
+	fmt.Fprintf(p.out, "\n[Задача #%d спрашивает] %s\n", q.taskID, question)
+	fmt.Fprintf(p.out, "Ответ: answer %d <текст> (или attach %d и ввод ответа)\n", q.taskID, q.taskID)
+	p.mu.Unlock()
 
 	select {
 	case <-ctx.Done():
+		p.remove(q)
 		return "", ctx.Err()
-	case err := <-errChan:
-		return "", err
-	case answer := <-answerChan:
+	case answer := <-q.answer:
 		return answer, nil
 	}
 }
+
+// HasPending возвращает true если задача ждет ответа пользователя.
+func (p *userInputProvider) HasPending(taskID uint) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, q := range p.pending {
+		if q.taskID == taskID {
+			return true
+		}
+	}
+	return false
+}
+
+// Answer передает строку как ответ на самый ранний вопрос задачи taskID.
+// Возвращает false если задача не ждет ответа.
+func (p *userInputProvider) Answer(taskID uint, line string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, q := range p.pending {
+		if q.taskID == taskID {
+			p.pending = append(p.pending[:i], p.pending[i+1:]...)
+			q.answer <- line
+			return true
+		}
+	}
+	return false
+}
+
+func (p *userInputProvider) remove(q *pendingQuestion) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, pq := range p.pending {
+		if pq == q {
+			p.pending = append(p.pending[:i], p.pending[i+1:]...)
+			return
+		}
+	}
+}
@@ -18,6 +18,7 @@ type Cfg struct {
 	Logger     Logger     // Конфигурация логирования
 	OpenAI     OpenAI     // Конфигурация OpenAI API
 	Browser    Browser    // Конфигурация браузера
+	Agent      Agent      // Конфигурация выполнения задач
 	Migrations Migrations // Конфигурация миграций БД
 }
 
@@ -56,6 +57,21 @@ type Browser struct {
 	BrowsersPath string // Путь к браузерам Playwright
 }
 
+// Agent содержит параметры выполнения задач агентом.
+type Agent struct {
+	Workers            int               // Количество задач, выполняемых параллельно (каждая в своем контексте браузера)
+	DailyTokenBudget   int64             // Лимит токенов в сутки на все задачи (0 - без лимита)
+	DailyCostBudget    float64           // Лимит стоимости в сутки, USD (0 - без лимита)
+	MonthlyTokenBudget int64             // Лимит токенов в месяц (0 - без лимита)
+	MonthlyCostBudget  float64           // Лимит стоимости в месяц, USD (0 - без лимита)
+	Subgoals           bool              // Разбивать задачу на дерево подзадач с отдельными бюджетами шагов
+	SpecsDir           string            // Каталог YAML/JSON описаний специализированных агентов
+	LLMRouting         bool              // Выбирать специализированного агента с помощью LLM
+	MemoryTTLDays      int               // Срок хранения записей памяти агента с последнего использования, дней
+	MemoryEmbedder     string            // Эмбеддер для поиска по памяти: local (без внешних запросов) или openai
+	RetryPolicies      map[string]string // Правила повторов AGENT_RETRY_<ОБЛАСТЬ>: область (действие, llm, default) -> правило
+}
+
 // Load загружает конфигурацию из файла .env и переменных окружения.
 // Автоматически валидирует все обязательные параметры.
 // Возвращает ошибку если конфигурация невалидна.
@@ -85,6 +101,19 @@ func Load() (*Cfg, error) {
 			UserDataDir:  env("PW_USER_DATA_DIR", "./userdata"),
 			BrowsersPath: env("PLAYWRIGHT_BROWSERS_PATH", ""),
 		},
+		Agent: Agent{
+			Workers:            envInt("AGENT_WORKERS", 2),
+			DailyTokenBudget:   int64(envInt("BUDGET_DAILY_TOKENS", 0)),
+			DailyCostBudget:    envFloat("BUDGET_DAILY_COST", 0),
+			MonthlyTokenBudget: int64(envInt("BUDGET_MONTHLY_TOKENS", 0)),
+			MonthlyCostBudget:  envFloat("BUDGET_MONTHLY_COST", 0),
+			Subgoals:           envBool("AGENT_SUBGOALS"),
+			SpecsDir:           env("AGENT_SPECS_DIR", "./agents"),
+			LLMRouting:         envBool("AGENT_LLM_ROUTING"),
+			MemoryTTLDays:      envInt("AGENT_MEMORY_TTL_DAYS", 30),
+			MemoryEmbedder:     env("AGENT_MEMORY_EMBEDDER", "local"),
+			RetryPolicies:      envPrefix("AGENT_RETRY_"),
+		},
 		Migrations: Migrations{
 			Path: env("MIGRATIONS_PATH", "file://internal/migrations/scripts"),
 		},
@@ -152,6 +181,24 @@ func (c *Cfg) Validate() error {
 		}
 	}
 
+	// Проверка Agent
+	if c.Agent.Workers < 1 || c.Agent.Workers > 32 {
+		errors = append(errors, "AGENT_WORKERS должен быть от 1 до 32")
+	}
+
+	if c.Agent.MemoryTTLDays < 1 {
+		errors = append(errors, "AGENT_MEMORY_TTL_DAYS должен быть не меньше 1")
+	}
+
+	if c.Agent.MemoryEmbedder != "local" && c.Agent.MemoryEmbedder != "openai" {
+		errors = append(errors, "AGENT_MEMORY_EMBEDDER должен быть local или openai")
+	}
+
+	if c.Agent.DailyTokenBudget < 0 || c.Agent.MonthlyTokenBudget < 0 ||
+		c.Agent.DailyCostBudget < 0 || c.Agent.MonthlyCostBudget < 0 {
+		errors = append(errors, "BUDGET_* не могут быть отрицательными")
+	}
+
 	// Проверка Migrations
 	if c.Migrations.Path == "" {
 		errors = append(errors, "MIGRATIONS_PATH обязателен")
@@ -180,6 +227,28 @@ func envInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+func envFloat(key string, defaultValue float64) float64 {
+	if v := os.Getenv(key); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return f
+		}
+	}
+	return defaultValue
+}
+
+// envPrefix возвращает переменные с префиксом: ключ - остаток имени в нижнем регистре
+// (AGENT_RETRY_EXTRACT_INFO -> extract_info).
+func envPrefix(prefix string) map[string]string {
+	values := make(map[string]string)
+	for _, kv := range os.Environ() {
+		key, value, _ := strings.Cut(kv, "=")
+		if name, ok := strings.CutPrefix(key, prefix); ok && name != "" && value != "" {
+			values[strings.ToLower(name)] = value
+		}
+	}
+	return values
+}
+
 func envBool(key string) bool {
 	v := strings.ToLower(os.Getenv(key))
 	return v == "true" || v == "1" || v == "yes"
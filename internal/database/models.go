@@ -5,14 +5,22 @@ package database
 import "time"
 
 // Task представляет задачу для выполнения агентом.
-// Статусы: pending, running, completed, failed.
+// Статусы: pending, running, completed, failed, interrupted, budget_exceeded.
 type Task struct {
-	ID            uint      `gorm:"primaryKey"`
-	UserInput     string    `gorm:"type:text;not null"`           // Текст задачи от пользователя
-	Status        string    `gorm:"type:varchar(32);not null;default:'pending'"` // Статус выполнения
-	ResultSummary string    `gorm:"type:text"`                    // Итоговый результат выполнения
-	CreatedAt     time.Time `gorm:"autoCreateTime"`
-	UpdatedAt     time.Time `gorm:"autoUpdateTime"`
+	ID              uint      `gorm:"primaryKey"`
+	UserInput       string    `gorm:"type:text;not null"`                          // Текст задачи от пользователя
+	Status          string    `gorm:"type:varchar(32);not null;default:'pending'"` // Статус выполнения
+	ResultSummary   string    `gorm:"type:text"`                                   // Итоговый результат выполнения
+	ScheduleID      *uint     `gorm:"index"`                                       // Расписание, создавшее этот запуск (nil для ручных задач)
+	TokenBudget     int       `gorm:"not null;default:0"`                          // Лимит токенов на задачу (0 - без лимита)
+	CostBudget      float64   `gorm:"type:numeric(12,4);not null;default:0"`       // Лимит стоимости в USD (0 - без лимита)
+	SuccessCriteria string    `gorm:"type:text"`                                   // Критерии успеха, по одному на строку (заданы пользователем или сформулированы LLM)
+	OutputSchema    string    `gorm:"type:jsonb;default:null"`                     // JSON схема структурированного результата (опционально)
+	Result          string    `gorm:"type:jsonb;default:null"`                     // Структурированный результат: записи, извлеченные по OutputSchema
+	RoutedAgent     string    `gorm:"type:varchar(64)"`                            // Специализированный агент, выбранный роутером
+	Routing         string    `gorm:"type:jsonb;default:null"`                     // Решение маршрутизации: способ, уверенность, обоснование, кандидаты
+	CreatedAt       time.Time `gorm:"autoCreateTime"`
+	UpdatedAt       time.Time `gorm:"autoUpdateTime"`
 }
 
 // AgentStep представляет один шаг выполнения задачи.
@@ -26,6 +34,14 @@ type AgentStep struct {
 	Reasoning      string    `gorm:"type:text"`                    // Обоснование действия от LLM
 	Result         string    `gorm:"type:text"`                    // Результат выполнения шага
 	ScreenshotPath string    `gorm:"type:text"`                    // Путь к скриншоту (если есть)
+	Verdict        string    `gorm:"type:varchar(32)"`             // Вердикт рефлексии (success, no_effect, wrong_target, error)
+	VerdictReason  string    `gorm:"type:text"`                    // Причина вердикта рефлексии
+	LoopKind       string    `gorm:"type:varchar(32)"`             // Тип обнаруженного зацикливания (repeated_action, oscillation, no_progress)
+	LoopEscalation string    `gorm:"type:varchar(16)"`             // Реакция на зацикливание (observe, replan, ask_user)
+	LoopDetail     string    `gorm:"type:text"`                    // Описание зацикливания
+	DryRun         bool      `gorm:"not null;default:false"`       // Шаг пробного запуска: действие только симулировано
+	Attempts       int       `gorm:"not null;default:0"`           // Попыток действия с учетом повторов (0 - действие не выполнялось)
+	AttemptLog     string    `gorm:"type:jsonb;default:null"`      // Попытки действия и обращений к LLM с задержками ([]agent.RetryAttempt)
 	CreatedAt      time.Time `gorm:"autoCreateTime"`
 }
 
@@ -40,5 +56,124 @@ type LlmLog struct {
 	ResponseText string `gorm:"type:text"`                   // Текст ответа
 	Model        string `gorm:"type:varchar(64)"`            // Модель (gpt-4o)
 	TokensUsed   int                                         // Количество токенов
+	PromptTokens     int `gorm:"not null;default:0"`           // Входные токены (промпт)
+	CompletionTokens int `gorm:"not null;default:0"`           // Выходные токены (ответ)
 	CreatedAt    time.Time `gorm:"autoCreateTime"`
 }
+
+// ModelUsage - суммарный расход токенов по одной модели (агрегат по llm_logs).
+type ModelUsage struct {
+	Model            string
+	Tokens           int64
+	PromptTokens     int64
+	CompletionTokens int64
+}
+
+// TaskCheckpoint хранит состояние выполнения задачи после последнего завершенного шага.
+// Используется для возобновления задачи, прерванной падением процесса или Ctrl+C.
+type TaskCheckpoint struct {
+	TaskID           uint      `gorm:"primaryKey;autoIncrement:false"` // ID задачи
+	StepNo           int       `gorm:"not null"`                       // Номер последнего завершенного шага
+	ReasoningHistory string    `gorm:"type:jsonb"`                     // История рассуждений (llm.ReasoningHistory)
+	Transcript       string    `gorm:"type:jsonb"`                     // Транскрипт действий (llm.ActionTranscript)
+	LastURL          string    `gorm:"type:text"`                      // URL страницы после последнего шага
+	StorageState     string    `gorm:"type:jsonb"`                     // Cookies и localStorage браузера
+	UpdatedAt        time.Time `gorm:"autoUpdateTime"`
+}
+
+// TaskSchedule хранит cron-расписание задачи.
+// При каждом срабатывании планировщик создает новую задачу с тем же текстом (отдельный запуск).
+type TaskSchedule struct {
+	ID         uint       `gorm:"primaryKey"`
+	TaskID     uint       `gorm:"index;not null"`                // Задача-шаблон
+	CronExpr   string     `gorm:"type:varchar(128);not null"`    // Cron выражение (минута час день месяц день_недели)
+	Enabled    bool       `gorm:"not null;default:true"`         // Расписание активно
+	NextRunAt  time.Time  `gorm:"not null"`                      // Время следующего запуска
+	LastRunAt  *time.Time                                        // Время последнего запуска
+	LastTaskID *uint                                             // Задача, созданная последним запуском
+	LastStatus string     `gorm:"type:varchar(32)"`              // Итог последнего запуска (completed, failed, interrupted, skipped)
+	LastError  string     `gorm:"type:text"`                     // Ошибка последнего запуска
+	CreatedAt  time.Time  `gorm:"autoCreateTime"`
+	UpdatedAt  time.Time  `gorm:"autoUpdateTime"`
+}
+
+// TaskSubgoal - подзадача в дереве декомпозиции задачи.
+// Листовые подзадачи выполняются по порядку, каждая со своим бюджетом шагов и проверкой завершения;
+// подзадача с дочерними выполнена, когда выполнены все дочерние.
+// Статусы: pending, running, completed, failed, replaced (заменена при перепланировании).
+type TaskSubgoal struct {
+	ID           uint      `gorm:"primaryKey"`
+	TaskID       uint      `gorm:"index;not null"`                              // ID задачи
+	ParentID     *uint     `gorm:"index"`                                       // Родительская подзадача (nil для верхнего уровня)
+	Position     int       `gorm:"not null"`                                    // Порядок выполнения в пределах задачи
+	Revision     int       `gorm:"not null;default:0"`                          // Номер перепланирования, создавшего подзадачу
+	Title        string    `gorm:"type:text;not null"`                          // Формулировка подзадачи
+	SuccessCheck string    `gorm:"type:text"`                                   // Проверка завершения подзадачи
+	StepBudget   int       `gorm:"not null;default:0"`                          // Бюджет шагов
+	StepsUsed    int       `gorm:"not null;default:0"`                          // Израсходовано шагов
+	Status       string    `gorm:"type:varchar(32);not null;default:'pending'"` // Статус выполнения
+	Result       string    `gorm:"type:text"`                                   // Итог или причина неудачи
+	CreatedAt    time.Time `gorm:"autoCreateTime"`
+	UpdatedAt    time.Time `gorm:"autoUpdateTime"`
+}
+
+// TaskAttempt - попытка выполнения задачи одним агентом из цепочки fallback.
+// Если специализированный агент не справился, задача передается следующему кандидату роутера
+// и в конце - общему агенту (general); шаги попыток нумеруются подряд.
+// Статусы: running, completed, failed, interrupted (отменена, продолжается через resume).
+type TaskAttempt struct {
+	ID         uint       `gorm:"primaryKey"`
+	TaskID     uint       `gorm:"index;not null"`                              // ID задачи
+	AttemptNo  int        `gorm:"not null"`                                    // Порядковый номер попытки
+	Agent      string     `gorm:"type:varchar(64);not null"`                   // Тип агента (general - общий цикл шагов)
+	FirstStep  int        `gorm:"not null"`                                    // Номер первого шага попытки
+	LastStep   int        `gorm:"not null;default:0"`                          // Номер последнего выполненного шага (0 - шагов не было)
+	Status     string     `gorm:"type:varchar(32);not null;default:'running'"` // Статус попытки
+	Error      string     `gorm:"type:text"`                                   // Причина неудачи
+	StartedAt  time.Time  `gorm:"not null"`
+	FinishedAt *time.Time
+}
+
+// MemoryPath - успешный путь выполнения задачи в памяти агента.
+// Путь, не использованный до ExpiresAt, считается устаревшим (сайт мог измениться) и удаляется.
+type MemoryPath struct {
+	ID           uint       `gorm:"primaryKey"`
+	TaskHash     string     `gorm:"type:varchar(64);index;not null"` // SHA-256 нормализованного текста задачи
+	Task         string     `gorm:"type:text"`                       // Текст задачи
+	Domain       string     `gorm:"type:varchar(255);index"`         // Домен, на котором задача завершилась
+	Steps        string     `gorm:"type:jsonb;not null"`             // Шаги пути ([]llm.StepPlan)
+	Strategy     string     `gorm:"type:text"`                       // Стратегия из последнего рассуждения
+	Embedding    string     `gorm:"type:jsonb;default:null"`         // Вектор текста задачи ([]float32)
+	SuccessCount int        `gorm:"not null;default:1"`              // Сколько раз путь привел к успеху
+	AverageMs    int64      `gorm:"not null;default:0"`              // Среднее время выполнения, мс
+	Confidence   float64    `gorm:"not null;default:1"`              // Уверенность, что путь подходит к текущей верстке (0..1)
+	LastMatch    float64    `gorm:"not null;default:0"`              // Доля шагов, совпавших со страницей при последнем воспроизведении
+	LastUsed     time.Time  `gorm:"not null"`
+	ExpiresAt    *time.Time `gorm:"index"` // Срок хранения (nil - бессрочно)
+	CreatedAt    time.Time  `gorm:"autoCreateTime"`
+	UpdatedAt    time.Time  `gorm:"autoUpdateTime"`
+}
+
+// MemoryFailure - повторяющаяся ошибка действия и способ ее обхода.
+type MemoryFailure struct {
+	ID        uint       `gorm:"primaryKey"`
+	ErrorType string     `gorm:"type:varchar(32);not null"` // Класс ошибки (timeout, element_not_found, ...)
+	Action    string     `gorm:"type:varchar(64);not null"` // Действие
+	Selector  string     `gorm:"type:text;not null"`        // Селектор (пустая строка - без селектора)
+	Count     int        `gorm:"not null;default:1"`        // Сколько раз ошибка повторялась
+	Recovery  string     `gorm:"type:text"`                 // Что помогло обойти ошибку
+	Task      string     `gorm:"type:text;not null"`        // Задача, при которой ошибка случилась последний раз
+	Embedding string     `gorm:"type:jsonb;default:null"`   // Вектор задачи и описания ошибки ([]float32)
+	LastSeen  time.Time  `gorm:"not null"`
+	ExpiresAt *time.Time `gorm:"index"` // Срок хранения (nil - бессрочно)
+}
+
+// MemorySite - знания агента о сайте: типичные селекторы и структура форм.
+type MemorySite struct {
+	Domain         string     `gorm:"type:varchar(255);primaryKey"`
+	CommonPatterns string     `gorm:"type:jsonb;default:null"` // Назначение -> селектор (map[string]string)
+	FormStructure  string     `gorm:"type:jsonb;default:null"` // Поля форм ([]string)
+	Embedding      string     `gorm:"type:jsonb;default:null"` // Вектор описания сайта ([]float32)
+	LastVisited    time.Time  `gorm:"not null"`
+	ExpiresAt      *time.Time `gorm:"index"` // Срок хранения (nil - бессрочно)
+}
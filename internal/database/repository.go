@@ -2,8 +2,10 @@ package database
 
 import (
 	"context"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // TaskRepository предоставляет методы для работы с задачами и логами.
@@ -55,19 +57,69 @@ func (r *TaskRepository) UpdateTaskStatus(id uint, status, summary string) error
 		}).Error
 }
 
-func (r *TaskRepository) LogLLMRequest(ctx context.Context, taskID *uint, stepID *uint, role, promptText, responseText, model string, tokensUsed int) error {
+// UpdateTaskCriteria сохраняет критерии успеха задачи.
+func (r *TaskRepository) UpdateTaskCriteria(id uint, criteria string) error {
+	return r.db.Model(&Task{}).Where("id = ?", id).Update("success_criteria", criteria).Error
+}
+
+// UpdateTaskResult сохраняет структурированный результат задачи (JSON).
+func (r *TaskRepository) UpdateTaskResult(id uint, result string) error {
+	return r.db.Model(&Task{}).Where("id = ?", id).Update("result", result).Error
+}
+
+// UpdateTaskRouting сохраняет выбранного агента и решение маршрутизации (JSON).
+func (r *TaskRepository) UpdateTaskRouting(id uint, agent, routing string) error {
+	return r.db.Model(&Task{}).
+		Where("id = ?", id).
+		Updates(map[string]any{
+			"routed_agent": agent,
+			"routing":      routing,
+		}).Error
+}
+
+func (r *TaskRepository) LogLLMRequest(ctx context.Context, taskID *uint, stepID *uint, role, promptText, responseText, model string, promptTokens, completionTokens int) error {
 	log := &LlmLog{
-		TaskID:       taskID,
-		StepID:       stepID,
-		Role:         role,
-		PromptText:   promptText,
-		ResponseText: responseText,
-		Model:        model,
-		TokensUsed:   tokensUsed,
+		TaskID:           taskID,
+		StepID:           stepID,
+		Role:             role,
+		PromptText:       promptText,
+		ResponseText:     responseText,
+		Model:            model,
+		TokensUsed:       promptTokens + completionTokens,
+		PromptTokens:     promptTokens,
+		CompletionTokens: completionTokens,
 	}
 	return r.db.WithContext(ctx).Create(log).Error
 }
 
+// TaskTokenUsage возвращает расход токенов задачи по моделям.
+func (r *TaskRepository) TaskTokenUsage(taskID uint) ([]ModelUsage, error) {
+	var usage []ModelUsage
+	if err := r.db.Model(&LlmLog{}).
+		Select("COALESCE(model, '') AS model, COALESCE(SUM(tokens_used), 0) AS tokens, "+
+			"COALESCE(SUM(prompt_tokens), 0) AS prompt_tokens, COALESCE(SUM(completion_tokens), 0) AS completion_tokens").
+		Where("task_id = ?", taskID).
+		Group("model").
+		Scan(&usage).Error; err != nil {
+		return nil, err
+	}
+	return usage, nil
+}
+
+// TokenUsageSince возвращает расход токенов всех задач по моделям начиная с since.
+func (r *TaskRepository) TokenUsageSince(since time.Time) ([]ModelUsage, error) {
+	var usage []ModelUsage
+	if err := r.db.Model(&LlmLog{}).
+		Select("COALESCE(model, '') AS model, COALESCE(SUM(tokens_used), 0) AS tokens, "+
+			"COALESCE(SUM(prompt_tokens), 0) AS prompt_tokens, COALESCE(SUM(completion_tokens), 0) AS completion_tokens").
+		Where("created_at >= ?", since).
+		Group("model").
+		Scan(&usage).Error; err != nil {
+		return nil, err
+	}
+	return usage, nil
+}
+
 func (r *TaskRepository) CreateStep(step *AgentStep) error {
 	return r.db.Create(step).Error
 }
@@ -79,3 +131,277 @@ func (r *TaskRepository) GetStepsByTaskID(taskID uint) ([]AgentStep, error) {
 	}
 	return steps, nil
 }
+
+// MarkRunningTasksInterrupted переводит задачи, оставшиеся в статусе running после
+// прошлого запуска приложения, в статус interrupted. Возвращает количество таких задач.
+func (r *TaskRepository) MarkRunningTasksInterrupted() (int64, error) {
+	res := r.db.Model(&Task{}).
+		Where("status = ?", "running").
+		Update("status", "interrupted")
+	return res.RowsAffected, res.Error
+}
+
+func (r *TaskRepository) SaveCheckpoint(cp *TaskCheckpoint) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "task_id"}},
+		UpdateAll: true,
+	}).Create(cp).Error
+}
+
+func (r *TaskRepository) GetCheckpoint(taskID uint) (*TaskCheckpoint, error) {
+	var cp TaskCheckpoint
+	if err := r.db.Where("task_id = ?", taskID).First(&cp).Error; err != nil {
+		return nil, err
+	}
+	return &cp, nil
+}
+
+func (r *TaskRepository) DeleteCheckpoint(taskID uint) error {
+	return r.db.Where("task_id = ?", taskID).Delete(&TaskCheckpoint{}).Error
+}
+
+func (r *TaskRepository) CreateSchedule(s *TaskSchedule) error {
+	return r.db.Create(s).Error
+}
+
+func (r *TaskRepository) ListSchedules() ([]TaskSchedule, error) {
+	var schedules []TaskSchedule
+	if err := r.db.Order("id ASC").Find(&schedules).Error; err != nil {
+		return nil, err
+	}
+	return schedules, nil
+}
+
+func (r *TaskRepository) GetSchedule(id uint) (*TaskSchedule, error) {
+	var s TaskSchedule
+	if err := r.db.First(&s, id).Error; err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// DeleteSchedule удаляет расписание. Возвращает gorm.ErrRecordNotFound если расписания нет.
+func (r *TaskRepository) DeleteSchedule(id uint) error {
+	res := r.db.Delete(&TaskSchedule{}, id)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// SetScheduleEnabled включает или выключает расписание, при включении задавая время следующего запуска.
+func (r *TaskRepository) SetScheduleEnabled(id uint, enabled bool, nextRunAt time.Time) error {
+	updates := map[string]any{"enabled": enabled}
+	if enabled {
+		updates["next_run_at"] = nextRunAt
+	}
+	res := r.db.Model(&TaskSchedule{}).Where("id = ?", id).Updates(updates)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// DueSchedules возвращает активные расписания, время запуска которых наступило.
+func (r *TaskRepository) DueSchedules(now time.Time) ([]TaskSchedule, error) {
+	var schedules []TaskSchedule
+	if err := r.db.Where("enabled = ? AND next_run_at <= ?", true, now).
+		Order("next_run_at ASC").
+		Find(&schedules).Error; err != nil {
+		return nil, err
+	}
+	return schedules, nil
+}
+
+// MarkScheduleRun фиксирует срабатывание расписания и время следующего запуска.
+// lastTaskID - созданная задача (nil если запуск пропущен).
+func (r *TaskRepository) MarkScheduleRun(id uint, runAt, nextRunAt time.Time, lastTaskID *uint, status, errMsg string) error {
+	updates := map[string]any{
+		"next_run_at": nextRunAt,
+		"last_run_at": runAt,
+		"last_status": status,
+		"last_error":  errMsg,
+	}
+	if lastTaskID != nil {
+		updates["last_task_id"] = *lastTaskID
+	}
+	return r.db.Model(&TaskSchedule{}).Where("id = ?", id).Updates(updates).Error
+}
+
+// UpdateScheduleOutcome сохраняет итог запуска, созданного расписанием.
+func (r *TaskRepository) UpdateScheduleOutcome(id uint, status, errMsg string) error {
+	return r.db.Model(&TaskSchedule{}).
+		Where("id = ?", id).
+		Updates(map[string]any{
+			"last_status": status,
+			"last_error":  errMsg,
+		}).Error
+}
+
+// CreateSubgoals сохраняет подзадачи. Дочерние подзадачи сохраняются отдельным вызовом
+// после родительской, чтобы ParentID указывал на сохраненную запись.
+func (r *TaskRepository) CreateSubgoals(subgoals []*TaskSubgoal) error {
+	if len(subgoals) == 0 {
+		return nil
+	}
+	return r.db.Create(subgoals).Error
+}
+
+// ListSubgoals возвращает подзадачи задачи в порядке выполнения.
+func (r *TaskRepository) ListSubgoals(taskID uint) ([]TaskSubgoal, error) {
+	var subgoals []TaskSubgoal
+	if err := r.db.Where("task_id = ?", taskID).Order("position ASC, id ASC").Find(&subgoals).Error; err != nil {
+		return nil, err
+	}
+	return subgoals, nil
+}
+
+// UpdateSubgoal сохраняет статус, расход шагов и итог подзадачи.
+func (r *TaskRepository) UpdateSubgoal(s *TaskSubgoal) error {
+	return r.db.Model(&TaskSubgoal{}).
+		Where("id = ?", s.ID).
+		Updates(map[string]any{
+			"status":     s.Status,
+			"steps_used": s.StepsUsed,
+			"result":     s.Result,
+		}).Error
+}
+
+// DeleteSubgoals удаляет дерево подзадач задачи (перед новым запуском).
+func (r *TaskRepository) DeleteSubgoals(taskID uint) error {
+	return r.db.Where("task_id = ?", taskID).Delete(&TaskSubgoal{}).Error
+}
+
+// ReplaceSubgoals помечает невыполненные подзадачи задачи как замененные при перепланировании.
+func (r *TaskRepository) ReplaceSubgoals(taskID uint) error {
+	return r.db.Model(&TaskSubgoal{}).
+		Where("task_id = ? AND status IN ?", taskID, []string{"pending", "running"}).
+		Update("status", "replaced").Error
+}
+
+// LastStepNoSince возвращает наибольший номер шага задачи, сохраненного начиная с since (0 - шагов нет).
+func (r *TaskRepository) LastStepNoSince(taskID uint, since time.Time) (int, error) {
+	var stepNo int
+	err := r.db.Model(&AgentStep{}).
+		Where("task_id = ? AND created_at >= ?", taskID, since).
+		Select("COALESCE(MAX(step_no), 0)").
+		Scan(&stepNo).Error
+	return stepNo, err
+}
+
+// CreateAttempt сохраняет начатую попытку выполнения задачи.
+func (r *TaskRepository) CreateAttempt(a *TaskAttempt) error {
+	return r.db.Create(a).Error
+}
+
+// UpdateAttempt сохраняет итог попытки выполнения задачи.
+func (r *TaskRepository) UpdateAttempt(a *TaskAttempt) error {
+	return r.db.Model(&TaskAttempt{}).
+		Where("id = ?", a.ID).
+		Updates(map[string]any{
+			"last_step":   a.LastStep,
+			"status":      a.Status,
+			"error":       a.Error,
+			"finished_at": a.FinishedAt,
+		}).Error
+}
+
+// ListAttempts возвращает попытки выполнения задачи по порядку.
+func (r *TaskRepository) ListAttempts(taskID uint) ([]TaskAttempt, error) {
+	var attempts []TaskAttempt
+	if err := r.db.Where("task_id = ?", taskID).Order("attempt_no ASC").Find(&attempts).Error; err != nil {
+		return nil, err
+	}
+	return attempts, nil
+}
+
+// DeleteAttempts удаляет попытки предыдущего запуска задачи.
+func (r *TaskRepository) DeleteAttempts(taskID uint) error {
+	return r.db.Where("task_id = ?", taskID).Delete(&TaskAttempt{}).Error
+}
+
+// SaveMemoryPath сохраняет успешный путь (новый - создается, существующий - обновляется).
+// Время создания при обновлении не переписывается: в памяти агента его нет.
+func (r *TaskRepository) SaveMemoryPath(p *MemoryPath) error {
+	if p.ID == 0 {
+		return r.db.Create(p).Error
+	}
+	return r.db.Omit("created_at").Save(p).Error
+}
+
+// SaveMemoryFailure сохраняет паттерн ошибки; паттерн с тем же типом, действием и селектором обновляется.
+func (r *TaskRepository) SaveMemoryFailure(f *MemoryFailure) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "error_type"}, {Name: "action"}, {Name: "selector"}},
+		DoUpdates: clause.AssignmentColumns([]string{"count", "recovery", "task", "embedding", "last_seen", "expires_at"}),
+	}).Create(f).Error
+}
+
+// SaveMemorySite сохраняет знания о сайте.
+func (r *TaskRepository) SaveMemorySite(s *MemorySite) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "domain"}},
+		UpdateAll: true,
+	}).Create(s).Error
+}
+
+// ListMemoryPaths возвращает все успешные пути памяти.
+func (r *TaskRepository) ListMemoryPaths() ([]MemoryPath, error) {
+	var paths []MemoryPath
+	if err := r.db.Order("id ASC").Find(&paths).Error; err != nil {
+		return nil, err
+	}
+	return paths, nil
+}
+
+// ListMemoryFailures возвращает все паттерны ошибок памяти.
+func (r *TaskRepository) ListMemoryFailures() ([]MemoryFailure, error) {
+	var failures []MemoryFailure
+	if err := r.db.Order("id ASC").Find(&failures).Error; err != nil {
+		return nil, err
+	}
+	return failures, nil
+}
+
+// ListMemorySites возвращает знания о всех сайтах.
+func (r *TaskRepository) ListMemorySites() ([]MemorySite, error) {
+	var sites []MemorySite
+	if err := r.db.Order("domain ASC").Find(&sites).Error; err != nil {
+		return nil, err
+	}
+	return sites, nil
+}
+
+// DeleteExpiredMemory удаляет записи памяти с истекшим сроком хранения. Возвращает количество удаленных записей.
+func (r *TaskRepository) DeleteExpiredMemory(now time.Time) (int64, error) {
+	var total int64
+	for _, model := range []any{&MemoryPath{}, &MemoryFailure{}, &MemorySite{}} {
+		res := r.db.Where("expires_at IS NOT NULL AND expires_at < ?", now).Delete(model)
+		if res.Error != nil {
+			return total, res.Error
+		}
+		total += res.RowsAffected
+	}
+	return total, nil
+}
+
+// DeleteMemoryPath удаляет успешный путь памяти.
+func (r *TaskRepository) DeleteMemoryPath(id uint) error {
+	return r.db.Delete(&MemoryPath{}, id).Error
+}
+
+// DeleteMemoryFailure удаляет паттерн ошибки памяти.
+func (r *TaskRepository) DeleteMemoryFailure(id uint) error {
+	return r.db.Delete(&MemoryFailure{}, id).Error
+}
+
+// DeleteMemorySite удаляет знания о сайте.
+func (r *TaskRepository) DeleteMemorySite(domain string) error {
+	return r.db.Where("domain = ?", domain).Delete(&MemorySite{}).Error
+}
@@ -17,6 +17,9 @@ type ElementInfo struct {
 	Bounds      Bounds
 	Role        string
 	Label       string
+	ID          string // Атрибут id
+	Name        string // Атрибут name
+	Path        string // Путь в DOM (tag:nth-of-type от html), сам является CSS селектором
 	Priority    int
 }
 
@@ -164,6 +167,9 @@ func extractElements(ctx context.Context, page playwright.Page) ([]ElementInfo,
 					},
 					role: role,
 					label: label,
+					id: el.id || '',
+					name: el.getAttribute('name') || '',
+					path: domPath(el),
 					priority: calculatePriority(el, isInteractive, inViewport, text)
 				});
 			});
@@ -206,6 +212,21 @@ func extractElements(ctx context.Context, page playwright.Page) ([]ElementInfo,
 				return el.tagName.toLowerCase();
 			}
 
+			function domPath(el) {
+				const parts = [];
+				for (let node = el; node && node.nodeType === 1; node = node.parentElement) {
+					const tag = node.tagName.toLowerCase();
+					const parent = node.parentElement;
+					if (!parent) {
+						parts.unshift(tag);
+						break;
+					}
+					const index = Array.from(parent.children).filter(child => child.tagName === node.tagName).indexOf(node) + 1;
+					parts.unshift(tag + ':nth-of-type(' + index + ')');
+				}
+				return parts.join(' > ');
+			}
+
 			function isUniqueID(id) {
 				const commonIDs = ['content', 'main', 'header', 'footer', 'nav', 'menu'];
 				return !commonIDs.includes(id.toLowerCase());
@@ -301,6 +322,15 @@ func parseElementInfo(data map[string]interface{}) *ElementInfo {
 	if label, ok := data["label"].(string); ok {
 		elem.Label = label
 	}
+	if id, ok := data["id"].(string); ok {
+		elem.ID = id
+	}
+	if name, ok := data["name"].(string); ok {
+		elem.Name = name
+	}
+	if path, ok := data["path"].(string); ok {
+		elem.Path = path
+	}
 	if priority, ok := data["priority"].(float64); ok {
 		elem.Priority = int(priority)
 	}
@@ -100,6 +100,7 @@ func GetSystemPromptForCategory(category TaskCategory) string {
 - type(selector, value) - ввод текста
 - extract_info(selector) - извлечение информации со страницы (ИСПОЛЬЗУЙ ПРАВИЛЬНЫЕ СЕЛЕКТОРЫ!)
 - ask_user(question) - запрос у пользователя (ИСПОЛЬЗУЙ МИНИМАЛЬНО!)
+- takeover(reason) - передать управление браузером пользователю (2FA, капча)
 - complete() - задача выполнена
 
 Используй tool calling для выбора действия.
@@ -121,6 +122,7 @@ func GetSystemPromptForCategory(category TaskCategory) string {
 - type(selector, value) - ввод текста
 - extract_info(selector) - извлечение информации
 - ask_user(question) - запрос у пользователя
+- takeover(reason) - передать управление браузером пользователю (2FA, капча, нестандартный виджет)
 - complete() - задача выполнена
 
 Используй tool calling для выбора действия.
@@ -24,9 +24,11 @@ func NewClientWithRateLimit(apiKey, model string, logger Logger, requestsPerMinu
 	client := &Client{
 		client:      openai.NewClient(apiKey),
 		model:       model,
-		logger:      logger,
 		rateLimiter: NewRateLimiter(requestsPerMinute, tokensPerHour),
 	}
+	if logger != nil {
+		client.logger = taskAwareLogger{logger}
+	}
 
 	client.sanitizer = sanitizer.NewWithAI(client)
 	return client
@@ -54,7 +56,7 @@ func (c *Client) createChatCompletionWithRateLimit(ctx context.Context, req open
 	// Выполняем запрос
 	resp, err := c.client.CreateChatCompletion(ctx, req)
 	if err != nil {
-		return resp, err
+		return resp, wrapAPIError(err)
 	}
 
 	// Корректируем использованные токены (теперь знаем точное значение)
@@ -0,0 +1,263 @@
+// Package llm - критерии успеха задачи и проверка завершения.
+// Перед тем как принять action "complete", агент сверяет критерии с текущей страницей
+// и собранными результатами, чтобы не отчитываться об успехе преждевременно.
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// CriterionCheck - результат проверки одного критерия.
+type CriterionCheck struct {
+	Number    int    `json:"number"` // Номер критерия в списке, переданном на проверку (с 1)
+	Criterion string `json:"criterion"`
+	Met       bool   `json:"met"`
+	Evidence  string `json:"evidence"` // Что на странице/в результатах подтверждает или опровергает критерий
+}
+
+// CompletionVerdict - итог проверки критериев успеха перед завершением задачи.
+type CompletionVerdict struct {
+	Passed  bool             `json:"passed"`
+	Checks  []CriterionCheck `json:"checks"`
+	Summary string           `json:"summary"`
+}
+
+// Unmet возвращает невыполненные критерии.
+func (v *CompletionVerdict) Unmet() []CriterionCheck {
+	var unmet []CriterionCheck
+	for _, c := range v.Checks {
+		if !c.Met {
+			unmet = append(unmet, c)
+		}
+	}
+	return unmet
+}
+
+// matchCriteria сопоставляет проверки модели с запрошенными критериями: по номеру,
+// а если номер не указан - по тексту критерия. Критерий без проверки считается
+// невыполненным, проверки критериев, которых не было в запросе, отбрасываются.
+func matchCriteria(criteria []string, checks []CriterionCheck) []CriterionCheck {
+	used := make([]bool, len(checks))
+	find := func(number int, criterion string) int {
+		for i, check := range checks {
+			if !used[i] && check.Number == number {
+				return i
+			}
+		}
+		for i, check := range checks {
+			if !used[i] && check.Number == 0 && strings.EqualFold(strings.TrimSpace(check.Criterion), criterion) {
+				return i
+			}
+		}
+		return -1
+	}
+
+	matched := make([]CriterionCheck, 0, len(criteria))
+	for i, criterion := range criteria {
+		check := CriterionCheck{Number: i + 1, Criterion: criterion, Evidence: "критерий не проверен"}
+		if j := find(i+1, strings.TrimSpace(criterion)); j >= 0 {
+			used[j] = true
+			check.Met = checks[j].Met
+			check.Evidence = checks[j].Evidence
+		}
+		matched = append(matched, check)
+	}
+	return matched
+}
+
+// Format форматирует вердикт для сохранения в ResultSummary и вывода пользователю.
+func (v *CompletionVerdict) Format() string {
+	var sb strings.Builder
+	if v.Passed {
+		sb.WriteString("Критерии успеха: выполнены")
+	} else {
+		sb.WriteString("Критерии успеха: НЕ выполнены")
+	}
+	if v.Summary != "" {
+		fmt.Fprintf(&sb, " - %s", v.Summary)
+	}
+	for _, c := range v.Checks {
+		mark := "[x]"
+		if !c.Met {
+			mark = "[ ]"
+		}
+		fmt.Fprintf(&sb, "\n%s %s", mark, c.Criterion)
+		if c.Evidence != "" {
+			fmt.Fprintf(&sb, ": %s", c.Evidence)
+		}
+	}
+	return sb.String()
+}
+
+// DeriveCriteria формулирует проверяемые критерии успеха для задачи пользователя.
+func (c *Client) DeriveCriteria(ctx context.Context, task string, pageContext string, taskID *uint, stepID *uint) ([]string, error) {
+	systemPrompt := `Ты помогаешь автономному AI-агенту, управляющему браузером, понять, когда задача выполнена.
+
+Сформулируй 1-5 конкретных проверяемых критериев успеха для задачи.
+Каждый критерий должен проверяться по содержимому страницы или по собранным данным
+(например: "на странице отображается подтверждение заказа", "собрано не менее 3 вакансий с зарплатой").
+Не добавляй критерии, которых нет в задаче.
+
+Отвечай ТОЛЬКО в формате JSON:
+{
+  "criteria": ["критерий 1", "критерий 2"]
+}`
+
+	userPrompt := fmt.Sprintf(`Задача: %s
+
+Текущая страница:
+%s`, task, pageContext)
+
+	resp, err := c.createChatCompletionWithRateLimit(ctx, openai.ChatCompletionRequest{
+		Model: c.model,
+		Messages: []openai.ChatCompletionMessage{
+			{
+				Role:    openai.ChatMessageRoleSystem,
+				Content: systemPrompt,
+			},
+			{
+				Role:    openai.ChatMessageRoleUser,
+				Content: userPrompt,
+			},
+		},
+		ResponseFormat: &openai.ChatCompletionResponseFormat{
+			Type: openai.ChatCompletionResponseFormatTypeJSONObject,
+		},
+		Temperature: 0.2,
+	})
+
+	if err != nil {
+		if c.logger != nil {
+			sanitizedPrompt := c.sanitizer.Sanitize(formatPrompt(systemPrompt, userPrompt))
+			sanitizedError := c.sanitizer.Sanitize(err.Error())
+			_ = c.logger.LogLLMRequest(ctx, taskID, stepID, "criteria_error", sanitizedPrompt, sanitizedError, c.model, 0, 0)
+		}
+		return nil, fmt.Errorf("ошибка запроса критериев к OpenAI: %w", err)
+	}
+
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("%w: пустой ответ критериев от OpenAI", ErrMalformedResponse)
+	}
+
+	responseText := resp.Choices[0].Message.Content
+	var parsed struct {
+		Criteria []string `json:"criteria"`
+	}
+	if err := json.Unmarshal([]byte(responseText), &parsed); err != nil {
+		return nil, fmt.Errorf("%w: ошибка парсинга критериев JSON: %w", ErrMalformedResponse, err)
+	}
+
+	if c.logger != nil {
+		sanitizedPrompt := c.sanitizer.Sanitize(formatPrompt(systemPrompt, userPrompt))
+		sanitizedResponse := c.sanitizer.Sanitize(responseText)
+		_ = c.logger.LogLLMRequest(ctx, taskID, stepID, "criteria", sanitizedPrompt, sanitizedResponse, c.model, resp.Usage.PromptTokens, resp.Usage.CompletionTokens)
+	}
+
+	criteria := make([]string, 0, len(parsed.Criteria))
+	for _, criterion := range parsed.Criteria {
+		if criterion = strings.TrimSpace(criterion); criterion != "" {
+			criteria = append(criteria, criterion)
+		}
+	}
+	return criteria, nil
+}
+
+// VerifyCompletion проверяет критерии успеха по текущей странице и собранным результатам.
+// results - данные, извлеченные агентом во время выполнения (extract_info).
+func (c *Client) VerifyCompletion(ctx context.Context, task string, criteria []string, pageContext, results string, transcript *ActionTranscript, taskID *uint, stepID *uint) (*CompletionVerdict, error) {
+	systemPrompt := `Ты модуль проверки завершения задачи автономного AI-агента, управляющего браузером.
+
+Агент считает задачу выполненной. Проверь КАЖДЫЙ критерий успеха по текущей странице,
+собранным результатам и истории действий. Критерий выполнен только если есть явное подтверждение.
+Для каждого критерия укажи его номер из списка и приведи доказательство: цитату со страницы, из результатов или из истории.
+
+Отвечай ТОЛЬКО в формате JSON:
+{
+  "checks": [
+    {"number": 1, "criterion": "текст критерия", "met": true, "evidence": "доказательство"}
+  ],
+  "summary": "краткий итог проверки"
+}`
+
+	var criteriaList strings.Builder
+	for i, criterion := range criteria {
+		fmt.Fprintf(&criteriaList, "%d. %s\n", i+1, criterion)
+	}
+	if results == "" {
+		results = "(нет)"
+	}
+	history := transcript.Format()
+	if history == "" {
+		history = "(нет)"
+	}
+
+	userPrompt := fmt.Sprintf(`Задача: %s
+
+Критерии успеха:
+%s
+Собранные результаты:
+%s
+
+История действий:
+%s
+
+Текущая страница:
+%s`, task, criteriaList.String(), results, history, pageContext)
+
+	resp, err := c.createChatCompletionWithRateLimit(ctx, openai.ChatCompletionRequest{
+		Model: c.model,
+		Messages: []openai.ChatCompletionMessage{
+			{
+				Role:    openai.ChatMessageRoleSystem,
+				Content: systemPrompt,
+			},
+			{
+				Role:    openai.ChatMessageRoleUser,
+				Content: userPrompt,
+			},
+		},
+		ResponseFormat: &openai.ChatCompletionResponseFormat{
+			Type: openai.ChatCompletionResponseFormatTypeJSONObject,
+		},
+		Temperature: 0.1,
+	})
+
+	if err != nil {
+		if c.logger != nil {
+			sanitizedPrompt := c.sanitizer.Sanitize(formatPrompt(systemPrompt, userPrompt))
+			sanitizedError := c.sanitizer.Sanitize(err.Error())
+			_ = c.logger.LogLLMRequest(ctx, taskID, stepID, "verify_error", sanitizedPrompt, sanitizedError, c.model, 0, 0)
+		}
+		return nil, fmt.Errorf("ошибка запроса проверки завершения к OpenAI: %w", err)
+	}
+
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("%w: пустой ответ проверки завершения от OpenAI", ErrMalformedResponse)
+	}
+
+	responseText := resp.Choices[0].Message.Content
+	var verdict CompletionVerdict
+	if err := json.Unmarshal([]byte(responseText), &verdict); err != nil {
+		return nil, fmt.Errorf("%w: ошибка парсинга проверки завершения JSON: %w", ErrMalformedResponse, err)
+	}
+	if len(verdict.Checks) == 0 {
+		return nil, fmt.Errorf("%w: проверка завершения не вернула результатов по критериям", ErrMalformedResponse)
+	}
+
+	// Задача принимается только если выполнены все запрошенные критерии - не доверяем полю passed от модели
+	verdict.Checks = matchCriteria(criteria, verdict.Checks)
+	verdict.Passed = len(verdict.Unmet()) == 0
+
+	if c.logger != nil {
+		sanitizedPrompt := c.sanitizer.Sanitize(formatPrompt(systemPrompt, userPrompt))
+		sanitizedResponse := c.sanitizer.Sanitize(responseText)
+		_ = c.logger.LogLLMRequest(ctx, taskID, stepID, "verify", sanitizedPrompt, sanitizedResponse, c.model, resp.Usage.PromptTokens, resp.Usage.CompletionTokens)
+	}
+
+	return &verdict, nil
+}
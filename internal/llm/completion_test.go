@@ -0,0 +1,77 @@
+package llm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMatchCriteria(t *testing.T) {
+	criteria := []string{"заказ оформлен", "собрано 3 вакансии"}
+	tests := []struct {
+		name       string
+		checks     []CriterionCheck
+		wantMet    []bool
+		wantPassed bool
+	}{
+		{
+			name: "все критерии по номеру",
+			checks: []CriterionCheck{
+				{Number: 2, Criterion: "вакансии", Met: true},
+				{Number: 1, Criterion: "заказ", Met: true},
+			},
+			wantMet:    []bool{true, true},
+			wantPassed: true,
+		},
+		{
+			name:       "критерий без проверки не выполнен",
+			checks:     []CriterionCheck{{Number: 1, Met: true}},
+			wantMet:    []bool{true, false},
+			wantPassed: false,
+		},
+		{
+			name: "лишние проверки отбрасываются",
+			checks: []CriterionCheck{
+				{Number: 3, Criterion: "выдуманный критерий", Met: true},
+				{Criterion: "другая задача", Met: true},
+			},
+			wantMet:    []bool{false, false},
+			wantPassed: false,
+		},
+		{
+			name: "сопоставление по тексту без номера",
+			checks: []CriterionCheck{
+				{Criterion: " Заказ оформлен", Met: true},
+				{Criterion: "собрано 3 вакансии", Met: true},
+			},
+			wantMet:    []bool{true, true},
+			wantPassed: true,
+		},
+		{
+			name: "повторная проверка не засчитывается дважды",
+			checks: []CriterionCheck{
+				{Number: 1, Met: false},
+				{Number: 1, Met: true},
+			},
+			wantMet:    []bool{false, false},
+			wantPassed: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verdict := CompletionVerdict{Checks: matchCriteria(criteria, tt.checks)}
+			met := make([]bool, 0, len(verdict.Checks))
+			for i, check := range verdict.Checks {
+				if check.Criterion != criteria[i] || check.Number != i+1 {
+					t.Errorf("проверка %d = %+v, ожидался критерий %q", i, check, criteria[i])
+				}
+				met = append(met, check.Met)
+			}
+			if !reflect.DeepEqual(met, tt.wantMet) {
+				t.Errorf("выполнены = %v, ожидалось %v", met, tt.wantMet)
+			}
+			if passed := len(verdict.Unmet()) == 0; passed != tt.wantPassed {
+				t.Errorf("passed = %v, ожидалось %v", passed, tt.wantPassed)
+			}
+		})
+	}
+}
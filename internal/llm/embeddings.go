@@ -0,0 +1,56 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// embeddingDimensions - размерность векторов модели text-embedding-3-small.
+const embeddingDimensions = 1536
+
+// Embed возвращает вектор текста от модели эмбеддингов OpenAI (text-embedding-3-small).
+// Вместе с Dimensions позволяет использовать Client как эмбеддер памяти агента.
+func (c *Client) Embed(ctx context.Context, text string) ([]float32, error) {
+	if err := c.rateLimiter.AllowRequest(ctx); err != nil {
+		return nil, err
+	}
+
+	input := strings.ReplaceAll(text, "\n", " ")
+	estimatedTokens := len(input) / 4
+	if err := c.rateLimiter.AllowTokens(ctx, estimatedTokens); err != nil {
+		return nil, err
+	}
+	resp, err := c.client.CreateEmbeddings(ctx, openai.EmbeddingRequestStrings{
+		Input: []string{input},
+		Model: openai.SmallEmbedding3,
+	})
+	if err != nil {
+		if c.logger != nil {
+			sanitizedError := c.sanitizer.Sanitize(err.Error())
+			_ = c.logger.LogLLMRequest(ctx, nil, nil, "embedding_error", c.sanitizer.Sanitize(input), sanitizedError, string(openai.SmallEmbedding3), 0, 0)
+		}
+		return nil, fmt.Errorf("ошибка запроса эмбеддинга к OpenAI: %w", wrapAPIError(err))
+	}
+	if len(resp.Data) == 0 {
+		return nil, fmt.Errorf("%w: пустой ответ эмбеддинга от OpenAI", ErrMalformedResponse)
+	}
+
+	if resp.Usage.TotalTokens > estimatedTokens {
+		c.rateLimiter.ConsumeTokens(resp.Usage.TotalTokens - estimatedTokens)
+	}
+
+	// Токены эмбеддингов входят в расход задачи (ID подставляется из контекста) и в бюджеты
+	if c.logger != nil {
+		response := fmt.Sprintf("вектор из %d чисел", len(resp.Data[0].Embedding))
+		_ = c.logger.LogLLMRequest(ctx, nil, nil, "embedding", c.sanitizer.Sanitize(input), response, string(openai.SmallEmbedding3), resp.Usage.PromptTokens, 0)
+	}
+	return resp.Data[0].Embedding, nil
+}
+
+// Dimensions возвращает размерность векторов Embed
+func (c *Client) Dimensions() int {
+	return embeddingDimensions
+}
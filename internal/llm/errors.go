@@ -0,0 +1,52 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// Ошибки обращения к LLM. Клиент оборачивает в них ошибки API и разбора ответа (fmt.Errorf с %w),
+// поэтому вызывающий код различает их через errors.Is.
+var (
+	ErrRateLimited       = errors.New("лимит запросов к LLM исчерпан")
+	ErrContextTooLong    = errors.New("запрос не помещается в контекст модели")
+	ErrMalformedResponse = errors.New("некорректный ответ LLM")
+)
+
+// wrapAPIError переводит ошибку OpenAI API в ошибку LLM. Исчерпанная квота (insufficient_quota)
+// приходит тем же статусом 429, но ожиданием не лечится и лимитом не считается.
+func wrapAPIError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		switch {
+		case apiErr.Code == "context_length_exceeded":
+			return fmt.Errorf("%w: %w", ErrContextTooLong, err)
+		case apiErr.HTTPStatusCode == http.StatusTooManyRequests && apiErr.Code != "insufficient_quota":
+			return fmt.Errorf("%w: %w", ErrRateLimited, err)
+		}
+		return err
+	}
+
+	var reqErr *openai.RequestError
+	if errors.As(err, &reqErr) && reqErr.HTTPStatusCode == http.StatusTooManyRequests {
+		return fmt.Errorf("%w: %w", ErrRateLimited, err)
+	}
+	return err
+}
+
+// IsServerError сообщает, что API ответил ошибкой сервера (5xx).
+func IsServerError(err error) bool {
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.HTTPStatusCode >= http.StatusInternalServerError
+	}
+	var reqErr *openai.RequestError
+	return errors.As(err, &reqErr) && reqErr.HTTPStatusCode >= http.StatusInternalServerError
+}
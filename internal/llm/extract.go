@@ -0,0 +1,81 @@
+// Package llm - структурированное извлечение данных со страницы по JSON схеме задачи.
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// ExtractRecords извлекает со страницы записи, соответствующие JSON схеме одной записи.
+// Возвращает записи как есть - проверка по схеме выполняется вызывающей стороной.
+func (c *Client) ExtractRecords(ctx context.Context, task string, pageContext string, recordSchema string, taskID *uint, stepID *uint) ([]json.RawMessage, error) {
+	systemPrompt := `Ты модуль извлечения данных автономного AI-агента, управляющего браузером.
+
+Извлеки со страницы все записи, относящиеся к задаче пользователя.
+Каждая запись должна СТРОГО соответствовать JSON схеме записи: обязательные поля заполнены,
+типы совпадают, лишние поля не добавляются. Не выдумывай данные - только то, что есть на странице.
+Если подходящих записей нет, верни пустой список.
+
+Отвечай ТОЛЬКО в формате JSON:
+{
+  "records": [ ... ]
+}`
+
+	userPrompt := fmt.Sprintf(`Задача: %s
+
+JSON схема записи:
+%s
+
+Страница:
+%s`, task, recordSchema, pageContext)
+
+	resp, err := c.createChatCompletionWithRateLimit(ctx, openai.ChatCompletionRequest{
+		Model: c.model,
+		Messages: []openai.ChatCompletionMessage{
+			{
+				Role:    openai.ChatMessageRoleSystem,
+				Content: systemPrompt,
+			},
+			{
+				Role:    openai.ChatMessageRoleUser,
+				Content: userPrompt,
+			},
+		},
+		ResponseFormat: &openai.ChatCompletionResponseFormat{
+			Type: openai.ChatCompletionResponseFormatTypeJSONObject,
+		},
+		Temperature: 0.1,
+	})
+
+	if err != nil {
+		if c.logger != nil {
+			sanitizedPrompt := c.sanitizer.Sanitize(formatPrompt(systemPrompt, userPrompt))
+			sanitizedError := c.sanitizer.Sanitize(err.Error())
+			_ = c.logger.LogLLMRequest(ctx, taskID, stepID, "extract_error", sanitizedPrompt, sanitizedError, c.model, 0, 0)
+		}
+		return nil, fmt.Errorf("ошибка запроса извлечения к OpenAI: %w", err)
+	}
+
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("%w: пустой ответ извлечения от OpenAI", ErrMalformedResponse)
+	}
+
+	responseText := resp.Choices[0].Message.Content
+	var parsed struct {
+		Records []json.RawMessage `json:"records"`
+	}
+	if err := json.Unmarshal([]byte(responseText), &parsed); err != nil {
+		return nil, fmt.Errorf("%w: ошибка парсинга извлеченных записей JSON: %w", ErrMalformedResponse, err)
+	}
+
+	if c.logger != nil {
+		sanitizedPrompt := c.sanitizer.Sanitize(formatPrompt(systemPrompt, userPrompt))
+		sanitizedResponse := c.sanitizer.Sanitize(responseText)
+		_ = c.logger.LogLLMRequest(ctx, taskID, stepID, "extract", sanitizedPrompt, sanitizedResponse, c.model, resp.Usage.PromptTokens, resp.Usage.CompletionTokens)
+	}
+
+	return parsed.Records, nil
+}
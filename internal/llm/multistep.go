@@ -44,6 +44,7 @@ func (c *Client) PlanMultiStep(ctx context.Context, task string, pageContext str
 - type: ввести текст в элемент
 - extract_info: извлечь информацию со страницы
 - ask_user: спросить пользователя
+- takeover: передать управление браузером пользователю (value - что нужно сделать)
 - complete: задача завершена
 
 Отвечай в формате JSON:
@@ -81,21 +82,21 @@ func (c *Client) PlanMultiStep(ctx context.Context, task string, pageContext str
 	})
 
 	if err != nil {
-		return nil, fmt.Errorf("failed to plan multi-step: %w", err)
+		return nil, fmt.Errorf("failed to plan multi-step: %w", wrapAPIError(err))
 	}
 
 	if len(resp.Choices) == 0 {
-		return nil, fmt.Errorf("no response from LLM")
+		return nil, fmt.Errorf("%w: no response from LLM", ErrMalformedResponse)
 	}
 
 	content := resp.Choices[0].Message.Content
 	var result MultiStepPlan
 	if err := json.Unmarshal([]byte(content), &result); err != nil {
-		return nil, fmt.Errorf("failed to parse multi-step plan: %w", err)
+		return nil, fmt.Errorf("%w: failed to parse multi-step plan: %w", ErrMalformedResponse, err)
 	}
 
 	if c.logger != nil {
-		c.logger.LogLLMRequest(ctx, taskID, stepID, "system", prompt, content, c.model, resp.Usage.TotalTokens)
+		c.logger.LogLLMRequest(ctx, taskID, stepID, "system", prompt, content, c.model, resp.Usage.PromptTokens, resp.Usage.CompletionTokens)
 	}
 
 	return &result, nil
@@ -163,21 +164,21 @@ func (c *Client) Replan(ctx context.Context, task string, pageContext string, or
 	})
 
 	if err != nil {
-		return nil, fmt.Errorf("failed to replan: %w", err)
+		return nil, fmt.Errorf("failed to replan: %w", wrapAPIError(err))
 	}
 
 	if len(resp.Choices) == 0 {
-		return nil, fmt.Errorf("no response from LLM")
+		return nil, fmt.Errorf("%w: no response from LLM", ErrMalformedResponse)
 	}
 
 	content := resp.Choices[0].Message.Content
 	var result MultiStepPlan
 	if err := json.Unmarshal([]byte(content), &result); err != nil {
-		return nil, fmt.Errorf("failed to parse replan: %w", err)
+		return nil, fmt.Errorf("%w: failed to parse replan: %w", ErrMalformedResponse, err)
 	}
 
 	if c.logger != nil {
-		c.logger.LogLLMRequest(ctx, taskID, stepID, "system", prompt, content, c.model, resp.Usage.TotalTokens)
+		c.logger.LogLLMRequest(ctx, taskID, stepID, "system", prompt, content, c.model, resp.Usage.PromptTokens, resp.Usage.CompletionTokens)
 	}
 
 	return &result, nil
@@ -31,6 +31,9 @@ func parseToolCall(toolCall openai.ToolCall) *StepPlan {
 	if v, ok := args["question"].(string); ok {
 		plan.Value = v
 	}
+	if v, ok := args["reason"].(string); ok {
+		plan.Value = v
+	}
 	if v, ok := args["reasoning"].(string); ok {
 		plan.Reasoning = v
 	}
@@ -0,0 +1,103 @@
+// Package llm - исправление шага запомненного пути под изменившуюся страницу.
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// PatchStep подбирает новый селектор для шага запомненного пути, если старый не совпал со страницей.
+// Действие и значение шага не меняются: модель ищет на странице элемент по намерению шага
+// (step.Reasoning). Возвращает исправленную копию шага или nil, если подходящего элемента нет.
+func (c *Client) PatchStep(ctx context.Context, task string, pageContext string, step *StepPlan, problem string, taskID *uint, stepID *uint) (*StepPlan, error) {
+	systemPrompt := `Ты модуль исправления шагов автономного AI-агента, управляющего браузером.
+
+Агент повторяет путь, который уже приводил к успеху, но верстка сайта могла измениться,
+и селектор шага больше не указывает ровно на один видимый элемент.
+Найди на странице элемент, соответствующий намерению шага, и верни для него селектор.
+Используй только селекторы элементов из контекста страницы. Действие шага не меняй.
+Если подходящего элемента на странице нет, верни found: false - не подбирай похожий наугад.
+
+Отвечай ТОЛЬКО в формате JSON:
+{
+  "found": true,
+  "selector": "селектор элемента",
+  "explanation": "почему это тот же элемент"
+}`
+
+	userPrompt := fmt.Sprintf(`Задача: %s
+
+Шаг:
+Действие: %s
+Селектор: %s
+Значение: %s
+Намерение: %s
+
+Проблема: %s
+
+Страница:
+%s`, task, step.Action, step.Selector, step.Value, step.Reasoning, problem, pageContext)
+
+	resp, err := c.createChatCompletionWithRateLimit(ctx, openai.ChatCompletionRequest{
+		Model: c.model,
+		Messages: []openai.ChatCompletionMessage{
+			{
+				Role:    openai.ChatMessageRoleSystem,
+				Content: systemPrompt,
+			},
+			{
+				Role:    openai.ChatMessageRoleUser,
+				Content: userPrompt,
+			},
+		},
+		ResponseFormat: &openai.ChatCompletionResponseFormat{
+			Type: openai.ChatCompletionResponseFormatTypeJSONObject,
+		},
+		Temperature: 0.1,
+	})
+
+	if err != nil {
+		if c.logger != nil {
+			sanitizedPrompt := c.sanitizer.Sanitize(formatPrompt(systemPrompt, userPrompt))
+			sanitizedError := c.sanitizer.Sanitize(err.Error())
+			_ = c.logger.LogLLMRequest(ctx, taskID, stepID, "patch_error", sanitizedPrompt, sanitizedError, c.model, 0, 0)
+		}
+		return nil, fmt.Errorf("ошибка запроса исправления шага к OpenAI: %w", err)
+	}
+
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("%w: пустой ответ исправления шага от OpenAI", ErrMalformedResponse)
+	}
+
+	responseText := resp.Choices[0].Message.Content
+	var parsed struct {
+		Found       bool   `json:"found"`
+		Selector    string `json:"selector"`
+		Explanation string `json:"explanation"`
+	}
+	if err := json.Unmarshal([]byte(responseText), &parsed); err != nil {
+		return nil, fmt.Errorf("%w: ошибка парсинга исправления шага JSON: %w", ErrMalformedResponse, err)
+	}
+
+	if c.logger != nil {
+		sanitizedPrompt := c.sanitizer.Sanitize(formatPrompt(systemPrompt, userPrompt))
+		sanitizedResponse := c.sanitizer.Sanitize(responseText)
+		_ = c.logger.LogLLMRequest(ctx, taskID, stepID, "patch", sanitizedPrompt, sanitizedResponse, c.model, resp.Usage.PromptTokens, resp.Usage.CompletionTokens)
+	}
+
+	if !parsed.Found {
+		return nil, nil
+	}
+	selector := strings.TrimSpace(parsed.Selector)
+	if selector == "" {
+		return nil, fmt.Errorf("%w: модель нашла элемент, но не вернула селектор", ErrMalformedResponse)
+	}
+
+	patched := *step
+	patched.Selector = selector
+	return &patched, nil
+}
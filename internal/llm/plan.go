@@ -13,12 +13,12 @@ func formatPrompt(systemMsg, userPrompt string) string {
 
 // PlanActionWithReasoning планирует действие С УЧЕТОМ предыдущего reasoning.
 // Это новый метод для работы с ReAct pattern - reasoning направляет планирование.
-func (c *Client) PlanActionWithReasoning(ctx context.Context, task string, pageContext string, reasoning *ReasoningStep, taskID *uint, stepID *uint) (*StepPlan, error) {
-	tools := getTools()
+func (c *Client) PlanActionWithReasoning(ctx context.Context, task string, pageContext string, reasoning *ReasoningStep, transcript *ActionTranscript, taskID *uint, stepID *uint) (*StepPlan, error) {
+	tools := toolsForContext(ctx)
 
 	// Определяем категорию задачи для использования специализированного промпта
 	category := DetectTaskCategory(task)
-	systemMsg := GetSystemPromptForCategory(category)
+	systemMsg := applyProfilePrompt(ctx, GetSystemPromptForCategory(category))
 
 	// Формируем prompt с reasoning context
 	prompt := fmt.Sprintf(`Текущая задача: %s
@@ -43,6 +43,8 @@ func (c *Client) PlanActionWithReasoning(ctx context.Context, task string, pageC
 			reasoning.Confidence)
 	}
 
+	prompt += formatTranscriptSection(transcript)
+
 	prompt += "\n\nОпредели КОНКРЕТНОЕ следующее действие для выполнения задачи. Используй tool calling."
 
 	resp, err := c.createChatCompletionWithRateLimit(ctx, openai.ChatCompletionRequest{
@@ -65,13 +67,13 @@ func (c *Client) PlanActionWithReasoning(ctx context.Context, task string, pageC
 			fullPrompt := formatPrompt(systemMsg, prompt)
 			sanitizedPrompt := c.sanitizer.Sanitize(fullPrompt)
 			sanitizedError := c.sanitizer.Sanitize(err.Error())
-			_ = c.logger.LogLLMRequest(ctx, taskID, stepID, "planning_error", sanitizedPrompt, sanitizedError, c.model, 0)
+			_ = c.logger.LogLLMRequest(ctx, taskID, stepID, "planning_error", sanitizedPrompt, sanitizedError, c.model, 0, 0)
 		}
 		return nil, fmt.Errorf("ошибка запроса к OpenAI: %w", err)
 	}
 
 	if len(resp.Choices) == 0 {
-		return nil, fmt.Errorf("пустой ответ от OpenAI")
+		return nil, fmt.Errorf("%w: пустой ответ от OpenAI", ErrMalformedResponse)
 	}
 
 	choice := resp.Choices[0]
@@ -89,8 +91,7 @@ func (c *Client) PlanActionWithReasoning(ctx context.Context, task string, pageC
 		fullPrompt := formatPrompt(systemMsg, prompt)
 		sanitizedPrompt := c.sanitizer.Sanitize(fullPrompt)
 		sanitizedResponse := c.sanitizer.Sanitize(responseText)
-		tokensUsed := resp.Usage.TotalTokens
-		_ = c.logger.LogLLMRequest(ctx, taskID, stepID, "planning", sanitizedPrompt, sanitizedResponse, c.model, tokensUsed)
+		_ = c.logger.LogLLMRequest(ctx, taskID, stepID, "planning", sanitizedPrompt, sanitizedResponse, c.model, resp.Usage.PromptTokens, resp.Usage.CompletionTokens)
 	}
 
 	if toolCall != nil {
@@ -107,10 +108,10 @@ func (c *Client) PlanActionWithReasoning(ctx context.Context, task string, pageC
 // Для новой архитектуры используй PlanActionWithReasoning.
 // Сохранено для обратной совместимости с кодом который не использует reasoning.
 func (c *Client) PlanAction(ctx context.Context, task string, pageContext string, taskID *uint, stepID *uint) (*StepPlan, error) {
-	tools := getTools()
+	tools := toolsForContext(ctx)
 
 	category := DetectTaskCategory(task)
-	systemMsg := GetSystemPromptForCategory(category)
+	systemMsg := applyProfilePrompt(ctx, GetSystemPromptForCategory(category))
 	fewShot := GetFewShotExamplesForCategory(category)
 	guidance := GetTaskSpecificGuidance(category)
 
@@ -145,13 +146,13 @@ func (c *Client) PlanAction(ctx context.Context, task string, pageContext string
 			fullPrompt := formatPrompt(systemMsg, prompt)
 			sanitizedPrompt := c.sanitizer.Sanitize(fullPrompt)
 			sanitizedError := c.sanitizer.Sanitize(err.Error())
-			_ = c.logger.LogLLMRequest(ctx, taskID, stepID, "error", sanitizedPrompt, sanitizedError, c.model, 0)
+			_ = c.logger.LogLLMRequest(ctx, taskID, stepID, "error", sanitizedPrompt, sanitizedError, c.model, 0, 0)
 		}
-		return nil, fmt.Errorf("ошибка запроса к OpenAI: %w", err)
+		return nil, fmt.Errorf("ошибка запроса к OpenAI: %w", wrapAPIError(err))
 	}
 
 	if len(resp.Choices) == 0 {
-		return nil, fmt.Errorf("пустой ответ от OpenAI")
+		return nil, fmt.Errorf("%w: пустой ответ от OpenAI", ErrMalformedResponse)
 	}
 
 	choice := resp.Choices[0]
@@ -169,8 +170,7 @@ func (c *Client) PlanAction(ctx context.Context, task string, pageContext string
 		fullPrompt := formatPrompt(systemMsg, prompt)
 		sanitizedPrompt := c.sanitizer.Sanitize(fullPrompt)
 		sanitizedResponse := c.sanitizer.Sanitize(responseText)
-		tokensUsed := resp.Usage.TotalTokens
-		_ = c.logger.LogLLMRequest(ctx, taskID, stepID, "assistant", sanitizedPrompt, sanitizedResponse, c.model, tokensUsed)
+		_ = c.logger.LogLLMRequest(ctx, taskID, stepID, "assistant", sanitizedPrompt, sanitizedResponse, c.model, resp.Usage.PromptTokens, resp.Usage.CompletionTokens)
 	}
 
 	if toolCall != nil {
@@ -54,21 +54,21 @@ func (c *Client) AnalyzePopup(ctx context.Context, elements string) (*PopupInfo,
 	})
 
 	if err != nil {
-		return nil, fmt.Errorf("failed to analyze popup: %w", err)
+		return nil, fmt.Errorf("failed to analyze popup: %w", wrapAPIError(err))
 	}
 
 	if len(resp.Choices) == 0 {
-		return nil, fmt.Errorf("no response from LLM")
+		return nil, fmt.Errorf("%w: no response from LLM", ErrMalformedResponse)
 	}
 
 	content := resp.Choices[0].Message.Content
 	var result PopupInfo
 	if err := json.Unmarshal([]byte(content), &result); err != nil {
-		return nil, fmt.Errorf("failed to parse popup analysis: %w", err)
+		return nil, fmt.Errorf("%w: failed to parse popup analysis: %w", ErrMalformedResponse, err)
 	}
 
 	if c.logger != nil {
-		c.logger.LogLLMRequest(ctx, nil, nil, "system", prompt, content, c.model, resp.Usage.TotalTokens)
+		c.logger.LogLLMRequest(ctx, nil, nil, "system", prompt, content, c.model, resp.Usage.PromptTokens, resp.Usage.CompletionTokens)
 	}
 
 	return &result, nil
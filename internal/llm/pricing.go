@@ -0,0 +1,63 @@
+package llm
+
+import "strings"
+
+// ModelPrice - цена модели в USD за 1М токенов.
+type ModelPrice struct {
+	Input  float64 // Входные токены (промпт)
+	Output float64 // Выходные токены (ответ)
+}
+
+// Blended возвращает усредненную цену за 1М токенов.
+// Нужна только для старых записей llm_logs, где сохранено лишь общее количество
+// токенов: считаем типичную для агента пропорцию 3 входных токена на 1 выходной.
+func (p ModelPrice) Blended() float64 {
+	return (3*p.Input + p.Output) / 4
+}
+
+// modelPrices - таблица цен OpenAI. Более специфичные префиксы идут первыми.
+var modelPrices = []struct {
+	prefix string
+	price  ModelPrice
+}{
+	{"gpt-4o-mini", ModelPrice{Input: 0.15, Output: 0.60}},
+	{"gpt-4o", ModelPrice{Input: 2.50, Output: 10.00}},
+	{"gpt-4.1-nano", ModelPrice{Input: 0.10, Output: 0.40}},
+	{"gpt-4.1-mini", ModelPrice{Input: 0.40, Output: 1.60}},
+	{"gpt-4.1", ModelPrice{Input: 2.00, Output: 8.00}},
+	{"gpt-4-turbo", ModelPrice{Input: 10.00, Output: 30.00}},
+	{"gpt-4", ModelPrice{Input: 30.00, Output: 60.00}},
+	{"gpt-3.5-turbo", ModelPrice{Input: 0.50, Output: 1.50}},
+	{"o3-mini", ModelPrice{Input: 1.10, Output: 4.40}},
+	{"o1-mini", ModelPrice{Input: 1.10, Output: 4.40}},
+	{"o1", ModelPrice{Input: 15.00, Output: 60.00}},
+	// У эмбеддингов только входные токены: выходная цена равна входной, чтобы Blended не занижал расход старых записей
+	{"text-embedding-3-small", ModelPrice{Input: 0.02, Output: 0.02}},
+	{"text-embedding-3-large", ModelPrice{Input: 0.13, Output: 0.13}},
+}
+
+// defaultModelPrice используется для неизвестных моделей (цена gpt-4o, чтобы не занижать расход).
+var defaultModelPrice = ModelPrice{Input: 2.50, Output: 10.00}
+
+// PriceForModel возвращает цену модели по таблице.
+func PriceForModel(model string) ModelPrice {
+	model = strings.ToLower(model)
+	for _, p := range modelPrices {
+		if strings.HasPrefix(model, p.prefix) {
+			return p.price
+		}
+	}
+	return defaultModelPrice
+}
+
+// EstimateCost оценивает стоимость токенов модели в USD.
+// Входные и выходные токены считаются по своим ценам; остаток total сверх
+// prompt+completion (записи до разделения токенов) - по усредненной цене.
+func EstimateCost(model string, prompt, completion, total int64) float64 {
+	price := PriceForModel(model)
+	cost := float64(prompt)*price.Input + float64(completion)*price.Output
+	if legacy := total - prompt - completion; legacy > 0 {
+		cost += float64(legacy) * price.Blended()
+	}
+	return cost / 1_000_000
+}
@@ -0,0 +1,30 @@
+package llm
+
+import (
+	"math"
+	"testing"
+)
+
+func TestEstimateCost(t *testing.T) {
+	tests := []struct {
+		name                      string
+		model                     string
+		prompt, completion, total int64
+		want                      float64
+	}{
+		{"входные и выходные по своим ценам", "gpt-4o-2024-08-06", 1_000_000, 1_000_000, 2_000_000, 12.50},
+		{"только ответ", "gpt-4o-mini", 0, 1_000_000, 1_000_000, 0.60},
+		{"старые записи без разделения", "gpt-4o", 0, 0, 1_000_000, 4.375},
+		{"часть записей без разделения", "gpt-4o", 1_000_000, 0, 2_000_000, 6.875},
+		{"неизвестная модель по цене gpt-4o", "custom", 0, 1_000_000, 1_000_000, 10.00},
+		{"эмбеддинги", "text-embedding-3-small", 1_000_000, 0, 1_000_000, 0.02},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := EstimateCost(tt.model, tt.prompt, tt.completion, tt.total)
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("EstimateCost() = %v, ожидалось %v", got, tt.want)
+			}
+		})
+	}
+}
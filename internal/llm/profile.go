@@ -0,0 +1,69 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// AgentProfile - настройки декларативного специализированного агента, влияющие на запросы к модели:
+// дополнительный системный промпт и набор разрешенных инструментов.
+type AgentProfile struct {
+	Name         string
+	SystemPrompt string
+	AllowedTools []string // Пустой список - доступны все инструменты
+}
+
+type agentProfileKey struct{}
+
+// WithAgentProfile возвращает контекст, в котором планирование и reasoning выполняются по профилю агента.
+func WithAgentProfile(ctx context.Context, profile *AgentProfile) context.Context {
+	return context.WithValue(ctx, agentProfileKey{}, profile)
+}
+
+// AgentProfileFromContext возвращает профиль агента из контекста (nil если не задан).
+func AgentProfileFromContext(ctx context.Context) *AgentProfile {
+	profile, _ := ctx.Value(agentProfileKey{}).(*AgentProfile)
+	return profile
+}
+
+// applyProfilePrompt дополняет системный промпт инструкциями профиля агента из контекста.
+func applyProfilePrompt(ctx context.Context, systemMsg string) string {
+	profile := AgentProfileFromContext(ctx)
+	if profile == nil || profile.SystemPrompt == "" {
+		return systemMsg
+	}
+	return systemMsg + fmt.Sprintf("\n\nТы работаешь как специализированный агент \"%s\":\n%s", profile.Name, profile.SystemPrompt)
+}
+
+// toolsForContext возвращает инструменты, разрешенные профилем агента из контекста.
+func toolsForContext(ctx context.Context) []openai.Tool {
+	tools := getTools()
+	profile := AgentProfileFromContext(ctx)
+	if profile == nil || len(profile.AllowedTools) == 0 {
+		return tools
+	}
+
+	allowed := make(map[string]bool, len(profile.AllowedTools))
+	for _, name := range profile.AllowedTools {
+		allowed[name] = true
+	}
+	filtered := make([]openai.Tool, 0, len(profile.AllowedTools))
+	for _, tool := range tools {
+		if allowed[tool.Function.Name] {
+			filtered = append(filtered, tool)
+		}
+	}
+	return filtered
+}
+
+// ToolNames возвращает имена всех инструментов браузера, доступных модели.
+func ToolNames() []string {
+	tools := getTools()
+	names := make([]string, 0, len(tools))
+	for _, tool := range tools {
+		names = append(names, tool.Function.Name)
+	}
+	return names
+}
@@ -88,7 +88,7 @@ func (rl *RateLimiter) AllowRequest(ctx context.Context) error {
 
 	if rl.requestTokens <= 0 {
 		waitTime := time.Minute / time.Duration(rl.requestsPerMinute)
-		return fmt.Errorf("превышен лимит запросов (%d RPM), повторите через %v", rl.requestsPerMinute, waitTime)
+		return fmt.Errorf("%w: превышен лимит запросов (%d RPM), повторите через %v", ErrRateLimited, rl.requestsPerMinute, waitTime)
 	}
 
 	rl.requestTokens--
@@ -104,8 +104,8 @@ func (rl *RateLimiter) AllowTokens(ctx context.Context, tokens int) error {
 
 	if rl.tokenBudget < tokens {
 		waitTime := time.Hour / time.Duration(rl.tokensPerHour/tokens)
-		return fmt.Errorf("превышен лимит токенов (%d TPH), недостаточно токенов (%d требуется, %d доступно), повторите через %v",
-			rl.tokensPerHour, tokens, rl.tokenBudget, waitTime)
+		return fmt.Errorf("%w: превышен лимит токенов (%d TPH), недостаточно токенов (%d требуется, %d доступно), повторите через %v",
+			ErrRateLimited, rl.tokensPerHour, tokens, rl.tokenBudget, waitTime)
 	}
 
 	rl.tokenBudget -= tokens
@@ -36,6 +36,9 @@ type ReasoningStep struct {
 
 	// ReasonForUserInput - почему нужен ввод пользователя
 	ReasonForUserInput string `json:"reason_for_user_input,omitempty"`
+
+	// Reflection - вердикт рефлексии по действию, выполненному на основе этого рассуждения
+	Reflection *Reflection `json:"reflection,omitempty"`
 }
 
 // ReasoningHistory хранит историю рассуждений агента.
@@ -57,6 +60,15 @@ func (rh *ReasoningHistory) GetLastStep() *ReasoningStep {
 	return &rh.Steps[len(rh.Steps)-1]
 }
 
+// AttachReflection привязывает вердикт рефлексии к последнему шагу рассуждения.
+// Именно последний шаг направлял планирование выполненного действия.
+func (rh *ReasoningHistory) AttachReflection(reflection *Reflection) {
+	if len(rh.Steps) == 0 || reflection == nil {
+		return
+	}
+	rh.Steps[len(rh.Steps)-1].Reflection = reflection
+}
+
 // Clear очищает историю рассуждений.
 func (rh *ReasoningHistory) Clear() {
 	rh.Steps = nil
@@ -83,12 +95,13 @@ func (rh *ReasoningHistory) ToJSON() string {
 //   - task: текущая задача пользователя
 //   - pageContext: контекст страницы (snapshot элементов)
 //   - history: история предыдущих рассуждений (может быть nil)
+//   - transcript: предыдущие действия и их результаты (может быть nil)
 //   - taskID, stepID: идентификаторы для логирования
 //
 // Возвращает:
 //   - ReasoningStep с полями observation, analysis, strategy, confidence
 //   - error в случае ошибки LLM запроса
-func (c *Client) Reason(ctx context.Context, task string, pageContext string, history *ReasoningHistory, taskID *uint, stepID *uint) (*ReasoningStep, error) {
+func (c *Client) Reason(ctx context.Context, task string, pageContext string, history *ReasoningHistory, transcript *ActionTranscript, taskID *uint, stepID *uint) (*ReasoningStep, error) {
 	// Минимальный system prompt - только роль и формат ответа
 	systemPrompt := `Ты автономный AI-агент для управления браузером.
 
@@ -129,6 +142,9 @@ func (c *Client) Reason(ctx context.Context, task string, pageContext string, hi
 %s`, history.ToJSON())
 	}
 
+	userPrompt += formatTranscriptSection(transcript)
+	systemPrompt = applyProfilePrompt(ctx, systemPrompt)
+
 	// Делаем запрос к LLM в JSON mode для получения structured output
 	resp, err := c.createChatCompletionWithRateLimit(ctx, openai.ChatCompletionRequest{
 		Model: c.model,
@@ -153,13 +169,13 @@ func (c *Client) Reason(ctx context.Context, task string, pageContext string, hi
 			fullPrompt := formatPrompt(systemPrompt, userPrompt)
 			sanitizedPrompt := c.sanitizer.Sanitize(fullPrompt)
 			sanitizedError := c.sanitizer.Sanitize(err.Error())
-			_ = c.logger.LogLLMRequest(ctx, taskID, stepID, "reasoning_error", sanitizedPrompt, sanitizedError, c.model, 0)
+			_ = c.logger.LogLLMRequest(ctx, taskID, stepID, "reasoning_error", sanitizedPrompt, sanitizedError, c.model, 0, 0)
 		}
 		return nil, fmt.Errorf("ошибка reasoning запроса к OpenAI: %w", err)
 	}
 
 	if len(resp.Choices) == 0 {
-		return nil, fmt.Errorf("пустой ответ reasoning от OpenAI")
+		return nil, fmt.Errorf("%w: пустой ответ reasoning от OpenAI", ErrMalformedResponse)
 	}
 
 	// Парсим JSON ответ в ReasoningStep
@@ -170,9 +186,9 @@ func (c *Client) Reason(ctx context.Context, task string, pageContext string, hi
 			fullPrompt := formatPrompt(systemPrompt, userPrompt)
 			sanitizedPrompt := c.sanitizer.Sanitize(fullPrompt)
 			sanitizedResponse := c.sanitizer.Sanitize(responseText)
-			_ = c.logger.LogLLMRequest(ctx, taskID, stepID, "reasoning_parse_error", sanitizedPrompt, sanitizedResponse, c.model, resp.Usage.TotalTokens)
+			_ = c.logger.LogLLMRequest(ctx, taskID, stepID, "reasoning_parse_error", sanitizedPrompt, sanitizedResponse, c.model, resp.Usage.PromptTokens, resp.Usage.CompletionTokens)
 		}
-		return nil, fmt.Errorf("ошибка парсинга reasoning JSON: %w", err)
+		return nil, fmt.Errorf("%w: ошибка парсинга reasoning JSON: %w", ErrMalformedResponse, err)
 	}
 
 	// Логируем успешный reasoning
@@ -180,7 +196,7 @@ func (c *Client) Reason(ctx context.Context, task string, pageContext string, hi
 		fullPrompt := formatPrompt(systemPrompt, userPrompt)
 		sanitizedPrompt := c.sanitizer.Sanitize(fullPrompt)
 		sanitizedResponse := c.sanitizer.Sanitize(responseText)
-		_ = c.logger.LogLLMRequest(ctx, taskID, stepID, "reasoning", sanitizedPrompt, sanitizedResponse, c.model, resp.Usage.TotalTokens)
+		_ = c.logger.LogLLMRequest(ctx, taskID, stepID, "reasoning", sanitizedPrompt, sanitizedResponse, c.model, resp.Usage.PromptTokens, resp.Usage.CompletionTokens)
 	}
 
 	return &reasoning, nil
@@ -188,7 +204,7 @@ func (c *Client) Reason(ctx context.Context, task string, pageContext string, hi
 
 // ReasonWithContext выполняет reasoning с дополнительным контекстом из Memory.
 // Используется когда есть relevant patterns из предыдущего опыта.
-func (c *Client) ReasonWithContext(ctx context.Context, task string, pageContext string, history *ReasoningHistory, memoryContext string, taskID *uint, stepID *uint) (*ReasoningStep, error) {
+func (c *Client) ReasonWithContext(ctx context.Context, task string, pageContext string, history *ReasoningHistory, transcript *ActionTranscript, memoryContext string, taskID *uint, stepID *uint) (*ReasoningStep, error) {
 	// Аналогично Reason(), но добавляем memory context в prompt
 	systemPrompt := `Ты автономный AI-агент для управления браузером.
 
@@ -232,6 +248,9 @@ func (c *Client) ReasonWithContext(ctx context.Context, task string, pageContext
 %s`, history.ToJSON())
 	}
 
+	userPrompt += formatTranscriptSection(transcript)
+	systemPrompt = applyProfilePrompt(ctx, systemPrompt)
+
 	resp, err := c.createChatCompletionWithRateLimit(ctx, openai.ChatCompletionRequest{
 		Model: c.model,
 		Messages: []openai.ChatCompletionMessage{
@@ -255,21 +274,35 @@ func (c *Client) ReasonWithContext(ctx context.Context, task string, pageContext
 	}
 
 	if len(resp.Choices) == 0 {
-		return nil, fmt.Errorf("пустой ответ reasoning от OpenAI")
+		return nil, fmt.Errorf("%w: пустой ответ reasoning от OpenAI", ErrMalformedResponse)
 	}
 
 	responseText := resp.Choices[0].Message.Content
 	var reasoning ReasoningStep
 	if err := json.Unmarshal([]byte(responseText), &reasoning); err != nil {
-		return nil, fmt.Errorf("ошибка парсинга reasoning JSON: %w", err)
+		return nil, fmt.Errorf("%w: ошибка парсинга reasoning JSON: %w", ErrMalformedResponse, err)
 	}
 
 	if c.logger != nil {
 		fullPrompt := formatPrompt(systemPrompt, userPrompt)
 		sanitizedPrompt := c.sanitizer.Sanitize(fullPrompt)
 		sanitizedResponse := c.sanitizer.Sanitize(responseText)
-		_ = c.logger.LogLLMRequest(ctx, taskID, stepID, "reasoning_with_context", sanitizedPrompt, sanitizedResponse, c.model, resp.Usage.TotalTokens)
+		_ = c.logger.LogLLMRequest(ctx, taskID, stepID, "reasoning_with_context", sanitizedPrompt, sanitizedResponse, c.model, resp.Usage.PromptTokens, resp.Usage.CompletionTokens)
 	}
 
 	return &reasoning, nil
 }
+
+// formatTranscriptSection возвращает секцию промпта с транскриптом действий
+// или пустую строку если действий еще не было.
+func formatTranscriptSection(transcript *ActionTranscript) string {
+	if transcript.Len() == 0 {
+		return ""
+	}
+	return fmt.Sprintf(`
+
+Предыдущие действия и их результаты:
+%s
+
+Не повторяй действия, которые уже завершились ошибкой или не изменили страницу.`, transcript.Format())
+}
@@ -0,0 +1,128 @@
+// Package llm - reflection module: оценка результата действия после его выполнения.
+// Сравнивает состояние страницы до и после шага и определяет, достигнута ли цель шага.
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// ReflectionVerdict - итог рефлексии по шагу.
+type ReflectionVerdict string
+
+const (
+	VerdictSuccess     ReflectionVerdict = "success"      // Цель шага достигнута
+	VerdictNoEffect    ReflectionVerdict = "no_effect"    // Действие выполнено, но страница не изменилась
+	VerdictWrongTarget ReflectionVerdict = "wrong_target" // Действие сработало, но не на тот элемент/не с тем результатом
+	VerdictError       ReflectionVerdict = "error"        // Действие завершилось ошибкой
+)
+
+// Reflection представляет структурированный результат рефлексии после действия.
+type Reflection struct {
+	StepNo         int               `json:"step_no"`
+	Verdict        ReflectionVerdict `json:"verdict"`
+	IntentAchieved bool              `json:"intent_achieved"`
+	ErrorCause     string            `json:"error_cause,omitempty"` // Причина неудачи (для всех вердиктов кроме success)
+	Explanation    string            `json:"explanation"`
+	Confidence     float64           `json:"confidence"`
+}
+
+// Succeeded возвращает true если цель шага достигнута.
+func (r *Reflection) Succeeded() bool {
+	return r != nil && r.Verdict == VerdictSuccess
+}
+
+// Reflect оценивает результат выполненного действия.
+// LLM получает намерение шага (plan.Reasoning), контекст страницы до и после действия
+// и текст результата, и возвращает вердикт: success / no_effect / wrong_target / error.
+func (c *Client) Reflect(ctx context.Context, task string, plan *StepPlan, pageBefore, pageAfter, result string, taskID *uint, stepID *uint) (*Reflection, error) {
+	systemPrompt := `Ты модуль рефлексии автономного AI-агента, управляющего браузером.
+
+Твоя задача - определить, достигло ли только что выполненное действие своей цели.
+Сравни состояние страницы ДО и ПОСЛЕ действия с намерением шага.
+
+Возможные вердикты:
+- "success" - намерение шага достигнуто
+- "no_effect" - действие выполнилось, но на странице ничего не изменилось
+- "wrong_target" - страница изменилась, но не так, как было задумано (кликнули не туда, ввели не в то поле)
+- "error" - страница показывает ошибку или действие явно провалилось
+
+Отвечай ТОЛЬКО в формате JSON:
+{
+  "verdict": "success",
+  "intent_achieved": true,
+  "error_cause": "причина неудачи (пусто при success)",
+  "explanation": "краткое объяснение",
+  "confidence": 0.8
+}`
+
+	userPrompt := fmt.Sprintf(`Задача: %s
+
+Выполненное действие:
+- Действие: %s
+- Селектор: %s
+- Значение: %s
+- Намерение: %s
+
+Результат выполнения: %s
+
+Страница ДО действия:
+%s
+
+Страница ПОСЛЕ действия:
+%s`, task, plan.Action, plan.Selector, plan.Value, plan.Reasoning, result, pageBefore, pageAfter)
+
+	resp, err := c.createChatCompletionWithRateLimit(ctx, openai.ChatCompletionRequest{
+		Model: c.model,
+		Messages: []openai.ChatCompletionMessage{
+			{
+				Role:    openai.ChatMessageRoleSystem,
+				Content: systemPrompt,
+			},
+			{
+				Role:    openai.ChatMessageRoleUser,
+				Content: userPrompt,
+			},
+		},
+		ResponseFormat: &openai.ChatCompletionResponseFormat{
+			Type: openai.ChatCompletionResponseFormatTypeJSONObject,
+		},
+		Temperature: 0.2,
+	})
+
+	if err != nil {
+		if c.logger != nil {
+			sanitizedPrompt := c.sanitizer.Sanitize(formatPrompt(systemPrompt, userPrompt))
+			sanitizedError := c.sanitizer.Sanitize(err.Error())
+			_ = c.logger.LogLLMRequest(ctx, taskID, stepID, "reflection_error", sanitizedPrompt, sanitizedError, c.model, 0, 0)
+		}
+		return nil, fmt.Errorf("ошибка reflection запроса к OpenAI: %w", err)
+	}
+
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("%w: пустой ответ reflection от OpenAI", ErrMalformedResponse)
+	}
+
+	responseText := resp.Choices[0].Message.Content
+	var reflection Reflection
+	if err := json.Unmarshal([]byte(responseText), &reflection); err != nil {
+		return nil, fmt.Errorf("%w: ошибка парсинга reflection JSON: %w", ErrMalformedResponse, err)
+	}
+
+	switch reflection.Verdict {
+	case VerdictSuccess, VerdictNoEffect, VerdictWrongTarget, VerdictError:
+	default:
+		return nil, fmt.Errorf("%w: неизвестный вердикт рефлексии: %q", ErrMalformedResponse, reflection.Verdict)
+	}
+
+	if c.logger != nil {
+		sanitizedPrompt := c.sanitizer.Sanitize(formatPrompt(systemPrompt, userPrompt))
+		sanitizedResponse := c.sanitizer.Sanitize(responseText)
+		_ = c.logger.LogLLMRequest(ctx, taskID, stepID, "reflection", sanitizedPrompt, sanitizedResponse, c.model, resp.Usage.PromptTokens, resp.Usage.CompletionTokens)
+	}
+
+	return &reflection, nil
+}
@@ -0,0 +1,132 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// AgentCandidate - специализированный агент, среди которых модель выбирает исполнителя задачи.
+type AgentCandidate struct {
+	Type        string
+	Description string
+	Expertise   []string
+}
+
+// RouteChoice - оценка модели, насколько агент подходит для задачи.
+type RouteChoice struct {
+	Agent      string  `json:"agent"`
+	Confidence float64 `json:"confidence"`
+	Rationale  string  `json:"rationale"`
+}
+
+// RouteTask ранжирует агентов-кандидатов для задачи: возвращает оценки по убыванию уверенности.
+// Агенты, которых нет среди кандидатов, отбрасываются.
+func (c *Client) RouteTask(ctx context.Context, task string, pageContext string, candidates []AgentCandidate, taskID *uint, stepID *uint) ([]RouteChoice, error) {
+	systemPrompt := `Ты маршрутизатор задач автономного AI-агента, управляющего браузером.
+
+Выбери, какой специализированный агент лучше выполнит задачу. Оценивай по сути задачи
+(что нужно сделать и на каком сайте), а не по отдельным словам: "пришли мне на почту список вакансий" -
+это поиск вакансий, а не работа с почтой.
+
+Правила:
+- Оцени каждого подходящего агента: confidence от 0.0 до 1.0
+- rationale - одно предложение, почему агент подходит или не подходит
+- Если ни один агент не подходит, верни всех с низкой уверенностью
+
+Отвечай ТОЛЬКО в формате JSON:
+{
+  "choices": [
+    {"agent": "тип агента", "confidence": 0.9, "rationale": "почему"}
+  ]
+}`
+
+	var sb strings.Builder
+	for _, candidate := range candidates {
+		fmt.Fprintf(&sb, "- %s: %s", candidate.Type, candidate.Description)
+		if len(candidate.Expertise) > 0 {
+			fmt.Fprintf(&sb, " (экспертиза: %s)", strings.Join(candidate.Expertise, ", "))
+		}
+		sb.WriteString("\n")
+	}
+
+	userPrompt := fmt.Sprintf(`Задача: %s
+
+Агенты:
+%s
+Текущая страница:
+%s`, task, sb.String(), pageContext)
+
+	resp, err := c.createChatCompletionWithRateLimit(ctx, openai.ChatCompletionRequest{
+		Model: c.model,
+		Messages: []openai.ChatCompletionMessage{
+			{
+				Role:    openai.ChatMessageRoleSystem,
+				Content: systemPrompt,
+			},
+			{
+				Role:    openai.ChatMessageRoleUser,
+				Content: userPrompt,
+			},
+		},
+		ResponseFormat: &openai.ChatCompletionResponseFormat{
+			Type: openai.ChatCompletionResponseFormatTypeJSONObject,
+		},
+		Temperature: 0.1,
+	})
+
+	if err != nil {
+		if c.logger != nil {
+			sanitizedPrompt := c.sanitizer.Sanitize(formatPrompt(systemPrompt, userPrompt))
+			sanitizedError := c.sanitizer.Sanitize(err.Error())
+			_ = c.logger.LogLLMRequest(ctx, taskID, stepID, "routing_error", sanitizedPrompt, sanitizedError, c.model, 0, 0)
+		}
+		return nil, fmt.Errorf("ошибка запроса маршрутизации к OpenAI: %w", err)
+	}
+
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("%w: пустой ответ маршрутизации от OpenAI", ErrMalformedResponse)
+	}
+
+	responseText := resp.Choices[0].Message.Content
+	if c.logger != nil {
+		sanitizedPrompt := c.sanitizer.Sanitize(formatPrompt(systemPrompt, userPrompt))
+		sanitizedResponse := c.sanitizer.Sanitize(responseText)
+		_ = c.logger.LogLLMRequest(ctx, taskID, stepID, "routing", sanitizedPrompt, sanitizedResponse, c.model, resp.Usage.PromptTokens, resp.Usage.CompletionTokens)
+	}
+
+	var parsed struct {
+		Choices []RouteChoice `json:"choices"`
+	}
+	if err := json.Unmarshal([]byte(responseText), &parsed); err != nil {
+		return nil, fmt.Errorf("%w: ошибка парсинга маршрутизации JSON: %w", ErrMalformedResponse, err)
+	}
+
+	known := make(map[string]bool, len(candidates))
+	for _, candidate := range candidates {
+		known[candidate.Type] = true
+	}
+	seen := make(map[string]bool)
+	choices := make([]RouteChoice, 0, len(parsed.Choices))
+	for _, choice := range parsed.Choices {
+		choice.Agent = strings.TrimSpace(choice.Agent)
+		if !known[choice.Agent] || seen[choice.Agent] {
+			continue
+		}
+		seen[choice.Agent] = true
+		choice.Confidence = min(max(choice.Confidence, 0), 1)
+		choices = append(choices, choice)
+	}
+	if len(choices) == 0 {
+		return nil, fmt.Errorf("%w: модель не выбрала ни одного известного агента", ErrMalformedResponse)
+	}
+
+	sort.SliceStable(choices, func(i, j int) bool {
+		return choices[i].Confidence > choices[j].Confidence
+	})
+	return choices, nil
+}
@@ -46,11 +46,11 @@ func (c *Client) CheckSensitiveData(ctx context.Context, text string) (bool, err
 	})
 
 	if err != nil {
-		return false, fmt.Errorf("ошибка запроса к OpenAI: %w", err)
+		return false, fmt.Errorf("ошибка запроса к OpenAI: %w", wrapAPIError(err))
 	}
 
 	if len(resp.Choices) == 0 {
-		return false, fmt.Errorf("пустой ответ от OpenAI")
+		return false, fmt.Errorf("%w: пустой ответ от OpenAI", ErrMalformedResponse)
 	}
 
 	responseText := resp.Choices[0].Message.Content
@@ -60,7 +60,7 @@ func (c *Client) CheckSensitiveData(ctx context.Context, text string) (bool, err
 		Reason      string `json:"reason"`
 	}
 	if err := json.Unmarshal([]byte(responseText), &result); err != nil {
-		return false, fmt.Errorf("ошибка парсинга ответа: %w", err)
+		return false, fmt.Errorf("%w: ошибка парсинга ответа: %w", ErrMalformedResponse, err)
 	}
 
 	return result.IsSensitive, nil
@@ -55,11 +55,11 @@ func (c *Client) CheckDangerousAction(ctx context.Context, action, selector, val
 	})
 
 	if err != nil {
-		return false, "", fmt.Errorf("ошибка запроса к OpenAI для проверки безопасности: %w", err)
+		return false, "", fmt.Errorf("ошибка запроса к OpenAI для проверки безопасности: %w", wrapAPIError(err))
 	}
 
 	if len(resp.Choices) == 0 {
-		return false, "", fmt.Errorf("пустой ответ от OpenAI")
+		return false, "", fmt.Errorf("%w: пустой ответ от OpenAI", ErrMalformedResponse)
 	}
 
 	responseText := resp.Choices[0].Message.Content
@@ -73,13 +73,13 @@ func (c *Client) CheckDangerousAction(ctx context.Context, action, selector, val
 
 	var result SecurityCheckResult
 	if err := json.Unmarshal([]byte(responseText), &result); err != nil {
-		return false, "", fmt.Errorf("ошибка парсинга ответа безопасности: %w", err)
+		return false, "", fmt.Errorf("%w: ошибка парсинга ответа безопасности: %w", ErrMalformedResponse, err)
 	}
 
 	if c.logger != nil {
 		sanitizedPrompt := c.sanitizer.Sanitize(prompt)
 		sanitizedResponse := c.sanitizer.Sanitize(responseText)
-		_ = c.logger.LogLLMRequest(ctx, nil, nil, "security_check", sanitizedPrompt, sanitizedResponse, c.model, resp.Usage.TotalTokens)
+		_ = c.logger.LogLLMRequest(ctx, nil, nil, "security_check", sanitizedPrompt, sanitizedResponse, c.model, resp.Usage.PromptTokens, resp.Usage.CompletionTokens)
 	}
 
 	return result.IsDangerous, result.Message, nil
@@ -0,0 +1,173 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// Subgoal - подзадача в дереве декомпозиции задачи.
+// Подзадача с дочерними выполнена, когда выполнены все дочерние.
+type Subgoal struct {
+	Title        string    `json:"title"`
+	SuccessCheck string    `json:"success_check"` // Как проверить по странице, что подзадача выполнена
+	StepBudget   int       `json:"step_budget"`   // Бюджет шагов (для подзадачи с дочерними - не используется)
+	Subgoals     []Subgoal `json:"subgoals,omitempty"`
+}
+
+// SubgoalProgress - состояние подзадачи, передаваемое модели при перепланировании.
+type SubgoalProgress struct {
+	Title  string
+	Status string
+	Result string
+}
+
+const subgoalsFormat = `Отвечай ТОЛЬКО в формате JSON:
+{
+  "subgoals": [
+    {
+      "title": "подзадача",
+      "success_check": "проверяемый по странице признак выполнения",
+      "step_budget": 5,
+      "subgoals": []
+    }
+  ]
+}`
+
+// DecomposeTask разбивает задачу на упорядоченное дерево подзадач (не более двух уровней).
+// maxSteps - общий бюджет шагов задачи, который распределяется между подзадачами.
+func (c *Client) DecomposeTask(ctx context.Context, task string, pageContext string, maxSteps int, taskID *uint, stepID *uint) ([]Subgoal, error) {
+	systemPrompt := `Ты планировщик автономного AI-агента, управляющего браузером.
+
+Разбей задачу на упорядоченные подзадачи, которые агент выполнит по очереди.
+Правила:
+- 2-7 подзадач верхнего уровня; крупную подзадачу можно разбить на дочерние (не глубже одного уровня)
+- Каждая подзадача - законченный этап с результатом, видимым на странице (не отдельный клик)
+- success_check - конкретный признак на странице или в собранных данных, что этап выполнен
+- step_budget - сколько действий браузера нужно на этап; сумма бюджетов не должна превышать общий бюджет
+- Повторяющиеся действия (например, обработка 10 писем) оформляй одной подзадачей с достаточным бюджетом
+
+` + subgoalsFormat
+
+	userPrompt := fmt.Sprintf(`Задача: %s
+
+Общий бюджет шагов: %d
+
+Текущая страница:
+%s`, task, maxSteps, pageContext)
+
+	return c.requestSubgoals(ctx, systemPrompt, userPrompt, "decompose", taskID, stepID)
+}
+
+// ReplanSubgoals перестраивает оставшуюся часть дерева подзадач после неудачи подзадачи.
+// Выполненные подзадачи сохраняются, модель возвращает новые подзадачи вместо невыполненных.
+func (c *Client) ReplanSubgoals(ctx context.Context, task string, pageContext string, progress []SubgoalProgress, failed string, reason string, maxSteps int, taskID *uint, stepID *uint) ([]Subgoal, error) {
+	systemPrompt := `Ты планировщик автономного AI-агента, управляющего браузером.
+
+Подзадача не выполнена. Составь новые подзадачи для ОСТАВШЕЙСЯ части задачи.
+Правила:
+- Не повторяй уже выполненные подзадачи
+- Учитывай причину неудачи: выбери другой путь к цели, а не ту же формулировку
+- Сумма step_budget не должна превышать оставшийся бюджет шагов
+- Дочерние подзадачи допускаются, но не глубже одного уровня
+
+` + subgoalsFormat
+
+	var sb strings.Builder
+	for i, p := range progress {
+		fmt.Fprintf(&sb, "%d. [%s] %s", i+1, p.Status, p.Title)
+		if p.Result != "" {
+			fmt.Fprintf(&sb, " - %s", p.Result)
+		}
+		sb.WriteString("\n")
+	}
+
+	userPrompt := fmt.Sprintf(`Задача: %s
+
+Ход выполнения:
+%s
+Не выполнена подзадача: %s
+Причина: %s
+
+Оставшийся бюджет шагов: %d
+
+Текущая страница:
+%s`, task, sb.String(), failed, reason, maxSteps, pageContext)
+
+	return c.requestSubgoals(ctx, systemPrompt, userPrompt, "subreplan", taskID, stepID)
+}
+
+func (c *Client) requestSubgoals(ctx context.Context, systemPrompt, userPrompt, role string, taskID *uint, stepID *uint) ([]Subgoal, error) {
+	resp, err := c.createChatCompletionWithRateLimit(ctx, openai.ChatCompletionRequest{
+		Model: c.model,
+		Messages: []openai.ChatCompletionMessage{
+			{
+				Role:    openai.ChatMessageRoleSystem,
+				Content: systemPrompt,
+			},
+			{
+				Role:    openai.ChatMessageRoleUser,
+				Content: userPrompt,
+			},
+		},
+		ResponseFormat: &openai.ChatCompletionResponseFormat{
+			Type: openai.ChatCompletionResponseFormatTypeJSONObject,
+		},
+		Temperature: 0.3,
+	})
+
+	if err != nil {
+		if c.logger != nil {
+			sanitizedPrompt := c.sanitizer.Sanitize(formatPrompt(systemPrompt, userPrompt))
+			sanitizedError := c.sanitizer.Sanitize(err.Error())
+			_ = c.logger.LogLLMRequest(ctx, taskID, stepID, role+"_error", sanitizedPrompt, sanitizedError, c.model, 0, 0)
+		}
+		return nil, fmt.Errorf("ошибка запроса подзадач к OpenAI: %w", err)
+	}
+
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("%w: пустой ответ подзадач от OpenAI", ErrMalformedResponse)
+	}
+
+	responseText := resp.Choices[0].Message.Content
+	if c.logger != nil {
+		sanitizedPrompt := c.sanitizer.Sanitize(formatPrompt(systemPrompt, userPrompt))
+		sanitizedResponse := c.sanitizer.Sanitize(responseText)
+		_ = c.logger.LogLLMRequest(ctx, taskID, stepID, role, sanitizedPrompt, sanitizedResponse, c.model, resp.Usage.PromptTokens, resp.Usage.CompletionTokens)
+	}
+
+	var parsed struct {
+		Subgoals []Subgoal `json:"subgoals"`
+	}
+	if err := json.Unmarshal([]byte(responseText), &parsed); err != nil {
+		return nil, fmt.Errorf("%w: ошибка парсинга подзадач JSON: %w", ErrMalformedResponse, err)
+	}
+
+	subgoals := cleanSubgoals(parsed.Subgoals, 0)
+	if len(subgoals) == 0 {
+		return nil, fmt.Errorf("%w: модель не вернула ни одной подзадачи", ErrMalformedResponse)
+	}
+	return subgoals, nil
+}
+
+// cleanSubgoals отбрасывает подзадачи без формулировки и обрезает дерево до двух уровней.
+func cleanSubgoals(subgoals []Subgoal, depth int) []Subgoal {
+	cleaned := make([]Subgoal, 0, len(subgoals))
+	for _, s := range subgoals {
+		s.Title = strings.TrimSpace(s.Title)
+		if s.Title == "" {
+			continue
+		}
+		s.SuccessCheck = strings.TrimSpace(s.SuccessCheck)
+		if depth == 0 {
+			s.Subgoals = cleanSubgoals(s.Subgoals, depth+1)
+		} else {
+			s.Subgoals = nil
+		}
+		cleaned = append(cleaned, s)
+	}
+	return cleaned
+}
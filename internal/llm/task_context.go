@@ -0,0 +1,32 @@
+package llm
+
+import "context"
+
+type taskIDKey struct{}
+
+// WithTaskID возвращает контекст с ID выполняемой задачи.
+// LLM запросы, вызванные без явного taskID (проверка безопасности, попапы, multi-step),
+// логируются с этим ID, чтобы расход токенов учитывался в бюджете задачи.
+func WithTaskID(ctx context.Context, taskID uint) context.Context {
+	return context.WithValue(ctx, taskIDKey{}, taskID)
+}
+
+// TaskIDFromContext возвращает ID задачи из контекста (nil если не задан).
+func TaskIDFromContext(ctx context.Context) *uint {
+	if id, ok := ctx.Value(taskIDKey{}).(uint); ok {
+		return &id
+	}
+	return nil
+}
+
+// taskAwareLogger подставляет ID задачи из контекста в логи запросов без явного taskID.
+type taskAwareLogger struct {
+	Logger
+}
+
+func (l taskAwareLogger) LogLLMRequest(ctx context.Context, taskID *uint, stepID *uint, role, promptText, responseText, model string, promptTokens, completionTokens int) error {
+	if taskID == nil {
+		taskID = TaskIDFromContext(ctx)
+	}
+	return l.Logger.LogLLMRequest(ctx, taskID, stepID, role, promptText, responseText, model, promptTokens, completionTokens)
+}
@@ -113,5 +113,26 @@ func getTools() []openai.Tool {
 				},
 			},
 		},
+		{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "takeover",
+				Description: "Передать управление окном браузера пользователю. Используй когда шаг может выполнить только человек: код 2FA, капча, нестандартный виджет. После возврата управления ты получишь описание изменений на странице.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"reason": map[string]interface{}{
+							"type":        "string",
+							"description": "Что пользователь должен сделать в браузере (например: 'введите код подтверждения из SMS')",
+						},
+						"reasoning": map[string]interface{}{
+							"type":        "string",
+							"description": "Объяснение почему агент не может выполнить этот шаг сам",
+						},
+					},
+					"required": []string{"reason", "reasoning"},
+				},
+			},
+		},
 	}
 }
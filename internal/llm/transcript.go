@@ -0,0 +1,168 @@
+// Package llm - транскрипт действий агента (action/observation log).
+// Позволяет модели видеть что она уже делала на предыдущих шагах и чем это закончилось.
+package llm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultTranscriptWindow - количество последних шагов, которые передаются в LLM полностью.
+const DefaultTranscriptWindow = 8
+
+// TranscriptEntry представляет одну запись транскрипта: действие и его наблюдаемый результат.
+type TranscriptEntry struct {
+	StepNo      int      `json:"step_no"`                // Номер шага
+	Plan        StepPlan `json:"plan"`                   // Выполненное действие
+	Result      string   `json:"result,omitempty"`       // Результат выполнения
+	ErrorType   string   `json:"error_type,omitempty"`   // Тип ошибки (пусто если шаг успешен)
+	URLBefore   string   `json:"url_before,omitempty"`   // URL до действия
+	URLAfter    string   `json:"url_after,omitempty"`    // URL после действия
+	TitleBefore string   `json:"title_before,omitempty"` // Заголовок до действия
+	TitleAfter  string   `json:"title_after,omitempty"`  // Заголовок после действия
+	Observation string   `json:"observation,omitempty"`  // Дополнительное наблюдение для модели (например, о зацикливании)
+}
+
+// Failed возвращает true если шаг завершился ошибкой.
+func (e TranscriptEntry) Failed() bool {
+	return e.ErrorType != ""
+}
+
+// ActionTranscript хранит историю действий и наблюдений в рамках одной задачи.
+// Старые записи за пределами окна сворачиваются в краткую сводку.
+type ActionTranscript struct {
+	Entries []TranscriptEntry `json:"entries"`
+	Window  int               `json:"window"`
+}
+
+// NewActionTranscript создает транскрипт с заданным окном.
+// Если window <= 0, используется DefaultTranscriptWindow.
+func NewActionTranscript(window int) *ActionTranscript {
+	if window <= 0 {
+		window = DefaultTranscriptWindow
+	}
+	return &ActionTranscript{Window: window}
+}
+
+// Add добавляет запись в транскрипт.
+func (t *ActionTranscript) Add(entry TranscriptEntry) {
+	t.Entries = append(t.Entries, entry)
+}
+
+// Len возвращает количество записей в транскрипте.
+func (t *ActionTranscript) Len() int {
+	if t == nil {
+		return 0
+	}
+	return len(t.Entries)
+}
+
+// Format форматирует транскрипт для передачи в промпт.
+// Последние Window записей выводятся полностью, более старые - сводкой.
+func (t *ActionTranscript) Format() string {
+	if t.Len() == 0 {
+		return ""
+	}
+
+	window := t.Window
+	if window <= 0 {
+		window = DefaultTranscriptWindow
+	}
+
+	var sb strings.Builder
+
+	recent := t.Entries
+	if len(t.Entries) > window {
+		older := t.Entries[:len(t.Entries)-window]
+		recent = t.Entries[len(t.Entries)-window:]
+		sb.WriteString(summarizeEntries(older))
+		sb.WriteString("\n")
+	}
+
+	for _, e := range recent {
+		sb.WriteString(formatEntry(e))
+		sb.WriteString("\n")
+	}
+
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+func formatEntry(e TranscriptEntry) string {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "[Шаг %d] %s", e.StepNo, e.Plan.Action)
+	if e.Plan.Selector != "" {
+		fmt.Fprintf(&sb, " selector=%q", e.Plan.Selector)
+	}
+	if e.Plan.Value != "" {
+		fmt.Fprintf(&sb, " value=%q", truncate(e.Plan.Value, 80))
+	}
+
+	if e.Failed() {
+		fmt.Fprintf(&sb, "\n  Итог: ОШИБКА (%s) %s", e.ErrorType, truncate(e.Result, 200))
+	} else {
+		fmt.Fprintf(&sb, "\n  Итог: OK %s", truncate(e.Result, 200))
+	}
+
+	switch {
+	case e.URLBefore != e.URLAfter && e.URLAfter != "":
+		fmt.Fprintf(&sb, "\n  Страница: %s -> %s", e.URLBefore, e.URLAfter)
+	case e.TitleBefore != e.TitleAfter && e.TitleAfter != "":
+		fmt.Fprintf(&sb, "\n  Заголовок: %q -> %q", e.TitleBefore, e.TitleAfter)
+	default:
+		sb.WriteString("\n  Страница не изменилась")
+	}
+
+	if e.Observation != "" {
+		fmt.Fprintf(&sb, "\n  Наблюдение: %s", e.Observation)
+	}
+
+	return sb.String()
+}
+
+// summarizeEntries сворачивает старые записи в одну строку со статистикой.
+func summarizeEntries(entries []TranscriptEntry) string {
+	actions := make(map[string]int)
+	var order []string
+	failed := 0
+	var urls []string
+	seenURL := make(map[string]bool)
+
+	for _, e := range entries {
+		if _, ok := actions[e.Plan.Action]; !ok {
+			order = append(order, e.Plan.Action)
+		}
+		actions[e.Plan.Action]++
+		if e.Failed() {
+			failed++
+		}
+		if e.URLAfter != "" && !seenURL[e.URLAfter] {
+			seenURL[e.URLAfter] = true
+			urls = append(urls, e.URLAfter)
+		}
+	}
+
+	parts := make([]string, 0, len(order))
+	for _, action := range order {
+		parts = append(parts, fmt.Sprintf("%s x%d", action, actions[action]))
+	}
+
+	summary := fmt.Sprintf("[Шаги %d-%d, сводка] %s; ошибок: %d",
+		entries[0].StepNo, entries[len(entries)-1].StepNo, strings.Join(parts, ", "), failed)
+	if len(urls) > 0 {
+		if len(urls) > 5 {
+			urls = urls[len(urls)-5:]
+		}
+		summary += "; посещено: " + strings.Join(urls, ", ")
+	}
+
+	return summary
+}
+
+func truncate(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max]) + "..."
+}
@@ -2,12 +2,15 @@
 // Включает rate limiting, логирование запросов и проверку безопасности действий.
 package llm
 
-import "context"
+import (
+	"context"
+	"encoding/json"
+)
 
 // Logger определяет интерфейс для логирования LLM запросов.
 type Logger interface {
 	// LogLLMRequest сохраняет информацию о запросе к LLM в базу данных.
-	LogLLMRequest(ctx context.Context, taskID *uint, stepID *uint, role, promptText, responseText, model string, tokensUsed int) error
+	LogLLMRequest(ctx context.Context, taskID *uint, stepID *uint, role, promptText, responseText, model string, promptTokens, completionTokens int) error
 }
 
 // LLMClient определяет интерфейс для взаимодействия с LLM.
@@ -15,19 +18,43 @@ type Logger interface {
 type LLMClient interface {
 	// Reason выполняет фазу явного рассуждения перед планированием действия (ReAct pattern).
 	// Агент анализирует ситуацию, вырабатывает стратегию и оценивает уверенность.
-	Reason(ctx context.Context, task string, pageContext string, history *ReasoningHistory, taskID *uint, stepID *uint) (*ReasoningStep, error)
+	// transcript содержит предыдущие действия и их результаты (может быть nil).
+	Reason(ctx context.Context, task string, pageContext string, history *ReasoningHistory, transcript *ActionTranscript, taskID *uint, stepID *uint) (*ReasoningStep, error)
 
 	// ReasonWithContext выполняет reasoning с учетом релевантных паттернов из памяти агента.
-	ReasonWithContext(ctx context.Context, task string, pageContext string, history *ReasoningHistory, memoryContext string, taskID *uint, stepID *uint) (*ReasoningStep, error)
+	ReasonWithContext(ctx context.Context, task string, pageContext string, history *ReasoningHistory, transcript *ActionTranscript, memoryContext string, taskID *uint, stepID *uint) (*ReasoningStep, error)
 
 	// PlanActionWithReasoning планирует действие с учетом reasoning context (ReAct pattern).
 	// Reasoning направляет планирование - агент планирует на основе выработанной стратегии.
-	PlanActionWithReasoning(ctx context.Context, task string, pageContext string, reasoning *ReasoningStep, taskID *uint, stepID *uint) (*StepPlan, error)
+	// transcript позволяет не повторять действия, которые уже провалились.
+	PlanActionWithReasoning(ctx context.Context, task string, pageContext string, reasoning *ReasoningStep, transcript *ActionTranscript, taskID *uint, stepID *uint) (*StepPlan, error)
 
 	// PlanAction планирует следующее действие на основе задачи и контекста страницы (legacy).
 	// Для новой архитектуры с ReAct pattern используй PlanActionWithReasoning.
 	PlanAction(ctx context.Context, task string, pageContext string, taskID *uint, stepID *uint) (*StepPlan, error)
 
+	// Reflect оценивает результат выполненного действия, сравнивая страницу до и после него.
+	Reflect(ctx context.Context, task string, plan *StepPlan, pageBefore, pageAfter, result string, taskID *uint, stepID *uint) (*Reflection, error)
+
+	// DeriveCriteria формулирует проверяемые критерии успеха задачи, если пользователь их не задал.
+	DeriveCriteria(ctx context.Context, task string, pageContext string, taskID *uint, stepID *uint) ([]string, error)
+
+	// VerifyCompletion проверяет критерии успеха перед тем как принять завершение задачи.
+	VerifyCompletion(ctx context.Context, task string, criteria []string, pageContext, results string, transcript *ActionTranscript, taskID *uint, stepID *uint) (*CompletionVerdict, error)
+
+	// ExtractRecords извлекает со страницы записи по JSON схеме записи (структурированный результат задачи).
+	ExtractRecords(ctx context.Context, task string, pageContext string, recordSchema string, taskID *uint, stepID *uint) ([]json.RawMessage, error)
+
+	// DecomposeTask разбивает задачу на упорядоченное дерево подзадач с бюджетами шагов и проверками завершения.
+	DecomposeTask(ctx context.Context, task string, pageContext string, maxSteps int, taskID *uint, stepID *uint) ([]Subgoal, error)
+
+	// ReplanSubgoals перестраивает оставшиеся подзадачи после неудачи подзадачи failed.
+	ReplanSubgoals(ctx context.Context, task string, pageContext string, progress []SubgoalProgress, failed string, reason string, maxSteps int, taskID *uint, stepID *uint) ([]Subgoal, error)
+
+	// RouteTask ранжирует специализированных агентов для задачи по их описаниям и экспертизе.
+	// Возвращает оценки с уверенностью и обоснованием по убыванию уверенности.
+	RouteTask(ctx context.Context, task string, pageContext string, candidates []AgentCandidate, taskID *uint, stepID *uint) ([]RouteChoice, error)
+
 	// CheckDangerousAction проверяет является ли действие потенциально опасным.
 	CheckDangerousAction(ctx context.Context, action, selector, value, reasoning string) (bool, string, error)
 
@@ -36,6 +63,10 @@ type LLMClient interface {
 
 	// Replan пересоздает план после ошибки выполнения шага.
 	Replan(ctx context.Context, task string, pageContext string, originalPlan *MultiStepPlan, failedStep *StepPlan, errorMessage string, maxSteps int, taskID *uint, stepID *uint) (*MultiStepPlan, error)
+
+	// PatchStep подбирает новый селектор для шага запомненного пути по намерению шага.
+	// nil без ошибки - подходящего элемента на странице нет.
+	PatchStep(ctx context.Context, task string, pageContext string, step *StepPlan, problem string, taskID *uint, stepID *uint) (*StepPlan, error)
 }
 
 // StepPlan представляет план одного шага действия.
@@ -0,0 +1,2 @@
+ALTER TABLE agent_steps DROP COLUMN IF EXISTS verdict_reason;
+ALTER TABLE agent_steps DROP COLUMN IF EXISTS verdict;
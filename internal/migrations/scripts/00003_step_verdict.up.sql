@@ -0,0 +1,2 @@
+ALTER TABLE agent_steps ADD COLUMN IF NOT EXISTS verdict VARCHAR(32);
+ALTER TABLE agent_steps ADD COLUMN IF NOT EXISTS verdict_reason TEXT;
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS task_checkpoints (
+    task_id           INT PRIMARY KEY REFERENCES tasks(id) ON DELETE CASCADE,
+    step_no           INT NOT NULL,
+    reasoning_history JSONB,
+    transcript        JSONB,
+    last_url          TEXT,
+    storage_state     JSONB,
+    updated_at        TIMESTAMP NOT NULL DEFAULT NOW()
+);
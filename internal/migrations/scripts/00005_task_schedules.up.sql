@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS task_schedules (
+    id            SERIAL PRIMARY KEY,
+    task_id       INT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    cron_expr     VARCHAR(128) NOT NULL,
+    enabled       BOOLEAN NOT NULL DEFAULT TRUE,
+    next_run_at   TIMESTAMP NOT NULL,
+    last_run_at   TIMESTAMP,
+    last_task_id  INT REFERENCES tasks(id) ON DELETE SET NULL,
+    last_status   VARCHAR(32),
+    last_error    TEXT,
+    created_at    TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at    TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_task_schedules_due ON task_schedules(enabled, next_run_at);
+
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS schedule_id INT REFERENCES task_schedules(id) ON DELETE SET NULL;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_llm_logs_created_at;
+
+ALTER TABLE tasks DROP COLUMN IF EXISTS cost_budget;
+ALTER TABLE tasks DROP COLUMN IF EXISTS token_budget;
@@ -0,0 +1,4 @@
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS token_budget INT NOT NULL DEFAULT 0;
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS cost_budget NUMERIC(12, 4) NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_llm_logs_created_at ON llm_logs(created_at);
@@ -0,0 +1,3 @@
+ALTER TABLE agent_steps DROP COLUMN IF EXISTS loop_detail;
+ALTER TABLE agent_steps DROP COLUMN IF EXISTS loop_escalation;
+ALTER TABLE agent_steps DROP COLUMN IF EXISTS loop_kind;
@@ -0,0 +1,3 @@
+ALTER TABLE agent_steps ADD COLUMN IF NOT EXISTS loop_kind VARCHAR(32);
+ALTER TABLE agent_steps ADD COLUMN IF NOT EXISTS loop_escalation VARCHAR(16);
+ALTER TABLE agent_steps ADD COLUMN IF NOT EXISTS loop_detail TEXT;
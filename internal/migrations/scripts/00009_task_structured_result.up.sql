@@ -0,0 +1,2 @@
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS output_schema JSONB;
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS result JSONB;
@@ -0,0 +1 @@
+ALTER TABLE agent_steps DROP COLUMN IF EXISTS dry_run;
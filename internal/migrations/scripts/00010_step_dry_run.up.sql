@@ -0,0 +1 @@
+ALTER TABLE agent_steps ADD COLUMN IF NOT EXISTS dry_run BOOLEAN NOT NULL DEFAULT false;
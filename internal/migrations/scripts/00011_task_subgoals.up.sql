@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS task_subgoals (
+    id             SERIAL PRIMARY KEY,
+    task_id        INT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    parent_id      INT REFERENCES task_subgoals(id) ON DELETE CASCADE,
+    position       INT NOT NULL,
+    revision       INT NOT NULL DEFAULT 0,
+    title          TEXT NOT NULL,
+    success_check  TEXT,
+    step_budget    INT NOT NULL DEFAULT 0,
+    steps_used     INT NOT NULL DEFAULT 0,
+    status         VARCHAR(32) NOT NULL DEFAULT 'pending',
+    result         TEXT,
+    created_at     TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at     TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_task_subgoals_task ON task_subgoals(task_id, position);
@@ -0,0 +1,2 @@
+ALTER TABLE tasks DROP COLUMN IF EXISTS routing;
+ALTER TABLE tasks DROP COLUMN IF EXISTS routed_agent;
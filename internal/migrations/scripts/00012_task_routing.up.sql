@@ -0,0 +1,2 @@
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS routed_agent VARCHAR(64);
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS routing JSONB;
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS task_attempts (
+    id           SERIAL PRIMARY KEY,
+    task_id      INT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    attempt_no   INT NOT NULL,
+    agent        VARCHAR(64) NOT NULL,
+    first_step   INT NOT NULL,
+    last_step    INT NOT NULL DEFAULT 0,
+    status       VARCHAR(32) NOT NULL DEFAULT 'running',
+    error        TEXT,
+    started_at   TIMESTAMP NOT NULL DEFAULT NOW(),
+    finished_at  TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_task_attempts_task ON task_attempts(task_id, attempt_no);
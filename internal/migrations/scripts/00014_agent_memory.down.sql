@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS memory_sites;
+DROP TABLE IF EXISTS memory_failures;
+DROP TABLE IF EXISTS memory_paths;
@@ -0,0 +1,42 @@
+CREATE TABLE IF NOT EXISTS memory_paths (
+    id             SERIAL PRIMARY KEY,
+    task_hash      VARCHAR(64) NOT NULL,
+    task           TEXT,
+    domain         VARCHAR(255),
+    steps          JSONB NOT NULL,
+    strategy       TEXT,
+    success_count  INT NOT NULL DEFAULT 1,
+    average_ms     BIGINT NOT NULL DEFAULT 0,
+    last_used      TIMESTAMP NOT NULL,
+    expires_at     TIMESTAMP,
+    created_at     TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at     TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_memory_paths_task_hash ON memory_paths(task_hash);
+CREATE INDEX IF NOT EXISTS idx_memory_paths_domain ON memory_paths(domain);
+CREATE INDEX IF NOT EXISTS idx_memory_paths_expires_at ON memory_paths(expires_at);
+
+CREATE TABLE IF NOT EXISTS memory_failures (
+    id          SERIAL PRIMARY KEY,
+    error_type  VARCHAR(32) NOT NULL,
+    action      VARCHAR(64) NOT NULL,
+    selector    TEXT NOT NULL DEFAULT '',
+    count       INT NOT NULL DEFAULT 1,
+    recovery    TEXT,
+    last_seen   TIMESTAMP NOT NULL,
+    expires_at  TIMESTAMP,
+    UNIQUE (error_type, action, selector)
+);
+
+CREATE INDEX IF NOT EXISTS idx_memory_failures_expires_at ON memory_failures(expires_at);
+
+CREATE TABLE IF NOT EXISTS memory_sites (
+    domain           VARCHAR(255) PRIMARY KEY,
+    common_patterns  JSONB,
+    form_structure   JSONB,
+    last_visited     TIMESTAMP NOT NULL,
+    expires_at       TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_memory_sites_expires_at ON memory_sites(expires_at);
@@ -0,0 +1,3 @@
+ALTER TABLE memory_sites DROP COLUMN IF EXISTS embedding;
+ALTER TABLE memory_failures DROP COLUMN IF EXISTS embedding;
+ALTER TABLE memory_paths DROP COLUMN IF EXISTS embedding;
@@ -0,0 +1,3 @@
+ALTER TABLE memory_paths ADD COLUMN IF NOT EXISTS embedding JSONB;
+ALTER TABLE memory_failures ADD COLUMN IF NOT EXISTS embedding JSONB;
+ALTER TABLE memory_sites ADD COLUMN IF NOT EXISTS embedding JSONB;
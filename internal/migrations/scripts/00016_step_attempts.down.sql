@@ -0,0 +1,2 @@
+ALTER TABLE agent_steps DROP COLUMN IF EXISTS attempt_log;
+ALTER TABLE agent_steps DROP COLUMN IF EXISTS attempts;
@@ -0,0 +1,2 @@
+ALTER TABLE agent_steps ADD COLUMN IF NOT EXISTS attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE agent_steps ADD COLUMN IF NOT EXISTS attempt_log JSONB;
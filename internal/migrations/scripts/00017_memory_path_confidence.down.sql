@@ -0,0 +1,2 @@
+ALTER TABLE memory_paths DROP COLUMN IF EXISTS last_match;
+ALTER TABLE memory_paths DROP COLUMN IF EXISTS confidence;
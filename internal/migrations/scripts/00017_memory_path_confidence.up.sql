@@ -0,0 +1,2 @@
+ALTER TABLE memory_paths ADD COLUMN IF NOT EXISTS confidence NUMERIC(4, 3) NOT NULL DEFAULT 1;
+ALTER TABLE memory_paths ADD COLUMN IF NOT EXISTS last_match NUMERIC(4, 3) NOT NULL DEFAULT 0;
@@ -0,0 +1 @@
+ALTER TABLE memory_failures DROP COLUMN IF EXISTS task;
@@ -0,0 +1 @@
+ALTER TABLE memory_failures ADD COLUMN IF NOT EXISTS task TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+ALTER TABLE llm_logs DROP COLUMN IF EXISTS completion_tokens;
+ALTER TABLE llm_logs DROP COLUMN IF EXISTS prompt_tokens;
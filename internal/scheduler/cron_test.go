@@ -0,0 +1,84 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+	}{
+		{"мало полей", "0 9 * *"},
+		{"много полей", "0 9 * * * *"},
+		{"минута вне диапазона", "60 * * * *"},
+		{"час вне диапазона", "0 24 * * *"},
+		{"день месяца ноль", "0 0 0 * *"},
+		{"месяц вне диапазона", "0 0 1 13 *"},
+		{"день недели вне диапазона", "0 0 * * 8"},
+		{"обратный диапазон", "0 18-9 * * *"},
+		{"нулевой шаг", "*/0 * * * *"},
+		{"шаг не число", "*/x * * * *"},
+		{"пустой элемент списка", "1,,2 * * * *"},
+		{"не число", "a * * * *"},
+		{"неизвестный макрос", "@yearly"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseCron(tt.expr); err == nil {
+				t.Errorf("ParseCron(%q): ожидалась ошибка", tt.expr)
+			}
+		})
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	// Среда, 15 января 2025, 10:30
+	from := time.Date(2025, 1, 15, 10, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		expr string
+		want time.Time
+	}{
+		{"каждую минуту", "* * * * *", time.Date(2025, 1, 15, 10, 31, 0, 0, time.UTC)},
+		{"строго после текущей минуты", "30 10 * * *", time.Date(2025, 1, 16, 10, 30, 0, 0, time.UTC)},
+		{"шаг минут", "*/15 * * * *", time.Date(2025, 1, 15, 10, 45, 0, 0, time.UTC)},
+		{"шаг от значения", "5/20 * * * *", time.Date(2025, 1, 15, 10, 45, 0, 0, time.UTC)},
+		{"список часов", "0 9,18 * * *", time.Date(2025, 1, 15, 18, 0, 0, 0, time.UTC)},
+		{"диапазон с шагом", "0 9-18/3 * * *", time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)},
+		{"будни", "0 9 * * 1-5", time.Date(2025, 1, 16, 9, 0, 0, 0, time.UTC)},
+		{"воскресенье как 0", "0 9 * * 0", time.Date(2025, 1, 19, 9, 0, 0, 0, time.UTC)},
+		{"воскресенье как 7", "0 9 * * 7", time.Date(2025, 1, 19, 9, 0, 0, 0, time.UTC)},
+		{"следующий месяц", "0 0 1 * *", time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"следующий год", "0 0 1 1 *", time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"день месяца или день недели", "0 0 20 * 5", time.Date(2025, 1, 17, 0, 0, 0, 0, time.UTC)},
+		{"макрос @hourly", "@hourly", time.Date(2025, 1, 15, 11, 0, 0, 0, time.UTC)},
+		{"макрос @weekly", "@weekly", time.Date(2025, 1, 19, 0, 0, 0, 0, time.UTC)},
+		{"макрос в верхнем регистре", "@DAILY", time.Date(2025, 1, 16, 0, 0, 0, 0, time.UTC)},
+		{"29 февраля", "0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"30 февраля не наступает", "0 0 30 2 *", time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := ParseCron(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseCron(%q): %v", tt.expr, err)
+			}
+			if got := c.Next(from); !got.Equal(tt.want) {
+				t.Errorf("Next(%q) = %v, ожидалось %v", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCronString(t *testing.T) {
+	c, err := ParseCron("  @daily ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.String(); got != "@daily" {
+		t.Errorf("String() = %q, ожидалось %q", got, "@daily")
+	}
+}
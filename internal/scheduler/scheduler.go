@@ -0,0 +1,186 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"aiAgent/internal/agent"
+	"aiAgent/internal/database"
+	"aiAgent/internal/logger"
+
+	"go.uber.org/zap"
+)
+
+// DefaultInterval - как часто планировщик проверяет наступившие расписания.
+const DefaultInterval = 30 * time.Second
+
+// Итоги запуска расписания (task_schedules.last_status).
+const (
+	OutcomeRunning     = "running"
+	OutcomeCompleted   = "completed"
+	OutcomeFailed      = "failed"
+	OutcomeInterrupted = "interrupted"
+	OutcomeBudget      = agent.StatusBudgetExceeded
+	OutcomeSkipped     = "skipped" // Предыдущий запуск еще выполняется
+	OutcomeInvalid     = "invalid" // Расписание не удалось разобрать или у него нет следующего запуска
+)
+
+// Scheduler создает и запускает задачи по расписаниям из task_schedules.
+// Каждое срабатывание - новая задача с текстом задачи-шаблона, выполняемая через
+// Agent.ExecuteTask в общем пуле воркеров, поэтому шаги и LLM логи пишутся на каждый запуск.
+type Scheduler struct {
+	repo     *database.TaskRepository
+	agent    *agent.Agent
+	jobs     *agent.JobManager
+	log      *logger.Zap
+	interval time.Duration
+}
+
+// New создает планировщик с интервалом проверки DefaultInterval.
+func New(repo *database.TaskRepository, ag *agent.Agent, jobs *agent.JobManager, log *logger.Zap) *Scheduler {
+	return &Scheduler{
+		repo:     repo,
+		agent:    ag,
+		jobs:     jobs,
+		log:      log,
+		interval: DefaultInterval,
+	}
+}
+
+// Run проверяет расписания до отмены контекста.
+// Расписания, пропущенные пока приложение было выключено, срабатывают один раз при старте.
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	s.tick(ctx, time.Now())
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.tick(ctx, now)
+		}
+	}
+}
+
+func (s *Scheduler) tick(ctx context.Context, now time.Time) {
+	schedules, err := s.repo.DueSchedules(now)
+	if err != nil {
+		s.log.Warn("Ошибка чтения расписаний", zap.Error(err))
+		return
+	}
+
+	for _, sch := range schedules {
+		if ctx.Err() != nil {
+			return
+		}
+		s.trigger(ctx, sch, now)
+	}
+}
+
+// trigger создает новый запуск задачи по сработавшему расписанию.
+func (s *Scheduler) trigger(ctx context.Context, sch database.TaskSchedule, now time.Time) {
+	fields := []zap.Field{zap.Uint("schedule_id", sch.ID), zap.Uint("template_task_id", sch.TaskID)}
+
+	cron, err := ParseCron(sch.CronExpr)
+	if err != nil {
+		s.disable(sch, now, fmt.Sprintf("неверное cron выражение: %v", err))
+		return
+	}
+	next := cron.Next(now)
+	if next.IsZero() {
+		s.disable(sch, now, "у расписания нет следующего запуска")
+		return
+	}
+
+	if sch.LastTaskID != nil {
+		if job, ok := s.jobs.Get(*sch.LastTaskID); ok && (job.Status() == agent.JobQueued || job.Status() == agent.JobRunning) {
+			s.log.Info("Предыдущий запуск по расписанию еще выполняется, пропускаем", append(fields, zap.Uint("task_id", *sch.LastTaskID))...)
+			s.markRun(sch.ID, now, next, nil, OutcomeSkipped, "предыдущий запуск еще выполняется")
+			return
+		}
+	}
+
+	template, err := s.repo.GetTaskByID(sch.TaskID)
+	if err != nil {
+		s.markRun(sch.ID, now, next, nil, OutcomeFailed, fmt.Sprintf("задача-шаблон #%d не найдена: %v", sch.TaskID, err))
+		return
+	}
+
+	run := &database.Task{
+		UserInput:       template.UserInput,
+		Status:          "pending",
+		ScheduleID:      &sch.ID,
+		SuccessCriteria: template.SuccessCriteria,
+		OutputSchema:    template.OutputSchema,
+		TokenBudget:     template.TokenBudget,
+		CostBudget:      template.CostBudget,
+	}
+	if err := s.repo.CreateTask(run); err != nil {
+		s.markRun(sch.ID, now, next, nil, OutcomeFailed, fmt.Sprintf("ошибка создания задачи: %v", err))
+		return
+	}
+	s.markRun(sch.ID, now, next, &run.ID, OutcomeRunning, "")
+
+	_, err = s.jobs.Start(ctx, run.ID, func(ctx context.Context) error {
+		return s.agent.ExecuteTask(ctx, run)
+	}, func(job *agent.Job) {
+		s.finish(sch.ID, run.ID, job)
+	})
+	if err != nil {
+		s.repo.UpdateTaskStatus(run.ID, "failed", err.Error())
+		s.repo.UpdateScheduleOutcome(sch.ID, OutcomeFailed, err.Error())
+		return
+	}
+
+	s.log.Info("Запуск задачи по расписанию", append(fields, zap.Uint("task_id", run.ID), zap.Time("next_run_at", next))...)
+}
+
+// finish сохраняет итог запуска в задаче и расписании.
+func (s *Scheduler) finish(scheduleID, taskID uint, job *agent.Job) {
+	fields := []zap.Field{zap.Uint("schedule_id", scheduleID), zap.Uint("task_id", taskID)}
+
+	switch job.Status() {
+	case agent.JobCompleted:
+		s.repo.UpdateScheduleOutcome(scheduleID, OutcomeCompleted, "")
+		s.log.Info("Запуск по расписанию завершен", fields...)
+	case agent.JobCanceled:
+		if !job.StartedAt().IsZero() {
+			s.repo.UpdateTaskStatus(taskID, "interrupted", "")
+		}
+		s.repo.UpdateScheduleOutcome(scheduleID, OutcomeInterrupted, "")
+		s.log.Info("Запуск по расписанию прерван", fields...)
+	default:
+		errMsg := ""
+		if err := job.Err(); err != nil {
+			errMsg = err.Error()
+		}
+		if errors.Is(job.Err(), agent.ErrBudgetExceeded) {
+			// Статус задачи уже выставлен агентом
+			s.repo.UpdateScheduleOutcome(scheduleID, OutcomeBudget, errMsg)
+			s.log.Warn("Запуск по расписанию остановлен: бюджет исчерпан", append(fields, zap.String("error", errMsg))...)
+			return
+		}
+		s.repo.UpdateTaskStatus(taskID, "failed", errMsg)
+		s.repo.UpdateScheduleOutcome(scheduleID, OutcomeFailed, errMsg)
+		s.log.Warn("Запуск по расписанию завершился ошибкой", append(fields, zap.String("error", errMsg))...)
+	}
+}
+
+func (s *Scheduler) markRun(id uint, runAt, next time.Time, taskID *uint, status, errMsg string) {
+	if err := s.repo.MarkScheduleRun(id, runAt, next, taskID, status, errMsg); err != nil {
+		s.log.Warn("Ошибка обновления расписания", zap.Uint("schedule_id", id), zap.Error(err))
+	}
+}
+
+// disable выключает расписание, которое не может сработать.
+func (s *Scheduler) disable(sch database.TaskSchedule, now time.Time, reason string) {
+	s.log.Warn("Расписание отключено", zap.Uint("schedule_id", sch.ID), zap.String("reason", reason))
+	if err := s.repo.SetScheduleEnabled(sch.ID, false, now); err != nil {
+		s.log.Warn("Ошибка отключения расписания", zap.Uint("schedule_id", sch.ID), zap.Error(err))
+	}
+	s.repo.UpdateScheduleOutcome(sch.ID, OutcomeInvalid, reason)
+}